@@ -0,0 +1,198 @@
+package activity
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+type Type string
+
+const (
+	TypeCheck      Type = "check"
+	TypeSync       Type = "sync"
+	TypeTransition Type = "transition"
+	TypeBranch     Type = "branch"
+	TypeMerge      Type = "merge"
+)
+
+const (
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+// Event is an action that the app performed in a repository.
+type Event struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	Type        Type      `json:"type"`
+	PullRequest int       `json:"pullRequest,omitempty"`
+	Branch      string    `json:"branch,omitempty"`
+	Issue       string    `json:"issue,omitempty"`
+	Summary     string    `json:"summary"`
+
+	// Restricted is set for events on Jira issues with a security level.
+	// The issue and the summary of such events are not published.
+	Restricted bool `json:"restricted,omitempty"`
+}
+
+func (e Event) redacted() Event {
+	if e.Restricted {
+		e.Issue = ""
+		e.Summary = "[restricted]"
+	}
+	return e
+}
+
+type Page struct {
+	Events   []Event `json:"events"`
+	NextPage int     `json:"nextPage,omitempty"`
+}
+
+// Recorder keeps the most recent events for each repository in memory.
+type Recorder struct {
+	mutex     sync.Mutex
+	maxEvents int
+	lastID    int64
+	events    map[string][]Event
+	now       func() time.Time
+}
+
+func NewRecorder(maxEvents int) *Recorder {
+	return &Recorder{
+		maxEvents: maxEvents,
+		events:    make(map[string][]Event),
+		now:       time.Now,
+	}
+}
+
+// Record adds the event to the feed of the repository owner/repo. It is safe
+// to call Record on a nil Recorder.
+func (r *Recorder) Record(owner, repo string, e Event) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lastID++
+	e.ID = r.lastID
+	e.Time = r.now().UTC()
+
+	key := owner + "/" + repo
+	events := append(r.events[key], e)
+	if len(events) > r.maxEvents {
+		events = events[len(events)-r.maxEvents:]
+	}
+	r.events[key] = events
+}
+
+// Events returns a page of events of the repository, newest first. Pages are
+// numbered from 1.
+func (r *Recorder) Events(owner, repo string, page, perPage int) Page {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := r.events[owner+"/"+repo]
+	result := Page{Events: []Event{}}
+	start := (page - 1) * perPage
+	for i := start; i < start+perPage && i < len(events); i++ {
+		result.Events = append(result.Events, events[len(events)-1-i].redacted())
+	}
+	if start+perPage < len(events) {
+		result.NextPage = page + 1
+	}
+	return result
+}
+
+// RepositoryEvent is an event with the repository where it happened.
+type RepositoryEvent struct {
+	Repository string `json:"repository"`
+	Event
+}
+
+// Recent returns the last n events of all repositories that have one of the
+// types, or of any type if types is empty, newest first.
+func (r *Recorder) Recent(n int, types ...Type) []RepositoryEvent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var events []RepositoryEvent
+	for repo, repoEvents := range r.events {
+		for _, e := range repoEvents {
+			if len(types) > 0 && !hasType(types, e.Type) {
+				continue
+			}
+			events = append(events, RepositoryEvent{Repository: repo, Event: e.redacted()})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID > events[j].ID
+	})
+	if len(events) > n {
+		events = events[:n]
+	}
+	return events
+}
+
+func hasType(types []Type, t Type) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func intParam(r *http.Request, name string, defaultValue int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// ServeHTTP serves GET /api/v1/activity/{owner}/{repo}?page=N&per_page=M.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v1/activity/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "expected /api/v1/activity/{owner}/{repo}", http.StatusNotFound)
+		return
+	}
+
+	page, ok := intParam(req, "page", 1)
+	if !ok {
+		http.Error(w, "invalid page", http.StatusBadRequest)
+		return
+	}
+	perPage, ok := intParam(req, "per_page", defaultPerPage)
+	if !ok {
+		http.Error(w, "invalid per_page", http.StatusBadRequest)
+		return
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(r.Events(parts[0], parts[1], page, perPage))
+	if err != nil {
+		klog.Errorf("failed to encode activity feed: %v", err)
+	}
+}
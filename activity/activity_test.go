@@ -0,0 +1,100 @@
+package activity
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder(3)
+	r.Record("quay", "quay", Event{Type: TypeCheck, PullRequest: 1, Summary: "first"})
+	r.Record("quay", "quay", Event{Type: TypeTransition, Issue: "PROJQUAY-2", Summary: "second", Restricted: true})
+	r.Record("quay", "other", Event{Type: TypeSync, Branch: "master", Summary: "other repo"})
+	r.Record("quay", "quay", Event{Type: TypeTransition, Issue: "PROJQUAY-3", Summary: "third"})
+	r.Record("quay", "quay", Event{Type: TypeSync, Branch: "redhat-3.8", Summary: "fourth"})
+
+	page := r.Events("quay", "quay", 1, 2)
+	if len(page.Events) != 2 || page.Events[0].Summary != "fourth" || page.Events[1].Summary != "third" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	if page.NextPage != 2 {
+		t.Errorf("got next page %d, want 2", page.NextPage)
+	}
+
+	page = r.Events("quay", "quay", 2, 2)
+	if len(page.Events) != 1 || page.NextPage != 0 {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+	if e := page.Events[0]; e.Issue != "" || e.Summary != "[restricted]" || !e.Restricted {
+		t.Errorf("restricted event is not redacted: %+v", e)
+	}
+
+	if page := r.Events("quay", "missing", 1, 10); len(page.Events) != 0 {
+		t.Errorf("unexpected events for unknown repository: %+v", page)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	r := NewRecorder(10)
+	r.Record("quay", "quay", Event{Type: TypeCheck, PullRequest: 1, Summary: "first"})
+	r.Record("quay", "other", Event{Type: TypeSync, Branch: "master", Summary: "second"})
+	r.Record("quay", "quay", Event{Type: TypeCheck, Issue: "PROJQUAY-2", Summary: "third", Restricted: true})
+	r.Record("quay", "other", Event{Type: TypeCheck, PullRequest: 2, Summary: "fourth"})
+
+	events := r.Recent(3)
+	if len(events) != 3 || events[0].Summary != "fourth" || events[0].Repository != "quay/other" || events[2].Summary != "second" {
+		t.Fatalf("unexpected recent events: %+v", events)
+	}
+	if e := events[1]; e.Summary != "[restricted]" {
+		t.Errorf("restricted event is not redacted: %+v", e)
+	}
+
+	checks := r.Recent(10, TypeCheck)
+	if len(checks) != 3 || checks[2].Summary != "first" {
+		t.Errorf("unexpected recent checks: %+v", checks)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRecorder(10)
+	r.Record("quay", "quay", Event{Type: TypeCheck, PullRequest: 1, Summary: "first"})
+	r.Record("quay", "quay", Event{Type: TypeCheck, Issue: "PROJQUAY-2", Summary: "second", Restricted: true})
+	r.Record("quay", "quay", Event{Type: TypeSync, Branch: "master", Summary: "third"})
+
+	testCases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: http.MethodPost, path: "/api/v1/activity/quay/quay", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/api/v1/activity/quay", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/api/v1/activity/quay/quay/extra", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/api/v1/activity/quay/quay?page=0", status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/api/v1/activity/quay/quay?per_page=x", status: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, w.Code, tc.status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/activity/quay/quay?page=1&per_page=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var page Page
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Events) != 2 || page.Events[0].Summary != "third" || page.NextPage != 2 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if e := page.Events[1]; e.Issue != "" || e.Summary != "[restricted]" {
+		t.Errorf("restricted event is not redacted: %+v", e)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+// SyncResult is the outcome of a branch sync that is run by POST /admin/sync.
+type SyncResult struct {
+	Branch  string `json:"branch"`
+	Source  string `json:"source"`
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RecheckResult is the outcome of the Jira check that is run by POST
+// /admin/recheck.
+type RecheckResult struct {
+	Repository  string `json:"repository"`
+	PullRequest int    `json:"pullRequest"`
+	HeadSHA     string `json:"headSha"`
+	Conclusion  string `json:"conclusion,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// AdminHandler serves the endpoints under /admin that let the operators run
+// the jobs of the app right away instead of waiting for them. The requests are
+// authenticated with the admin section of the configuration.
+type AdminHandler struct {
+	reactor *reactor
+	auth    *adminAuth
+	mux     *http.ServeMux
+	handler http.Handler
+}
+
+func NewAdminHandler(r *reactor) *AdminHandler {
+	ah := &AdminHandler{
+		reactor: r,
+		auth:    newAdminAuth(r.cfg),
+		mux:     http.NewServeMux(),
+	}
+	ah.mux.HandleFunc("/admin/sync", ah.serveSync)
+	ah.mux.HandleFunc("/admin/recheck", ah.serveRecheck)
+	ah.handler = ah.auth.Wrap(ah.mux)
+	return ah
+}
+
+func (ah *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ah.handler.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("failed to encode the response: %v", err)
+	}
+}
+
+// parseBranchReference parses a branch like quay/quay:redhat-3.9.
+func parseBranchReference(s string) (configuration.BranchReference, bool) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return configuration.BranchReference{}, false
+	}
+	repo := strings.SplitN(parts[0], "/", 2)
+	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
+		return configuration.BranchReference{}, false
+	}
+	return configuration.BranchReference{Owner: repo[0], Repo: repo[1], Branch: parts[1]}, true
+}
+
+// serveSync syncs the branch from the branch query parameter, or all synced
+// branches if it's not set, and responds with the results when the sync is
+// done. The response status is 500 if any branch failed to sync.
+func (ah *AdminHandler) serveSync(w http.ResponseWriter, r *http.Request) {
+	var only *configuration.BranchReference
+	if branch := r.URL.Query().Get("branch"); branch != "" {
+		ref, ok := parseBranchReference(branch)
+		if !ok {
+			http.Error(w, "invalid branch "+branch+", expected owner/repo:branch", http.StatusBadRequest)
+			return
+		}
+		only = &ref
+	}
+
+	results := []SyncResult{}
+	failed := false
+	for _, repo := range ah.reactor.cfg.Get().ExplicitRepositories() {
+		for _, branch := range repo.Branches {
+			dest := configuration.BranchReference{Owner: repo.Owner, Repo: repo.Repo, Branch: branch.Name}
+			src, ok := repo.SyncSource(branch)
+			if !ok || (only != nil && dest != *only) {
+				continue
+			}
+
+			klog.Infof("syncing %s from %s on request of %s", dest, src, adminIdentity(r))
+			result := SyncResult{Branch: dest.String(), Source: src.String()}
+			if err := ah.reactor.syncRepository(r.Context(), repo, branch.Name); err != nil {
+				result.Error = err.Error()
+				failed = true
+			}
+			if status := ah.reactor.statusInformer.BranchSyncStatus(dest.String()); status != nil {
+				result.Status = status.Status
+				result.Message = status.Message
+			}
+			results = append(results, result)
+		}
+	}
+
+	if only != nil && len(results) == 0 {
+		http.Error(w, only.String()+" is not a synced branch", http.StatusNotFound)
+		return
+	}
+	status := http.StatusOK
+	if failed {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, results)
+}
+
+// serveRecheck runs the Jira check for the pull request from the repo and pr
+// query parameters, like the /recheck comment does, and responds with the
+// check run that it reported.
+func (ah *AdminHandler) serveRecheck(w http.ResponseWriter, r *http.Request) {
+	repoParts := strings.SplitN(r.URL.Query().Get("repo"), "/", 2)
+	if len(repoParts) != 2 || repoParts[0] == "" || repoParts[1] == "" {
+		http.Error(w, "the repo parameter should be owner/repo", http.StatusBadRequest)
+		return
+	}
+	number, err := strconv.Atoi(r.URL.Query().Get("pr"))
+	if err != nil || number <= 0 {
+		http.Error(w, "the pr parameter should be a pull request number", http.StatusBadRequest)
+		return
+	}
+	org, repo := repoParts[0], repoParts[1]
+	cfg := ah.reactor.cfg.Get()
+	if _, ok := cfg.Repository(org, repo); !ok {
+		http.Error(w, fmt.Sprintf("%s/%s is not configured", org, repo), http.StatusNotFound)
+		return
+	}
+
+	pr, resp, err := ah.reactor.client.PullRequests.Get(r.Context(), org, repo, number)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		http.Error(w, fmt.Sprintf("%s/%s#%d does not exist", org, repo, number), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get the pull request: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	klog.Infof("rechecking %s/%s#%d on request of %s", org, repo, number, adminIdentity(r))
+	result := RecheckResult{
+		Repository:  org + "/" + repo,
+		PullRequest: number,
+		HeadSHA:     pr.GetHead().GetSHA(),
+	}
+	status := http.StatusOK
+	if err := ah.reactor.runJiraCheck(r.Context(), cfg, checks.EventRecheck, org, repo, pr); err != nil {
+		result.Error = err.Error()
+		status = http.StatusInternalServerError
+	}
+
+	checkRuns, _, err := ah.reactor.client.Checks.ListCheckRunsForRef(r.Context(), org, repo, result.HeadSHA, &github.ListCheckRunsOptions{
+		CheckName: github.String(checks.TitleCheckRunName),
+	})
+	if err != nil {
+		klog.Errorf("failed to get the check runs of %s/%s#%d: %v", org, repo, number, err)
+	} else {
+		var latest *github.CheckRun
+		for _, checkRun := range checkRuns.CheckRuns {
+			if latest == nil || checkRun.GetID() > latest.GetID() {
+				latest = checkRun
+			}
+		}
+		result.Conclusion = latest.GetConclusion()
+		result.Title = latest.GetOutput().GetTitle()
+	}
+	writeJSON(w, status, result)
+}
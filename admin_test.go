@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+	"github.com/quay/quay-ci-app/slo"
+)
+
+func newAdminTestReactor(gh *fakes.GitHub, cfg *configuration.Configuration) *reactor {
+	return &reactor{
+		client:         gh.Client(),
+		cfg:            configuration.NewStore(cfg),
+		statusInformer: &StatusInformer{},
+		slo:            slo.NewTracker(),
+		activity:       activity.NewRecorder(10),
+	}
+}
+
+// adminRequest returns a request from the loopback address, which is allowed
+// if the admin API is not configured.
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.RemoteAddr = "127.0.0.1:40000"
+	return req
+}
+
+func ref(sha string) *github.Reference {
+	return &github.Reference{Object: &github.GitObject{SHA: github.String(sha)}}
+}
+
+func TestAdminSync(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay:heads/master"] = ref("new")
+	gh.Refs["quay/quay:heads/redhat-3.9"] = ref("old")
+	gh.Refs["quay/quay:heads/redhat-3.8"] = ref("old")
+	ah := NewAdminHandler(newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{
+			Owner: "quay",
+			Repo:  "quay",
+			Branches: []configuration.Branch{
+				{Name: "redhat-3.9", SyncFrom: configuration.BranchReference{Branch: "master"}},
+				{Name: "redhat-3.8", SyncFrom: configuration.BranchReference{Branch: "release-3.8"}},
+				{Name: "master"},
+			},
+		}},
+	}))
+
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/sync?branch=quay/quay:redhat-3.9", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	var results []SyncResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Branch != "quay/quay:redhat-3.9" || results[0].Status != "Synced" {
+		t.Errorf("got results %+v", results)
+	}
+	if sha := gh.Refs["quay/quay:heads/redhat-3.9"].GetObject().GetSHA(); sha != "new" {
+		t.Errorf("the branch should be synced, got %s", sha)
+	}
+	if sha := gh.Refs["quay/quay:heads/redhat-3.8"].GetObject().GetSHA(); sha != "old" {
+		t.Errorf("only the requested branch should be synced, got %s", sha)
+	}
+
+	w = httptest.NewRecorder()
+	ah.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/sync", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want 500 as the source of redhat-3.8 does not exist", w.Code)
+	}
+	results = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Status != "Error" || results[1].Error == "" {
+		t.Errorf("got results %+v", results)
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		want           int
+	}{
+		{http.MethodPost, "/admin/sync?branch=quay/quay:master", http.StatusNotFound},
+		{http.MethodPost, "/admin/sync?branch=quay", http.StatusBadRequest},
+		{http.MethodGet, "/admin/sync", http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		ah.ServeHTTP(w, adminRequest(tc.method, tc.target, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.target, w.Code, tc.want)
+		}
+	}
+}
+
+func TestAdminSyncFailureIssue(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay:heads/redhat-3.9"] = ref("old")
+	repo := configuration.Repository{
+		Owner: "quay",
+		Repo:  "quay",
+		Branches: []configuration.Branch{
+			{Name: "redhat-3.9", SyncFrom: configuration.BranchReference{Branch: "master"}},
+		},
+		SyncFailureIssue: &configuration.SyncFailureIssue{After: configuration.Duration{Duration: time.Nanosecond}},
+	}
+	r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+	r.syncIssues = NewSyncFailureIssues()
+	ah := NewAdminHandler(r)
+
+	// The failures of the requested syncs are tracked like the ones of the
+	// sync loop.
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		ah.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/sync?branch=quay/quay:redhat-3.9", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("got status %d, want 500 as the source does not exist", w.Code)
+		}
+	}
+	if len(gh.Issues) != 1 {
+		t.Errorf("got %d issues, want one about the failing sync", len(gh.Issues))
+	}
+}
+
+func TestAdminRecheck(t *testing.T) {
+	gh := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{Owner: "quay", Repo: "quay", Jira: configuration.Jira{Key: "PROJQUAY"}}},
+	})
+	r.jiraCheck = checks.NewJira(gh.Client(), gh.Client(), fakeJira.Client(), nil, nil, r.activity, time.Minute, time.Minute)
+	pr := fakes.PullRequest("quay", "quay", 1234, "Fix the build (PROJQUAY-123)")
+	gh.AddPullRequest(pr)
+	ah := NewAdminHandler(r)
+
+	w := httptest.NewRecorder()
+	ah.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/recheck?repo=quay/quay&pr=1234", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	var result RecheckResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.HeadSHA != pr.GetHead().GetSHA() || result.Conclusion != "success" {
+		t.Errorf("got result %+v", result)
+	}
+
+	for _, tc := range []struct {
+		target string
+		want   int
+	}{
+		{"/admin/recheck?repo=quay/quay&pr=42", http.StatusNotFound},
+		{"/admin/recheck?repo=quay/clair&pr=1234", http.StatusNotFound},
+		{"/admin/recheck?repo=quay&pr=1234", http.StatusBadRequest},
+		{"/admin/recheck?repo=quay/quay&pr=latest", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		ah.ServeHTTP(w, adminRequest(http.MethodPost, tc.target, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: got status %d, want %d", tc.target, w.Code, tc.want)
+		}
+	}
+}
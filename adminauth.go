@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/quay/quay-ci-app/audit"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+// oidcKeysRefreshInterval limits how often the keys of the issuer are fetched
+// again when a token is signed with an unknown key.
+const oidcKeysRefreshInterval = time.Minute
+
+type adminIdentityKey struct{}
+
+// adminIdentity returns who made the request to the /admin endpoints, for the
+// logs.
+func adminIdentity(r *http.Request) string {
+	if identity, ok := r.Context().Value(adminIdentityKey{}).(string); ok {
+		return identity
+	}
+	return r.RemoteAddr
+}
+
+// adminAuth authenticates the requests to the /admin endpoints with the admin
+// section of the configuration.
+type adminAuth struct {
+	cfg    *configuration.Store
+	client *http.Client
+
+	mutex sync.Mutex
+	oidc  *oidcVerifier
+}
+
+func newAdminAuth(cfg *configuration.Store) *adminAuth {
+	return &adminAuth{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Wrap returns a handler that serves the authenticated requests with h. The
+// identity of the client is available to h through adminIdentity, and the
+// mutations of h are audited as requests of the client.
+func (a *adminAuth) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, status, err := a.authenticate(r)
+		if err != nil {
+			klog.Warningf("rejected the request to %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		ctx := context.WithValue(r.Context(), adminIdentityKey{}, identity)
+		ctx = audit.WithTrigger(ctx, audit.Trigger{
+			Event:      "admin " + r.URL.Path,
+			Actor:      identity,
+			Repository: r.URL.Query().Get("repo"),
+		})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns the identity of the client of r, or the response
+// status and the reason why the request is rejected.
+func (a *adminAuth) authenticate(r *http.Request) (string, int, error) {
+	admin := a.cfg.Get().Admin
+	addr, err := clientAddress(r, parseNetworks(admin.TrustedProxies))
+	if err != nil {
+		return "", http.StatusForbidden, err
+	}
+	if networks := parseNetworks(admin.AllowedNetworks); len(networks) > 0 && !containsIP(networks, addr) {
+		return "", http.StatusForbidden, fmt.Errorf("%s is not in the allowed networks", addr)
+	}
+
+	if len(admin.Tokens) == 0 && admin.OIDC == nil {
+		if !addr.IsLoopback() {
+			return "", http.StatusForbidden, fmt.Errorf("the admin API is not configured, only the requests from the loopback address are allowed")
+		}
+		return "loopback " + addr.String(), 0, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", http.StatusUnauthorized, fmt.Errorf("missing bearer token")
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+	for _, t := range admin.Tokens {
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(t.TokenSHA256)), []byte(hash)) == 1 {
+			return "token " + t.Name + " from " + addr.String(), 0, nil
+		}
+	}
+	if admin.OIDC != nil {
+		subject, err := a.verifier(admin.OIDC.Issuer).Verify(r.Context(), token, *admin.OIDC)
+		if err != nil {
+			return "", http.StatusUnauthorized, fmt.Errorf("invalid token: %w", err)
+		}
+		return subject + " from " + addr.String(), 0, nil
+	}
+	return "", http.StatusUnauthorized, fmt.Errorf("invalid token")
+}
+
+// verifier returns the verifier of the issuer. The keys are kept until the
+// issuer changes in the configuration.
+func (a *adminAuth) verifier(issuer string) *oidcVerifier {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.oidc == nil || a.oidc.issuer != issuer {
+		a.oidc = &oidcVerifier{issuer: issuer, client: a.client}
+	}
+	return a.oidc
+}
+
+// parseNetworks parses the networks of the admin section. The invalid
+// networks are rejected by Validate, so they are skipped.
+func parseNetworks(networks []string) []*net.IPNet {
+	var parsed []*net.IPNet
+	for _, network := range networks {
+		if n, err := configuration.ParseNetwork(network); err == nil {
+			parsed = append(parsed, n)
+		}
+	}
+	return parsed
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddress returns the address of the client of r. If the request comes
+// from a trusted proxy, the client address is the last address of the
+// X-Forwarded-For header that is not a trusted proxy.
+func clientAddress(r *http.Request, trustedProxies []*net.IPNet) (net.IP, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr := net.ParseIP(host)
+	if addr == nil {
+		return nil, fmt.Errorf("invalid remote address %q", r.RemoteAddr)
+	}
+	if !containsIP(trustedProxies, addr) {
+		return addr, nil
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid X-Forwarded-For address %q", forwarded[i])
+		}
+		addr = ip
+		if !containsIP(trustedProxies, addr) {
+			break
+		}
+	}
+	return addr, nil
+}
+
+// oidcVerifier verifies the ID tokens of an OIDC issuer with the keys from
+// its discovery document.
+type oidcVerifier struct {
+	issuer string
+	client *http.Client
+
+	mutex     sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// Verify checks the signature, the issuer, the audience and the expiration of
+// the token, and that its sub claim, or its email claim if the email is
+// verified, is one of the allowed subjects. It returns the subject, or the
+// verified email.
+func (v *oidcVerifier) Verify(ctx context.Context, token string, cfg configuration.OIDC) (string, error) {
+	if len(cfg.Subjects) == 0 {
+		return "", fmt.Errorf("no subjects are allowed")
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return "", err
+	}
+	if !claims.VerifyIssuer(cfg.Issuer, true) {
+		return "", fmt.Errorf("the token is not issued by %s", cfg.Issuer)
+	}
+	if !claims.VerifyAudience(cfg.Audience, true) {
+		return "", fmt.Errorf("the token is not issued for %s", cfg.Audience)
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return "", fmt.Errorf("the token does not expire")
+	}
+
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		email = ""
+	}
+	for _, s := range cfg.Subjects {
+		if email != "" && s == email {
+			return email, nil
+		}
+		if subject != "" && s == subject {
+			return subject, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not an allowed subject", subject)
+}
+
+// key returns the key with the ID kid. The keys are fetched again if kid is
+// unknown, e.g. after the issuer rotated its keys.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (interface{}, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(v.fetchedAt) < oidcKeysRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	v.fetchedAt = time.Now()
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookup returns the key with the ID kid, or the only key if the token doesn't
+// have a key ID. The mutex must be held.
+func (v *oidcVerifier) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys gets the signing keys of the issuer from the jwks_uri of its
+// discovery document.
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]interface{}, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, strings.TrimSuffix(v.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to get the OIDC discovery document: %w", err)
+	}
+	if discovery.Issuer != v.issuer {
+		return nil, fmt.Errorf("the OIDC discovery document is for the issuer %q", discovery.Issuer)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to get the OIDC keys: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid OIDC key %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the RSA or ECDSA key, or nil for other types of keys.
+func (jwk jsonWebKey) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		buf, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) == 0 {
+			return nil, fmt.Errorf("missing parameter")
+		}
+		return new(big.Int).SetBytes(buf), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("the point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func tokenSHA256(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAdminAuth(t *testing.T) {
+	cfg := &configuration.Configuration{Admin: configuration.AdminAPI{
+		Tokens:          []configuration.AdminToken{{Name: "oncall", TokenSHA256: tokenSHA256("secret")}},
+		AllowedNetworks: []string{"10.0.0.0/8", "127.0.0.1"},
+		TrustedProxies:  []string{"10.128.0.0/14"},
+	}}
+	ah := NewAdminHandler(newAdminTestReactor(fakes.NewGitHub(), cfg))
+
+	for _, tc := range []struct {
+		name      string
+		remote    string
+		forwarded string
+		token     string
+		want      int
+	}{
+		{"valid token", "10.0.0.1:40000", "", "secret", http.StatusOK},
+		{"invalid token", "10.0.0.1:40000", "", "guess", http.StatusUnauthorized},
+		{"missing token", "127.0.0.1:40000", "", "", http.StatusUnauthorized},
+		{"not allowed network", "192.0.2.1:40000", "", "secret", http.StatusForbidden},
+		{"untrusted proxy", "192.0.2.1:40000", "10.0.0.1", "secret", http.StatusForbidden},
+		{"trusted proxy", "10.128.0.5:40000", "10.0.0.1", "secret", http.StatusOK},
+		{"trusted proxy from other network", "10.128.0.5:40000", "10.0.0.1, 192.0.2.1", "secret", http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/sync", nil)
+			req.RemoteAddr = tc.remote
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			ah.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+
+	unconfigured := NewAdminHandler(newAdminTestReactor(fakes.NewGitHub(), &configuration.Configuration{}))
+	req := httptest.NewRequest(http.MethodPost, "/admin/sync", nil)
+	req.RemoteAddr = "10.0.0.1:40000"
+	w := httptest.NewRecorder()
+	unconfigured.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("the requests from other addresses than loopback should be forbidden if the admin API is not configured, got status %d", w.Code)
+	}
+}
+
+func TestAdminAuthOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	issuer = server.URL
+
+	cfg := &configuration.Configuration{Admin: configuration.AdminAPI{
+		OIDC: &configuration.OIDC{Issuer: issuer, Audience: "quay-ci-app", Subjects: []string{"oncall@example.com", "f4c3b00c"}},
+	}}
+	ah := NewAdminHandler(newAdminTestReactor(fakes.NewGitHub(), cfg))
+	ah.auth.client = server.Client()
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	for _, tc := range []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"valid token", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": exp, "email": "oncall@example.com", "email_verified": true}, http.StatusOK},
+		{"allowed sub", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": exp, "sub": "f4c3b00c"}, http.StatusOK},
+		{"unverified email", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": exp, "email": "oncall@example.com"}, http.StatusUnauthorized},
+		{"email not verified", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": exp, "email": "oncall@example.com", "email_verified": false}, http.StatusUnauthorized},
+		{"other audience", jwt.MapClaims{"iss": issuer, "aud": "console", "exp": exp, "email": "oncall@example.com", "email_verified": true}, http.StatusUnauthorized},
+		{"other issuer", jwt.MapClaims{"iss": "https://example.com", "aud": "quay-ci-app", "exp": exp, "email": "oncall@example.com", "email_verified": true}, http.StatusUnauthorized},
+		{"expired", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": time.Now().Add(-time.Hour).Unix(), "email": "oncall@example.com", "email_verified": true}, http.StatusUnauthorized},
+		{"no expiration", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "email": "oncall@example.com", "email_verified": true}, http.StatusUnauthorized},
+		{"not allowed subject", jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": exp, "email": "dev@example.com", "email_verified": true}, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/sync", nil)
+			req.Header.Set("Authorization", "Bearer "+sign(tc.claims))
+			w := httptest.NewRecorder()
+			ah.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+
+	t.Run("no subjects", func(t *testing.T) {
+		cfg.Admin.OIDC.Subjects = nil
+		req := httptest.NewRequest(http.MethodPost, "/admin/sync", nil)
+		req.Header.Set("Authorization", "Bearer "+sign(jwt.MapClaims{"iss": issuer, "aud": "quay-ci-app", "exp": exp, "email": "oncall@example.com", "email_verified": true}))
+		w := httptest.NewRecorder()
+		ah.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("got status %d, want %d: %s", w.Code, http.StatusUnauthorized, w.Body)
+		}
+	})
+}
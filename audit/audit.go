@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Outcomes of the mutations.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeError   = "error"
+)
+
+// Trigger is what made the app perform a mutation, e.g. the webhook event
+// pull_request.opened sent by the user Actor in Repository.
+type Trigger struct {
+	Event      string
+	Actor      string
+	Repository string
+}
+
+type triggerKey struct{}
+
+// WithTrigger returns a context for the mutations caused by trigger.
+func WithTrigger(ctx context.Context, trigger Trigger) context.Context {
+	return context.WithValue(ctx, triggerKey{}, trigger)
+}
+
+// TriggerFrom returns the trigger of ctx.
+func TriggerFrom(ctx context.Context) (Trigger, bool) {
+	trigger, ok := ctx.Value(triggerKey{}).(Trigger)
+	return trigger, ok
+}
+
+// Entry is a mutation that the app performed. Action is what the request did,
+// like update_ref or transition_issue, and Target is the object that it
+// changed, like heads/redhat-3.9 or PROJQUAY-123.
+type Entry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Service    string    `json:"service"`
+	Repository string    `json:"repository,omitempty"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Event      string    `json:"event,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Log keeps the last entries in memory and appends every entry to a writer,
+// e.g. a file, as JSON lines. The entries are never changed once they are
+// recorded.
+type Log struct {
+	mutex      sync.Mutex
+	maxEntries int
+	lastID     int64
+	entries    []Entry
+	w          io.Writer
+	backend    Backend
+	onRecord   []func(Entry)
+	now        func() time.Time
+}
+
+// Backend is a database that keeps the entries across restarts.
+type Backend interface {
+	SaveAuditEntry(e Entry) error
+	LoadAuditEntries(limit int) ([]Entry, error)
+}
+
+// NewLog returns a log that keeps maxEntries in memory. If w is not nil, all
+// entries are also written to w.
+func NewLog(maxEntries int, w io.Writer) *Log {
+	return &Log{
+		maxEntries: maxEntries,
+		w:          w,
+		now:        time.Now,
+	}
+}
+
+// SetBackend loads the last entries from the backend and saves the new entries
+// to it. It must be called before the first entry is recorded.
+func (l *Log) SetBackend(b Backend) error {
+	entries, err := b.LoadAuditEntries(l.maxEntries)
+	if err != nil {
+		return fmt.Errorf("failed to load the audit log: %w", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = entries
+	for _, e := range entries {
+		if e.ID > l.lastID {
+			l.lastID = e.ID
+		}
+	}
+	l.backend = b
+	return nil
+}
+
+// OnRecord makes the log call f with every new entry. f must not block.
+func (l *Log) OnRecord(f func(Entry)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.onRecord = append(l.onRecord, f)
+}
+
+// Record adds the entry to the log. It is safe to call Record on a nil Log.
+func (l *Log) Record(e Entry) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastID++
+	e.ID = l.lastID
+	e.Time = l.now().UTC()
+
+	l.entries = append(l.entries, e)
+	if len(l.entries) > l.maxEntries {
+		l.entries = l.entries[len(l.entries)-l.maxEntries:]
+	}
+
+	if l.w != nil {
+		buf, err := json.Marshal(e)
+		if err == nil {
+			_, err = l.w.Write(append(buf, '\n'))
+		}
+		if err != nil {
+			klog.Errorf("failed to write the audit log entry %d: %v", e.ID, err)
+		}
+	}
+	if l.backend != nil {
+		if err := l.backend.SaveAuditEntry(e); err != nil {
+			klog.Errorf("failed to save the audit log entry %d: %v", e.ID, err)
+		}
+	}
+	for _, f := range l.onRecord {
+		f(e)
+	}
+}
+
+// Query returns the entries of the repository, or of all repositories if repo
+// is empty, that were recorded after since, oldest first.
+func (l *Log) Query(repo string, since time.Time) []Entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entries := []Entry{}
+	for _, e := range l.entries {
+		if (repo == "" || e.Repository == repo) && e.Time.After(since) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ServeHTTP serves GET /audit?repo={owner}/{repo}&since={time}. since is a
+// time like 2022-03-01T12:00:00Z or a duration like 24h before now.
+func (l *Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			since = t
+		} else if d, err := time.ParseDuration(value); err == nil {
+			since = l.now().Add(-d)
+		} else {
+			http.Error(w, "invalid since, expected a time like 2022-03-01T12:00:00Z or a duration like 24h", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(l.Query(r.URL.Query().Get("repo"), since))
+	if err != nil {
+		klog.Errorf("failed to encode the audit log: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/git/refs/heads/redhat-3.9") {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	var file bytes.Buffer
+	log := NewLog(10, &file)
+	client := github.NewClient(&http.Client{Transport: &Transport{Log: log, Service: ServiceGitHub}})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ctx := WithTrigger(context.Background(), Trigger{Event: "pull_request.opened", Actor: "octocat", Repository: "quay/quay"})
+	client.Repositories.Get(ctx, "quay", "quay")
+	client.Checks.CreateCheckRun(ctx, "quay", "quay", github.CreateCheckRunOptions{Name: "Pull Request Title", HeadSHA: "abc"})
+	client.Issues.CreateComment(ctx, "quay", "quay", 1234, &github.IssueComment{Body: github.String("hello")})
+	client.Issues.DeleteComment(ctx, "quay", "quay", 42)
+	client.Git.UpdateRef(context.Background(), "quay", "quay", &github.Reference{
+		Ref:    github.String("refs/heads/redhat-3.9"),
+		Object: &github.GitObject{SHA: github.String("abc")},
+	}, false)
+
+	entries := log.Query("", time.Time{})
+	for i := range entries {
+		if entries[i].Time.IsZero() {
+			t.Errorf("the entry %d should have a time", i)
+		}
+		entries[i].Time = time.Time{}
+	}
+	want := []Entry{
+		{ID: 1, Service: "github", Repository: "quay/quay", Action: "create_check_run", Target: "Pull Request Title", Method: "POST", Path: "/repos/quay/quay/check-runs", Event: "pull_request.opened", Actor: "octocat", StatusCode: 200, Outcome: "success"},
+		{ID: 2, Service: "github", Repository: "quay/quay", Action: "create_comment", Target: "#1234", Method: "POST", Path: "/repos/quay/quay/issues/1234/comments", Event: "pull_request.opened", Actor: "octocat", StatusCode: 200, Outcome: "success"},
+		{ID: 3, Service: "github", Repository: "quay/quay", Action: "delete_comment", Target: "comment 42", Method: "DELETE", Path: "/repos/quay/quay/issues/comments/42", Event: "pull_request.opened", Actor: "octocat", StatusCode: 200, Outcome: "success"},
+		{ID: 4, Service: "github", Repository: "quay/quay", Action: "update_ref", Target: "heads/redhat-3.9", Method: "PATCH", Path: "/repos/quay/quay/git/refs/heads/redhat-3.9", StatusCode: 422, Outcome: "failure"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got entries\n%+v\nwant\n%+v", entries, want)
+	}
+
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines in the file, want 4", len(lines))
+	}
+	var last Entry
+	if err := json.Unmarshal([]byte(lines[3]), &last); err != nil || last.Action != "update_ref" {
+		t.Errorf("got the last line %s: %v", lines[3], err)
+	}
+}
+
+func TestJiraActions(t *testing.T) {
+	log := NewLog(10, nil)
+	tr := &Transport{
+		Base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+		}),
+		Log:     log,
+		Service: ServiceJira,
+	}
+	ctx := WithTrigger(context.Background(), Trigger{Event: "pull_request.closed", Repository: "quay/quay"})
+	for _, r := range []struct{ method, path string }{
+		{http.MethodPost, "/rest/api/2/issue/PROJQUAY-123/transitions"},
+		{http.MethodPut, "/rest/api/2/issue/PROJQUAY-123"},
+		{http.MethodPut, "/rest/api/2/version/1001"},
+	} {
+		req, _ := http.NewRequestWithContext(ctx, r.method, "https://issues.example.com"+r.path, nil)
+		if _, err := tr.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, e := range log.Query("quay/quay", time.Time{}) {
+		got = append(got, e.Action+" "+e.Target)
+	}
+	want := []string{"transition_issue PROJQUAY-123", "update_issue PROJQUAY-123", "update_version 1001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestServeHTTP(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	log := NewLog(2, nil)
+	log.now = func() time.Time { return now }
+	log.Record(Entry{Repository: "quay/quay", Action: "first"})
+	now = now.Add(time.Hour)
+	log.Record(Entry{Repository: "quay/clair", Action: "second"})
+	now = now.Add(time.Hour)
+	log.Record(Entry{Repository: "quay/quay", Action: "third"})
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"second", "third"}},
+		{"?repo=quay/quay", []string{"third"}},
+		{"?since=2022-03-01T13:30:00Z", []string{"third"}},
+		{"?since=3h", []string{"second", "third"}},
+	} {
+		w := httptest.NewRecorder()
+		log.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit"+tc.query, nil))
+		var entries []Entry
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("%s: %v: %s", tc.query, err, w.Body)
+		}
+		got := []string{}
+		for _, e := range entries {
+			got = append(got, e.Action)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.query, got, tc.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	log.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit?since=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid since, want 400", w.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Services of the entries.
+const (
+	ServiceGitHub = "github"
+	ServiceJira   = "jira"
+)
+
+// action describes the requests that match method and path. The owner and
+// repo groups of path are the repository, and the target group or the
+// bodyField of the request body is the target, with prefix.
+type action struct {
+	method    string
+	path      *regexp.Regexp
+	name      string
+	prefix    string
+	bodyField string
+}
+
+func repoAction(method, path, name, prefix, bodyField string) action {
+	return action{method, regexp.MustCompile(`^/repos/(?P<owner>[^/]+)/(?P<repo>[^/]+)` + path + `$`), name, prefix, bodyField}
+}
+
+func jiraAction(method, path, name, bodyField string) action {
+	return action{method, regexp.MustCompile(path + `$`), name, "", bodyField}
+}
+
+var githubActions = []action{
+	repoAction(http.MethodPost, `/git/refs`, "create_ref", "", "ref"),
+	repoAction(http.MethodPatch, `/git/refs/(?P<target>.+)`, "update_ref", "", ""),
+	repoAction(http.MethodDelete, `/git/refs/(?P<target>.+)`, "delete_ref", "", ""),
+	repoAction(http.MethodPost, `/check-runs`, "create_check_run", "", "name"),
+	repoAction(http.MethodPatch, `/check-runs/(?P<target>\d+)`, "update_check_run", "", ""),
+	repoAction(http.MethodPost, `/issues/(?P<target>\d+)/comments`, "create_comment", "#", ""),
+	repoAction(http.MethodPatch, `/issues/comments/(?P<target>\d+)`, "update_comment", "comment ", ""),
+	repoAction(http.MethodDelete, `/issues/comments/(?P<target>\d+)`, "delete_comment", "comment ", ""),
+	repoAction(http.MethodPost, `/issues/(?P<target>\d+)/labels`, "add_labels", "#", ""),
+	repoAction(http.MethodDelete, `/issues/(?P<target>\d+)/labels/.+`, "remove_label", "#", ""),
+	repoAction(http.MethodPost, `/issues`, "create_issue", "", "title"),
+	repoAction(http.MethodPatch, `/issues/(?P<target>\d+)`, "update_issue", "#", ""),
+	repoAction(http.MethodPut, `/pulls/(?P<target>\d+)/merge`, "merge_pull_request", "#", ""),
+	repoAction(http.MethodPost, `/statuses/(?P<target>[0-9a-f]+)`, "create_status", "", ""),
+	repoAction(http.MethodPost, `/releases`, "create_release", "", "tag_name"),
+	repoAction(http.MethodPost, `/milestones`, "create_milestone", "", "title"),
+	repoAction(http.MethodPatch, `/milestones/(?P<target>\d+)`, "update_milestone", "milestone ", ""),
+	{http.MethodPost, regexp.MustCompile(`^/app/installations/(?P<target>\d+)/access_tokens$`), "create_installation_token", "installation ", ""},
+}
+
+var jiraActions = []action{
+	jiraAction(http.MethodPost, `/issue/(?P<target>[^/]+)/transitions`, "transition_issue", ""),
+	jiraAction(http.MethodPost, `/issue/(?P<target>[^/]+)/comment`, "create_comment", ""),
+	jiraAction(http.MethodPut, `/issue/(?P<target>[^/]+)`, "update_issue", ""),
+	jiraAction(http.MethodPost, `/issue`, "create_issue", ""),
+	jiraAction(http.MethodPost, `/issueLink`, "link_issues", ""),
+	jiraAction(http.MethodPost, `/version`, "create_version", "name"),
+	jiraAction(http.MethodPut, `/version/(?P<target>\d+)`, "update_version", ""),
+}
+
+// Transport records the mutating requests (everything except GET, HEAD and
+// OPTIONS) to Log. The GitHub GraphQL requests are not recorded as the app
+// uses GraphQL only for queries.
+type Transport struct {
+	Base    http.RoundTripper
+	Log     *Log
+	Service string
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func mutating(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !strings.HasSuffix(req.URL.Path, "/graphql")
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Log == nil || !mutating(req) {
+		return t.base().RoundTrip(req)
+	}
+
+	e := t.describe(req)
+	if trigger, ok := TriggerFrom(req.Context()); ok {
+		e.Event = trigger.Event
+		e.Actor = trigger.Actor
+		if e.Repository == "" {
+			e.Repository = trigger.Repository
+		}
+	}
+	resp, err := t.base().RoundTrip(req)
+	switch {
+	case err != nil:
+		e.Outcome = OutcomeError
+		e.Error = err.Error()
+	case resp.StatusCode >= 400:
+		e.StatusCode = resp.StatusCode
+		e.Outcome = OutcomeFailure
+	default:
+		e.StatusCode = resp.StatusCode
+		e.Outcome = OutcomeSuccess
+	}
+	t.Log.Record(e)
+	return resp, err
+}
+
+// describe returns the entry of the request without its outcome.
+func (t *Transport) describe(req *http.Request) Entry {
+	e := Entry{
+		Service: t.Service,
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Action:  strings.ToLower(req.Method) + " " + req.URL.Path,
+	}
+	actions := jiraActions
+	if t.Service == ServiceGitHub {
+		actions = githubActions
+	}
+	for _, a := range actions {
+		if a.method != req.Method {
+			continue
+		}
+		match := a.path.FindStringSubmatch(req.URL.Path)
+		if match == nil {
+			continue
+		}
+		group := func(name string) string {
+			if i := a.path.SubexpIndex(name); i >= 0 {
+				return match[i]
+			}
+			return ""
+		}
+		e.Action = a.name
+		if owner := group("owner"); owner != "" {
+			e.Repository = owner + "/" + group("repo")
+		}
+		target := group("target")
+		if a.bodyField != "" {
+			target = bodyField(req, a.bodyField)
+		}
+		if target != "" {
+			e.Target = a.prefix + target
+		}
+		break
+	}
+	return e
+}
+
+// bodyField returns the string field of the JSON body of the request without
+// consuming the body.
+func bodyField(req *http.Request, field string) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	var fields map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&fields); err != nil {
+		return ""
+	}
+	value, _ := fields[field].(string)
+	return value
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+type mergeState int
+
+const (
+	mergePending mergeState = iota
+	mergeReady
+	mergeBlocked
+)
+
+func checkRunPassed(run *github.CheckRun) bool {
+	switch run.GetConclusion() {
+	case "success", "neutral", "skipped":
+		return true
+	}
+	return false
+}
+
+// mergeReadiness evaluates the latest check runs on the head commit of a pull
+// request. If the pull request is blocked, the name of the failed check is
+// returned.
+func mergeReadiness(runs []*github.CheckRun, names []string) (mergeState, string) {
+	latest := map[string]*github.CheckRun{}
+	for _, run := range runs {
+		latest[run.GetName()] = run
+	}
+
+	state := mergeReady
+	for _, name := range names {
+		run, ok := latest[name]
+		if !ok || run.GetStatus() != "completed" {
+			state = mergePending
+			continue
+		}
+		if !checkRunPassed(run) {
+			return mergeBlocked, name
+		}
+	}
+	return state, ""
+}
+
+func hasLabel(pr *github.PullRequest, label string) bool {
+	for _, l := range pr.Labels {
+		if l.GetName() == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (r reactor) listCheckRuns(ctx context.Context, org, repo, ref string) ([]*github.CheckRun, error) {
+	var runs []*github.CheckRun
+	opts := &github.ListCheckRunsOptions{
+		Filter: github.String("latest"),
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		result, resp, err := r.client.Checks.ListCheckRunsForRef(ctx, org, repo, ref, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list check runs for %s/%s@%s: %w", org, repo, ref, err)
+		}
+		runs = append(runs, result.CheckRuns...)
+		if resp.NextPage == 0 {
+			return runs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// removeFromMergePool removes the auto-merge label from the pull request and
+// explains why.
+func (r reactor) removeFromMergePool(ctx context.Context, org, repo string, pr *github.PullRequest, label, reason string) error {
+	klog.V(2).Infof("%sremoving %s/%s#%d from the merge pool: %s", logctx.Prefix(ctx), org, repo, pr.GetNumber(), reason)
+	_, err := r.client.Issues.RemoveLabelForIssue(ctx, org, repo, pr.GetNumber(), label)
+	if err != nil {
+		return fmt.Errorf("failed to remove the label %s from %s/%s#%d: %w", label, org, repo, pr.GetNumber(), err)
+	}
+	_, _, err = r.client.Issues.CreateComment(ctx, org, repo, pr.GetNumber(), &github.IssueComment{
+		Body: github.String(fmt.Sprintf("The pull request is not merged automatically: %s. Add the label `%s` again to retry.\n", reason, label)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to comment on %s/%s#%d: %w", org, repo, pr.GetNumber(), err)
+	}
+	return nil
+}
+
+// tryMerge merges the pull request if it has the auto-merge label and its
+// checks pass.
+func (r reactor) tryMerge(ctx context.Context, cfg *configuration.Configuration, org, repo string, pr *github.PullRequest) error {
+	repoConfig, ok := cfg.Repository(org, repo)
+	if !ok || repoConfig.AutoMerge.Label == "" {
+		return nil
+	}
+	autoMerge := repoConfig.AutoMerge
+	if pr.GetState() != "open" || pr.GetDraft() || !hasLabel(pr, autoMerge.Label) {
+		return nil
+	}
+	defer r.mergeLocks.Lock(fmt.Sprintf("%s/%s#%d", org, repo, pr.GetNumber()))()
+
+	headSHA := pr.GetHead().GetSHA()
+	runs, err := r.listCheckRuns(ctx, org, repo, headSHA)
+	if err != nil {
+		return err
+	}
+	var required []string
+	if repoConfig.Jira.Key != "" {
+		required = append(required, checks.TitleCheckRunName)
+	}
+	required = append(required, autoMerge.RequiredChecks...)
+	if len(autoMerge.RequiredChecks) == 0 {
+		for _, run := range runs {
+			required = append(required, run.GetName())
+		}
+	}
+	state, failed := mergeReadiness(runs, required)
+	switch state {
+	case mergePending:
+		klog.V(4).Infof("%snot merging %s/%s#%d yet: checks are pending", logctx.Prefix(ctx), org, repo, pr.GetNumber())
+		return nil
+	case mergeBlocked:
+		return r.removeFromMergePool(ctx, org, repo, pr, autoMerge.Label, fmt.Sprintf("the check %s failed on %s", failed, headSHA))
+	}
+
+	klog.V(2).Infof("%smerging %s/%s#%d (%s)...", logctx.Prefix(ctx), org, repo, pr.GetNumber(), headSHA)
+	_, resp, err := r.client.PullRequests.Merge(ctx, org, repo, pr.GetNumber(), "", &github.PullRequestOptions{
+		MergeMethod: autoMerge.MethodOrDefault(),
+		SHA:         headSHA,
+	})
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusConflict) {
+			// pr may be stale: another event may have merged it while this
+			// one was waiting for the lock.
+			current, _, getErr := r.client.PullRequests.Get(ctx, org, repo, pr.GetNumber())
+			if getErr != nil {
+				return fmt.Errorf("failed to merge %s/%s#%d: %w (failed to get the pull request: %v)", org, repo, pr.GetNumber(), err, getErr)
+			}
+			if current.GetMerged() {
+				klog.V(2).Infof("%s%s/%s#%d is already merged", logctx.Prefix(ctx), org, repo, pr.GetNumber())
+				return nil
+			}
+			return r.removeFromMergePool(ctx, org, repo, pr, autoMerge.Label, fmt.Sprintf("GitHub refused to merge it: %v", err))
+		}
+		return fmt.Errorf("failed to merge %s/%s#%d: %w", org, repo, pr.GetNumber(), err)
+	}
+	r.activity.Record(org, repo, activity.Event{
+		Type:        activity.TypeMerge,
+		PullRequest: pr.GetNumber(),
+		Summary:     fmt.Sprintf("Merged %s with the %s method", headSHA, autoMerge.MethodOrDefault()),
+	})
+	return nil
+}
+
+// HandleCheckRunComplete re-evaluates the pull requests of the check run for
+// auto-merge.
+func (r reactor) HandleCheckRunComplete(ctx context.Context, org, repo string, checkRun *github.CheckRun) error {
+	cfg := r.cfg.Get()
+	repoConfig, ok := cfg.Repository(org, repo)
+	if !ok || repoConfig.AutoMerge.Label == "" {
+		return nil
+	}
+	for _, p := range checkRun.PullRequests {
+		pr, _, err := r.client.PullRequests.Get(ctx, org, repo, p.GetNumber())
+		if err != nil {
+			return fmt.Errorf("failed to get pull request %s/%s#%d: %w", org, repo, p.GetNumber(), err)
+		}
+		if pr.GetHead().GetSHA() != checkRun.GetHeadSHA() {
+			continue
+		}
+		if err := r.tryMerge(ctx, cfg, org, repo, pr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestMergeReadiness(t *testing.T) {
+	run := func(name, status, conclusion string) *github.CheckRun {
+		return &github.CheckRun{
+			Name:       github.String(name),
+			Status:     github.String(status),
+			Conclusion: github.String(conclusion),
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		runs       []*github.CheckRun
+		required   []string
+		wantState  mergeState
+		wantFailed string
+	}{
+		{
+			name:      "all passed",
+			runs:      []*github.CheckRun{run("Pull Request Title", "completed", "success"), run("unit", "completed", "success")},
+			required:  []string{"Pull Request Title", "unit"},
+			wantState: mergeReady,
+		},
+		{
+			name:      "skipped",
+			runs:      []*github.CheckRun{run("unit", "completed", "skipped")},
+			required:  []string{"unit"},
+			wantState: mergeReady,
+		},
+		{
+			name:      "in progress",
+			runs:      []*github.CheckRun{run("unit", "in_progress", "")},
+			required:  []string{"unit"},
+			wantState: mergePending,
+		},
+		{
+			name:      "missing",
+			runs:      []*github.CheckRun{run("unit", "completed", "success")},
+			required:  []string{"Pull Request Title", "unit"},
+			wantState: mergePending,
+		},
+		{
+			name:       "failed",
+			runs:       []*github.CheckRun{run("unit", "in_progress", ""), run("e2e", "completed", "failure")},
+			required:   []string{"unit", "e2e"},
+			wantState:  mergeBlocked,
+			wantFailed: "e2e",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state, failed := mergeReadiness(tc.runs, tc.required)
+			if state != tc.wantState || failed != tc.wantFailed {
+				t.Errorf("got %v, %q, want %v, %q", state, failed, tc.wantState, tc.wantFailed)
+			}
+		})
+	}
+}
+
+// newAutoMergeTestReactor returns a reactor that merges the pull requests of
+// quay/quay with the label automerge once the unit check passes.
+func newAutoMergeTestReactor(gh *fakes.GitHub) *reactor {
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{
+			Owner: "quay",
+			Repo:  "quay",
+			AutoMerge: configuration.AutoMerge{
+				Label:          "automerge",
+				RequiredChecks: []string{"unit"},
+			},
+		}},
+	})
+	r.mergeLocks = NewBranchLocks()
+	return r
+}
+
+func autoMergePullRequest(gh *fakes.GitHub, conclusion string) *github.PullRequest {
+	pr := fakes.PullRequest("quay", "quay", 1234, "Fix the build")
+	pr.Labels = []*github.Label{{Name: github.String("automerge")}}
+	gh.AddPullRequest(pr)
+	gh.Labels[fakes.IssueKey("quay", "quay", 1234)] = []string{"automerge"}
+	gh.CheckRuns = append(gh.CheckRuns, &github.CheckRun{
+		Name:       github.String("unit"),
+		HeadSHA:    pr.Head.SHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+	})
+	return pr
+}
+
+func TestTryMerge(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newAutoMergeTestReactor(gh)
+	pr := autoMergePullRequest(gh, "success")
+
+	if err := r.tryMerge(context.Background(), r.cfg.Get(), "quay", "quay", pr); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"quay/quay#1234"}; !reflect.DeepEqual(gh.Merged, want) {
+		t.Errorf("got the merges %v, want %v", gh.Merged, want)
+	}
+}
+
+func TestTryMergeFailedCheck(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newAutoMergeTestReactor(gh)
+	pr := autoMergePullRequest(gh, "failure")
+	key := fakes.IssueKey("quay", "quay", 1234)
+
+	if err := r.tryMerge(context.Background(), r.cfg.Get(), "quay", "quay", pr); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.Merged) != 0 {
+		t.Errorf("the pull request should not be merged, got the merges %v", gh.Merged)
+	}
+	if len(gh.Labels[key]) != 0 {
+		t.Errorf("the label should be removed, got %q", gh.Labels[key])
+	}
+	if len(gh.Comments[key]) != 1 || !strings.Contains(gh.Comments[key][0].GetBody(), "the check unit failed") {
+		t.Errorf("got the comments %v, want one about the failed check", gh.Comments[key])
+	}
+}
+
+func TestTryMergeAlreadyMerged(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newAutoMergeTestReactor(gh)
+	pr := autoMergePullRequest(gh, "success")
+	key := fakes.IssueKey("quay", "quay", 1234)
+	stale := *pr
+
+	// Two check runs complete at the same time: the second event still has
+	// the open pull request when the first one has merged it.
+	if err := r.tryMerge(context.Background(), r.cfg.Get(), "quay", "quay", pr); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.tryMerge(context.Background(), r.cfg.Get(), "quay", "quay", &stale); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.Merged) != 1 {
+		t.Errorf("got the merges %v, want one", gh.Merged)
+	}
+	if want := []string{"automerge"}; !reflect.DeepEqual(gh.Labels[key], want) {
+		t.Errorf("the label of the merged pull request should be kept, got %q", gh.Labels[key])
+	}
+	if len(gh.Comments[key]) != 0 {
+		t.Errorf("the merged pull request should not get comments, got %v", gh.Comments[key])
+	}
+}
+
+func TestTryMergeRefused(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newAutoMergeTestReactor(gh)
+	pr := autoMergePullRequest(gh, "success")
+	key := fakes.IssueKey("quay", "quay", 1234)
+
+	// The head was pushed after the event, GitHub refuses to merge the old
+	// head.
+	stale := *pr
+	stale.Head = &github.PullRequestBranch{SHA: github.String("old")}
+	gh.CheckRuns = append(gh.CheckRuns, &github.CheckRun{
+		Name:       github.String("unit"),
+		HeadSHA:    github.String("old"),
+		Status:     github.String("completed"),
+		Conclusion: github.String("success"),
+	})
+	if err := r.tryMerge(context.Background(), r.cfg.Get(), "quay", "quay", &stale); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.Merged) != 0 {
+		t.Errorf("the pull request should not be merged, got the merges %v", gh.Merged)
+	}
+	if len(gh.Labels[key]) != 0 {
+		t.Errorf("the label should be removed, got %q", gh.Labels[key])
+	}
+	if len(gh.Comments[key]) != 1 || !strings.Contains(gh.Comments[key][0].GetBody(), "GitHub refused to merge it") {
+		t.Errorf("got the comments %v, want one about the refused merge", gh.Comments[key])
+	}
+}
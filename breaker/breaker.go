@@ -0,0 +1,155 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ErrOpen is returned by Transport when the circuit is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+// Breaker opens the circuit after Threshold consecutive failures. While the
+// circuit is open, requests are rejected. After Cooldown, one request is let
+// through to probe the service; its outcome closes or reopens the circuit.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	failures int
+	state    State
+	openedAt time.Time
+	probing  bool
+}
+
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     StateClosed,
+	}
+}
+
+// Allow returns true if a request can be sent.
+func (b *Breaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		klog.V(2).Infof("%s: circuit is half-open, probing", b.name)
+		b.state = StateHalfOpen
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Ready returns true if the circuit is closed or a probe can be sent. Unlike
+// Allow, it doesn't change the state of the breaker.
+func (b *Breaker) Ready() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		return b.now().Sub(b.openedAt) >= b.cooldown
+	case StateHalfOpen:
+		return !b.probing
+	}
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state != StateClosed {
+		klog.Infof("%s: circuit is closed", b.name)
+	}
+	b.failures = 0
+	b.state = StateClosed
+	b.probing = false
+}
+
+func (b *Breaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || b.state == StateClosed && b.failures >= b.threshold {
+		if b.state == StateClosed {
+			klog.Warningf("%s: circuit is open after %d consecutive failures", b.name, b.failures)
+		}
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Cancel is called when the caller cancelled the request, which tells nothing
+// about the service. A cancelled probe lets the next request probe.
+func (b *Breaker) Cancel() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// Transport rejects requests with ErrOpen while the circuit is open. Network
+// errors and 5xx responses are counted as failures, except for the requests
+// whose context is done, e.g. the checks of a superseded head.
+type Transport struct {
+	Base    http.RoundTripper
+	Breaker *Breaker
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.Breaker.Allow() {
+		return nil, ErrOpen
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if req.Context().Err() != nil {
+		t.Breaker.Cancel()
+	} else if err != nil || resp.StatusCode >= 500 {
+		t.Breaker.Failure()
+	} else {
+		t.Breaker.Success()
+	}
+	return resp, err
+}
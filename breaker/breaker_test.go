@@ -0,0 +1,102 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	b := New("test", 3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		b.Failure()
+	}
+	if !b.Allow() || b.State() != StateClosed {
+		t.Fatalf("expected closed circuit after 2 failures, got %s", b.State())
+	}
+
+	b.Failure()
+	if b.Allow() || b.State() != StateOpen {
+		t.Fatalf("expected open circuit after 3 failures, got %s", b.State())
+	}
+
+	if b.Ready() {
+		t.Fatalf("expected breaker not to be ready during cooldown")
+	}
+	now = now.Add(time.Minute)
+	if !b.Ready() {
+		t.Fatalf("expected breaker to be ready after cooldown")
+	}
+	if !b.Allow() {
+		t.Fatalf("expected probe to be allowed after cooldown")
+	}
+	if b.Allow() {
+		t.Fatalf("expected only one probe to be allowed")
+	}
+
+	b.Failure()
+	if b.Allow() || b.State() != StateOpen {
+		t.Fatalf("expected open circuit after failed probe, got %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatalf("expected probe to be allowed after cooldown")
+	}
+	b.Success()
+	if !b.Allow() || b.State() != StateClosed {
+		t.Fatalf("expected closed circuit after successful probe, got %s", b.State())
+	}
+}
+
+func TestTransportCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	b := New("test", 1, time.Minute)
+	client := &http.Client{Transport: &Transport{Breaker: b}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("want an error for the cancelled request")
+	}
+	if b.State() != StateClosed {
+		t.Errorf("got the %s circuit after a cancelled request, want closed", b.State())
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if b.State() != StateOpen {
+		t.Errorf("got the %s circuit after a 503, want open", b.State())
+	}
+}
+
+func TestCancelledProbe(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	b := New("test", 1, time.Minute)
+	b.now = func() time.Time { return now }
+	b.Failure()
+	now = now.Add(time.Minute)
+
+	if !b.Allow() {
+		t.Fatal("expected probe to be allowed after cooldown")
+	}
+	b.Cancel()
+	if !b.Allow() {
+		t.Errorf("expected another probe after the cancelled one")
+	}
+	if b.State() != StateHalfOpen {
+		t.Errorf("got the %s circuit, want half-open", b.State())
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+// The build information is set by the linker, see the Makefile.
+var (
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+type BuildInfo struct {
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	AppID     int64  `json:"appID"`
+}
+
+func buildInfo(cfg *configuration.Configuration) BuildInfo {
+	return BuildInfo{
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		AppID:     cfg.AppID,
+	}
+}
+
+// BuildInfoHandler serves GET /version, which tells which build of the app is
+// running.
+type BuildInfoHandler struct {
+	cfg *configuration.Store
+}
+
+func (bh *BuildInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildInfo(bh.cfg.Get())); err != nil {
+		klog.Errorf("failed to encode the build information: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestBuildInfoHandler(t *testing.T) {
+	h := &BuildInfoHandler{cfg: configuration.NewStore(&configuration.Configuration{AppID: 275455})}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", w.Code)
+	}
+	var info BuildInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	want := BuildInfo{GitCommit: "unknown", BuildDate: "unknown", GoVersion: runtime.Version(), AppID: 275455}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/version", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d for POST, want 405", w.Code)
+	}
+}
@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	value   interface{}
+	addedAt time.Time
+}
+
+// Stats describes the usage of a cache.
+type Stats struct {
+	Name       string `json:"name"`
+	Entries    int    `json:"entries"`
+	MaxEntries int    `json:"maxEntries"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+}
+
+// Cache is a size-limited LRU cache with optional expiration of entries. It is
+// safe for concurrent use.
+type Cache struct {
+	mutex      sync.Mutex
+	name       string
+	maxEntries int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []*Cache
+)
+
+// New creates a cache that holds up to maxEntries entries (0 means no limit)
+// for ttl (0 means entries don't expire). The cache is registered under name
+// for AllStats.
+func New(name string, maxEntries int, ttl time.Duration) *Cache {
+	c := &Cache{
+		name:       name,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+		now:        time.Now,
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry = append(registry, c)
+
+	return c
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
+
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && c.now().Sub(e.addedAt) >= c.ttl {
+		c.removeElement(el)
+		c.evictions++
+		c.misses++
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits++
+	return e.value, true
+}
+
+// Peek returns the value of key like Get, but without making the entry
+// recently used or counting a hit or a miss.
+func (c *Cache) Peek(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && c.now().Sub(e.addedAt) >= c.ttl {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *Cache) Add(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		e.value = value
+		e.addedAt = c.now()
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{
+		key:     key,
+		value:   value,
+		addedAt: c.now(),
+	})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+}
+
+func (c *Cache) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.ll.Init()
+	c.items = map[string]*list.Element{}
+}
+
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ll.Len()
+}
+
+// Keys returns the keys of the cache in no particular order. Expired entries
+// may be included.
+func (c *Cache) Keys() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := make([]string, 0, len(c.items))
+	for key := range c.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{
+		Name:       c.name,
+		Entries:    c.ll.Len(),
+		MaxEntries: c.maxEntries,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+}
+
+// AllStats returns the stats of all caches sorted by name.
+func AllStats() []Stats {
+	registryMutex.Lock()
+	caches := make([]*Cache, len(registry))
+	copy(caches, registry)
+	registryMutex.Unlock()
+
+	stats := make([]Stats, 0, len(caches))
+	for _, c := range caches {
+		stats = append(stats, c.Stats())
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
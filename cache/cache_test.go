@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCacheLRU(t *testing.T) {
+	c := New("test-lru", 2, 0)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	keys := c.Keys()
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "c"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := New("test-ttl", 0, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("PROJQUAY-123", "issue")
+	now = now.Add(30 * time.Second)
+	if v, ok := c.Get("PROJQUAY-123"); !ok || v != "issue" {
+		t.Errorf("expected cached value, got %v", v)
+	}
+	now = now.Add(30 * time.Second)
+	if v, ok := c.Get("PROJQUAY-123"); ok {
+		t.Errorf("expected expired value, got %v", v)
+	}
+}
+
+func TestCacheRemoveAndPurge(t *testing.T) {
+	c := New("test-remove", 0, 0)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to be removed")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("expected empty cache, got %d entries", c.Len())
+	}
+}
+
+func TestCachePeek(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := New("test-peek", 2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("got %v, %t, want 1", v, ok)
+	}
+	// Peek doesn't make a recently used, so it's evicted first.
+	c.Add("c", 3)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a should be evicted")
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 1 {
+		t.Errorf("got %d hits and %d misses, Peek should not count", stats.Hits, stats.Misses)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Peek("b"); ok {
+		t.Error("b should be expired")
+	}
+}
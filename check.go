@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/breaker"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/taginformer"
+	"k8s.io/klog/v2"
+)
+
+// parseRepo splits owner/repo.
+func parseRepo(s string) (string, string, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid repository %q, expected owner/repo", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// defaultEvent is the event that is evaluated for the pull request if it's
+// not given on the command line.
+func defaultEvent(pr *github.PullRequest) checks.Event {
+	if pr.GetState() == "closed" {
+		return checks.EventClosed
+	}
+	return checks.EventOpened
+}
+
+func writeExplanation(w io.Writer, pr *github.PullRequest, explanation *checks.Explanation) {
+	fmt.Fprintf(w, "Pull request: %s (%s)\n", pr.GetHTMLURL(), pr.GetTitle())
+	fmt.Fprintf(w, "Event: %s\n", explanation.Event)
+	if explanation.Skipped != "" {
+		fmt.Fprintf(w, "Skipped: %s\n", explanation.Skipped)
+		return
+	}
+
+	issue := explanation.Issue
+	fmt.Fprintf(w, "Jira issue: %s (%s, %s)\n", issue.Key, issue.Fields.Type.Name, issue.Fields.Status.Name)
+	if explanation.FixVersion != "" {
+		fmt.Fprintf(w, "Fix version: %s (%s)\n", explanation.FixVersion, explanation.FixVersionDerivation)
+	} else {
+		fmt.Fprintf(w, "Fix version: none (%s)\n", explanation.FixVersionDerivation)
+	}
+
+	fmt.Fprintf(w, "\nRules:\n")
+	if len(explanation.Rules) == 0 {
+		fmt.Fprintf(w, "  the repository does not have Jira rules\n")
+	}
+	for i, evaluation := range explanation.Rules {
+		switch {
+		case i == explanation.Applied:
+			fmt.Fprintf(w, "  #%d matches and would be applied\n", i+1)
+		case evaluation.Mismatch == "":
+			fmt.Fprintf(w, "  #%d matches, but only the first matching rule is applied\n", i+1)
+		default:
+			fmt.Fprintf(w, "  #%d does not match: %s\n", i+1, evaluation.Mismatch)
+		}
+	}
+
+	if explanation.Applied == -1 {
+		return
+	}
+	fmt.Fprintf(w, "\nActions:\n")
+	if len(explanation.Actions) == 0 {
+		fmt.Fprintf(w, "  nothing would change\n")
+	}
+	for _, action := range explanation.Actions {
+		fmt.Fprintf(w, "  - %s\n", strings.ReplaceAll(action, "\n", "\n    "))
+	}
+}
+
+// runCheck evaluates the Jira rules for a pull request and prints what would
+// be done. Without -dry-run, the Jira check is then run for the event.
+func runCheck(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	repoFlag := fs.String("repo", "", "repository of the pull request, owner/repo")
+	number := fs.Int("pr", 0, "number of the pull request")
+	event := fs.String("event", "", "event to evaluate the rules for, closed for closed pull requests and opened otherwise by default")
+	dryRun := fs.Bool("dry-run", true, "only print what would be done")
+	_ = fs.Parse(args)
+
+	owner, repo, err := parseRepo(*repoFlag)
+	if err != nil {
+		klog.Exit(err)
+	}
+	if *number <= 0 {
+		klog.Exit("-pr is required")
+	}
+
+	cfg, err := configuration.LoadFromFile(*configFile)
+	if err != nil {
+		klog.Exitf("failed to load configuration: %v", err)
+	}
+	keys, jiraToken, err := loadAppSecrets(ctx, cfg.Secrets, newVaultClient())
+	if err != nil {
+		klog.Exit(err)
+	}
+	jiraClient, err := newJiraClient(jiraToken, breaker.New("jira", *jiraBreakerThreshold, *jiraBreakerCooldown), nil)
+	if err != nil {
+		klog.Exitf("failed to create jira client: %v", err)
+	}
+	rawClient, appClient, _, err := newGitHubClients(ctx, cfg, keys, nil)
+	if err != nil {
+		klog.Exit(err)
+	}
+	client := clients.NewGitHub(rawClient)
+	tagInformer := taginformer.New(rawClient, func(org, repo string) taginformer.TagPattern {
+		repoConfig, _ := cfg.Repository(org, repo)
+		pattern, _ := taginformer.ParseTagPattern(repoConfig.TagPattern)
+		return pattern
+	})
+	jiraCheck := checks.NewJira(client, clients.NewGitHub(appClient), clients.NewJira(jiraClient), tagInformer, func(branch, message string) {}, activity.NewRecorder(1), 0, 0)
+
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, *number)
+	if err != nil {
+		klog.Exitf("failed to get pull request %s/%s#%d: %v", owner, repo, *number, err)
+	}
+	ev := checks.Event(*event)
+	if ev == "" {
+		ev = defaultEvent(pr)
+	}
+
+	jiraConfig := cfg.Jira(owner, repo)
+	branchConfig := cfg.Branch(owner, repo, pr.GetBase().GetRef())
+	explanation, err := jiraCheck.Explain(ctx, ev, jiraConfig, branchConfig, pr)
+	if err != nil {
+		klog.Exit(err)
+	}
+	writeExplanation(os.Stdout, pr, explanation)
+
+	if !*dryRun {
+		if err := jiraCheck.Run(ctx, ev, jiraConfig, branchConfig, pr); err != nil {
+			klog.Exit(err)
+		}
+	}
+}
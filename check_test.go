@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestParseRepo(t *testing.T) {
+	owner, repo, err := parseRepo("quay/quay")
+	if err != nil || owner != "quay" || repo != "quay" {
+		t.Errorf("got %q, %q, %v, want quay, quay", owner, repo, err)
+	}
+	for _, s := range []string{"", "quay", "quay/", "/quay"} {
+		if _, _, err := parseRepo(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	pr := fakes.PullRequest("quay", "quay", 1234, "Fix the build (PROJQUAY-123)")
+	explanation := &checks.Explanation{
+		Event:    checks.EventOpened,
+		IssueKey: "PROJQUAY-123",
+		Issue: &jira.Issue{
+			Key: "PROJQUAY-123",
+			Fields: &jira.IssueFields{
+				Type:   jira.IssueType{Name: "Bug"},
+				Status: &jira.Status{Name: "New"},
+			},
+		},
+		FixVersionDerivation: "the branch does not have a version",
+		Rules: []checks.RuleEvaluation{
+			{Mismatch: "merged is false"},
+			{},
+			{},
+		},
+		Applied: 1,
+		Actions: []string{"add the comment:\nline 1\nline 2", "transition the issue to In Progress"},
+	}
+
+	var buf bytes.Buffer
+	writeExplanation(&buf, pr, explanation)
+	want := `Pull request: https://github.com/quay/quay/pull/1234 (Fix the build (PROJQUAY-123))
+Event: opened
+Jira issue: PROJQUAY-123 (Bug, New)
+Fix version: none (the branch does not have a version)
+
+Rules:
+  #1 does not match: merged is false
+  #2 matches and would be applied
+  #3 matches, but only the first matching rule is applied
+
+Actions:
+  - add the comment:
+    line 1
+    line 2
+  - transition the issue to In Progress
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
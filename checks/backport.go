@@ -0,0 +1,97 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+// needsBackportClone returns true if the issue is tracked in another stream,
+// i.e. it has fix versions, but none of them belongs to the stream xy.
+func needsBackportClone(issue *jira.Issue, prefix, xy string) bool {
+	names := fixVersionNames(issue)
+	return len(names) > 0 && !inStream(names, prefix, xy)
+}
+
+// findBackportClone returns the key of an issue that is linked to the issue
+// with the given link type and is targeted at the stream xy.
+func (c *Jira) findBackportClone(ctx context.Context, issue *jira.Issue, linkType, prefix, xy string) (string, error) {
+	for _, link := range issue.Fields.IssueLinks {
+		if link.Type.Name != linkType || link.InwardIssue == nil {
+			continue
+		}
+		linked, _, err := c.jiraClient.Issue.GetWithContext(ctx, link.InwardIssue.Key, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get Jira issue %s: %w", link.InwardIssue.Key, err)
+		}
+		if inStream(fixVersionNames(linked), prefix, xy) {
+			return linked.Key, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *Jira) cloneForBackport(ctx context.Context, issue *jira.Issue, pr *github.PullRequest, jiraConfig configuration.Jira, branchConfig configuration.Branch, fixVersion string) error {
+	if !needsBackportClone(issue, jiraConfig.FixVersionPrefix, branchConfig.Version) {
+		return nil
+	}
+
+	linkType := jiraConfig.Backport.LinkTypeOrDefault()
+	cloneKey, err := c.findBackportClone(ctx, issue, linkType, jiraConfig.FixVersionPrefix, branchConfig.Version)
+	if err != nil {
+		return err
+	}
+
+	if cloneKey == "" {
+		var fixVersions []*jira.FixVersion
+		if fixVersion != "" {
+			exists, err := c.ensureFixVersion(ctx, jiraConfig, fixVersion)
+			if err != nil {
+				return err
+			}
+			if exists {
+				fixVersions = append(fixVersions, &jira.FixVersion{Name: fixVersion})
+			}
+		}
+
+		klog.V(2).Infof("%scloning issue %s for the %s backport...", logctx.Prefix(ctx), issue.Key, branchConfig.Version)
+		clone, _, err := c.jiraClient.Issue.CreateWithContext(ctx, &jira.Issue{
+			Fields: &jira.IssueFields{
+				Project:     jira.Project{Key: jiraConfig.Key},
+				Type:        jira.IssueType{Name: issue.Fields.Type.Name},
+				Summary:     fmt.Sprintf("[%s] %s", branchConfig.Version, issue.Fields.Summary),
+				Description: fmt.Sprintf("Backport of %s to %s.\n\n%s", issue.Key, branchConfig.Version, issue.Fields.Description),
+				FixVersions: fixVersions,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to clone issue %s: %w", issue.Key, err)
+		}
+		cloneKey = clone.Key
+
+		_, err = c.jiraClient.Issue.AddLinkWithContext(ctx, &jira.IssueLink{
+			Type:         jira.IssueLinkType{Name: linkType},
+			InwardIssue:  &jira.Issue{Key: cloneKey},
+			OutwardIssue: &jira.Issue{Key: issue.Key},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to link issue %s to %s: %w", cloneKey, issue.Key, err)
+		}
+	}
+
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	_, _, err = c.githubClient.Issues.CreateComment(ctx, owner, repo, pr.GetNumber(), &github.IssueComment{
+		Body: github.String(fmt.Sprintf("The issue %s is tracked in another stream. The backport to %s is tracked in %s, please use it in the pull request title.\n", issue.Key, branchConfig.Version, cloneKey)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to comment on pull request %s/%s#%d: %w", owner, repo, pr.GetNumber(), err)
+	}
+
+	return nil
+}
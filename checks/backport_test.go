@@ -0,0 +1,36 @@
+package checks
+
+import "testing"
+
+func TestNeedsBackportClone(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fixVersions []string
+		xy          string
+		want        bool
+	}{
+		{
+			name: "issue without fix versions",
+			xy:   "3.8",
+			want: false,
+		},
+		{
+			name:        "issue is fixed in the same stream",
+			fixVersions: []string{"quay-v3.8.3"},
+			xy:          "3.8",
+			want:        false,
+		},
+		{
+			name:        "issue is fixed in another stream",
+			fixVersions: []string{"quay-v3.10.0"},
+			xy:          "3.8",
+			want:        true,
+		},
+	}
+	for _, tc := range testCases {
+		issue := fakeIssue(issueData{key: "PROJQUAY-123", fixVersions: tc.fixVersions})
+		if got := needsBackportClone(issue, "quay-v", tc.xy); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package checks
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+// reportCheckRun creates a completed check run on the head commit of the pull
+// request and records it in the activity feed.
+func reportCheckRun(ctx context.Context, client *clients.GitHub, recorder *activity.Recorder, pr *github.PullRequest, name, conclusion string, output *github.CheckRunOutput) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	klog.V(4).Infof("%sreporting %s result on %s/%s#%d: %s: %s", logctx.Prefix(ctx), name, owner, repo, pr.GetNumber(), conclusion, output.GetTitle())
+
+	_, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    pr.GetHead().GetSHA(),
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+		Output:     output,
+	})
+	if err != nil {
+		return err
+	}
+	recorder.Record(owner, repo, activity.Event{
+		Type:        activity.TypeCheck,
+		PullRequest: pr.GetNumber(),
+		Summary:     name + ": " + conclusion + ": " + output.GetTitle(),
+	})
+	return nil
+}
+
+// mutedOutput is the output of checks that are muted by mute.
+func mutedOutput(owner, repo string, mute configuration.CheckMute) *github.CheckRunOutput {
+	summary := "This check is muted for " + owner + "/" + repo + " until " + mute.Until.UTC().Format(time.RFC3339) + ".\n"
+	if mute.Reason != "" {
+		summary += "\nReason: " + mute.Reason + "\n"
+	}
+	return &github.CheckRunOutput{
+		Title:   github.String("This check is muted"),
+		Summary: github.String(summary),
+	}
+}
@@ -0,0 +1,384 @@
+package checks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+// CodeOwnersCheckName is the name that is used to refer to the code owners
+// check in the configuration.
+const CodeOwnersCheckName = configuration.CheckCodeOwners
+
+// CodeOwnersCheckRunName is the name of the check run that is reported by the
+// code owners check.
+const CodeOwnersCheckRunName = "Code Owners"
+
+// codeOwnersLocations are the paths where GitHub looks for the CODEOWNERS
+// file, in the order of precedence.
+var codeOwnersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type codeOwnersRule struct {
+	pattern        string
+	re             *regexp.Regexp
+	directChildren bool
+	owners         []string
+}
+
+// codeOwnersRegexp translates the gitignore-like pattern into a regular
+// expression. Patterns with a slash in the middle or at the beginning are
+// relative to the root of the repository, others match at any depth.
+func codeOwnersRegexp(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var sb strings.Builder
+	if anchored {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				if i+2 < len(pattern) && pattern[i+2] == '/' {
+					sb.WriteString("(?:.*/)?")
+					i += 2
+				} else {
+					sb.WriteString(".*")
+					i++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// parseCodeOwners parses the CODEOWNERS file. Invalid patterns and rules
+// whose owners are all identified by email are reported as errors: emails
+// can't be matched with reviewers, so the files of such a rule could never be
+// approved.
+func parseCodeOwners(content string) ([]codeOwnersRule, error) {
+	var rules []codeOwnersRule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		var owners []string
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+			owners = append(owners, field)
+		}
+		re, err := codeOwnersRegexp(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q: %w", lineNumber, fields[0], err)
+		}
+		if len(owners) > 0 && !hasLoginOwner(owners) {
+			return nil, fmt.Errorf("line %d: the owners of %q are only emails, use GitHub users or teams", lineNumber, fields[0])
+		}
+		rules = append(rules, codeOwnersRule{
+			pattern: fields[0],
+			re:      re,
+			// Unlike in gitignore, docs/* doesn't match the files in the
+			// subdirectories of docs.
+			directChildren: strings.HasSuffix(fields[0], "/*"),
+			owners:         owners,
+		})
+	}
+	return rules, scanner.Err()
+}
+
+func hasLoginOwner(owners []string) bool {
+	for _, owner := range owners {
+		if strings.HasPrefix(owner, "@") {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule codeOwnersRule) matches(filename string) bool {
+	if rule.re.MatchString(filename) {
+		return true
+	}
+	if rule.directChildren {
+		return false
+	}
+	for dir := path.Dir(filename); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if rule.re.MatchString(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileOwners returns the owners of the file. The last matching rule wins.
+func fileOwners(rules []codeOwnersRule, filename string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(filename) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+// ownerGroup is a set of owners, one of whom has to approve the changes to
+// files.
+type ownerGroup struct {
+	owners []string
+	files  []string
+}
+
+func requiredOwnerGroups(rules []codeOwnersRule, filenames []string) []ownerGroup {
+	var groups []ownerGroup
+	index := map[string]int{}
+	for _, filename := range filenames {
+		owners := fileOwners(rules, filename)
+		if len(owners) == 0 {
+			continue
+		}
+		key := strings.Join(owners, " ")
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ownerGroup{owners: owners})
+		}
+		groups[i].files = append(groups[i].files, filename)
+	}
+	return groups
+}
+
+// approvers returns the lowercase logins of the users whose latest review
+// approves the pull request.
+func approvers(reviews []*github.PullRequestReview, author string) map[string]bool {
+	latest := map[string]string{}
+	for _, review := range reviews {
+		login := strings.ToLower(review.GetUser().GetLogin())
+		switch state := review.GetState(); state {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latest[login] = state
+		}
+	}
+	result := map[string]bool{}
+	for login, state := range latest {
+		if state == "APPROVED" && login != strings.ToLower(author) {
+			result[login] = true
+		}
+	}
+	return result
+}
+
+// groupApproved reports whether one of the owners of the group has approved.
+// teamMembers holds the lowercase logins of the members of the teams.
+func groupApproved(group ownerGroup, approved map[string]bool, teamMembers map[string][]string) bool {
+	for _, owner := range group.owners {
+		if !strings.HasPrefix(owner, "@") {
+			// Owners identified by email can't be matched with reviewers.
+			continue
+		}
+		if strings.Contains(owner, "/") {
+			for _, member := range teamMembers[owner] {
+				if approved[member] {
+					return true
+				}
+			}
+			continue
+		}
+		if approved[strings.ToLower(strings.TrimPrefix(owner, "@"))] {
+			return true
+		}
+	}
+	return false
+}
+
+func codeOwnersResult(groups []ownerGroup, approved map[string]bool, teamMembers map[string][]string) (string, *github.CheckRunOutput) {
+	var missing []string
+	for _, group := range groups {
+		if groupApproved(group, approved, teamMembers) {
+			continue
+		}
+		files := group.files
+		more := ""
+		if len(files) > 5 {
+			more = fmt.Sprintf(" and %d more", len(files)-5)
+			files = files[:5]
+		}
+		missing = append(missing, fmt.Sprintf("* %s: `%s`%s", strings.Join(group.owners, ", "), strings.Join(files, "`, `"), more))
+	}
+
+	if len(missing) == 0 {
+		return "success", &github.CheckRunOutput{
+			Title:   github.String("The changes are approved by their owners"),
+			Summary: github.String(fmt.Sprintf("All %d groups of code owners for the changed files have approved.\n", len(groups))),
+		}
+	}
+	return "failure", &github.CheckRunOutput{
+		Title:   github.String(fmt.Sprintf("Approvals are missing from %d of %d groups of code owners", len(missing), len(groups))),
+		Summary: github.String("An approval from one of the owners is needed for each group of files:\n\n" + strings.Join(missing, "\n") + "\n"),
+	}
+}
+
+// CodeOwners is the check that requires the changes to be approved by the
+// owners from the CODEOWNERS file of the base branch.
+type CodeOwners struct {
+	githubClient *clients.GitHub
+	activity     *activity.Recorder
+}
+
+func NewCodeOwners(githubClient *clients.GitHub, recorder *activity.Recorder) *CodeOwners {
+	return &CodeOwners{
+		githubClient: githubClient,
+		activity:     recorder,
+	}
+}
+
+// codeOwnersFile returns the content of the CODEOWNERS file on the branch, or
+// an empty string if there is none.
+func (c *CodeOwners) codeOwnersFile(ctx context.Context, owner, repo, branch string) (string, error) {
+	for _, location := range codeOwnersLocations {
+		file, _, resp, err := c.githubClient.Repositories.GetContents(ctx, owner, repo, location, &github.RepositoryContentGetOptions{
+			Ref: branch,
+		})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return "", fmt.Errorf("failed to get %s: %w", location, err)
+		}
+		return file.GetContent()
+	}
+	return "", nil
+}
+
+func (c *CodeOwners) listReviews(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	var reviews []*github.PullRequestReview
+	opts := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		page, resp, err := c.githubClient.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list reviews of %s/%s#%d: %w", owner, repo, number, err)
+		}
+		reviews = append(reviews, page...)
+		if resp.NextPage == 0 {
+			return reviews, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (c *CodeOwners) listTeamMembers(ctx context.Context, team string) ([]string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(team, "@"), "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid team %s", team)
+	}
+	org, slug := parts[0], parts[1]
+	var members []string
+	opts := &github.TeamListTeamMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		page, resp, err := c.githubClient.Teams.ListTeamMembersBySlug(ctx, org, slug, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of %s: %w", team, err)
+		}
+		for _, user := range page {
+			members = append(members, strings.ToLower(user.GetLogin()))
+		}
+		if resp.NextPage == 0 {
+			return members, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (c *CodeOwners) Run(ctx context.Context, pr *github.PullRequest) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	content, err := c.codeOwnersFile(ctx, owner, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+	if content == "" {
+		return reportCheckRun(ctx, c.githubClient, c.activity, pr, CodeOwnersCheckRunName, "neutral", &github.CheckRunOutput{
+			Title:   github.String("There is no CODEOWNERS file"),
+			Summary: github.String("The branch `" + pr.GetBase().GetRef() + "` doesn't have a CODEOWNERS file.\n"),
+		})
+	}
+	rules, err := parseCodeOwners(content)
+	if err != nil {
+		return reportCheckRun(ctx, c.githubClient, c.activity, pr, CodeOwnersCheckRunName, "failure", &github.CheckRunOutput{
+			Title:   github.String("The CODEOWNERS file is invalid"),
+			Summary: github.String(fmt.Sprintf("Failed to parse the CODEOWNERS file of the branch `%s`: %v.\n", pr.GetBase().GetRef(), err)),
+		})
+	}
+
+	files, err := listFiles(ctx, c.githubClient, pr)
+	if err != nil {
+		return err
+	}
+	var filenames []string
+	for _, file := range files {
+		filenames = append(filenames, file.GetFilename())
+		if file.GetPreviousFilename() != "" {
+			filenames = append(filenames, file.GetPreviousFilename())
+		}
+	}
+	groups := requiredOwnerGroups(rules, filenames)
+
+	reviews, err := c.listReviews(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+	teamMembers := map[string][]string{}
+	for _, group := range groups {
+		for _, o := range group.owners {
+			if _, ok := teamMembers[o]; ok || !strings.HasPrefix(o, "@") || !strings.Contains(o, "/") {
+				continue
+			}
+			members, err := c.listTeamMembers(ctx, o)
+			if err != nil {
+				return err
+			}
+			teamMembers[o] = members
+		}
+	}
+
+	conclusion, output := codeOwnersResult(groups, approvers(reviews, pr.GetUser().GetLogin()), teamMembers)
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, CodeOwnersCheckRunName, conclusion, output)
+}
+
+func (c *CodeOwners) ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, CodeOwnersCheckRunName, "neutral", mutedOutput(owner, repo, mute))
+}
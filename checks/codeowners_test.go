@@ -0,0 +1,101 @@
+package checks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+)
+
+const testCodeOwners = `# Default owners
+*                @quay/maintainers
+
+*.js             @quay/ui
+/docs/           @writer docs@example.com
+apps/            @apps-owner
+/config/*        @config-owner
+**/storage/**    @storage-owner
+/vendor/
+`
+
+func TestFileOwners(t *testing.T) {
+	rules, err := parseCodeOwners(testCodeOwners)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		filename string
+		want     []string
+	}{
+		{filename: "main.go", want: []string{"@quay/maintainers"}},
+		{filename: "web/src/app.js", want: []string{"@quay/ui"}},
+		{filename: "docs/index.md", want: []string{"@writer", "docs@example.com"}},
+		{filename: "docs/images/logo.js", want: []string{"@writer", "docs@example.com"}},
+		{filename: "web/docs/index.md", want: []string{"@quay/maintainers"}},
+		{filename: "apps/a/main.go", want: []string{"@apps-owner"}},
+		{filename: "cmd/apps/main.go", want: []string{"@apps-owner"}},
+		{filename: "config/app.yaml", want: []string{"@config-owner"}},
+		{filename: "config/dev/app.yaml", want: []string{"@quay/maintainers"}},
+		{filename: "pkg/storage/s3/s3.go", want: []string{"@storage-owner"}},
+		{filename: "vendor/github.com/foo/foo.go", want: nil},
+	}
+	for _, tc := range testCases {
+		if got := fileOwners(rules, tc.filename); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.filename, got, tc.want)
+		}
+	}
+}
+
+func TestParseCodeOwnersEmailOnly(t *testing.T) {
+	_, err := parseCodeOwners("*       @quay/maintainers\n/docs/  docs@example.com writer@example.com\n")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got the error %v, want an error for the owners on line 2", err)
+	}
+}
+
+func TestCodeOwnersResult(t *testing.T) {
+	rules, err := parseCodeOwners(testCodeOwners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := requiredOwnerGroups(rules, []string{"main.go", "web/src/app.js", "web/src/index.js", "vendor/foo.go"})
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+
+	review := func(login, state string) *github.PullRequestReview {
+		return &github.PullRequestReview{
+			User:  &github.User{Login: github.String(login)},
+			State: github.String(state),
+		}
+	}
+	teamMembers := map[string][]string{
+		"@quay/maintainers": {"alice", "author"},
+		"@quay/ui":          {"bob"},
+	}
+
+	reviews := []*github.PullRequestReview{
+		review("Alice", "APPROVED"),
+		review("bob", "APPROVED"),
+		review("bob", "COMMENTED"),
+	}
+	if got, output := codeOwnersResult(groups, approvers(reviews, "author"), teamMembers); got != "success" {
+		t.Errorf("got %s (%s), want success", got, output.GetSummary())
+	}
+
+	reviews = []*github.PullRequestReview{
+		review("author", "APPROVED"),
+		review("bob", "APPROVED"),
+		review("alice", "APPROVED"),
+		review("alice", "CHANGES_REQUESTED"),
+	}
+	got, output := codeOwnersResult(groups, approvers(reviews, "author"), teamMembers)
+	if got != "failure" {
+		t.Errorf("got %s, want failure", got)
+	}
+	if want := "Approvals are missing from 1 of 2 groups of code owners"; output.GetTitle() != want {
+		t.Errorf("got title %q, want %q", output.GetTitle(), want)
+	}
+}
@@ -0,0 +1,63 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/clients"
+)
+
+// listCommits returns the commits of the pull request. GitHub lists up to 250
+// commits.
+func listCommits(ctx context.Context, client *clients.GitHub, pr *github.PullRequest) ([]*github.RepositoryCommit, error) {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	var commits []*github.RepositoryCommit
+	opts := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		page, resp, err := client.PullRequests.ListCommits(ctx, owner, repo, pr.GetNumber(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits of %s/%s#%d: %w", owner, repo, pr.GetNumber(), err)
+		}
+		commits = append(commits, page...)
+		if resp.NextPage == 0 {
+			return commits, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listFiles returns the files that are changed by the pull request. GitHub
+// lists up to 3000 files.
+func listFiles(ctx context.Context, client *clients.GitHub, pr *github.PullRequest) ([]*github.CommitFile, error) {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	var files []*github.CommitFile
+	opts := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		page, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, pr.GetNumber(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of %s/%s#%d: %w", owner, repo, pr.GetNumber(), err)
+		}
+		files = append(files, page...)
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// shortSHA returns the abbreviated commit SHA.
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
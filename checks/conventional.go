@@ -0,0 +1,97 @@
+package checks
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+// ConventionalTitleCheckName is the name that is used to refer to the
+// conventional title check in the configuration.
+const ConventionalTitleCheckName = configuration.CheckConventionalTitle
+
+// ConventionalTitleCheckRunName is the name of the check run that is reported
+// by the conventional title check.
+const ConventionalTitleCheckRunName = "Conventional Title"
+
+var conventionalTitleRegex = regexp.MustCompile(`^([a-z]+)(?:\(([^()]+)\))?!?: \S`)
+
+// DefaultConventionalTypes are the types that are allowed if the
+// configuration doesn't list them.
+var DefaultConventionalTypes = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+
+// stripIssueKey removes the Jira issue key suffix from the pull request title.
+func stripIssueKey(title string) string {
+	if loc := titleJiraRegex.FindStringIndex(title); loc != nil {
+		return title[:loc[0]]
+	}
+	return title
+}
+
+func conventionalTitleResult(cfg configuration.ConventionalTitle, title string) (string, *github.CheckRunOutput) {
+	title = stripIssueKey(title)
+	types := cfg.Types
+	if len(types) == 0 {
+		types = DefaultConventionalTypes
+	}
+
+	failure := func(problem string) (string, *github.CheckRunOutput) {
+		summary := problem + "\n\nThe title should look like `type(scope): description`. Allowed types: `" + strings.Join(types, "`, `") + "`.\n"
+		if len(cfg.Scopes) > 0 {
+			summary += "Allowed scopes: `" + strings.Join(cfg.Scopes, "`, `") + "`.\n"
+		}
+		return "failure", &github.CheckRunOutput{
+			Title:   github.String("The title does not follow the conventional commits format"),
+			Summary: github.String(summary),
+		}
+	}
+
+	matches := conventionalTitleRegex.FindStringSubmatch(title)
+	if matches == nil {
+		return failure("The title `" + title + "` does not start with a type.")
+	}
+	commitType, scope := matches[1], matches[2]
+	if !contains(types, commitType) {
+		return failure("The type `" + commitType + "` is not allowed.")
+	}
+	if scope == "" && cfg.RequireScope {
+		return failure("The title does not have a scope.")
+	}
+	if scope != "" && len(cfg.Scopes) > 0 && !contains(cfg.Scopes, scope) {
+		return failure("The scope `" + scope + "` is not allowed.")
+	}
+	return "success", &github.CheckRunOutput{
+		Title:   github.String("The title follows the conventional commits format"),
+		Summary: github.String("The title has the type `" + commitType + "`.\n"),
+	}
+}
+
+// ConventionalTitle is the check that requires pull request titles to follow
+// the conventional commits format.
+type ConventionalTitle struct {
+	githubClient *clients.GitHub
+	activity     *activity.Recorder
+}
+
+func NewConventionalTitle(githubClient *clients.GitHub, recorder *activity.Recorder) *ConventionalTitle {
+	return &ConventionalTitle{
+		githubClient: githubClient,
+		activity:     recorder,
+	}
+}
+
+func (c *ConventionalTitle) Run(ctx context.Context, cfg configuration.ConventionalTitle, pr *github.PullRequest) error {
+	conclusion, output := conventionalTitleResult(cfg, pr.GetTitle())
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, ConventionalTitleCheckRunName, conclusion, output)
+}
+
+func (c *ConventionalTitle) ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, ConventionalTitleCheckRunName, "neutral", mutedOutput(owner, repo, mute))
+}
@@ -0,0 +1,34 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestConventionalTitleResult(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   configuration.ConventionalTitle
+		title string
+		want  string
+	}{
+		{name: "type", title: "fix: handle timeouts", want: "success"},
+		{name: "type and key", title: "fix: handle timeouts (PROJQUAY-123)", want: "success"},
+		{name: "scope", title: "feat(api)!: remove v1 (PROJQUAY-123)", want: "success"},
+		{name: "no type", title: "Handle timeouts (PROJQUAY-123)", want: "failure"},
+		{name: "unknown type", title: "wip: handle timeouts", want: "failure"},
+		{name: "no description", title: "fix: (PROJQUAY-123)", want: "failure"},
+		{name: "custom types", cfg: configuration.ConventionalTitle{Types: []string{"wip"}}, title: "wip: handle timeouts", want: "success"},
+		{name: "allowed scope", cfg: configuration.ConventionalTitle{Scopes: []string{"api", "ui"}}, title: "fix(ui): align buttons", want: "success"},
+		{name: "unknown scope", cfg: configuration.ConventionalTitle{Scopes: []string{"api", "ui"}}, title: "fix(db): add index", want: "failure"},
+		{name: "required scope", cfg: configuration.ConventionalTitle{RequireScope: true}, title: "fix: add index", want: "failure"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, output := conventionalTitleResult(tc.cfg, tc.title); got != tc.want {
+				t.Errorf("got %s (%s), want %s", got, output.GetSummary(), tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+// DCOCheckName is the name that is used to refer to the DCO check in the
+// configuration.
+const DCOCheckName = configuration.CheckDCO
+
+// DCOCheckRunName is the name of the check run that is reported by the DCO
+// check.
+const DCOCheckRunName = "DCO"
+
+var signedOffByRegex = regexp.MustCompile(`(?m)^Signed-off-by: (.+) <([^<>]+)>\s*$`)
+
+// signedOff reports whether the commit message has a Signed-off-by trailer of
+// the author.
+func signedOff(message, authorName, authorEmail string) bool {
+	for _, matches := range signedOffByRegex.FindAllStringSubmatch(message, -1) {
+		if strings.EqualFold(strings.TrimSpace(matches[1]), authorName) && strings.EqualFold(matches[2], authorEmail) {
+			return true
+		}
+	}
+	return false
+}
+
+func dcoResult(commits []*github.RepositoryCommit) (string, *github.CheckRunOutput) {
+	var offending []string
+	for _, commit := range commits {
+		if len(commit.Parents) > 1 {
+			// Merge commits are created by tools and don't need a sign-off.
+			continue
+		}
+		author := commit.GetCommit().GetAuthor()
+		if !signedOff(commit.GetCommit().GetMessage(), author.GetName(), author.GetEmail()) {
+			offending = append(offending, fmt.Sprintf("* %s %s (%s <%s>)", shortSHA(commit.GetSHA()), firstLine(commit.GetCommit().GetMessage()), author.GetName(), author.GetEmail()))
+		}
+	}
+
+	if len(offending) == 0 {
+		return "success", &github.CheckRunOutput{
+			Title:   github.String("All commits are signed off"),
+			Summary: github.String("All commits have a `Signed-off-by` trailer of their authors.\n"),
+		}
+	}
+	return "failure", &github.CheckRunOutput{
+		Title: github.String(fmt.Sprintf("%d of %d commits are not signed off", len(offending), len(commits))),
+		Summary: github.String("The following commits don't have a `Signed-off-by` trailer that matches the commit author:\n\n" +
+			strings.Join(offending, "\n") + "\n\n" +
+			"To sign off the commits, rebase the branch and force-push it:\n\n" +
+			"```\n" +
+			fmt.Sprintf("git rebase --signoff HEAD~%d\n", len(commits)) +
+			"git push --force-with-lease\n" +
+			"```\n"),
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i]
+	}
+	return s
+}
+
+// DCO is the check that requires all commits of pull requests to be signed
+// off by their authors (Developer Certificate of Origin).
+type DCO struct {
+	githubClient *clients.GitHub
+	activity     *activity.Recorder
+}
+
+func NewDCO(githubClient *clients.GitHub, recorder *activity.Recorder) *DCO {
+	return &DCO{
+		githubClient: githubClient,
+		activity:     recorder,
+	}
+}
+
+func (c *DCO) Run(ctx context.Context, pr *github.PullRequest) error {
+	commits, err := listCommits(ctx, c.githubClient, pr)
+	if err != nil {
+		return err
+	}
+	conclusion, output := dcoResult(commits)
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, DCOCheckRunName, conclusion, output)
+}
+
+func (c *DCO) ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, DCOCheckRunName, "neutral", mutedOutput(owner, repo, mute))
+}
@@ -0,0 +1,42 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+)
+
+func TestDCOResult(t *testing.T) {
+	commit := func(sha, message, name, email string, parents int) *github.RepositoryCommit {
+		c := &github.RepositoryCommit{
+			SHA: github.String(sha),
+			Commit: &github.Commit{
+				Message: github.String(message),
+				Author: &github.CommitAuthor{
+					Name:  github.String(name),
+					Email: github.String(email),
+				},
+			},
+		}
+		for i := 0; i < parents; i++ {
+			c.Parents = append(c.Parents, &github.Commit{})
+		}
+		return c
+	}
+
+	signed := commit("1111", "Fix the bug\n\nSigned-off-by: Jane Doe <jane@example.com>\n", "Jane Doe", "jane@example.com", 1)
+	otherSigner := commit("2222", "Fix the test\n\nSigned-off-by: John Roe <john@example.com>", "Jane Doe", "jane@example.com", 1)
+	unsigned := commit("3333", "Fix the docs", "Jane Doe", "jane@example.com", 1)
+	merge := commit("4444", "Merge branch 'master'", "Jane Doe", "jane@example.com", 2)
+
+	if got, _ := dcoResult([]*github.RepositoryCommit{signed, merge}); got != "success" {
+		t.Errorf("got %s, want success", got)
+	}
+	got, output := dcoResult([]*github.RepositoryCommit{signed, otherSigner, unsigned})
+	if got != "failure" {
+		t.Errorf("got %s, want failure", got)
+	}
+	if want := "2 of 3 commits are not signed off"; output.GetTitle() != want {
+		t.Errorf("got title %q, want %q", output.GetTitle(), want)
+	}
+}
@@ -0,0 +1,138 @@
+package checks
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/replay"
+)
+
+// The e2e tests replay the GitHub and Jira interactions from the fixtures in
+// testdata/e2e. With -e2e.record, the tests talk to the live APIs instead and
+// overwrite the fixtures. In this mode, the inputs of the tests are read from
+// the E2E_* environment variables and the tokens from E2E_GITHUB_TOKEN and
+// E2E_JIRA_TOKEN.
+var recordE2E = flag.Bool("e2e.record", false, "record the e2e fixtures against the live GitHub and Jira APIs")
+
+// tokenTransport authenticates the requests to the recorded APIs.
+type tokenTransport struct {
+	tokens map[string]string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if token := t.tokens[req.URL.Host]; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+type e2e struct {
+	t        *testing.T
+	fixture  string
+	recorder *replay.Recorder
+	replayer *replay.Replayer
+}
+
+func newE2E(t *testing.T, fixture string) *e2e {
+	e := &e2e{t: t, fixture: fixture}
+	if !*recordE2E {
+		replayer, err := replay.Load(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.replayer = replayer
+		return e
+	}
+
+	tokens := map[string]string{"api.github.com": os.Getenv("E2E_GITHUB_TOKEN")}
+	if u, err := url.Parse(os.Getenv("E2E_JIRA_ENDPOINT")); err == nil {
+		tokens[u.Host] = os.Getenv("E2E_JIRA_TOKEN")
+	}
+	e.recorder = &replay.Recorder{Base: &tokenTransport{tokens: tokens}}
+	return e
+}
+
+// value returns the test input key. It is read from the environment variable
+// when recording and from the fixture when replaying.
+func (e *e2e) value(key, env string) string {
+	if e.replayer != nil {
+		return e.replayer.Value(key)
+	}
+	value := os.Getenv(env)
+	if value == "" {
+		e.t.Fatalf("%s is required to record %s", env, e.fixture)
+	}
+	e.recorder.SetValue(key, value)
+	return value
+}
+
+func (e *e2e) httpClient() *http.Client {
+	if e.replayer != nil {
+		return &http.Client{Transport: e.replayer}
+	}
+	return &http.Client{Transport: e.recorder}
+}
+
+// finish saves the fixture when recording. When replaying, it checks that the
+// test made exactly the recorded requests.
+func (e *e2e) finish() {
+	if e.recorder != nil {
+		if err := e.recorder.Save(e.fixture); err != nil {
+			e.t.Fatal(err)
+		}
+		return
+	}
+	if unmatched := e.replayer.Unmatched(); len(unmatched) > 0 {
+		e.t.Errorf("requests without recorded responses: %v", unmatched)
+	}
+	if unused := e.replayer.Unused(); len(unused) > 0 {
+		e.t.Errorf("recorded requests that were not made: %v", unused)
+	}
+}
+
+// TestE2EStaleErrorComments rechecks a pull request that has an internal
+// error comment of the app, which should be deleted once the check succeeds.
+//
+// To record the fixture, comment on a pull request with a valid Jira issue
+// in the title as the app, with a body that contains internalErrorMarker.
+func TestE2EStaleErrorComments(t *testing.T) {
+	e := newE2E(t, "testdata/e2e/stale_error_comments.json")
+	owner := e.value("owner", "E2E_OWNER")
+	repo := e.value("repo", "E2E_REPO")
+	number, err := strconv.Atoi(e.value("pull_request", "E2E_PULL_REQUEST"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jiraKey := e.value("jira_key", "E2E_JIRA_KEY")
+	appLogin := e.value("app_login", "E2E_APP_LOGIN")
+	jiraEndpoint := e.value("jira_endpoint", "E2E_JIRA_ENDPOINT")
+
+	githubClient := clients.NewGitHub(github.NewClient(e.httpClient()))
+	jiraClient, err := jira.NewClient(e.httpClient(), jiraEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewJira(githubClient, githubClient, clients.NewJira(jiraClient), nil, func(branch, message string) {}, activity.NewRecorder(10), 0, 0)
+	c.cachedGithubUserLogin = appLogin
+
+	pr, _, err := githubClient.PullRequests.Get(context.Background(), owner, repo, number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Run(context.Background(), EventRecheck, configuration.Jira{Key: jiraKey}, configuration.Branch{Name: pr.GetBase().GetRef()}, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.finish()
+}
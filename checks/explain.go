@@ -0,0 +1,147 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+// RuleEvaluation is the result of matching a Jira rule against the pull
+// request.
+type RuleEvaluation struct {
+	Rule configuration.JiraRule
+
+	// Mismatch describes why the rule doesn't match. It is empty if the rule
+	// matches.
+	Mismatch string
+}
+
+// Explanation describes what Run would do with the Jira issue of a pull
+// request.
+type Explanation struct {
+	Event    Event
+	IssueKey string
+	Issue    *jira.Issue
+
+	FixVersion            string
+	FixVersionDerivation  string
+	AllPullRequestsMerged bool
+
+	Rules []RuleEvaluation
+
+	// Applied is the index of the rule that would be applied, or -1 if no
+	// rule matches.
+	Applied int
+	Actions []string
+
+	// Skipped is the reason why the rules are not evaluated at all.
+	Skipped string
+}
+
+// Explain evaluates the rules for the pull request like Run does for event,
+// but only reads from GitHub and Jira.
+func (c *Jira) Explain(ctx context.Context, event Event, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest) (*Explanation, error) {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	explanation := &Explanation{
+		Event:   event,
+		Applied: -1,
+	}
+
+	if jiraConfig.Key == "" {
+		explanation.Skipped = "the repository does not have a Jira project"
+		return explanation, nil
+	}
+	key := issueKey(pr.GetTitle())
+	explanation.IssueKey = key
+	if !strings.HasPrefix(key, jiraConfig.Key+"-") {
+		explanation.Skipped = "the pull request title does not have an issue from the " + jiraConfig.Key + " project"
+		return explanation, nil
+	}
+
+	issue, _, err := c.jiraClient.Issue.GetWithContext(ctx, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Jira issue %s: %w", key, err)
+	}
+	explanation.Issue = issue
+
+	explanation.FixVersion, explanation.FixVersionDerivation, err = c.NextFixVersion(ctx, owner, repo, jiraConfig, branchConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if jiraConfig.UsesAllPullRequestsMerged() {
+		explanation.AllPullRequestsMerged, err = c.allPullRequestsMerged(ctx, owner, issue.Key, pr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, rule := range jiraConfig.Rules {
+		mismatch := conditionMismatch(event, issue, pr, explanation.FixVersion, explanation.AllPullRequestsMerged, jiraConfig, rule.When)
+		explanation.Rules = append(explanation.Rules, RuleEvaluation{
+			Rule:     rule,
+			Mismatch: mismatch,
+		})
+		if mismatch == "" && explanation.Applied == -1 {
+			explanation.Applied = i
+		}
+	}
+	if explanation.Applied == -1 {
+		return explanation, nil
+	}
+
+	explanation.Actions, err = c.ruleActions(ctx, issue, pr, explanation.FixVersion, jiraConfig, jiraConfig.Rules[explanation.Applied])
+	if err != nil {
+		return nil, err
+	}
+	return explanation, nil
+}
+
+// ruleActions describes what applyRule would change.
+func (c *Jira) ruleActions(ctx context.Context, issue *jira.Issue, pr *github.PullRequest, fixVersion string, jiraConfig configuration.Jira, rule configuration.JiraRule) ([]string, error) {
+	comment, err := c.renderComment(issue, pr, jiraConfig, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []string
+	if rule.SetFixVersion && fixVersion != "" && !hasFixVersion(issue, fixVersion) {
+		actions = append(actions, "add the fix version "+fixVersion)
+	}
+	if rule.RemoveFixVersion && fixVersion != "" && hasFixVersion(issue, fixVersion) {
+		actions = append(actions, "remove the fix version "+fixVersion)
+	}
+	if comment != "" {
+		actions = append(actions, "add the comment:\n"+comment)
+	}
+	if rule.SetFixVersion && fixVersion != "" && jiraConfig.SyncMilestones && pr.GetMilestone().GetTitle() != fixVersion {
+		actions = append(actions, "set the milestone "+fixVersion+" on the pull request")
+	}
+	if rule.TransitionTo != "" {
+		if issue.Fields.Status.Name == rule.TransitionTo {
+			actions = append(actions, "keep the status "+rule.TransitionTo)
+		} else {
+			transitions, _, err := c.jiraClient.Issue.GetTransitionsWithContext(ctx, issue.Key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get transitions for %s: %w", issue.Key, err)
+			}
+			available := false
+			for _, transition := range transitions {
+				if transition.To.Name == rule.TransitionTo {
+					available = true
+				}
+			}
+			if available {
+				actions = append(actions, "transition the issue to "+rule.TransitionTo)
+			} else {
+				actions = append(actions, "skip the transition to "+rule.TransitionTo+": there is no transition to it from "+issue.Fields.Status.Name)
+			}
+		}
+	}
+	return actions, nil
+}
@@ -0,0 +1,69 @@
+package checks
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestExplain(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	fakeJira.Transitions[""] = []jira.Transition{
+		{ID: "11", Name: "Start Progress", To: jira.Status{Name: "In Progress"}},
+	}
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+
+	merged := true
+	jiraConfig := configuration.Jira{
+		Key: "PROJQUAY",
+		Rules: []configuration.JiraRule{
+			{
+				When:         configuration.JiraCondition{Merged: &merged},
+				TransitionTo: "Done",
+			},
+			{
+				When:         configuration.JiraCondition{Event: []string{"opened"}, Status: []string{"New"}},
+				TransitionTo: "In Progress",
+				Comment:      "Pull request: {{.PullRequest.HTMLURL}}",
+			},
+			{
+				TransitionTo: "ON_QA",
+			},
+		},
+	}
+	branchConfig := configuration.Branch{Name: "master", FixVersion: "quay-v3.9.0"}
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	explanation, err := c.Explain(context.Background(), EventOpened, jiraConfig, branchConfig, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Applied != 1 {
+		t.Errorf("got applied rule %d, want 1", explanation.Applied)
+	}
+	if got := explanation.Rules[0].Mismatch; got != "merged is false" {
+		t.Errorf("got mismatch %q for the first rule", got)
+	}
+	if got := explanation.Rules[2].Mismatch; got != "" {
+		t.Errorf("the last rule should match, got %q", got)
+	}
+	want := []string{
+		"add the comment:\nPull request: https://github.com/quay/quay/pull/1",
+		"transition the issue to In Progress",
+	}
+	if !reflect.DeepEqual(explanation.Actions, want) {
+		t.Errorf("got actions %q, want %q", explanation.Actions, want)
+	}
+
+	if len(fakeGitHub.CheckRuns) != 0 || len(fakeGitHub.Comments) != 0 || len(fakeJira.PerformedTransitions) != 0 || len(fakeJira.Updates) != 0 {
+		t.Errorf("Explain should not change anything")
+	}
+}
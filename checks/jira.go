@@ -3,19 +3,48 @@ package checks
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/cache"
+	"github.com/quay/quay-ci-app/clients"
 	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/graphql"
+	"github.com/quay/quay-ci-app/logctx"
+	"github.com/quay/quay-ci-app/storage"
 	"github.com/quay/quay-ci-app/taginformer"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 )
 
+// ErrJiraUnavailable is returned by Run when the check couldn't be completed
+// because Jira is not reachable. The internal error has already been reported
+// on the pull request.
+var ErrJiraUnavailable = errors.New("jira is unavailable")
+
+// ErrJiraUpdateFailed is returned by Run when the check was reported, but the
+// Jira issue couldn't be updated, e.g. the transition of a rule failed. The
+// event should be handled again later.
+var ErrJiraUpdateFailed = errors.New("failed to update the Jira issue")
+
+// JiraCheckName is the name that is used to refer to the Jira check in the
+// configuration.
+const JiraCheckName = configuration.CheckJira
+
+// TitleCheckRunName is the name of the check run that is reported by the Jira
+// check.
+const TitleCheckRunName = "Pull Request Title"
+
 type Event string
 
 const (
@@ -30,6 +59,20 @@ var titleJiraRegex = regexp.MustCompile(` \(([A-Z]+-[0-9]+)\)$`)
 
 const internalErrorMarker = "<!-- quay-ci-app: jira internal error -->"
 
+const (
+	maxCachedIssues       = 1000
+	maxCachedProjects     = 100
+	maxCachedPullRequests = 5000
+)
+
+func issueKey(title string) string {
+	matches := titleJiraRegex.FindStringSubmatch(title)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[1]
+}
+
 func contains(list []string, str string) bool {
 	for _, v := range list {
 		if v == str {
@@ -39,21 +82,87 @@ func contains(list []string, str string) bool {
 	return false
 }
 
-func matchCondition(event Event, issue *jira.Issue, pr *github.PullRequest, fixVersion string, cond configuration.JiraCondition) bool {
+func hasCustomField(issue *jira.Issue, field string) bool {
+	if issue.Fields == nil || issue.Fields.Unknowns == nil {
+		return false
+	}
+	value, ok := issue.Fields.Unknowns[field]
+	if !ok || value == nil {
+		return false
+	}
+	if str, ok := value.(string); ok && str == "" {
+		return false
+	}
+	return true
+}
+
+func securityLevel(issue *jira.Issue) string {
+	if issue.Fields == nil || issue.Fields.Unknowns == nil {
+		return ""
+	}
+	security, ok := issue.Fields.Unknowns["security"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := security["name"].(string)
+	if name == "" {
+		// The security level is set, but we don't know its name.
+		return "unknown"
+	}
+	return name
+}
+
+// inStream returns true if one of the version names belongs to the y-stream
+// xy. Version names are expected to be in the format <prefix><x>.<y>[.<z>].
+func inStream(names []string, prefix, xy string) bool {
+	for _, v := range names {
+		name := strings.TrimPrefix(v, prefix)
+		if name == xy || strings.HasPrefix(name, xy+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func fixVersionNames(issue *jira.Issue) []string {
+	var names []string
+	for _, v := range issue.Fields.FixVersions {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// affectsStream returns true if one of the issue's Affects Version/s belongs to
+// the y-stream xy.
+func affectsStream(issue *jira.Issue, prefix, xy string) bool {
+	var names []string
+	for _, v := range issue.Fields.AffectsVersions {
+		names = append(names, v.Name)
+	}
+	return inStream(names, prefix, xy)
+}
+
+func matchCondition(event Event, issue *jira.Issue, pr *github.PullRequest, fixVersion string, allMerged bool, jiraConfig configuration.Jira, cond configuration.JiraCondition) bool {
+	return conditionMismatch(event, issue, pr, fixVersion, allMerged, jiraConfig, cond) == ""
+}
+
+// conditionMismatch describes the first part of cond that doesn't match, or
+// returns an empty string if cond matches.
+func conditionMismatch(event Event, issue *jira.Issue, pr *github.PullRequest, fixVersion string, allMerged bool, jiraConfig configuration.Jira, cond configuration.JiraCondition) string {
 	if len(cond.Status) > 0 {
 		if !contains(cond.Status, issue.Fields.Status.Name) {
-			return false
+			return fmt.Sprintf("the issue status is %s, not %s", issue.Fields.Status.Name, strings.Join(cond.Status, " or "))
 		}
 	}
 	if cond.Merged != nil {
 		merged := !pr.GetMergedAt().IsZero()
 		if merged != *cond.Merged {
-			return false
+			return fmt.Sprintf("merged is %t", merged)
 		}
 	}
 	if cond.HasFixVersion != nil {
 		if fixVersion == "" {
-			return false
+			return "the branch does not have a fix version"
 		}
 		hasFixVersion := false
 		for _, v := range issue.Fields.FixVersions {
@@ -63,36 +172,125 @@ func matchCondition(event Event, issue *jira.Issue, pr *github.PullRequest, fixV
 			}
 		}
 		if hasFixVersion != *cond.HasFixVersion {
-			return false
+			return fmt.Sprintf("has_fix_version is %t for %s", hasFixVersion, fixVersion)
+		}
+	}
+	if cond.HasQAContact != nil {
+		hasQAContact := hasCustomField(issue, jiraConfig.QAContactFieldOrDefault())
+		if hasQAContact != *cond.HasQAContact {
+			return fmt.Sprintf("has_qa_contact is %t", hasQAContact)
+		}
+	}
+	if cond.AllPullRequestsMerged != nil {
+		if allMerged != *cond.AllPullRequestsMerged {
+			return fmt.Sprintf("all_pull_requests_merged is %t", allMerged)
+		}
+	}
+	if cond.UpdatedWithin != nil || cond.NotUpdatedFor != nil {
+		sinceUpdate := time.Since(time.Time(issue.Fields.Updated))
+		if cond.UpdatedWithin != nil && sinceUpdate > cond.UpdatedWithin.Duration {
+			return fmt.Sprintf("the issue was updated %s ago, not within %s", sinceUpdate.Round(time.Second), cond.UpdatedWithin.Duration)
+		}
+		if cond.NotUpdatedFor != nil && sinceUpdate < cond.NotUpdatedFor.Duration {
+			return fmt.Sprintf("the issue was updated %s ago, less than %s", sinceUpdate.Round(time.Second), cond.NotUpdatedFor.Duration)
 		}
 	}
 	if len(cond.Event) != 0 && !contains(cond.Event, string(event)) {
-		return false
+		return fmt.Sprintf("the event is %s, not %s", event, strings.Join(cond.Event, " or "))
 	}
-	return true
+	return ""
 }
 
 type Jira struct {
-	githubClient    *github.Client
-	appGithubClient *github.Client
-	jiraClient      *jira.Client
+	githubClient    *clients.GitHub
+	appGithubClient *clients.GitHub
+	jiraClient      *clients.Jira
 	tagInformer     *taginformer.TagInformer
 
+	// fixVersionStatus is called with a message describing a problem with
+	// the branch fix version, or with an empty message if there is none.
+	fixVersionStatus func(branch, message string)
+
+	issueCache      *cache.Cache
+	issueCacheTTL   time.Duration
+	projectCache    *cache.Cache
+	projectCacheTTL time.Duration
+
+	// prefetched holds the pull requests fetched with GraphQL, see Prefetch.
+	prefetched *cache.Cache
+
+	activity *activity.Recorder
+
+	// ruleInputs remembers the inputs of the last rule application for each
+	// pull request, see rulesInputChanged.
+	ruleInputs *cache.Cache
+
+	// setFixVersions remembers the fix versions that the rules set on the
+	// issues of the pull requests, so that remove_fix_version removes the
+	// version that was set even if the computed version has changed since
+	// then. They are also kept in storage if it's set, see SetStorage.
+	setFixVersions *cache.Cache
+	storage        *storage.DB
+
+	// results are the recent results of Run, see RecentResults.
+	results  results
+	onResult func(repo string, result Result)
+
 	cachedGithubUserLogin string
 }
 
-func NewJira(githubClient *github.Client, appGithubClient *github.Client, jiraClient *jira.Client, tagInformer *taginformer.TagInformer) *Jira {
+func NewJira(githubClient *clients.GitHub, appGithubClient *clients.GitHub, jiraClient *clients.Jira, tagInformer *taginformer.TagInformer, fixVersionStatus func(branch, message string), recorder *activity.Recorder, issueCacheTTL, projectCacheTTL time.Duration) *Jira {
+	if fixVersionStatus == nil {
+		fixVersionStatus = func(branch, message string) {}
+	}
 	return &Jira{
-		githubClient:    githubClient,
-		appGithubClient: appGithubClient,
-		jiraClient:      jiraClient,
-		tagInformer:     tagInformer,
+		githubClient:     githubClient,
+		appGithubClient:  appGithubClient,
+		jiraClient:       jiraClient,
+		tagInformer:      tagInformer,
+		fixVersionStatus: fixVersionStatus,
+		activity:         recorder,
+		issueCache:       cache.New("jira-issues", maxCachedIssues, issueCacheTTL),
+		issueCacheTTL:    issueCacheTTL,
+		projectCache:     cache.New("jira-projects", maxCachedProjects, projectCacheTTL),
+		projectCacheTTL:  projectCacheTTL,
+		prefetched:       cache.New("jira-prefetched-pull-requests", maxCachedPullRequests, time.Minute),
+		ruleInputs:       cache.New("jira-rule-inputs", maxCachedPullRequests, 0),
+		setFixVersions:   cache.New("jira-set-fix-versions", maxCachedPullRequests, 0),
 	}
 }
 
-func (c *Jira) githubUserLogin() (string, error) {
+// SetStorage makes the check remember the fix versions that the rules set in
+// db, so that they can be removed after a restart.
+func (c *Jira) SetStorage(db *storage.DB) {
+	c.storage = db
+}
+
+// getIssue returns the Jira issue from the cache or fetches it from Jira. The
+// response is nil if the issue is served from the cache.
+func (c *Jira) getIssue(ctx context.Context, key string) (*jira.Issue, *jira.Response, error) {
+	if issue, ok := c.issueCache.Get(key); ok {
+		return issue.(*jira.Issue), nil, nil
+	}
+	issue, resp, err := c.jiraClient.Issue.GetWithContext(ctx, key, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+	if c.issueCacheTTL > 0 {
+		c.issueCache.Add(key, issue)
+	}
+	return issue, resp, nil
+}
+
+// InvalidateIssue drops the cached Jira issue, so that the next check gets
+// its changes.
+func (c *Jira) InvalidateIssue(key string) {
+	c.issueCache.Remove(key)
+}
+
+func (c *Jira) githubUserLogin(ctx context.Context) (string, error) {
 	if c.cachedGithubUserLogin == "" {
-		app, _, err := c.appGithubClient.Apps.Get(context.Background(), "")
+		app, _, err := c.appGithubClient.Apps.Get(ctx, "")
 		if err != nil {
 			return "", fmt.Errorf("failed to get current app: %w", err)
 		}
@@ -101,41 +299,122 @@ func (c *Jira) githubUserLogin() (string, error) {
 	return c.cachedGithubUserLogin, nil
 }
 
-func (c *Jira) reportTitleResult(ctx context.Context, owner, repo, headSHA string, number int, conclusion string, output *github.CheckRunOutput) error {
-	klog.V(4).Infof("reporting Pull Request Title result on %s/%s#%d: %s: %s", owner, repo, number, conclusion, output.GetTitle())
+func prefetchKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// Prefetch stores the comments and the check runs of the pull request that
+// were fetched with GraphQL, so that the next Run for this pull request
+// doesn't have to fetch them again.
+func (c *Jira) Prefetch(pr *graphql.PullRequest) {
+	owner := pr.PullRequest.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.PullRequest.GetBase().GetRepo().GetName()
+	c.prefetched.Add(prefetchKey(owner, repo, pr.PullRequest.GetNumber()), pr)
+}
+
+func (c *Jira) takePrefetched(owner, repo string, number int) *graphql.PullRequest {
+	key := prefetchKey(owner, repo, number)
+	pr, ok := c.prefetched.Get(key)
+	if !ok {
+		return nil
+	}
+	c.prefetched.Remove(key)
+	return pr.(*graphql.PullRequest)
+}
+
+// latestCheckRun returns the most recent check run with the given name that
+// was created by the app for headSHA.
+func (c *Jira) latestCheckRun(ctx context.Context, pr *graphql.PullRequest, headSHA, name string) *github.CheckRun {
+	userLogin, err := c.githubUserLogin(ctx)
+	if err != nil {
+		return nil
+	}
+	var latest *github.CheckRun
+	for _, checkRun := range pr.CheckRuns {
+		if checkRun.GetName() != name || checkRun.GetHeadSHA() != headSHA || checkRun.GetApp().GetSlug()+"[bot]" != userLogin {
+			continue
+		}
+		if latest == nil || checkRun.GetID() > latest.GetID() {
+			latest = checkRun
+		}
+	}
+	return latest
+}
+
+// reportTitleResult reports the result of the check. The activity event is
+// restricted if the Jira issue of the pull request is.
+func (c *Jira) reportTitleResult(ctx context.Context, owner, repo, headSHA string, number int, conclusion string, output *github.CheckRunOutput, restricted bool) error {
+	klog.V(4).Infof("%sreporting Pull Request Title result on %s/%s#%d: %s: %s", logctx.Prefix(ctx), owner, repo, number, conclusion, output.GetTitle())
+
+	var comments []*github.IssueComment
+	if prefetched := c.takePrefetched(owner, repo, number); prefetched != nil {
+		comments = prefetched.Comments
+	}
 
 	checkRun, _, err := c.githubClient.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
-		Name:       "Pull Request Title",
+		Name:       TitleCheckRunName,
 		HeadSHA:    headSHA,
 		Status:     github.String("completed"),
 		Conclusion: github.String(conclusion),
 		Output:     output,
 	})
 
-	cleanupErr := c.deleteOldComments(ctx, owner, repo, number, checkRun.GetCompletedAt().Time, internalErrorMarker)
+	if err == nil {
+		c.activity.Record(owner, repo, activity.Event{
+			Type:        activity.TypeCheck,
+			PullRequest: number,
+			Summary:     "Pull Request Title: " + conclusion + ": " + output.GetTitle(),
+			Restricted:  restricted,
+		})
+	}
+
+	cleanupErr := c.deleteOldComments(ctx, owner, repo, number, comments, checkRun.GetCompletedAt().Time, internalErrorMarker)
 	if cleanupErr != nil {
-		klog.V(2).Infof("failed to delete old comments on %s/%s#%d: %v", owner, repo, number, cleanupErr)
+		klog.V(2).Infof("%sfailed to delete old comments on %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, number, cleanupErr)
 	}
 
 	return err
 }
 
-func (c *Jira) deleteOldComments(ctx context.Context, owner, repo string, number int, createdBefore time.Time, marker string) error {
-	userLogin, err := c.githubUserLogin()
+func (c *Jira) listComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	var comments []*github.IssueComment
+	opts := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		page, resp, err := c.githubClient.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list comments on pull request %s/%s#%d: %w", owner, repo, number, err)
+		}
+		comments = append(comments, page...)
+		if resp.NextPage == 0 {
+			return comments, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// deleteOldComments deletes the comments of the app that have marker and were
+// created before createdBefore. If comments is nil, the comments are fetched
+// from GitHub.
+func (c *Jira) deleteOldComments(ctx context.Context, owner, repo string, number int, comments []*github.IssueComment, createdBefore time.Time, marker string) error {
+	userLogin, err := c.githubUserLogin(ctx)
 	if err != nil {
 		return err
 	}
 
-	comments, _, err := c.githubClient.Issues.ListComments(ctx, owner, repo, number, nil)
-	if err != nil {
-		return fmt.Errorf("failed to list comments on pull request %s/%s#%d: %w", owner, repo, number, err)
+	if comments == nil {
+		comments, err = c.listComments(ctx, owner, repo, number)
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, comm := range comments {
 		if comm.GetUser().GetLogin() == userLogin && comm.GetCreatedAt().Before(createdBefore) && strings.Contains(comm.GetBody(), marker) {
 			_, err = c.githubClient.Issues.DeleteComment(ctx, owner, repo, comm.GetID())
 			if err != nil {
-				klog.V(2).Infof("failed to delete comment %s/%s#%d:%d: %v", owner, repo, number, comm.GetID(), err)
+				klog.V(2).Infof("%sfailed to delete comment %s/%s#%d:%d: %v", logctx.Prefix(ctx), owner, repo, number, comm.GetID(), err)
 			}
 		}
 	}
@@ -144,37 +423,48 @@ func (c *Jira) deleteOldComments(ctx context.Context, owner, repo string, number
 }
 
 func (c *Jira) reportInternalError(ctx context.Context, owner, repo, headSHA string, number int, msg string) error {
-	klog.V(4).Infof("reporting internal error on %s/%s#%d: %s", owner, repo, number, msg)
+	klog.V(4).Infof("%sreporting internal error on %s/%s#%d: %s", logctx.Prefix(ctx), owner, repo, number, msg)
 
-	_, _, _ = c.githubClient.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
-		Name:    "Pull Request Title",
-		HeadSHA: headSHA,
-		Status:  github.String("queued"),
-	})
+	prefetched := c.takePrefetched(owner, repo, number)
+	if prefetched == nil || c.latestCheckRun(ctx, prefetched, headSHA, TitleCheckRunName).GetStatus() != "queued" {
+		_, _, _ = c.githubClient.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+			Name:    TitleCheckRunName,
+			HeadSHA: headSHA,
+			Status:  github.String("queued"),
+		})
+	}
 	comment, _, err := c.githubClient.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{
 		Body: github.String(msg + "\n" + internalErrorMarker + "\n"),
 	})
 	if err == nil {
 		c.cachedGithubUserLogin = comment.GetUser().GetLogin()
 
-		err = c.deleteOldComments(ctx, owner, repo, number, comment.GetCreatedAt(), internalErrorMarker)
+		var comments []*github.IssueComment
+		if prefetched != nil {
+			comments = prefetched.Comments
+		}
+		err = c.deleteOldComments(ctx, owner, repo, number, comments, comment.GetCreatedAt(), internalErrorMarker)
 		if err != nil {
-			klog.V(2).Infof("failed to delete old comments on %s/%s#%d: %v", owner, repo, number, err)
+			klog.V(2).Infof("%sfailed to delete old comments on %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, number, err)
 		}
 	}
 	return err
 }
 
 func (c *Jira) transitionTo(ctx context.Context, issue *jira.Issue, desiredStatus string) error {
-	klog.V(4).Infof("transitioning issue %s from %s to %s...", issue.Key, issue.Fields.Status.Name, desiredStatus)
-
-	transitions, _, err := c.jiraClient.Issue.GetTransitions(issue.Key)
+	transitions, _, err := c.jiraClient.Issue.GetTransitionsWithContext(ctx, issue.Key)
 	if err != nil {
 		return fmt.Errorf("failed to get transitions for issue %s: %w", issue.Key, err)
 	}
+	return c.doTransition(ctx, issue, transitions, desiredStatus)
+}
+
+func (c *Jira) doTransition(ctx context.Context, issue *jira.Issue, transitions []jira.Transition, desiredStatus string) error {
+	klog.V(4).Infof("%stransitioning issue %s from %s to %s...", logctx.Prefix(ctx), issue.Key, issue.Fields.Status.Name, desiredStatus)
+
 	for _, transition := range transitions {
 		if transition.To.Name == desiredStatus {
-			_, err = c.jiraClient.Issue.DoTransitionWithContext(ctx, issue.Key, transition.ID)
+			_, err := c.jiraClient.Issue.DoTransitionWithContext(ctx, issue.Key, transition.ID)
 			if err != nil {
 				return fmt.Errorf("failed to transition issue %s with transition %s: %w", issue.Key, transition.Name, err)
 			}
@@ -185,86 +475,533 @@ func (c *Jira) transitionTo(ctx context.Context, issue *jira.Issue, desiredStatu
 	return nil
 }
 
-func (c *Jira) setFixVersion(ctx context.Context, issue *jira.Issue, fixVersion string) error {
-	for _, version := range issue.Fields.FixVersions {
+// ensureFixVersion checks that fixVersion exists in the Jira project and
+// creates it if the project is configured to do so. It returns false if the
+// version does not exist and was not created.
+func (c *Jira) ensureFixVersion(ctx context.Context, jiraConfig configuration.Jira, fixVersion string) (bool, error) {
+	project, err := c.project(ctx, jiraConfig.Key)
+	if err != nil {
+		return false, err
+	}
+	for _, version := range project.Versions {
 		if version.Name == fixVersion {
-			return nil
+			return true, nil
 		}
 	}
 
-	_, err := c.jiraClient.Issue.UpdateIssueWithContext(ctx, issue.Key, map[string]interface{}{
-		"update": map[string]interface{}{
-			"fixVersions": []map[string]interface{}{
-				{
-					"add": map[string]interface{}{
-						"name": fixVersion,
-					},
-				},
-			},
-		},
+	if !jiraConfig.CreateFixVersions {
+		return false, nil
+	}
+
+	projectID, err := strconv.Atoi(project.ID)
+	if err != nil {
+		return false, fmt.Errorf("unexpected id %q for Jira project %s: %w", project.ID, jiraConfig.Key, err)
+	}
+	klog.V(2).Infof("%screating version %s in Jira project %s...", logctx.Prefix(ctx), fixVersion, jiraConfig.Key)
+	_, _, err = c.jiraClient.Version.CreateWithContext(ctx, &jira.Version{
+		Name:      fixVersion,
+		ProjectID: projectID,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to set fix version %s for issue %s: %w", fixVersion, issue.Key, err)
+		return false, fmt.Errorf("failed to create version %s in Jira project %s: %w", fixVersion, jiraConfig.Key, err)
 	}
-
-	return nil
+	c.projectCache.Remove(jiraConfig.Key)
+	return true, nil
 }
 
-func (c *Jira) applyRule(ctx context.Context, issue *jira.Issue, pr *github.PullRequest, fixVersion string, rule configuration.JiraRule) error {
-	if rule.SetFixVersion && fixVersion != "" {
-		err := c.setFixVersion(ctx, issue, fixVersion)
+// ReleaseVersion marks the Jira version prefix+xy.z as released and creates
+// the next patch version. It is called when the corresponding tag is pushed.
+func (c *Jira) ReleaseVersion(ctx context.Context, jiraConfig configuration.Jira, xy string, z int) error {
+	// The release bookkeeping needs fresh data.
+	c.projectCache.Remove(jiraConfig.Key)
+	defer c.projectCache.Remove(jiraConfig.Key)
+
+	project, err := c.project(ctx, jiraConfig.Key)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s%s.%d", jiraConfig.FixVersionPrefix, xy, z)
+	nextName := fmt.Sprintf("%s%s.%d", jiraConfig.FixVersionPrefix, xy, z+1)
+	nextExists := false
+	for _, version := range project.Versions {
+		if version.Name == nextName {
+			nextExists = true
+		}
+		if version.Name != name || version.Released != nil && *version.Released {
+			continue
+		}
+		klog.V(2).Infof("%sreleasing version %s in Jira project %s...", logctx.Prefix(ctx), name, jiraConfig.Key)
+		released := true
+		_, _, err := c.jiraClient.Version.UpdateWithContext(ctx, &jira.Version{
+			ID:          version.ID,
+			Released:    &released,
+			ReleaseDate: time.Now().UTC().Format("2006-01-02"),
+		})
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to release version %s in Jira project %s: %w", name, jiraConfig.Key, err)
 		}
 	}
 
-	if rule.Comment != "" {
-		commentTemplate, err := template.New("comment").Parse(rule.Comment)
+	if !nextExists {
+		projectID, err := strconv.Atoi(project.ID)
 		if err != nil {
-			return fmt.Errorf("failed to parse comment template: %w", err)
+			return fmt.Errorf("unexpected id %q for Jira project %s: %w", project.ID, jiraConfig.Key, err)
 		}
-		var commentBuffer bytes.Buffer
-		err = commentTemplate.Execute(&commentBuffer, struct {
-			PullRequest *github.PullRequest
-		}{
-			PullRequest: pr,
+		klog.V(2).Infof("%screating version %s in Jira project %s...", logctx.Prefix(ctx), nextName, jiraConfig.Key)
+		_, _, err = c.jiraClient.Version.CreateWithContext(ctx, &jira.Version{
+			Name:      nextName,
+			ProjectID: projectID,
 		})
 		if err != nil {
-			return fmt.Errorf("failed to execute comment template: %w", err)
+			return fmt.Errorf("failed to create version %s in Jira project %s: %w", nextName, jiraConfig.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func missingFixVersionOutput(jiraConfig configuration.Jira, branchConfig configuration.Branch, fixVersion string) *github.CheckRunOutput {
+	contact := "the Jira project administrators"
+	if jiraConfig.VersionContact != "" {
+		contact = jiraConfig.VersionContact
+	}
+	return &github.CheckRunOutput{
+		Title: github.String("Jira version " + fixVersion + " does not exist"),
+		Summary: github.String("The fix version `" + fixVersion + "` for the branch `" + branchConfig.Name + "` does not exist in the Jira project " + jiraConfig.Key + ".\n" +
+			"\nPlease contact " + contact + " to create it, and then retry the check by commenting `/recheck` on the pull request.\n"),
+	}
+}
+
+// OpenPullRequests returns the open pull requests that are owned by owner and
+// reference the Jira issue key in their titles.
+func (c *Jira) OpenPullRequests(ctx context.Context, owner, key string) ([]*github.Issue, error) {
+	var open []*github.Issue
+	query := fmt.Sprintf("is:pr is:open in:title user:%s %q", owner, key)
+	opts := &github.SearchOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		result, resp, err := c.githubClient.Search.Issues(ctx, query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search pull requests for %s: %w", key, err)
+		}
+		for _, issue := range result.Issues {
+			if issueKey(issue.GetTitle()) == key {
+				open = append(open, issue)
+			}
+		}
+		if resp.NextPage == 0 {
+			return open, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// otherOpenPullRequests returns the open pull requests other than pr that are
+// owned by owner and reference the Jira issue key in their titles.
+func (c *Jira) otherOpenPullRequests(ctx context.Context, owner, key string, pr *github.PullRequest) ([]string, error) {
+	issues, err := c.OpenPullRequests(ctx, owner, key)
+	if err != nil {
+		return nil, err
+	}
+	var open []string
+	for _, issue := range issues {
+		if issue.GetHTMLURL() != pr.GetHTMLURL() {
+			open = append(open, issue.GetHTMLURL())
+		}
+	}
+	return open, nil
+}
+
+// allPullRequestsMerged returns true if pr is merged and there are no other
+// open pull requests for the Jira issue key.
+func (c *Jira) allPullRequestsMerged(ctx context.Context, owner, key string, pr *github.PullRequest) (bool, error) {
+	if pr.GetMergedAt().IsZero() {
+		return false, nil
+	}
+	open, err := c.otherOpenPullRequests(ctx, owner, key, pr)
+	if err != nil {
+		return false, err
+	}
+	if len(open) > 0 {
+		klog.V(4).Infof("%sissue %s has open pull requests: %s", logctx.Prefix(ctx), key, strings.Join(open, ", "))
+	}
+	return len(open) == 0, nil
+}
+
+// reopen transitions the issue to status (New by default) and returns the
+// updated issue.
+func (c *Jira) reopen(ctx context.Context, issue *jira.Issue, status string) (*jira.Issue, error) {
+	if status == "" {
+		status = "New"
+	}
+	if err := c.transitionTo(ctx, issue, status); err != nil {
+		return nil, err
+	}
+	c.issueCache.Remove(issue.Key)
+	reopened, _, err := c.jiraClient.Issue.GetWithContext(ctx, issue.Key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Jira issue %s: %w", issue.Key, err)
+	}
+	return reopened, nil
+}
+
+func hasFixVersion(issue *jira.Issue, fixVersion string) bool {
+	for _, version := range issue.Fields.FixVersions {
+		if version.Name == fixVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func fixVersionKey(pr *github.PullRequest, issueKey string) string {
+	return fmt.Sprintf("%s#%d %s", pr.GetBase().GetRepo().GetFullName(), pr.GetNumber(), issueKey)
+}
+
+func storagePullRequest(pr *github.PullRequest) storage.PullRequest {
+	return storage.PullRequest{
+		Owner:  pr.GetBase().GetRepo().GetOwner().GetLogin(),
+		Repo:   pr.GetBase().GetRepo().GetName(),
+		Number: pr.GetNumber(),
+	}
+}
+
+// recordFixVersion remembers that a rule set version on the issue of the pull
+// request.
+func (c *Jira) recordFixVersion(pr *github.PullRequest, issueKey, version string) {
+	c.setFixVersions.Add(fixVersionKey(pr, issueKey), version)
+	if c.storage != nil {
+		if err := c.storage.SaveFixVersion(storagePullRequest(pr), issueKey, version); err != nil {
+			klog.Errorf("failed to save the fix version %s of %s: %v", version, issueKey, err)
+		}
+	}
+}
+
+// recordedFixVersion returns the fix version that a rule set on the issue of
+// the pull request, or an empty string if no rule did.
+func (c *Jira) recordedFixVersion(pr *github.PullRequest, issueKey string) (string, error) {
+	if version, ok := c.setFixVersions.Get(fixVersionKey(pr, issueKey)); ok {
+		return version.(string), nil
+	}
+	if c.storage == nil {
+		return "", nil
+	}
+	version, err := c.storage.FixVersion(storagePullRequest(pr), issueKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the fix version of %s: %w", issueKey, err)
+	}
+	return version, nil
+}
+
+func (c *Jira) forgetFixVersion(pr *github.PullRequest, issueKey string) {
+	c.setFixVersions.Remove(fixVersionKey(pr, issueKey))
+	if c.storage != nil {
+		if err := c.storage.DeleteFixVersion(storagePullRequest(pr), issueKey); err != nil {
+			klog.Errorf("failed to delete the fix version of %s: %v", issueKey, err)
+		}
+	}
+}
+
+// issueUpdate returns the update of the issue for the rule: fixVersion is
+// added with set_fix_version, and setVersion, the version that a rule set
+// before, is removed with remove_fix_version.
+func issueUpdate(issue *jira.Issue, rule configuration.JiraRule, fixVersion, setVersion string, comment string) map[string]interface{} {
+	update := map[string]interface{}{}
+
+	var fixVersions []map[string]interface{}
+	if rule.SetFixVersion && fixVersion != "" && !hasFixVersion(issue, fixVersion) {
+		fixVersions = append(fixVersions, map[string]interface{}{
+			"add": map[string]interface{}{
+				"name": fixVersion,
+			},
+		})
+	}
+	if rule.RemoveFixVersion && setVersion != "" && hasFixVersion(issue, setVersion) {
+		fixVersions = append(fixVersions, map[string]interface{}{
+			"remove": map[string]interface{}{
+				"name": setVersion,
+			},
+		})
+	}
+	if len(fixVersions) > 0 {
+		update["fixVersions"] = fixVersions
+	}
+
+	if comment != "" {
+		update["comment"] = []map[string]interface{}{
+			{
+				"add": map[string]interface{}{
+					"body": comment,
+				},
+			},
 		}
-		_, _, err = c.jiraClient.Issue.AddComment(issue.Key, &jira.Comment{
-			Body: commentBuffer.String(),
+	}
+
+	if len(update) == 0 {
+		return nil
+	}
+	return update
+}
+
+func (c *Jira) renderComment(issue *jira.Issue, pr *github.PullRequest, jiraConfig configuration.Jira, rule configuration.JiraRule) (string, error) {
+	if rule.Comment == "" {
+		return "", nil
+	}
+	if level := securityLevel(issue); level != "" && !jiraConfig.SecurityLevel.AllowComments {
+		klog.V(4).Infof("not adding comment to issue %s: the issue has security level %s", issue.Key, level)
+		return "", nil
+	}
+
+	commentTemplate, err := template.New("comment").Parse(rule.Comment)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse comment template: %w", err)
+	}
+	var commentBuffer bytes.Buffer
+	err = commentTemplate.Execute(&commentBuffer, struct {
+		PullRequest *github.PullRequest
+	}{
+		PullRequest: pr,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute comment template: %w", err)
+	}
+	return commentBuffer.String(), nil
+}
+
+func (c *Jira) recordTransition(pr *github.PullRequest, issue *jira.Issue, status string) {
+	c.activity.Record(pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName(), activity.Event{
+		Type:        activity.TypeTransition,
+		PullRequest: pr.GetNumber(),
+		Issue:       issue.Key,
+		Summary:     "Transitioned " + issue.Key + " from " + issue.Fields.Status.Name + " to " + status,
+		Restricted:  securityLevel(issue) != "",
+	})
+}
+
+func (c *Jira) applyRule(ctx context.Context, issue *jira.Issue, pr *github.PullRequest, fixVersion string, jiraConfig configuration.Jira, rule configuration.JiraRule) error {
+	// The rule is going to modify the issue, so the cached copy is stale.
+	defer c.issueCache.Remove(issue.Key)
+
+	comment, err := c.renderComment(issue, pr, jiraConfig, rule)
+	if err != nil {
+		return err
+	}
+
+	// The available transitions are fetched while the issue is being
+	// updated, the transition itself is done after the update.
+	var transitions []jira.Transition
+	var transitionsErr error
+	var wg sync.WaitGroup
+	if rule.TransitionTo != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transitions, _, transitionsErr = c.jiraClient.Issue.GetTransitionsWithContext(ctx, issue.Key)
+		}()
+	}
+
+	var setVersion string
+	var updateErr error
+	if rule.RemoveFixVersion {
+		setVersion, updateErr = c.recordedFixVersion(pr, issue.Key)
+	}
+	addsVersion := rule.SetFixVersion && fixVersion != "" && !hasFixVersion(issue, fixVersion)
+	if update := issueUpdate(issue, rule, fixVersion, setVersion, comment); update != nil && updateErr == nil {
+		_, updateErr = c.jiraClient.Issue.UpdateIssueWithContext(ctx, issue.Key, map[string]interface{}{
+			"update": update,
 		})
+		if updateErr != nil {
+			updateErr = fmt.Errorf("failed to update issue %s: %w", issue.Key, updateErr)
+		}
+	}
+
+	wg.Wait()
+
+	if updateErr != nil {
+		return updateErr
+	}
+	if addsVersion {
+		c.recordFixVersion(pr, issue.Key, fixVersion)
+	}
+	if setVersion != "" {
+		c.forgetFixVersion(pr, issue.Key)
+	}
+
+	// The milestone is only a mirror of the fix version, so its failure
+	// doesn't prevent the transition.
+	var errs []error
+	if rule.SetFixVersion && fixVersion != "" && jiraConfig.SyncMilestones {
+		if err := c.setMilestone(ctx, pr, fixVersion); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if rule.TransitionTo != "" {
+		if transitionsErr != nil {
+			errs = append(errs, fmt.Errorf("failed to transition Jira issue %s to %s: failed to get transitions: %v", issue.Key, rule.TransitionTo, transitionsErr))
+		} else if err := c.doTransition(ctx, issue, transitions, rule.TransitionTo); err != nil {
+			errs = append(errs, fmt.Errorf("failed to transition Jira issue %s to %s: %v", issue.Key, rule.TransitionTo, err))
+		} else {
+			c.recordTransition(pr, issue, rule.TransitionTo)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c *Jira) fixVersion(ctx context.Context, owner, repo string, jiraConfig configuration.Jira, branchConfig configuration.Branch) (string, error) {
+	fixVersion, _, err := c.NextFixVersion(ctx, owner, repo, jiraConfig, branchConfig)
+	return fixVersion, err
+}
+
+// NextFixVersion computes the fix version for pull requests against the
+// branch and describes how it was derived.
+func (c *Jira) NextFixVersion(ctx context.Context, owner, repo string, jiraConfig configuration.Jira, branchConfig configuration.Branch) (string, string, error) {
+	if branchConfig.FixVersion != "" {
+		return branchConfig.FixVersion, "the fix version is set in the branch configuration", nil
+	}
+	if branchConfig.Version == "" {
+		return "", "the branch does not have a version", nil
+	}
+	z, err := c.tagInformer.NextPatchVersion(owner, repo, branchConfig.Version)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get next version for %s/%s:%s: %w", owner, repo, branchConfig.Name, err)
+	}
+	derivation := fmt.Sprintf("the next patch version after the %s tags is %d", branchConfig.Version, z)
+	if jiraConfig.ReconcileVersions {
+		project, err := c.project(ctx, jiraConfig.Key)
 		if err != nil {
-			return fmt.Errorf("failed to add comment to issue %s: %w", issue.Key, err)
+			return "", "", err
+		}
+		if unreleased := nextUnreleasedVersion(project.Versions, jiraConfig.FixVersionPrefix, branchConfig.Version, z); unreleased != z {
+			derivation += fmt.Sprintf(", versions up to %d are already released in Jira", unreleased-1)
+			z = unreleased
 		}
 	}
+	return fmt.Sprintf("%s%s.%d", jiraConfig.FixVersionPrefix, branchConfig.Version, z), derivation, nil
+}
+
+// nextUnreleasedVersion returns the first patch version starting from z that
+// is not released in Jira. A version can be released in Jira before its tag
+// is pushed, and the pull requests should not target it anymore.
+func nextUnreleasedVersion(versions []jira.Version, prefix, xy string, z int) int {
+	released := map[string]bool{}
+	for _, version := range versions {
+		if version.Released != nil && *version.Released {
+			released[version.Name] = true
+		}
+	}
+	for released[fmt.Sprintf("%s%s.%d", prefix, xy, z)] {
+		z++
+	}
+	return z
+}
+
+// ReportMuted reports the check as neutral because it's muted for the
+// repository. The mute only silences the pull request: when it is closed, the
+// rules still transition its issue, but without their comments.
+func (c *Jira) ReportMuted(ctx context.Context, event Event, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest, mute configuration.CheckMute) error {
+	if jiraConfig.Key == "" {
+		return nil
+	}
+
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	err := c.reportTitleResult(ctx, owner, repo, pr.GetHead().GetSHA(), pr.GetNumber(), "neutral", mutedOutput(owner, repo, mute), false)
+	if err != nil {
+		return err
+	}
+	if event != EventClosed {
+		return nil
+	}
+	return c.applyMutedRules(ctx, event, jiraConfig, branchConfig, pr)
+}
+
+// applyMutedRules applies the rules for the pull request of a muted
+// repository without commenting on the issue or updating the milestone and
+// the labels of the pull request.
+func (c *Jira) applyMutedRules(ctx context.Context, event Event, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest) error {
+	key := issueKey(pr.GetTitle())
+	if !strings.HasPrefix(key, jiraConfig.Key+"-") {
+		return nil
+	}
+
+	issue, resp, err := c.getIssue(ctx, key)
+	if err != nil {
+		if resp == nil || resp.StatusCode >= 500 {
+			return fmt.Errorf("%w: %v", ErrJiraUnavailable, err)
+		}
+		if resp.StatusCode == 404 {
+			return nil
+		}
+		return fmt.Errorf("failed to get Jira issue %s: %w", key, err)
+	}
 
-	err := c.transitionTo(ctx, issue, rule.TransitionTo)
+	fixVersion, err := c.fixVersion(ctx, pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName(), jiraConfig, branchConfig)
 	if err != nil {
-		return fmt.Errorf("failed to transition Jira issue %s to %s: %v", issue.Key, rule.TransitionTo, err)
+		return err
 	}
 
+	jiraConfig.SyncMilestones = false
+	rules := make([]configuration.JiraRule, len(jiraConfig.Rules))
+	for i, rule := range jiraConfig.Rules {
+		rule.Comment = ""
+		rules[i] = rule
+	}
+	jiraConfig.Rules = rules
+
+	result := Result{PullRequest: pr.GetNumber(), Event: event}
+	if err := c.applyRules(ctx, event, issue, pr, fixVersion, jiraConfig, &result); err != nil {
+		return fmt.Errorf("%w: %v", ErrJiraUpdateFailed, err)
+	}
 	return nil
 }
 
-func (c *Jira) Run(event Event, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest) error {
+// Run runs the check for the pull request and applies the Jira rules. The
+// result is kept for RecentResults. The requests to GitHub and Jira are
+// cancelled when ctx is done.
+func (c *Jira) Run(ctx context.Context, event Event, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest) error {
 	if jiraConfig.Key == "" {
 		return nil
 	}
 
-	ctx := context.Background()
+	result := Result{PullRequest: pr.GetNumber(), Event: event}
+	err := c.run(ctx, event, jiraConfig, branchConfig, pr, &result)
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	result.Time = time.Now().UTC()
+	c.results.add(pr.GetBase().GetRepo().GetFullName(), result)
+	if c.onResult != nil {
+		go c.onResult(pr.GetBase().GetRepo().GetFullName(), result)
+	}
+	return err
+}
+
+// reportResult reports the result of the check and remembers it in result.
+func (c *Jira) reportResult(ctx context.Context, result *Result, owner, repo, headSHA string, number int, conclusion string, output *github.CheckRunOutput) error {
+	result.Conclusion = conclusion
+	result.Title = output.GetTitle()
+	return c.reportTitleResult(ctx, owner, repo, headSHA, number, conclusion, output, result.Restricted)
+}
+
+// reportRunError reports the internal error and remembers it in result.
+func (c *Jira) reportRunError(ctx context.Context, result *Result, owner, repo, headSHA string, number int, msg string) error {
+	result.Error = msg
+	return c.reportInternalError(ctx, owner, repo, headSHA, number, msg)
+}
+
+func (c *Jira) run(ctx context.Context, event Event, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest, result *Result) error {
 	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
 	repo := pr.GetBase().GetRepo().GetName()
 	headSHA := pr.GetHead().GetSHA()
 
-	klog.V(4).Infof("checking pull request %s/%s#%d...", owner, repo, pr.GetNumber())
+	klog.V(4).Infof("%schecking pull request %s/%s#%d...", logctx.Prefix(ctx), owner, repo, pr.GetNumber())
 
-	matches := titleJiraRegex.FindStringSubmatch(pr.GetTitle())
-	key := ""
-	if len(matches) != 0 {
-		key = matches[1]
-	}
+	key := issueKey(pr.GetTitle())
 	if !strings.HasPrefix(key, jiraConfig.Key+"-") {
 		summary := "This check is skipped because the pull request title does not have a Jira issue in the title.\n"
 		if key != "" {
@@ -272,65 +1009,353 @@ func (c *Jira) Run(event Event, jiraConfig configuration.Jira, branchConfig conf
 		}
 		summary += "\nThe title should be in the format `Title (" + jiraConfig.Key + "-123)` and the Jira issue should be from the " + jiraConfig.Key + " project.\n"
 
-		return c.reportTitleResult(ctx, owner, repo, headSHA, pr.GetNumber(), "success", &github.CheckRunOutput{
+		if jiraConfig.StatusLabels {
+			c.setStatusLabel(ctx, pr, "", result)
+		}
+		return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "success", &github.CheckRunOutput{
 			Title:   github.String("Pull request does not have a Jira issue in the title"),
 			Summary: github.String(summary),
 		})
 	}
 
-	issue, resp, err := c.jiraClient.Issue.Get(key, nil)
+	if event == EventRecheck {
+		c.issueCache.Remove(key)
+	}
+
+	issue, resp, err := c.getIssue(ctx, key)
 	if err != nil {
-		klog.V(2).Infof("checking pull request %s/%s#%d: failed to get Jira issue %s: %v", owner, repo, pr.GetNumber(), key, err)
+		klog.V(2).Infof("%schecking pull request %s/%s#%d: failed to get Jira issue %s: %v", logctx.Prefix(ctx), owner, repo, pr.GetNumber(), key, err)
 
-		if resp == nil {
-			return c.reportInternalError(ctx, owner, repo, headSHA, pr.GetNumber(), "The Jira server is not reachable. You can retry the check by commenting `/recheck` on the pull request.")
+		if resp == nil || resp.StatusCode >= 500 {
+			err := c.reportRunError(ctx, result, owner, repo, headSHA, pr.GetNumber(), "The Jira server is not reachable. The check will be retried automatically once Jira is available again, or you can retry it by commenting `/recheck` on the pull request.")
+			if err != nil {
+				return err
+			}
+			return ErrJiraUnavailable
 		}
 		if resp.StatusCode != 404 {
-			return c.reportInternalError(ctx, owner, repo, headSHA, pr.GetNumber(), fmt.Sprintf("The Jira request failed with status code %d. You can retry the check by commenting `/recheck` on the pull request.", resp.StatusCode))
+			return c.reportRunError(ctx, result, owner, repo, headSHA, pr.GetNumber(), fmt.Sprintf("The Jira request failed with status code %d. You can retry the check by commenting `/recheck` on the pull request.", resp.StatusCode))
 		}
 
-		return c.reportTitleResult(ctx, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
+		if jiraConfig.StatusLabels {
+			c.setStatusLabel(ctx, pr, "", result)
+		}
+
+		return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
 			Title:   github.String("Jira issue " + key + " does not exist"),
 			Summary: github.String("The Jira issue `" + key + "` does not exist.\n"),
 		})
 	}
+	result.Restricted = securityLevel(issue) != ""
+
+	if jiraConfig.StatusLabels {
+		// The label is set once the check is done, after the issue may have
+		// been reopened or transitioned by a rule.
+		defer func() {
+			status := issue.Fields.Status.Name
+			if result.Rule != "" {
+				// The rule may have transitioned the issue, which is not
+				// cached anymore.
+				if updated, _, err := c.getIssue(ctx, key); err == nil {
+					status = updated.Fields.Status.Name
+				}
+			}
+			c.setStatusLabel(ctx, pr, status, result)
+		}()
+	}
 
 	if len(jiraConfig.ValidIssueTypes) > 0 {
 		issueType := issue.Fields.Type.Name
 		if !contains(jiraConfig.ValidIssueTypes, issueType) {
-			return c.reportTitleResult(ctx, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
+			return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
 				Title:   github.String("Jira issue " + key + " has an invalid issue type, expected one of " + strings.Join(jiraConfig.ValidIssueTypes, ", ")),
 				Summary: github.String("The Jira issue `" + key + "` has an invalid issue type `" + issueType + "`, expected one of " + strings.Join(jiraConfig.ValidIssueTypes, ", ") + ".\n"),
 			})
 		}
 	}
 
-	err = c.reportTitleResult(ctx, owner, repo, headSHA, pr.GetNumber(), "success", &github.CheckRunOutput{
+	if jiraConfig.CheckAffectsVersion && branchConfig.Version != "" && !affectsStream(issue, jiraConfig.FixVersionPrefix, branchConfig.Version) {
+		var affectsVersions []string
+		for _, v := range issue.Fields.AffectsVersions {
+			affectsVersions = append(affectsVersions, "`"+v.Name+"`")
+		}
+		summary := "The Jira issue `" + key + "` does not have Affects Version/s set.\n"
+		if len(affectsVersions) > 0 {
+			summary = "The Jira issue `" + key + "` affects " + strings.Join(affectsVersions, ", ") + ", but the pull request targets the branch `" + branchConfig.Name + "` (" + branchConfig.Version + ").\n"
+		}
+		return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
+			Title:   github.String("Jira issue " + key + " does not affect " + branchConfig.Version),
+			Summary: github.String(summary),
+		})
+	}
+
+	if level := securityLevel(issue); level != "" && jiraConfig.SecurityLevel.FailCheck {
+		return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
+			Title:   github.String("Jira issue " + key + " is restricted"),
+			Summary: github.String("The Jira issue `" + key + "` has a security level and should not be referenced from a public pull request.\n"),
+		})
+	}
+
+	fixVersion, err := c.fixVersion(ctx, owner, repo, jiraConfig, branchConfig)
+	if err != nil {
+		return err
+	}
+
+	if fixVersion != "" && jiraConfig.SetsFixVersion() {
+		branch := configuration.BranchReference{Owner: owner, Repo: repo, Branch: branchConfig.Name}.String()
+		exists, err := c.ensureFixVersion(ctx, jiraConfig, fixVersion)
+		if err != nil {
+			klog.V(2).Infof("%schecking pull request %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, pr.GetNumber(), err)
+			c.fixVersionStatus(branch, err.Error())
+		} else if !exists {
+			c.fixVersionStatus(branch, "Jira version "+fixVersion+" does not exist")
+			return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "failure", missingFixVersionOutput(jiraConfig, branchConfig, fixVersion))
+		} else {
+			c.fixVersionStatus(branch, "")
+		}
+	}
+
+	summary := "The pull request title is valid and has a Jira issue.\n"
+	closedIssues := jiraConfig.ClosedIssues
+	if closedIssues.Action != "" && pr.GetState() == "open" && contains(closedIssues.StatusesOrDefault(), issue.Fields.Status.Name) {
+		switch closedIssues.Action {
+		case configuration.ClosedIssueActionWarn:
+			summary += "\n**Warning:** the Jira issue `" + key + "` is already " + issue.Fields.Status.Name + ". Please make sure that the pull request should not use a new issue.\n"
+		case configuration.ClosedIssueActionFail:
+			return c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "failure", &github.CheckRunOutput{
+				Title:   github.String("Jira issue " + key + " is " + issue.Fields.Status.Name),
+				Summary: github.String("The Jira issue `" + key + "` is already " + issue.Fields.Status.Name + ". Please create a new issue for this pull request.\n"),
+			})
+		case configuration.ClosedIssueActionReopen:
+			// The issue is reopened only when the pull request starts
+			// referencing it. It can be closed later while the pull request
+			// is open, e.g. by the rules of another pull request.
+			if event != EventOpened && event != EventEdited {
+				break
+			}
+			reopened, err := c.reopen(ctx, issue, closedIssues.ReopenTo)
+			if err != nil {
+				klog.V(2).Infof("%schecking pull request %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, pr.GetNumber(), err)
+				if err := c.reportRunError(ctx, result, owner, repo, headSHA, pr.GetNumber(), fmt.Sprintf("Failed to reopen the Jira issue `%s`. You can retry the check by commenting `/recheck` on the pull request.", key)); err != nil {
+					return err
+				}
+				return fmt.Errorf("%w: %v", ErrJiraUpdateFailed, err)
+			}
+			c.recordTransition(pr, issue, reopened.Fields.Status.Name)
+			issue = reopened
+			summary += "\nThe Jira issue `" + key + "` has been reopened.\n"
+		}
+	}
+
+	err = c.reportResult(ctx, result, owner, repo, headSHA, pr.GetNumber(), "success", &github.CheckRunOutput{
 		Title:   github.String("Pull request title has a valid Jira issue"),
-		Summary: github.String("The pull request title is valid and has a Jira issue.\n"),
+		Summary: github.String(summary),
 	})
 	if err != nil {
 		return err
 	}
 
-	fixVersion := ""
-	if branchConfig.Version != "" {
-		bareFixVersion, err := c.tagInformer.NextVersion(owner, repo, branchConfig.Version)
+	if !c.rulesInputChanged(event, key, pr, fixVersion) {
+		klog.V(4).Infof("%schecking pull request %s/%s#%d: the rules inputs have not changed, skipping the rules", logctx.Prefix(ctx), owner, repo, pr.GetNumber())
+		return nil
+	}
+
+	var updateErr error
+	if event == EventOpened && jiraConfig.Backport.Clone && branchConfig.Version != "" {
+		updateErr = c.cloneForBackport(ctx, issue, pr, jiraConfig, branchConfig, fixVersion)
+		if updateErr != nil {
+			klog.V(2).Infof("%schecking pull request %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, pr.GetNumber(), updateErr)
+		}
+	}
+
+	if err := c.applyRules(ctx, event, issue, pr, fixVersion, jiraConfig, result); err != nil {
+		updateErr = err
+	}
+	if updateErr != nil {
+		return fmt.Errorf("%w: %v", ErrJiraUpdateFailed, updateErr)
+	}
+	c.recordRulesInput(key, pr, fixVersion)
+	return nil
+}
+
+func rulesInputKey(pr *github.PullRequest) string {
+	return fmt.Sprintf("%s#%d", pr.GetBase().GetRepo().GetFullName(), pr.GetNumber())
+}
+
+func rulesInput(key string, pr *github.PullRequest, fixVersion string) string {
+	return fmt.Sprintf("%s %s %s %s %t", key, pr.GetBase().GetRef(), fixVersion, pr.GetState(), pr.GetMerged())
+}
+
+// rulesInputChanged reports if the rules should be applied for event. The
+// check is reported for every new commit, but pushing commits rarely changes
+// anything for the rules, so on EventSync the rules are applied only if the
+// issue, the base branch, the fix version or the state of the pull request
+// have changed since the rules were applied last time, or if the rules were
+// never applied to the pull request since the app started.
+func (c *Jira) rulesInputChanged(event Event, key string, pr *github.PullRequest, fixVersion string) bool {
+	if event != EventSync {
+		return true
+	}
+	previous, ok := c.ruleInputs.Get(rulesInputKey(pr))
+	return !ok || previous.(string) != rulesInput(key, pr, fixVersion)
+}
+
+// recordRulesInput records what the rules saw of the pull request once they
+// were applied successfully. If applying them failed, the next event applies
+// them again.
+func (c *Jira) recordRulesInput(key string, pr *github.PullRequest, fixVersion string) {
+	c.ruleInputs.Add(rulesInputKey(pr), rulesInput(key, pr, fixVersion))
+}
+
+// applyRules applies the first rule that matches and returns the error of
+// its application.
+func (c *Jira) applyRules(ctx context.Context, event Event, issue *jira.Issue, pr *github.PullRequest, fixVersion string, jiraConfig configuration.Jira, result *Result) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	allMerged := false
+	if jiraConfig.UsesAllPullRequestsMerged() {
+		var err error
+		allMerged, err = c.allPullRequestsMerged(ctx, owner, issue.Key, pr)
+		if err != nil {
+			klog.V(2).Infof("%schecking pull request %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, pr.GetNumber(), err)
+		}
+	}
+
+	for i, rule := range jiraConfig.Rules {
+		if matchCondition(event, issue, pr, fixVersion, allMerged, jiraConfig, rule.When) {
+			result.Rule = fmt.Sprintf("rules[%d]", i)
+			result.Notify = rule.Notify
+			err := c.applyRule(ctx, issue, pr, fixVersion, jiraConfig, rule)
+			if err != nil {
+				klog.V(2).Infof("%schecking pull request %s/%s#%d: %v", logctx.Prefix(ctx), owner, repo, pr.GetNumber(), err)
+				result.Error = err.Error()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Inconsistency describes a merged pull request whose Jira issue is not in the
+// state that the rules for the closed event would have moved it to.
+type Inconsistency struct {
+	PullRequest    string `json:"pullRequest"`
+	URL            string `json:"url"`
+	Issue          string `json:"issue"`
+	Status         string `json:"status"`
+	ExpectedStatus string `json:"expectedStatus"`
+	Retried        bool   `json:"retried"`
+	Error          string `json:"error,omitempty"`
+}
+
+// statusSince returns the status that the issue had at since and the statuses
+// that it was moved to after since, according to its changelog.
+func statusSince(issue *jira.Issue, since time.Time) (string, []string) {
+	status := issue.Fields.Status.Name
+	if issue.Changelog == nil {
+		return status, nil
+	}
+
+	type move struct {
+		time     time.Time
+		from, to string
+	}
+	var moves []move
+	for _, history := range issue.Changelog.Histories {
+		created, err := history.CreatedTime()
+		if err != nil || !created.After(since) {
+			continue
+		}
+		for _, item := range history.Items {
+			if item.Field == "status" {
+				moves = append(moves, move{time: created, from: item.FromString, to: item.ToString})
+			}
+		}
+	}
+	// The moves are undone from the latest one.
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].time.After(moves[j].time)
+	})
+	var to []string
+	for _, m := range moves {
+		status = m.from
+		to = append(to, m.to)
+	}
+	return status, to
+}
+
+// Reconcile checks that the Jira issue of the merged pull request pr has been
+// transitioned by the rules for the closed event. If it hasn't been and retry
+// is true, the transition is retried, unless the issue was moved by someone
+// after the merge.
+func (c *Jira) Reconcile(ctx context.Context, jiraConfig configuration.Jira, branchConfig configuration.Branch, pr *github.PullRequest, retry bool) (*Inconsistency, error) {
+	if jiraConfig.Key == "" {
+		return nil, nil
+	}
+
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	key := issueKey(pr.GetTitle())
+	if !strings.HasPrefix(key, jiraConfig.Key+"-") {
+		return nil, nil
+	}
+
+	issue, _, err := c.jiraClient.Issue.GetWithContext(ctx, key, &jira.GetQueryOptions{Expand: "changelog"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Jira issue %s: %w", key, err)
+	}
+
+	fixVersion, err := c.fixVersion(ctx, owner, repo, jiraConfig, branchConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rules are matched against the issue as it was when the pull request
+	// was merged, it may have been moved since then.
+	mergedStatus, moves := statusSince(issue, pr.GetMergedAt())
+	merged := *issue
+	mergedFields := *issue.Fields
+	mergedFields.Status = &jira.Status{Name: mergedStatus}
+	merged.Fields = &mergedFields
+
+	allMerged := false
+	if jiraConfig.UsesAllPullRequestsMerged() {
+		allMerged, err = c.allPullRequestsMerged(ctx, owner, key, pr)
 		if err != nil {
-			return fmt.Errorf("failed to get next version for %s/%s:%s: %w", owner, repo, branchConfig.Name, err)
+			return nil, err
 		}
-		fixVersion = jiraConfig.FixVersionPrefix + bareFixVersion
 	}
 
 	for _, rule := range jiraConfig.Rules {
-		if matchCondition(event, issue, pr, fixVersion, rule.When) {
-			err = c.applyRule(ctx, issue, pr, fixVersion, rule)
-			if err != nil {
-				klog.V(2).Infof("checking pull request %s/%s#%d: %v", owner, repo, pr.GetNumber(), err)
+		if !matchCondition(EventClosed, &merged, pr, fixVersion, allMerged, jiraConfig, rule.When) {
+			continue
+		}
+		if rule.TransitionTo == "" || rule.TransitionTo == issue.Fields.Status.Name || contains(moves, rule.TransitionTo) {
+			return nil, nil
+		}
+
+		inconsistency := &Inconsistency{
+			PullRequest:    fmt.Sprintf("%s/%s#%d", owner, repo, pr.GetNumber()),
+			URL:            pr.GetHTMLURL(),
+			Issue:          key,
+			Status:         issue.Fields.Status.Name,
+			ExpectedStatus: rule.TransitionTo,
+		}
+		// If someone moved the issue after the merge, the transition is
+		// not retried over their decision.
+		if retry && len(moves) == 0 {
+			inconsistency.Retried = true
+			c.issueCache.Remove(issue.Key)
+			if err := c.transitionTo(ctx, issue, rule.TransitionTo); err != nil {
+				inconsistency.Error = err.Error()
+			} else {
+				c.recordTransition(pr, issue, rule.TransitionTo)
 			}
-			break
 		}
+		return inconsistency, nil
 	}
 
-	return nil
+	return nil, nil
 }
@@ -1,18 +1,30 @@
 package checks
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/cache"
 	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+	"github.com/quay/quay-ci-app/storage"
 )
 
 type issueData struct {
 	key         string
 	status      string
 	fixVersions []string
+	qaContact   string
+	updated     time.Time
 }
 
 func fakeIssue(d issueData) *jira.Issue {
@@ -21,6 +33,15 @@ func fakeIssue(d issueData) *jira.Issue {
 		fixVersions[i] = &jira.FixVersion{Name: v}
 	}
 
+	unknowns := map[string]interface{}{
+		configuration.DefaultQAContactField: nil,
+	}
+	if d.qaContact != "" {
+		unknowns[configuration.DefaultQAContactField] = map[string]interface{}{
+			"name": d.qaContact,
+		}
+	}
+
 	return &jira.Issue{
 		Key: d.key,
 		Fields: &jira.IssueFields{
@@ -28,6 +49,8 @@ func fakeIssue(d issueData) *jira.Issue {
 				Name: d.status,
 			},
 			FixVersions: fixVersions,
+			Updated:     jira.Time(d.updated),
+			Unknowns:    unknowns,
 		},
 	}
 }
@@ -48,6 +71,8 @@ func fakePullRequest(d pullRequestData) *github.PullRequest {
 
 func TestMatchCondition(t *testing.T) {
 	trueVal := true
+	falseVal := false
+	ninetyDays := &configuration.Duration{Duration: 90 * 24 * time.Hour}
 
 	testCases := []struct {
 		name        string
@@ -56,6 +81,7 @@ func TestMatchCondition(t *testing.T) {
 		issue       issueData
 		pullRequest pullRequestData
 		fixVersion  string
+		allMerged   bool
 		want        bool
 	}{
 		{
@@ -143,10 +169,692 @@ func TestMatchCondition(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "all pull requests are merged",
+			cond: configuration.JiraCondition{
+				AllPullRequestsMerged: &trueVal,
+			},
+			event:     EventClosed,
+			allMerged: true,
+			want:      true,
+		},
+		{
+			name: "not all pull requests are merged",
+			cond: configuration.JiraCondition{
+				AllPullRequestsMerged: &trueVal,
+			},
+			event:     EventClosed,
+			allMerged: false,
+			want:      false,
+		},
+		{
+			name: "stale issue",
+			cond: configuration.JiraCondition{
+				NotUpdatedFor: ninetyDays,
+			},
+			event: EventOpened,
+			issue: issueData{
+				key:     "PROJQUAY-123",
+				updated: time.Now().Add(-100 * 24 * time.Hour),
+			},
+			want: true,
+		},
+		{
+			name: "recently updated issue is not stale",
+			cond: configuration.JiraCondition{
+				NotUpdatedFor: ninetyDays,
+			},
+			event: EventOpened,
+			issue: issueData{
+				key:     "PROJQUAY-123",
+				updated: time.Now().Add(-24 * time.Hour),
+			},
+			want: false,
+		},
+		{
+			name: "recently updated issue",
+			cond: configuration.JiraCondition{
+				UpdatedWithin: ninetyDays,
+			},
+			event: EventOpened,
+			issue: issueData{
+				key:     "PROJQUAY-123",
+				updated: time.Now().Add(-24 * time.Hour),
+			},
+			want: true,
+		},
+		{
+			name: "issue has QA contact",
+			cond: configuration.JiraCondition{
+				HasQAContact: &trueVal,
+			},
+			event: EventClosed,
+			issue: issueData{
+				key:       "PROJQUAY-123",
+				status:    "In Progress",
+				qaContact: "qa@example.com",
+			},
+			want: true,
+		},
+		{
+			name: "issue does not have QA contact",
+			cond: configuration.JiraCondition{
+				HasQAContact: &falseVal,
+			},
+			event: EventClosed,
+			issue: issueData{
+				key:    "PROJQUAY-123",
+				status: "In Progress",
+			},
+			want: true,
+		},
+		{
+			name: "issue is expected to have QA contact",
+			cond: configuration.JiraCondition{
+				HasQAContact: &trueVal,
+			},
+			event: EventClosed,
+			issue: issueData{
+				key:    "PROJQUAY-123",
+				status: "In Progress",
+			},
+			want: false,
+		},
 	}
 	for _, tc := range testCases {
-		if got := matchCondition(tc.event, fakeIssue(tc.issue), fakePullRequest(tc.pullRequest), tc.fixVersion, tc.cond); got != tc.want {
+		if got := matchCondition(tc.event, fakeIssue(tc.issue), fakePullRequest(tc.pullRequest), tc.fixVersion, tc.allMerged, configuration.Jira{}, tc.cond); got != tc.want {
 			t.Errorf("%s: got %t, want %t", tc.name, got, tc.want)
 		}
 	}
 }
+
+func TestSecurityLevel(t *testing.T) {
+	testCases := []struct {
+		name     string
+		unknowns map[string]interface{}
+		want     string
+	}{
+		{
+			name: "no security level",
+			want: "",
+		},
+		{
+			name: "security level is null",
+			unknowns: map[string]interface{}{
+				"security": nil,
+			},
+			want: "",
+		},
+		{
+			name: "embargoed issue",
+			unknowns: map[string]interface{}{
+				"security": map[string]interface{}{
+					"id":   "11697",
+					"name": "Embargoed Security Issue",
+				},
+			},
+			want: "Embargoed Security Issue",
+		},
+	}
+	for _, tc := range testCases {
+		issue := &jira.Issue{
+			Fields: &jira.IssueFields{
+				Unknowns: tc.unknowns,
+			},
+		}
+		if got := securityLevel(issue); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAffectsStream(t *testing.T) {
+	testCases := []struct {
+		name            string
+		affectsVersions []string
+		xy              string
+		want            bool
+	}{
+		{
+			name: "no affects versions",
+			xy:   "3.8",
+			want: false,
+		},
+		{
+			name:            "affects patch version",
+			affectsVersions: []string{"quay-v3.8.2"},
+			xy:              "3.8",
+			want:            true,
+		},
+		{
+			name:            "affects y-stream",
+			affectsVersions: []string{"quay-v3.10.3", "quay-v3.8"},
+			xy:              "3.8",
+			want:            true,
+		},
+		{
+			name:            "affects only newer stream",
+			affectsVersions: []string{"quay-v3.10.0"},
+			xy:              "3.1",
+			want:            false,
+		},
+	}
+	for _, tc := range testCases {
+		issue := &jira.Issue{
+			Fields: &jira.IssueFields{},
+		}
+		for _, v := range tc.affectsVersions {
+			issue.Fields.AffectsVersions = append(issue.Fields.AffectsVersions, &jira.AffectsVersion{Name: v})
+		}
+		if got := affectsStream(issue, "quay-v", tc.xy); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIssueUpdate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fixVersions []string
+		setVersion  string
+		rule        configuration.JiraRule
+		comment     string
+		want        map[string]interface{}
+	}{
+		{
+			name: "nothing to update",
+			rule: configuration.JiraRule{TransitionTo: "ON_QA"},
+			want: nil,
+		},
+		{
+			name:        "fix version is already set",
+			fixVersions: []string{"quay-v3.8.1"},
+			rule:        configuration.JiraRule{SetFixVersion: true},
+			want:        nil,
+		},
+		{
+			name:    "set fix version and add comment",
+			rule:    configuration.JiraRule{SetFixVersion: true},
+			comment: "merged",
+			want: map[string]interface{}{
+				"fixVersions": []map[string]interface{}{
+					{"add": map[string]interface{}{"name": "quay-v3.8.1"}},
+				},
+				"comment": []map[string]interface{}{
+					{"add": map[string]interface{}{"body": "merged"}},
+				},
+			},
+		},
+		{
+			name:        "remove fix version",
+			fixVersions: []string{"quay-v3.8.1"},
+			setVersion:  "quay-v3.8.1",
+			rule:        configuration.JiraRule{RemoveFixVersion: true},
+			want: map[string]interface{}{
+				"fixVersions": []map[string]interface{}{
+					{"remove": map[string]interface{}{"name": "quay-v3.8.1"}},
+				},
+			},
+		},
+		{
+			name:        "remove the fix version that was set before a release",
+			fixVersions: []string{"quay-v3.8.0"},
+			setVersion:  "quay-v3.8.0",
+			rule:        configuration.JiraRule{RemoveFixVersion: true},
+			want: map[string]interface{}{
+				"fixVersions": []map[string]interface{}{
+					{"remove": map[string]interface{}{"name": "quay-v3.8.0"}},
+				},
+			},
+		},
+		{
+			name:        "fix version was not set by a rule",
+			fixVersions: []string{"quay-v3.8.1"},
+			rule:        configuration.JiraRule{RemoveFixVersion: true},
+			want:        nil,
+		},
+	}
+	for _, tc := range testCases {
+		issue := fakeIssue(issueData{key: "PROJQUAY-123", fixVersions: tc.fixVersions})
+		if got := issueUpdate(issue, tc.rule, "quay-v3.8.1", tc.setVersion, tc.comment); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRulesInputChanged(t *testing.T) {
+	c := &Jira{
+		ruleInputs: cache.New("test-jira-rule-inputs", 10, 0),
+	}
+	pr := &github.PullRequest{
+		Number: github.Int(1),
+		State:  github.String("open"),
+		Base: &github.PullRequestBranch{
+			Ref: github.String("master"),
+			Repo: &github.Repository{
+				FullName: github.String("quay/quay"),
+			},
+		},
+	}
+
+	steps := []struct {
+		name       string
+		event      Event
+		baseRef    string
+		fixVersion string
+		failed     bool
+		want       bool
+	}{
+		// The rules may have never been applied before the app was restarted.
+		{name: "unknown pull request", event: EventSync, baseRef: "master", want: true},
+		{name: "opened", event: EventOpened, baseRef: "master", want: true},
+		{name: "new commit", event: EventSync, baseRef: "master", want: false},
+		{name: "new fix version", event: EventSync, baseRef: "master", fixVersion: "quay-v3.8.0", failed: true, want: true},
+		// The rules failed for the new fix version, so they are applied again.
+		{name: "commit after failure", event: EventSync, baseRef: "master", fixVersion: "quay-v3.8.0", want: true},
+		{name: "another commit", event: EventSync, baseRef: "master", fixVersion: "quay-v3.8.0", want: false},
+		{name: "edited", event: EventEdited, baseRef: "redhat-3.8", fixVersion: "quay-v3.8.0", want: true},
+		{name: "commit after edit", event: EventSync, baseRef: "redhat-3.8", fixVersion: "quay-v3.8.0", want: false},
+	}
+	for _, step := range steps {
+		pr.Base.Ref = github.String(step.baseRef)
+		got := c.rulesInputChanged(step.event, "PROJQUAY-1", pr, step.fixVersion)
+		if got != step.want {
+			t.Errorf("%s: got %t, want %t", step.name, got, step.want)
+		}
+		if got && !step.failed {
+			c.recordRulesInput("PROJQUAY-1", pr, step.fixVersion)
+		}
+	}
+}
+
+func TestNextUnreleasedVersion(t *testing.T) {
+	released := true
+	unreleased := false
+	versions := []jira.Version{
+		{Name: "quay-v3.8.1", Released: &released},
+		{Name: "quay-v3.8.2", Released: &released},
+		{Name: "quay-v3.8.3", Released: &unreleased},
+		{Name: "quay-v3.9.0"},
+	}
+	testCases := []struct {
+		xy   string
+		z    int
+		want int
+	}{
+		{xy: "3.8", z: 0, want: 0},
+		{xy: "3.8", z: 1, want: 3},
+		{xy: "3.8", z: 3, want: 3},
+		{xy: "3.9", z: 0, want: 0},
+	}
+	for _, tc := range testCases {
+		if got := nextUnreleasedVersion(versions, "quay-v", tc.xy, tc.z); got != tc.want {
+			t.Errorf("%s.%d: got %d, want %d", tc.xy, tc.z, got, tc.want)
+		}
+	}
+}
+
+func TestNextFixVersionOverride(t *testing.T) {
+	c := &Jira{}
+	fixVersion, _, err := c.NextFixVersion(context.Background(), "quay", "quay", configuration.Jira{FixVersionPrefix: "quay-v"}, configuration.Branch{
+		Name:       "hotfix-3.8.4-cve",
+		Version:    "3.8",
+		FixVersion: "quay-v3.8.4-hotfix",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixVersion != "quay-v3.8.4-hotfix" {
+		t.Errorf("got %q, want %q", fixVersion, "quay-v3.8.4-hotfix")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	err := c.Run(ctx, EventOpened, configuration.Jira{Key: "PROJQUAY"}, configuration.Branch{Name: "master"}, pr)
+	if !errors.Is(err, ErrJiraUnavailable) {
+		t.Errorf("the Jira request should be cancelled with the context, got %v", err)
+	}
+}
+
+func TestRunWithFakes(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	fakeJira.Transitions[""] = []jira.Transition{
+		{ID: "11", Name: "Start Progress", To: jira.Status{Name: "In Progress"}},
+	}
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+
+	jiraConfig := configuration.Jira{
+		Key: "PROJQUAY",
+		Rules: []configuration.JiraRule{
+			{
+				When:         configuration.JiraCondition{Event: []string{"opened"}, Status: []string{"New"}},
+				TransitionTo: "In Progress",
+			},
+		},
+	}
+	branchConfig := configuration.Branch{Name: "master"}
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	if err := c.Run(context.Background(), EventOpened, jiraConfig, branchConfig, pr); err != nil {
+		t.Fatal(err)
+	}
+	if checkRun := fakeGitHub.LatestCheckRun(pr.GetHead().GetSHA(), TitleCheckRunName); checkRun.GetConclusion() != "success" {
+		t.Errorf("got conclusion %q, want success", checkRun.GetConclusion())
+	}
+	want := []fakes.PerformedTransition{{Issue: "PROJQUAY-123", TransitionID: "11", To: "In Progress"}}
+	if !reflect.DeepEqual(fakeJira.PerformedTransitions, want) {
+		t.Errorf("got transitions %v, want %v", fakeJira.PerformedTransitions, want)
+	}
+
+	pr = fakes.PullRequest("quay", "quay", 2, "Fix the tests (PROJQUAY-999)")
+	if err := c.Run(context.Background(), EventOpened, jiraConfig, branchConfig, pr); err != nil {
+		t.Fatal(err)
+	}
+	checkRun := fakeGitHub.LatestCheckRun(pr.GetHead().GetSHA(), TitleCheckRunName)
+	if checkRun.GetConclusion() != "failure" || checkRun.GetOutput().GetTitle() != "Jira issue PROJQUAY-999 does not exist" {
+		t.Errorf("got %s: %s, want the missing issue failure", checkRun.GetConclusion(), checkRun.GetOutput().GetTitle())
+	}
+
+	recent := c.RecentResults()
+	if len(recent) != 1 || recent[0].Repository != "quay/quay" || len(recent[0].Results) != 2 {
+		t.Fatalf("got recent results %+v, want 2 results for quay/quay", recent)
+	}
+	got := recent[0].Results
+	for i := range got {
+		if got[i].Time.IsZero() {
+			t.Errorf("the result %d should have a time", i)
+		}
+		got[i].Time = time.Time{}
+	}
+	wantResults := []Result{
+		{PullRequest: 2, Event: EventOpened, Conclusion: "failure", Title: "Jira issue PROJQUAY-999 does not exist"},
+		{PullRequest: 1, Event: EventOpened, Conclusion: "success", Title: "Pull request title has a valid Jira issue", Rule: "rules[0]"},
+	}
+	if !reflect.DeepEqual(got, wantResults) {
+		t.Errorf("got results %+v, want %+v", got, wantResults)
+	}
+}
+
+func TestRunRestrictedActivity(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	issue := fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	issue.Fields.Unknowns = map[string]interface{}{"security": map[string]interface{}{"name": "Embargoed Security Issue"}}
+	recorder := activity.NewRecorder(10)
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, recorder, time.Minute, time.Minute)
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	if err := c.Run(context.Background(), EventOpened, configuration.Jira{Key: "PROJQUAY"}, configuration.Branch{Name: "master"}, pr); err != nil {
+		t.Fatal(err)
+	}
+	page := recorder.Events("quay", "quay", 1, 10)
+	if len(page.Events) != 1 || !page.Events[0].Restricted || page.Events[0].Summary != "[restricted]" {
+		t.Errorf("got the events %+v, want a restricted check event", page.Events)
+	}
+}
+
+func TestRunClosedIssues(t *testing.T) {
+	for _, tc := range []struct {
+		action         string
+		event          Event
+		wantConclusion string
+		wantReopened   bool
+	}{
+		{action: configuration.ClosedIssueActionWarn, event: EventSync, wantConclusion: "success"},
+		{action: configuration.ClosedIssueActionFail, event: EventOpened, wantConclusion: "failure"},
+		{action: configuration.ClosedIssueActionFail, event: EventSync, wantConclusion: "failure"},
+		{action: configuration.ClosedIssueActionReopen, event: EventOpened, wantConclusion: "success", wantReopened: true},
+		{action: configuration.ClosedIssueActionReopen, event: EventEdited, wantConclusion: "success", wantReopened: true},
+		{action: configuration.ClosedIssueActionReopen, event: EventSync, wantConclusion: "success"},
+		{action: configuration.ClosedIssueActionReopen, event: EventRecheck, wantConclusion: "success"},
+	} {
+		t.Run(tc.action+" "+string(tc.event), func(t *testing.T) {
+			fakeGitHub := fakes.NewGitHub()
+			fakeJira := fakes.NewJira()
+			fakeJira.AddIssue("PROJQUAY-123", "Bug", "Closed")
+			fakeJira.Transitions[""] = []jira.Transition{{ID: "11", Name: "Reopen", To: jira.Status{Name: "New"}}}
+			c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+			jiraConfig := configuration.Jira{Key: "PROJQUAY", ClosedIssues: configuration.JiraClosedIssues{Action: tc.action}}
+
+			pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+			if err := c.Run(context.Background(), tc.event, jiraConfig, configuration.Branch{Name: "master"}, pr); err != nil {
+				t.Fatal(err)
+			}
+			checkRun := fakeGitHub.LatestCheckRun(pr.GetHead().GetSHA(), TitleCheckRunName)
+			if checkRun.GetConclusion() != tc.wantConclusion {
+				t.Errorf("got conclusion %q, want %q", checkRun.GetConclusion(), tc.wantConclusion)
+			}
+			if reopened := len(fakeJira.PerformedTransitions) == 1; reopened != tc.wantReopened {
+				t.Errorf("got the transitions %+v, want the issue reopened: %t", fakeJira.PerformedTransitions, tc.wantReopened)
+			}
+			if tc.action == configuration.ClosedIssueActionWarn && !strings.Contains(checkRun.GetOutput().GetSummary(), "**Warning:**") {
+				t.Errorf("got the summary %q, want a warning", checkRun.GetOutput().GetSummary())
+			}
+		})
+	}
+
+	// The policy doesn't apply to the pull requests that are closed.
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "Closed")
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	pr.State = github.String("closed")
+	jiraConfig := configuration.Jira{Key: "PROJQUAY", ClosedIssues: configuration.JiraClosedIssues{Action: configuration.ClosedIssueActionFail}}
+	if err := c.Run(context.Background(), EventClosed, jiraConfig, configuration.Branch{Name: "master"}, pr); err != nil {
+		t.Fatal(err)
+	}
+	if checkRun := fakeGitHub.LatestCheckRun(pr.GetHead().GetSHA(), TitleCheckRunName); checkRun.GetConclusion() != "success" {
+		t.Errorf("got conclusion %q for a closed pull request, want success", checkRun.GetConclusion())
+	}
+}
+
+func TestRunMissingFixVersion(t *testing.T) {
+	for _, create := range []bool{false, true} {
+		t.Run(fmt.Sprintf("create %t", create), func(t *testing.T) {
+			fakeGitHub := fakes.NewGitHub()
+			fakeJira := fakes.NewJira()
+			fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+			fakeJira.AddProject("PROJQUAY", []string{"New"}, "3.9.0")
+			statuses := map[string]string{}
+			fixVersionStatus := func(branch, message string) {
+				statuses[branch] = message
+			}
+			c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, fixVersionStatus, activity.NewRecorder(10), time.Minute, time.Minute)
+			jiraConfig := configuration.Jira{
+				Key:               "PROJQUAY",
+				VersionContact:    "#quay-release",
+				CreateFixVersions: create,
+				Rules: []configuration.JiraRule{{
+					When:          configuration.JiraCondition{Event: []string{"opened"}},
+					SetFixVersion: true,
+				}},
+			}
+			branchConfig := configuration.Branch{Name: "redhat-3.9", FixVersion: "3.9.1"}
+
+			pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+			if err := c.Run(context.Background(), EventOpened, jiraConfig, branchConfig, pr); err != nil {
+				t.Fatal(err)
+			}
+			checkRun := fakeGitHub.LatestCheckRun(pr.GetHead().GetSHA(), TitleCheckRunName)
+			project := fakeJira.Projects["PROJQUAY"]
+			if create {
+				if checkRun.GetConclusion() != "success" {
+					t.Errorf("got conclusion %q, want success once the version is created", checkRun.GetConclusion())
+				}
+				if n := len(project.Versions); n != 2 || project.Versions[1].Name != "3.9.1" {
+					t.Errorf("got the versions %+v, want 3.9.1 to be created", project.Versions)
+				}
+				if message := statuses["quay/quay:redhat-3.9"]; message != "" {
+					t.Errorf("got the fix version status %q, want none", message)
+				}
+				return
+			}
+			if checkRun.GetConclusion() != "failure" || checkRun.GetOutput().GetTitle() != "Jira version 3.9.1 does not exist" {
+				t.Errorf("got %s: %s, want the missing version failure", checkRun.GetConclusion(), checkRun.GetOutput().GetTitle())
+			}
+			if !strings.Contains(checkRun.GetOutput().GetSummary(), "#quay-release") {
+				t.Errorf("the summary should name the contact, got %q", checkRun.GetOutput().GetSummary())
+			}
+			if message := statuses["quay/quay:redhat-3.9"]; message != "Jira version 3.9.1 does not exist" {
+				t.Errorf("got the fix version status %q", message)
+			}
+			if len(project.Versions) != 1 || len(fakeJira.Updates["PROJQUAY-123"]) != 0 {
+				t.Errorf("the version should not be created or set, got the versions %+v and the updates %v", project.Versions, fakeJira.Updates["PROJQUAY-123"])
+			}
+		})
+	}
+}
+
+func TestIssueCache(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	issue := fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+	ctx := context.Background()
+
+	if _, resp, err := c.getIssue(ctx, "PROJQUAY-123"); err != nil || resp == nil {
+		t.Fatalf("the issue should be fetched, got the response %v and the error %v", resp, err)
+	}
+	issue.Fields.Status = &jira.Status{Name: "In Progress"}
+	got, resp, err := c.getIssue(ctx, "PROJQUAY-123")
+	if err != nil || resp != nil || got.Fields.Status.Name != "New" {
+		t.Errorf("expected cached issue, got %v (response %v, error %v)", got.Fields.Status, resp, err)
+	}
+
+	c.InvalidateIssue("PROJQUAY-123")
+	if got, _, err := c.getIssue(ctx, "PROJQUAY-123"); err != nil || got.Fields.Status.Name != "In Progress" {
+		t.Errorf("expected invalidated issue to be fetched again, got %v (error %v)", got.Fields.Status, err)
+	}
+}
+
+func TestIssueCacheDisabled(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	issue := fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), 0, time.Minute)
+	ctx := context.Background()
+
+	if _, _, err := c.getIssue(ctx, "PROJQUAY-123"); err != nil {
+		t.Fatal(err)
+	}
+	issue.Fields.Status = &jira.Status{Name: "In Progress"}
+	if got, resp, err := c.getIssue(ctx, "PROJQUAY-123"); err != nil || resp == nil || got.Fields.Status.Name != "In Progress" {
+		t.Errorf("expected no cached issue, got %v (response %v, error %v)", got.Fields.Status, resp, err)
+	}
+}
+
+func TestReportMuted(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "POST")
+	fakeJira.Transitions[""] = []jira.Transition{{ID: "51", Name: "Move to QA", To: jira.Status{Name: "ON_QA"}}}
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+	jiraConfig := configuration.Jira{
+		Key:          "PROJQUAY",
+		StatusLabels: true,
+		Rules: []configuration.JiraRule{{
+			When:         configuration.JiraCondition{Merged: github.Bool(true)},
+			TransitionTo: "ON_QA",
+			Comment:      "Merged {{.PullRequest.HTMLURL}}",
+		}},
+	}
+	mute := configuration.CheckMute{Check: JiraCheckName, Until: time.Now().Add(time.Hour)}
+	ctx := context.Background()
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	if err := c.ReportMuted(ctx, EventOpened, jiraConfig, configuration.Branch{Name: "master"}, pr, mute); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeGitHub.CheckRuns) != 1 || fakeGitHub.CheckRuns[0].GetConclusion() != "neutral" {
+		t.Fatalf("the check should be neutral, got %+v", fakeGitHub.CheckRuns)
+	}
+
+	// The merged pull request still transitions its issue.
+	pr.State = github.String("closed")
+	mergedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	pr.MergedAt = &mergedAt
+	pr.Merged = github.Bool(true)
+	if err := c.ReportMuted(ctx, EventClosed, jiraConfig, configuration.Branch{Name: "master"}, pr, mute); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeJira.PerformedTransitions) != 1 || fakeJira.PerformedTransitions[0].To != "ON_QA" {
+		t.Errorf("the issue should be moved to ON_QA, got %+v", fakeJira.PerformedTransitions)
+	}
+	if updates := fakeJira.Updates["PROJQUAY-123"]; len(updates) != 0 {
+		t.Errorf("the muted rule should not comment on the issue, got the updates %v", updates)
+	}
+	if labels := fakeGitHub.Labels[fakes.IssueKey("quay", "quay", 1)]; len(labels) != 0 {
+		t.Errorf("the muted check should not label the pull request, got %q", labels)
+	}
+
+	// Without a Jira project, nothing is reported.
+	if err := c.ReportMuted(ctx, EventOpened, configuration.Jira{}, configuration.Branch{Name: "master"}, pr, mute); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeGitHub.CheckRuns) != 2 {
+		t.Errorf("got %d check runs, the check without a Jira project should not be reported", len(fakeGitHub.CheckRuns))
+	}
+}
+
+func TestRemoveFixVersion(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "POST")
+	fakeJira.AddProject("PROJQUAY", nil, "quay-v3.8.1", "quay-v3.8.2")
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, func(string, string) {}, activity.NewRecorder(10), time.Minute, time.Minute)
+	db, err := storage.Open(storage.DriverSQLite, filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c.SetStorage(db)
+	jiraConfig := configuration.Jira{
+		Key: "PROJQUAY",
+		Rules: []configuration.JiraRule{
+			{When: configuration.JiraCondition{Event: []string{"opened"}}, SetFixVersion: true},
+			{When: configuration.JiraCondition{Event: []string{"closed"}, Merged: github.Bool(false)}, RemoveFixVersion: true},
+		},
+	}
+	ctx := context.Background()
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+
+	if err := c.Run(ctx, EventOpened, jiraConfig, configuration.Branch{Name: "master", FixVersion: "quay-v3.8.1"}, pr); err != nil {
+		t.Fatal(err)
+	}
+	fakeJira.Issues["PROJQUAY-123"].Fields.FixVersions = []*jira.FixVersion{{Name: "quay-v3.8.1"}}
+
+	// 3.8.1 is released before the pull request is closed, the computed fix
+	// version is now 3.8.2. The app is restarted in the meantime.
+	restarted := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, func(string, string) {}, activity.NewRecorder(10), time.Minute, time.Minute)
+	restarted.SetStorage(db)
+	pr.State = github.String("closed")
+	if err := restarted.Run(ctx, EventClosed, jiraConfig, configuration.Branch{Name: "master", FixVersion: "quay-v3.8.2"}, pr); err != nil {
+		t.Fatal(err)
+	}
+	updates := fakeJira.Updates["PROJQUAY-123"]
+	if len(updates) != 2 {
+		t.Fatalf("got the updates %v, want 2", updates)
+	}
+	want := map[string]interface{}{
+		"fixVersions": []map[string]interface{}{
+			{"remove": map[string]interface{}{"name": "quay-v3.8.1"}},
+		},
+	}
+	if got := updates[1]["update"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the update %v, want %v", got, want)
+	}
+	if version, err := db.FixVersion(storage.PullRequest{Owner: "quay", Repo: "quay", Number: 1}, "PROJQUAY-123"); err != nil || version != "" {
+		t.Errorf("the removed fix version should be forgotten, got %q, %v", version, err)
+	}
+}
@@ -0,0 +1,99 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// LabelsCheckName is the name that is used to refer to the required labels
+// check in the configuration.
+const LabelsCheckName = configuration.CheckLabels
+
+// LabelsCheckRunName is the name of the check run that is reported by the
+// required labels check.
+const LabelsCheckRunName = "Required Labels"
+
+// Labels is the check that requires pull requests to have at least one of the
+// configured labels.
+type Labels struct {
+	githubClient *clients.GitHub
+	activity     *activity.Recorder
+}
+
+func NewLabels(githubClient *clients.GitHub, recorder *activity.Recorder) *Labels {
+	return &Labels{
+		githubClient: githubClient,
+		activity:     recorder,
+	}
+}
+
+func labelsResult(required []string, pr *github.PullRequest) (string, *github.CheckRunOutput) {
+	for _, label := range pr.Labels {
+		if contains(required, label.GetName()) {
+			return "success", &github.CheckRunOutput{
+				Title:   github.String("The pull request has the label " + label.GetName()),
+				Summary: github.String("The pull request has the label `" + label.GetName() + "`.\n"),
+			}
+		}
+	}
+	return "failure", &github.CheckRunOutput{
+		Title:   github.String("The pull request does not have a required label"),
+		Summary: github.String("Please add one of the labels to the pull request: `" + strings.Join(required, "`, `") + "`.\n"),
+	}
+}
+
+// Run reports the result of the check for the pull request. The check is not
+// reported if required is empty.
+func (c *Labels) Run(ctx context.Context, required []string, pr *github.PullRequest) error {
+	if len(required) == 0 {
+		return nil
+	}
+	conclusion, output := labelsResult(required, pr)
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, LabelsCheckRunName, conclusion, output)
+}
+
+func (c *Labels) ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, LabelsCheckRunName, "neutral", mutedOutput(owner, repo, mute))
+}
+
+// replacePrefixedLabels replaces the labels of the pull request that start
+// with prefix with label. If label is empty, the labels are only removed.
+func replacePrefixedLabels(ctx context.Context, client *clients.GitHub, pr *github.PullRequest, prefix, label string) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+
+	var errs []error
+	hasLabel := label == ""
+	for _, l := range pr.Labels {
+		name := l.GetName()
+		if name == label {
+			hasLabel = true
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		klog.V(4).Infof("%sremoving label %s from %s/%s#%d...", logctx.Prefix(ctx), name, owner, repo, pr.GetNumber())
+		if _, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, pr.GetNumber(), name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove label %s from %s/%s#%d: %w", name, owner, repo, pr.GetNumber(), err))
+		}
+	}
+	if !hasLabel {
+		klog.V(4).Infof("%sadding label %s to %s/%s#%d...", logctx.Prefix(ctx), label, owner, repo, pr.GetNumber())
+		if _, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, pr.GetNumber(), []string{label}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to add label %s to %s/%s#%d: %w", label, owner, repo, pr.GetNumber(), err))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
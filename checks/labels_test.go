@@ -0,0 +1,55 @@
+package checks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestLabelsResult(t *testing.T) {
+	required := []string{"kind/bug", "kind/feature"}
+	testCases := []struct {
+		labels []string
+		want   string
+	}{
+		{labels: nil, want: "failure"},
+		{labels: []string{"approved"}, want: "failure"},
+		{labels: []string{"approved", "kind/feature"}, want: "success"},
+	}
+	for _, tc := range testCases {
+		pr := &github.PullRequest{}
+		for _, label := range tc.labels {
+			pr.Labels = append(pr.Labels, &github.Label{Name: github.String(label)})
+		}
+		if got, _ := labelsResult(required, pr); got != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.labels, got, tc.want)
+		}
+	}
+}
+
+func TestLabelsRun(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	c := NewLabels(fakeGitHub.Client(), activity.NewRecorder(10))
+
+	pr := fakes.PullRequest("quay", "quay", 42, "Fix the build")
+	pr.Labels = []*github.Label{{Name: github.String("kind/bug")}}
+	if err := c.Run(context.Background(), nil, pr); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeGitHub.CheckRuns) != 0 {
+		t.Fatalf("the check should not be reported without required labels")
+	}
+
+	if err := c.Run(context.Background(), []string{"kind/bug"}, pr); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeGitHub.CheckRuns) != 1 {
+		t.Fatalf("got %d check runs, want 1", len(fakeGitHub.CheckRuns))
+	}
+	if got := fakeGitHub.LatestCheckRun(pr.GetHead().GetSHA(), LabelsCheckRunName); got.GetConclusion() != "success" {
+		t.Errorf("got conclusion %q, want success", got.GetConclusion())
+	}
+}
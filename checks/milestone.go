@@ -0,0 +1,103 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+// findMilestone returns the milestone with the given title in the repository,
+// or nil if it doesn't exist.
+func (c *Jira) findMilestone(ctx context.Context, owner, repo, title string) (*github.Milestone, error) {
+	opts := &github.MilestoneListOptions{
+		State: "all",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		milestones, resp, err := c.githubClient.Issues.ListMilestones(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list milestones for %s/%s: %w", owner, repo, err)
+		}
+		for _, milestone := range milestones {
+			if milestone.GetTitle() == title {
+				return milestone, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ensureMilestone returns the milestone with the given title in the
+// repository and creates it if it doesn't exist.
+func (c *Jira) ensureMilestone(ctx context.Context, owner, repo, title string) (*github.Milestone, error) {
+	milestone, err := c.findMilestone(ctx, owner, repo, title)
+	if err != nil || milestone != nil {
+		return milestone, err
+	}
+
+	klog.V(2).Infof("%screating milestone %s in %s/%s...", logctx.Prefix(ctx), title, owner, repo)
+	milestone, _, err = c.githubClient.Issues.CreateMilestone(ctx, owner, repo, &github.Milestone{
+		Title: github.String(title),
+	})
+	if err != nil {
+		// The milestone may have been created by a concurrent check.
+		if existing, findErr := c.findMilestone(ctx, owner, repo, title); findErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("failed to create milestone %s in %s/%s: %w", title, owner, repo, err)
+	}
+	return milestone, nil
+}
+
+// setMilestone sets the milestone that matches fixVersion on the pull
+// request.
+func (c *Jira) setMilestone(ctx context.Context, pr *github.PullRequest, fixVersion string) error {
+	if pr.GetMilestone().GetTitle() == fixVersion {
+		return nil
+	}
+
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	milestone, err := c.ensureMilestone(ctx, owner, repo, fixVersion)
+	if err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("%ssetting milestone %s on %s/%s#%d...", logctx.Prefix(ctx), fixVersion, owner, repo, pr.GetNumber())
+	_, _, err = c.githubClient.Issues.Edit(ctx, owner, repo, pr.GetNumber(), &github.IssueRequest{
+		Milestone: milestone.Number,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set milestone %s on %s/%s#%d: %w", fixVersion, owner, repo, pr.GetNumber(), err)
+	}
+	return nil
+}
+
+// CloseMilestone closes the milestone with the given title in the repository
+// if it exists and is open. It is called when the fix version is released.
+func (c *Jira) CloseMilestone(ctx context.Context, owner, repo, title string) error {
+	milestone, err := c.findMilestone(ctx, owner, repo, title)
+	if err != nil {
+		return err
+	}
+	if milestone == nil || milestone.GetState() == "closed" {
+		return nil
+	}
+
+	klog.V(2).Infof("%sclosing milestone %s in %s/%s...", logctx.Prefix(ctx), title, owner, repo)
+	_, _, err = c.githubClient.Issues.EditMilestone(ctx, owner, repo, milestone.GetNumber(), &github.Milestone{
+		State: github.String("closed"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close milestone %s in %s/%s: %w", title, owner, repo, err)
+	}
+	return nil
+}
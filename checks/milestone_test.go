@@ -0,0 +1,104 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestSetMilestone(t *testing.T) {
+	var created, edited bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/quay/quay/milestones":
+			if got := r.URL.Query().Get("state"); got != "all" {
+				t.Errorf("got state %q, want all", got)
+			}
+			_, _ = io.WriteString(w, `[{"number": 1, "title": "quay-v3.8.1", "state": "closed"}]`)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/quay/quay/milestones":
+			created = true
+			_, _ = io.WriteString(w, `{"number": 2, "title": "quay-v3.8.2", "state": "open"}`)
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/quay/quay/issues/42":
+			edited = true
+			var req struct {
+				Milestone int `json:"milestone"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			if req.Milestone != 2 {
+				t.Errorf("got milestone %d, want 2", req.Milestone)
+			}
+			_, _ = io.WriteString(w, `{"number": 42}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	c := &Jira{githubClient: clients.NewGitHub(client)}
+
+	pr := &github.PullRequest{
+		Number: github.Int(42),
+		Base: &github.PullRequestBranch{
+			Repo: &github.Repository{
+				Name:  github.String("quay"),
+				Owner: &github.User{Login: github.String("quay")},
+			},
+		},
+	}
+	if err := c.setMilestone(context.Background(), pr, "quay-v3.8.2"); err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Errorf("the milestone should be created")
+	}
+	if !edited {
+		t.Errorf("the milestone should be set on the pull request")
+	}
+
+	created, edited = false, false
+	pr.Milestone = &github.Milestone{Title: github.String("quay-v3.8.2")}
+	if err := c.setMilestone(context.Background(), pr, "quay-v3.8.2"); err != nil {
+		t.Fatal(err)
+	}
+	if created || edited {
+		t.Errorf("the pull request already has the milestone")
+	}
+}
+
+func TestApplyRuleMilestoneFailure(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeGitHub.MilestoneErrors[fakes.RepoKey("quay", "quay")] = http.StatusInternalServerError
+	fakeJira := fakes.NewJira()
+	issue := fakeJira.AddIssue("PROJQUAY-123", "Bug", "POST")
+	fakeJira.Transitions[""] = []jira.Transition{{ID: "51", Name: "Move to QA", To: jira.Status{Name: "ON_QA"}}}
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	jiraConfig := configuration.Jira{Key: "PROJQUAY", SyncMilestones: true}
+	rule := configuration.JiraRule{SetFixVersion: true, TransitionTo: "ON_QA"}
+	err := c.applyRule(context.Background(), issue, pr, "quay-v3.8.2", jiraConfig, rule)
+	if err == nil {
+		t.Error("the milestone failure should be returned")
+	}
+	if len(fakeJira.PerformedTransitions) != 1 || fakeJira.PerformedTransitions[0].To != "ON_QA" {
+		t.Errorf("the issue should be transitioned despite the milestone failure, got %+v", fakeJira.PerformedTransitions)
+	}
+}
@@ -0,0 +1,120 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+type projectMetadata struct {
+	project  *jira.Project
+	statuses []string
+}
+
+type projectIssueTypeStatuses struct {
+	Name     string `json:"name"`
+	Statuses []struct {
+		Name string `json:"name"`
+	} `json:"statuses"`
+}
+
+func (c *Jira) fetchProjectStatuses(ctx context.Context, key string) ([]string, error) {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "GET", fmt.Sprintf("rest/api/2/project/%s/statuses", key), nil)
+	if err != nil {
+		return nil, err
+	}
+	var issueTypes []projectIssueTypeStatuses
+	resp, err := c.jiraClient.Do(req, &issueTypes)
+	if err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+
+	seen := map[string]bool{}
+	var statuses []string
+	for _, issueType := range issueTypes {
+		for _, status := range issueType.Statuses {
+			if !seen[status.Name] {
+				seen[status.Name] = true
+				statuses = append(statuses, status.Name)
+			}
+		}
+	}
+	return statuses, nil
+}
+
+func (c *Jira) projectMetadata(ctx context.Context, key string) (*projectMetadata, error) {
+	if metadata, ok := c.projectCache.Get(key); ok {
+		return metadata.(*projectMetadata), nil
+	}
+
+	project, _, err := c.jiraClient.Project.GetWithContext(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Jira project %s: %w", key, err)
+	}
+	statuses, err := c.fetchProjectStatuses(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statuses for Jira project %s: %w", key, err)
+	}
+
+	metadata := &projectMetadata{
+		project:  project,
+		statuses: statuses,
+	}
+	if c.projectCacheTTL > 0 {
+		c.projectCache.Add(key, metadata)
+	}
+	return metadata, nil
+}
+
+func (c *Jira) project(ctx context.Context, key string) (*jira.Project, error) {
+	metadata, err := c.projectMetadata(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.project, nil
+}
+
+// configuredStatuses returns the statuses that are referenced by the Jira
+// configuration, mapped to the places where they are used.
+func configuredStatuses(jiraConfig configuration.Jira) map[string][]string {
+	statuses := map[string][]string{}
+	for i, rule := range jiraConfig.Rules {
+		if rule.TransitionTo != "" {
+			statuses[rule.TransitionTo] = append(statuses[rule.TransitionTo], fmt.Sprintf("rules[%d].transition_to", i))
+		}
+		for _, status := range rule.When.Status {
+			statuses[status] = append(statuses[status], fmt.Sprintf("rules[%d].when.status", i))
+		}
+	}
+	if reopenTo := jiraConfig.ClosedIssues.ReopenTo; reopenTo != "" {
+		statuses[reopenTo] = append(statuses[reopenTo], "closed_issues.reopen_to")
+	}
+	return statuses
+}
+
+// ValidateStatuses checks that every status referenced by the Jira
+// configuration of the repositories exists in the workflows of the Jira
+// projects. Typos in transition_to would otherwise silently do nothing.
+func (c *Jira) ValidateStatuses(ctx context.Context, cfg *configuration.Configuration) []error {
+	var errs []error
+	for _, repo := range cfg.Repositories {
+		if repo.Jira.Key == "" {
+			continue
+		}
+		metadata, err := c.projectMetadata(ctx, repo.Jira.Key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", repo.Owner, repo.Repo, err))
+			continue
+		}
+		for status, fields := range configuredStatuses(repo.Jira) {
+			if !contains(metadata.statuses, status) {
+				for _, field := range fields {
+					errs = append(errs, fmt.Errorf("%s/%s: jira.%s: status %q does not exist in Jira project %s", repo.Owner, repo.Repo, field, status, repo.Jira.Key))
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,39 @@
+package checks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestConfiguredStatuses(t *testing.T) {
+	jiraConfig := configuration.Jira{
+		Rules: []configuration.JiraRule{
+			{
+				When: configuration.JiraCondition{
+					Status: []string{"New", "In Progress"},
+				},
+				TransitionTo: "Release Pending",
+			},
+			{
+				TransitionTo: "Release Pending",
+			},
+			{
+				Comment: "comment only",
+			},
+		},
+		ClosedIssues: configuration.JiraClosedIssues{
+			ReopenTo: "New",
+		},
+	}
+
+	want := map[string][]string{
+		"New":             {"rules[0].when.status", "closed_issues.reopen_to"},
+		"In Progress":     {"rules[0].when.status"},
+		"Release Pending": {"rules[0].transition_to", "rules[1].transition_to"},
+	}
+	if got := configuredStatuses(jiraConfig); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"k8s.io/klog/v2"
+)
+
+// fixVersionIssues returns the issues in the Jira project that have the fix
+// version.
+func (c *Jira) fixVersionIssues(ctx context.Context, projectKey, fixVersion string) ([]jira.Issue, error) {
+	jql := fmt.Sprintf("project = %q AND fixVersion = %q ORDER BY key ASC", projectKey, fixVersion)
+	opts := &jira.SearchOptions{
+		MaxResults: 100,
+		Fields:     []string{"summary", "issuetype", "security"},
+	}
+	var issues []jira.Issue
+	for {
+		page, resp, err := c.jiraClient.Issue.SearchWithContext(ctx, jql, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for issues with fix version %s: %w", fixVersion, jira.NewJiraError(resp, err))
+		}
+		issues = append(issues, page...)
+		if len(page) == 0 || resp == nil || resp.StartAt+len(page) >= resp.Total {
+			return issues, nil
+		}
+		opts.StartAt = resp.StartAt + len(page)
+	}
+}
+
+// ReleaseNotes renders the Markdown release notes for the Jira fix version.
+func (c *Jira) ReleaseNotes(ctx context.Context, projectKey, fixVersion string) (string, error) {
+	issues, err := c.fixVersionIssues(ctx, projectKey, fixVersion)
+	if err != nil {
+		return "", err
+	}
+	baseURL := c.jiraClient.GetBaseURL()
+	return releaseNotes(strings.TrimSuffix(baseURL.String(), "/"), fixVersion, issues), nil
+}
+
+// releaseNotes lists the issues grouped by their types. Issues with a
+// security level are left out so that embargoed issues are not disclosed.
+func releaseNotes(jiraURL, fixVersion string, issues []jira.Issue) string {
+	byType := map[string][]jira.Issue{}
+	for _, issue := range issues {
+		if level := securityLevel(&issue); level != "" {
+			klog.V(4).Infof("not adding issue %s to the release notes for %s: the issue has security level %s", issue.Key, fixVersion, level)
+			continue
+		}
+		issueType := "Other"
+		if issue.Fields != nil && issue.Fields.Type.Name != "" {
+			issueType = issue.Fields.Type.Name
+		}
+		byType[issueType] = append(byType[issueType], issue)
+	}
+
+	if len(byType) == 0 {
+		return fmt.Sprintf("There are no Jira issues with the fix version %s.\n", fixVersion)
+	}
+
+	var types []string
+	for issueType := range byType {
+		types = append(types, issueType)
+	}
+	sort.Strings(types)
+
+	var sb strings.Builder
+	for i, issueType := range types {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "## %s\n\n", issueType)
+		for _, issue := range byType[issueType] {
+			summary := ""
+			if issue.Fields != nil {
+				summary = issue.Fields.Summary
+			}
+			fmt.Fprintf(&sb, "- [%s](%s/browse/%s) %s\n", issue.Key, jiraURL, issue.Key, summary)
+		}
+	}
+	return sb.String()
+}
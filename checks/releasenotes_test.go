@@ -0,0 +1,34 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	issues := []jira.Issue{
+		{Key: "PROJQUAY-1", Fields: &jira.IssueFields{Summary: "Fix the crash", Type: jira.IssueType{Name: "Bug"}}},
+		{Key: "PROJQUAY-2", Fields: &jira.IssueFields{Summary: "Add the feature", Type: jira.IssueType{Name: "Story"}}},
+		{Key: "PROJQUAY-3", Fields: &jira.IssueFields{Summary: "Fix the CVE", Type: jira.IssueType{Name: "Bug"}, Unknowns: map[string]interface{}{
+			"security": map[string]interface{}{"name": "Embargoed"},
+		}}},
+		{Key: "PROJQUAY-4", Fields: &jira.IssueFields{Summary: "Fix the typo", Type: jira.IssueType{Name: "Bug"}}},
+	}
+
+	got := releaseNotes("https://issues.redhat.com", "quay-v3.8.1", issues)
+	want := "## Bug\n\n" +
+		"- [PROJQUAY-1](https://issues.redhat.com/browse/PROJQUAY-1) Fix the crash\n" +
+		"- [PROJQUAY-4](https://issues.redhat.com/browse/PROJQUAY-4) Fix the typo\n" +
+		"\n## Story\n\n" +
+		"- [PROJQUAY-2](https://issues.redhat.com/browse/PROJQUAY-2) Add the feature\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = releaseNotes("https://issues.redhat.com", "quay-v3.8.2", nil)
+	want = "There are no Jira issues with the fix version quay-v3.8.2.\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package checks
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxResultsPerRepository is how many results of the Jira check are kept for
+// each repository.
+const maxResultsPerRepository = 20
+
+// Result is an execution of the Jira check for a pull request. Rule is the
+// rule that matched, like rules[1], if the rules were applied.
+type Result struct {
+	PullRequest int       `json:"pullRequest"`
+	Event       Event     `json:"event"`
+	Conclusion  string    `json:"conclusion,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Rule        string    `json:"rule,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+	// Notify is set if the rule that matched has notify.
+	Notify bool `json:"-"`
+	// Restricted is set if the Jira issue has a security level.
+	Restricted bool `json:"-"`
+}
+
+// RepositoryResults are the recent results of the Jira check in a repository,
+// newest first.
+type RepositoryResults struct {
+	Repository string   `json:"repository"`
+	Results    []Result `json:"results"`
+}
+
+// results keeps the recent results of the Jira check in memory.
+type results struct {
+	mutex  sync.Mutex
+	byRepo map[string][]Result
+}
+
+func (r *results) add(repo string, result Result) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.byRepo == nil {
+		r.byRepo = map[string][]Result{}
+	}
+	list := append(r.byRepo[repo], result)
+	if len(list) > maxResultsPerRepository {
+		list = list[len(list)-maxResultsPerRepository:]
+	}
+	r.byRepo[repo] = list
+}
+
+func (r *results) recent() []RepositoryResults {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var recent []RepositoryResults
+	for repo, list := range r.byRepo {
+		repoResults := RepositoryResults{Repository: repo, Results: make([]Result, len(list))}
+		for i, result := range list {
+			repoResults.Results[len(list)-1-i] = result
+		}
+		recent = append(recent, repoResults)
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].Repository < recent[j].Repository
+	})
+	return recent
+}
+
+// OnResult makes the check call f with every result in the background, e.g.
+// to send notifications.
+func (c *Jira) OnResult(f func(repo string, result Result)) {
+	c.onResult = f
+}
+
+// RecentResults returns the recent results of the check for each repository.
+// It is safe to call RecentResults on a nil Jira.
+func (c *Jira) RecentResults() []RepositoryResults {
+	if c == nil {
+		return nil
+	}
+	return c.results.recent()
+}
@@ -0,0 +1,102 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+// SignedCommitsCheckName is the name that is used to refer to the signed
+// commits check in the configuration.
+const SignedCommitsCheckName = configuration.CheckSignedCommits
+
+// SignedCommitsCheckRunName is the name of the check run that is reported by
+// the signed commits check.
+const SignedCommitsCheckRunName = "Signed Commits"
+
+// signedCommitsRequired reports whether the pull requests against the branch
+// need signed commits.
+func signedCommitsRequired(cfg configuration.SignedCommits, branch string) bool {
+	for _, pattern := range cfg.Branches {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func signedCommitsResult(commits []*github.RepositoryCommit) (string, *github.CheckRunOutput) {
+	var unverified []string
+	for _, commit := range commits {
+		verification := commit.GetCommit().GetVerification()
+		if verification.GetVerified() {
+			continue
+		}
+		reason := verification.GetReason()
+		if reason == "" {
+			reason = "unsigned"
+		}
+		unverified = append(unverified, fmt.Sprintf("* %s %s (%s)", shortSHA(commit.GetSHA()), firstLine(commit.GetCommit().GetMessage()), reason))
+	}
+
+	if len(unverified) == 0 {
+		return "success", &github.CheckRunOutput{
+			Title:   github.String("All commits are signed"),
+			Summary: github.String("All commits have verified signatures.\n"),
+		}
+	}
+	return "failure", &github.CheckRunOutput{
+		Title: github.String(fmt.Sprintf("%d of %d commits are not verified", len(unverified), len(commits))),
+		Summary: github.String("Pull requests against this branch require commits with verified GPG or SSH signatures. The following commits are not verified:\n\n" +
+			strings.Join(unverified, "\n") + "\n\n" +
+			"See [signing commits](https://docs.github.com/en/authentication/managing-commit-signature-verification/signing-commits) to set up signing, then re-sign the commits and force-push the branch:\n\n" +
+			"```\n" +
+			fmt.Sprintf("git rebase --exec 'git commit --amend --no-edit -S' HEAD~%d\n", len(commits)) +
+			"git push --force-with-lease\n" +
+			"```\n"),
+	}
+}
+
+// SignedCommits is the check that requires the commits of pull requests
+// against some branches to be cryptographically signed.
+type SignedCommits struct {
+	githubClient *clients.GitHub
+	activity     *activity.Recorder
+}
+
+func NewSignedCommits(githubClient *clients.GitHub, recorder *activity.Recorder) *SignedCommits {
+	return &SignedCommits{
+		githubClient: githubClient,
+		activity:     recorder,
+	}
+}
+
+// Run reports the result of the check for the pull request. The check is
+// neutral if the base branch doesn't require signed commits, so that a
+// required check doesn't block the pull request.
+func (c *SignedCommits) Run(ctx context.Context, cfg configuration.SignedCommits, pr *github.PullRequest) error {
+	if !signedCommitsRequired(cfg, pr.GetBase().GetRef()) {
+		return reportCheckRun(ctx, c.githubClient, c.activity, pr, SignedCommitsCheckRunName, "neutral", &github.CheckRunOutput{
+			Title:   github.String("Signed commits are not required"),
+			Summary: github.String("The branch `" + pr.GetBase().GetRef() + "` doesn't require signed commits.\n"),
+		})
+	}
+	commits, err := listCommits(ctx, c.githubClient, pr)
+	if err != nil {
+		return err
+	}
+	conclusion, output := signedCommitsResult(commits)
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, SignedCommitsCheckRunName, conclusion, output)
+}
+
+func (c *SignedCommits) ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, SignedCommitsCheckRunName, "neutral", mutedOutput(owner, repo, mute))
+}
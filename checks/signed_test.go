@@ -0,0 +1,72 @@
+package checks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestSignedCommitsRequired(t *testing.T) {
+	cfg := configuration.SignedCommits{Branches: []string{"release-*", "redhat-3.8"}}
+	testCases := []struct {
+		branch string
+		want   bool
+	}{
+		{branch: "release-3.9", want: true},
+		{branch: "redhat-3.8", want: true},
+		{branch: "redhat-3.9", want: false},
+		{branch: "master", want: false},
+	}
+	for _, tc := range testCases {
+		if got := signedCommitsRequired(cfg, tc.branch); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.branch, got, tc.want)
+		}
+	}
+}
+
+func TestSignedCommitsResult(t *testing.T) {
+	commit := func(sha string, verified bool, reason string) *github.RepositoryCommit {
+		return &github.RepositoryCommit{
+			SHA: github.String(sha),
+			Commit: &github.Commit{
+				Message: github.String("Fix the bug"),
+				Verification: &github.SignatureVerification{
+					Verified: github.Bool(verified),
+					Reason:   github.String(reason),
+				},
+			},
+		}
+	}
+
+	if got, _ := signedCommitsResult([]*github.RepositoryCommit{commit("1111", true, "valid")}); got != "success" {
+		t.Errorf("got %s, want success", got)
+	}
+	got, output := signedCommitsResult([]*github.RepositoryCommit{commit("1111", true, "valid"), commit("2222", false, "unsigned")})
+	if got != "failure" {
+		t.Errorf("got %s, want failure", got)
+	}
+	if want := "1 of 2 commits are not verified"; output.GetTitle() != want {
+		t.Errorf("got title %q, want %q", output.GetTitle(), want)
+	}
+}
+
+func TestRunSignedCommitsNotRequired(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	c := NewSignedCommits(fakeGitHub.Client(), activity.NewRecorder(10))
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build")
+	fakeGitHub.Commits[fakes.IssueKey("quay", "quay", 1)] = []*github.RepositoryCommit{{
+		SHA:    github.String("1111"),
+		Commit: &github.Commit{Message: github.String("Fix the build")},
+	}}
+
+	if err := c.Run(context.Background(), configuration.SignedCommits{Branches: []string{"release-*"}}, pr); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeGitHub.CheckRuns) != 1 || fakeGitHub.CheckRuns[0].GetConclusion() != "neutral" {
+		t.Errorf("got the check runs %v, want a neutral one", fakeGitHub.CheckRuns)
+	}
+}
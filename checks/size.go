@@ -0,0 +1,142 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/apimachinery/pkg/util/errors"
+)
+
+// SizeCheckName is the name that is used to refer to the size check in the
+// configuration.
+const SizeCheckName = configuration.CheckSize
+
+// SizeCheckRunName is the name of the check run that is reported by the size
+// check.
+const SizeCheckRunName = "Pull Request Size"
+
+const sizeLabelPrefix = "size/"
+
+var sizeLabels = []struct {
+	name    string
+	maxSize int
+}{
+	{name: "XS", maxSize: 9},
+	{name: "S", maxSize: 29},
+	{name: "M", maxSize: 99},
+	{name: "L", maxSize: 499},
+	{name: "XL", maxSize: 999},
+	{name: "XXL", maxSize: -1},
+}
+
+// sizeLabel returns the label for the pull request with size changed lines.
+func sizeLabel(size int) string {
+	for _, l := range sizeLabels {
+		if l.maxSize == -1 || size <= l.maxSize {
+			return sizeLabelPrefix + l.name
+		}
+	}
+	panic("unreachable")
+}
+
+// excluded reports whether the file matches one of the patterns. Like in
+// .gitignore, a pattern without a slash matches any element of the path, and
+// other patterns match the path of the file or one of its parent directories.
+func excluded(filename string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if !strings.Contains(pattern, "/") {
+			for _, element := range strings.Split(filename, "/") {
+				if ok, _ := path.Match(pattern, element); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for p := filename; p != "." && p != "/"; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pullRequestSize(files []*github.CommitFile, exclude []string) int {
+	size := 0
+	for _, file := range files {
+		if excluded(file.GetFilename(), exclude) {
+			continue
+		}
+		size += file.GetAdditions() + file.GetDeletions()
+	}
+	return size
+}
+
+// Size is the check that labels pull requests by the number of changed lines.
+type Size struct {
+	githubClient *clients.GitHub
+	activity     *activity.Recorder
+}
+
+func NewSize(githubClient *clients.GitHub, recorder *activity.Recorder) *Size {
+	return &Size{
+		githubClient: githubClient,
+		activity:     recorder,
+	}
+}
+
+// setLabel replaces the size labels of the pull request with label.
+func (c *Size) setLabel(ctx context.Context, pr *github.PullRequest, label string) error {
+	return replacePrefixedLabels(ctx, c.githubClient, pr, sizeLabelPrefix, label)
+}
+
+func sizeResult(sizeConfig configuration.Size, size int) (string, *github.CheckRunOutput) {
+	summary := fmt.Sprintf("The pull request changes %d lines", size)
+	if len(sizeConfig.Exclude) > 0 {
+		summary += " (excluding `" + strings.Join(sizeConfig.Exclude, "`, `") + "`)"
+	}
+	summary += ".\n"
+	if sizeConfig.MaxSize > 0 && size > sizeConfig.MaxSize {
+		return "failure", &github.CheckRunOutput{
+			Title:   github.String(fmt.Sprintf("The pull request is too large: %d lines", size)),
+			Summary: github.String(summary + fmt.Sprintf("\nPull requests should not change more than %d lines, please split it into smaller pull requests.\n", sizeConfig.MaxSize)),
+		}
+	}
+	return "success", &github.CheckRunOutput{
+		Title:   github.String(fmt.Sprintf("%s: %d lines", sizeLabel(size), size)),
+		Summary: github.String(summary),
+	}
+}
+
+// Run labels the pull request with its size and reports the result of the
+// check.
+func (c *Size) Run(ctx context.Context, sizeConfig configuration.Size, pr *github.PullRequest) error {
+	files, err := listFiles(ctx, c.githubClient, pr)
+	if err != nil {
+		return err
+	}
+	size := pullRequestSize(files, sizeConfig.Exclude)
+
+	var errs []error
+	if err := c.setLabel(ctx, pr, sizeLabel(size)); err != nil {
+		errs = append(errs, err)
+	}
+	conclusion, output := sizeResult(sizeConfig, size)
+	if err := reportCheckRun(ctx, c.githubClient, c.activity, pr, SizeCheckRunName, conclusion, output); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.NewAggregate(errs)
+}
+
+func (c *Size) ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error {
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	return reportCheckRun(ctx, c.githubClient, c.activity, pr, SizeCheckRunName, "neutral", mutedOutput(owner, repo, mute))
+}
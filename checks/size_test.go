@@ -0,0 +1,50 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+)
+
+func TestSizeLabel(t *testing.T) {
+	testCases := []struct {
+		size int
+		want string
+	}{
+		{size: 0, want: "size/XS"},
+		{size: 9, want: "size/XS"},
+		{size: 10, want: "size/S"},
+		{size: 99, want: "size/M"},
+		{size: 100, want: "size/L"},
+		{size: 999, want: "size/XL"},
+		{size: 5000, want: "size/XXL"},
+	}
+	for _, tc := range testCases {
+		if got := sizeLabel(tc.size); got != tc.want {
+			t.Errorf("%d: got %s, want %s", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestPullRequestSize(t *testing.T) {
+	file := func(name string, additions, deletions int) *github.CommitFile {
+		return &github.CommitFile{
+			Filename:  github.String(name),
+			Additions: github.Int(additions),
+			Deletions: github.Int(deletions),
+		}
+	}
+	files := []*github.CommitFile{
+		file("main.go", 10, 5),
+		file("vendor/github.com/foo/bar/bar.go", 1000, 0),
+		file("web/node_modules/baz/index.js", 300, 0),
+		file("api/types.pb.go", 200, 100),
+		file("api/types.go", 3, 2),
+	}
+	if got, want := pullRequestSize(files, nil), 1620; got != want {
+		t.Errorf("got %d without exclusions, want %d", got, want)
+	}
+	if got, want := pullRequestSize(files, []string{"vendor/", "node_modules", "*.pb.go"}), 20; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
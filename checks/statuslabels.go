@@ -0,0 +1,47 @@
+package checks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+const statusLabelPrefix = "jira/"
+
+// statusLabel returns the label of the pull requests whose Jira issue is in
+// status, e.g. jira/in-progress for In Progress and jira/on-qa for ON_QA.
+func statusLabel(status string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(status) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return statusLabelPrefix + b.String()
+}
+
+// setStatusLabel replaces the status labels of the pull request with the
+// label of status. If status is empty, the status labels are removed. The
+// error is only logged and kept in result, the check itself is not affected.
+func (c *Jira) setStatusLabel(ctx context.Context, pr *github.PullRequest, status string, result *Result) {
+	err := replacePrefixedLabels(ctx, c.githubClient, pr, statusLabelPrefix, statusLabel(status))
+	if err != nil {
+		klog.V(2).Infof("%schecking pull request %s#%d: %v", logctx.Prefix(ctx), pr.GetBase().GetRepo().GetFullName(), pr.GetNumber(), err)
+		if result.Error == "" {
+			result.Error = err.Error()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package checks
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestStatusLabel(t *testing.T) {
+	for status, want := range map[string]string{
+		"In Progress":  "jira/in-progress",
+		"ON_QA":        "jira/on-qa",
+		"MODIFIED":     "jira/modified",
+		" Code Review": "jira/code-review",
+		"--":           "",
+		"":             "",
+	} {
+		if got := statusLabel(status); got != want {
+			t.Errorf("%q: got %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestRunStatusLabels(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	issue := fakeJira.AddIssue("PROJQUAY-123", "Bug", "In Progress")
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+	jiraConfig := configuration.Jira{Key: "PROJQUAY", StatusLabels: true}
+	branchConfig := configuration.Branch{Name: "master"}
+	ctx := context.Background()
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	key := fakes.IssueKey("quay", "quay", 1)
+	fakeGitHub.Labels[key] = []string{"size/S"}
+	pr.Labels = []*github.Label{{Name: github.String("size/S")}}
+	if err := c.Run(ctx, EventOpened, jiraConfig, branchConfig, pr); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"size/S", "jira/in-progress"}; !reflect.DeepEqual(fakeGitHub.Labels[key], want) {
+		t.Errorf("got the labels %q, want %q", fakeGitHub.Labels[key], want)
+	}
+
+	// The issue is moved to ON_QA in Jira.
+	issue.Fields.Status.Name = "ON_QA"
+	pr.Labels = append(pr.Labels, &github.Label{Name: github.String("jira/in-progress")})
+	if err := c.Run(ctx, EventRecheck, jiraConfig, branchConfig, pr); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"size/S", "jira/on-qa"}; !reflect.DeepEqual(fakeGitHub.Labels[key], want) {
+		t.Errorf("got the labels %q, want %q", fakeGitHub.Labels[key], want)
+	}
+
+	// The issue is removed from the title.
+	pr.Title = github.String("Fix the build")
+	pr.Labels = []*github.Label{{Name: github.String("size/S")}, {Name: github.String("jira/on-qa")}}
+	if err := c.Run(ctx, EventEdited, jiraConfig, branchConfig, pr); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"size/S"}; !reflect.DeepEqual(fakeGitHub.Labels[key], want) {
+		t.Errorf("got the labels %q, want %q", fakeGitHub.Labels[key], want)
+	}
+}
+
+func TestRunStatusLabelsAfterTransition(t *testing.T) {
+	fakeGitHub := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	fakeJira.Transitions[""] = []jira.Transition{
+		{ID: "11", Name: "Start Progress", To: jira.Status{Name: "In Progress"}},
+	}
+	c := NewJira(fakeGitHub.Client(), fakeGitHub.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute)
+	jiraConfig := configuration.Jira{
+		Key:          "PROJQUAY",
+		StatusLabels: true,
+		Rules: []configuration.JiraRule{{
+			When:         configuration.JiraCondition{Event: []string{"opened"}, Status: []string{"New"}},
+			TransitionTo: "In Progress",
+		}},
+	}
+
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	if err := c.Run(context.Background(), EventOpened, jiraConfig, configuration.Branch{Name: "master"}, pr); err != nil {
+		t.Fatal(err)
+	}
+	key := fakes.IssueKey("quay", "quay", 1)
+	if want := []string{"jira/in-progress"}; !reflect.DeepEqual(fakeGitHub.Labels[key], want) {
+		t.Errorf("the label should have the status after the transition, got %q, want %q", fakeGitHub.Labels[key], want)
+	}
+}
@@ -0,0 +1,58 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// The interfaces below are the subsets of the go-jira services that the app
+// uses.
+
+type JiraIssueService interface {
+	AddLinkWithContext(ctx context.Context, issueLink *jira.IssueLink) (*jira.Response, error)
+	CreateWithContext(ctx context.Context, issue *jira.Issue) (*jira.Issue, *jira.Response, error)
+	DoTransitionWithContext(ctx context.Context, ticketID, transitionID string) (*jira.Response, error)
+	GetTransitionsWithContext(ctx context.Context, id string) ([]jira.Transition, *jira.Response, error)
+	GetWithContext(ctx context.Context, issueID string, options *jira.GetQueryOptions) (*jira.Issue, *jira.Response, error)
+	SearchWithContext(ctx context.Context, jql string, options *jira.SearchOptions) ([]jira.Issue, *jira.Response, error)
+	UpdateIssueWithContext(ctx context.Context, jiraID string, data map[string]interface{}) (*jira.Response, error)
+}
+
+type JiraProjectService interface {
+	GetWithContext(ctx context.Context, projectID string) (*jira.Project, *jira.Response, error)
+}
+
+type JiraVersionService interface {
+	CreateWithContext(ctx context.Context, version *jira.Version) (*jira.Version, *jira.Response, error)
+	UpdateWithContext(ctx context.Context, version *jira.Version) (*jira.Version, *jira.Response, error)
+}
+
+// JiraRequestService sends raw API requests for the endpoints that go-jira
+// doesn't cover.
+type JiraRequestService interface {
+	NewRequestWithContext(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error)
+	Do(req *http.Request, v interface{}) (*jira.Response, error)
+	GetBaseURL() url.URL
+}
+
+// Jira mirrors the layout of *jira.Client, but every service is an interface.
+type Jira struct {
+	JiraRequestService
+
+	Issue   JiraIssueService
+	Project JiraProjectService
+	Version JiraVersionService
+}
+
+// NewJira wraps the services of the go-jira client.
+func NewJira(client *jira.Client) *Jira {
+	return &Jira{
+		JiraRequestService: client,
+		Issue:              client.Issue,
+		Project:            client.Project,
+		Version:            client.Version,
+	}
+}
@@ -0,0 +1,209 @@
+// Package cloudevents publishes the activity of the app as CloudEvents
+// (https://cloudevents.io) to an HTTP endpoint or a Kafka topic, so that
+// other systems can consume it.
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/retry"
+	"github.com/segmentio/kafka-go"
+	"k8s.io/klog/v2"
+)
+
+// TypePrefix is the prefix of the types of the events of the app.
+const TypePrefix = "com.github.quay.quay-ci-app."
+
+// Event is a CloudEvent in the structured JSON format.
+type Event struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// Sink sends the events somewhere.
+type Sink interface {
+	Send(ctx context.Context, e Event) error
+	Close() error
+}
+
+// NewSink returns the sink of the URL: an http or https URL that the events are
+// posted to, or kafka://broker1:9092,broker2:9092/topic.
+func NewSink(sinkURL string) (Sink, error) {
+	u, err := url.Parse(sinkURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CloudEvents sink: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPSink{
+			URL: sinkURL,
+			Client: &http.Client{
+				Timeout: 30 * time.Second,
+				Transport: &retry.Transport{
+					MaxRetries:     3,
+					InitialBackoff: time.Second,
+					MaxBackoff:     8 * time.Second,
+				},
+			},
+		}, nil
+	case "kafka":
+		topic := strings.Trim(u.Path, "/")
+		if u.Host == "" || topic == "" {
+			return nil, fmt.Errorf("invalid CloudEvents sink %q, expected kafka://broker:9092/topic", sinkURL)
+		}
+		return &KafkaSink{Writer: &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(u.Host, ",")...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+		}}, nil
+	}
+	return nil, fmt.Errorf("invalid CloudEvents sink %q, expected an http, https or kafka URL", sinkURL)
+}
+
+// HTTPSink posts the events to URL in the structured content mode.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *HTTPSink) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=UTF-8")
+	// The consumers deduplicate the events by their ID, so the event can be
+	// posted again if the sink fails.
+	req.Header.Set("Idempotency-Key", e.ID)
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from the CloudEvents sink: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	return nil
+}
+
+// KafkaSink writes the events to a Kafka topic in the structured content mode.
+// The subject is the key of the messages, so that the events of a pull request
+// or a branch stay in order.
+type KafkaSink struct {
+	Writer *kafka.Writer
+}
+
+func (s *KafkaSink) Send(ctx context.Context, e Event) error {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.Writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(e.Source + e.Subject),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/cloudevents+json; charset=UTF-8")},
+		},
+	})
+}
+
+func (s *KafkaSink) Close() error {
+	return s.Writer.Close()
+}
+
+// Publisher sends the events to the sink in the background. The events are
+// dropped if the sink can't keep up, publishing never blocks the app. It is
+// safe to call Publish on a nil Publisher.
+type Publisher struct {
+	sink  Sink
+	queue chan Event
+	now   func() time.Time
+
+	mutex  sync.Mutex
+	lastID int64
+	prefix string
+}
+
+func NewPublisher(sink Sink, queueSize int) *Publisher {
+	return &Publisher{
+		sink:  sink,
+		queue: make(chan Event, queueSize),
+		now:   time.Now,
+		// The IDs are unique for the source and the process.
+		prefix: strconv.FormatInt(time.Now().UnixNano(), 36) + "-",
+	}
+}
+
+// Publish queues the event of the type (without TypePrefix) about subject,
+// e.g. pull/1234, in the repository owner/repo.
+func (p *Publisher) Publish(eventType, repo, subject string, data interface{}) {
+	if p == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	p.lastID++
+	id := p.prefix + strconv.FormatInt(p.lastID, 10)
+	p.mutex.Unlock()
+
+	source := "https://github.com/" + repo
+	if repo == "" {
+		source = "https://github.com/quay/quay-ci-app"
+	}
+	e := Event{
+		SpecVersion:     "1.0",
+		ID:              id,
+		Source:          source,
+		Type:            TypePrefix + eventType,
+		Subject:         subject,
+		Time:            p.now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	select {
+	case p.queue <- e:
+	default:
+		klog.Warningf("dropping the CloudEvent %s %s, the queue is full", e.Type, e.ID)
+	}
+}
+
+// Run sends the queued events until ctx is done.
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := p.sink.Close(); err != nil {
+				klog.Errorf("failed to close the CloudEvents sink: %v", err)
+			}
+			return
+		case e := <-p.queue:
+			if err := p.sink.Send(ctx, e); err != nil {
+				klog.Errorf("failed to send the CloudEvent %s %s: %v", e.Type, e.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublisher(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/cloudevents+json; charset=UTF-8" {
+			t.Errorf("got content type %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var e map[string]interface{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("failed to decode %s: %v", body, err)
+		}
+		received <- e
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPublisher(sink, 10)
+	p.now = func() time.Time {
+		return time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	p.Publish("action.update_ref", "quay/quay", "heads/redhat-3.9", map[string]string{"outcome": "success"})
+
+	var e map[string]interface{}
+	select {
+	case e = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event was not sent")
+	}
+	if id, _ := e["id"].(string); id == "" {
+		t.Errorf("the event should have an id: %v", e)
+	}
+	delete(e, "id")
+	want := map[string]interface{}{
+		"specversion":     "1.0",
+		"source":          "https://github.com/quay/quay",
+		"type":            "com.github.quay.quay-ci-app.action.update_ref",
+		"subject":         "heads/redhat-3.9",
+		"time":            "2022-03-01T12:00:00Z",
+		"datacontenttype": "application/json",
+		"data":            map[string]interface{}{"outcome": "success"},
+	}
+	gotJSON, _ := json.Marshal(e)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("got %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestPublishFullQueue(t *testing.T) {
+	p := NewPublisher(&HTTPSink{}, 1)
+	p.Publish("webhook.push", "quay/quay", "push", nil)
+	p.Publish("webhook.push", "quay/quay", "push", nil)
+	if len(p.queue) != 1 {
+		t.Errorf("got %d queued events, want 1", len(p.queue))
+	}
+
+	var nilPublisher *Publisher
+	nilPublisher.Publish("webhook.push", "quay/quay", "push", nil)
+}
+
+func TestNewSink(t *testing.T) {
+	sink, err := NewSink("kafka://kafka-0:9092,kafka-1:9092/quay-ci-app-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kafkaSink, ok := sink.(*KafkaSink)
+	if !ok {
+		t.Fatalf("got %T, want a Kafka sink", sink)
+	}
+	if kafkaSink.Writer.Topic != "quay-ci-app-events" || kafkaSink.Writer.Addr.String() != "kafka-0:9092,kafka-1:9092" {
+		t.Errorf("got the topic %q at %s", kafkaSink.Writer.Topic, kafkaSink.Writer.Addr)
+	}
+
+	for _, sinkURL := range []string{"kafka://kafka-0:9092", "amqp://broker/events"} {
+		if _, err := NewSink(sinkURL); err == nil {
+			t.Errorf("%s: expected an error", sinkURL)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+type ConfigResponse struct {
+	Source        string                       `json:"source"`
+	LoadedAt      time.Time                    `json:"loadedAt"`
+	Configuration *configuration.Configuration `json:"configuration"`
+}
+
+// ConfigHandler serves GET /config, the configuration that the app currently
+// uses, with the secrets elided. It includes the repositories that are added
+// by the repository policies and discovery, and shows whether a change of the
+// configuration has been applied.
+type ConfigHandler struct {
+	cfg *configuration.Store
+}
+
+func (ch *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := ch.cfg.Get()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(ConfigResponse{
+		Source:        cfg.Source,
+		LoadedAt:      cfg.LoadedAt,
+		Configuration: cfg.Sanitized(),
+	})
+	if err != nil {
+		klog.Errorf("failed to encode the configuration: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestConfigHandler(t *testing.T) {
+	loadedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &configuration.Configuration{
+		AppID:        1,
+		Repositories: []configuration.Repository{{Owner: "quay", Repo: "quay"}},
+		TokenClients: []configuration.TokenClient{{Name: "release-tool", TokenSHA256: "0123456789abcdef"}},
+		Source:       "config.yaml",
+		LoadedAt:     loadedAt,
+	}
+	ch := &ConfigHandler{cfg: configuration.NewStore(cfg)}
+
+	w := httptest.NewRecorder()
+	ch.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "0123456789abcdef") {
+		t.Errorf("the token hash should be elided: %s", w.Body.String())
+	}
+	var resp ConfigResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Source != "config.yaml" || !resp.LoadedAt.Equal(loadedAt) {
+		t.Errorf("got source %q loaded at %s", resp.Source, resp.LoadedAt)
+	}
+	if len(resp.Configuration.Repositories) != 1 || resp.Configuration.TokenClients[0].Name != "release-tool" {
+		t.Errorf("got configuration %+v", resp.Configuration)
+	}
+	if cfg.TokenClients[0].TokenSHA256 != "0123456789abcdef" {
+		t.Errorf("the active configuration should not be changed")
+	}
+
+	w = httptest.NewRecorder()
+	ch.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d for POST", w.Code)
+	}
+}
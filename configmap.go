@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/kube"
+	"github.com/quay/quay-ci-app/taginformer"
+	"k8s.io/klog/v2"
+)
+
+// checkConfiguration checks the parts of the configuration that are parsed by
+// the app itself.
+func checkConfiguration(cfg *configuration.Configuration) error {
+	for _, repo := range cfg.Repositories {
+		if _, err := taginformer.ParseTagPattern(repo.TagPattern); err != nil {
+			return fmt.Errorf("invalid configuration for %s/%s: %w", repo.Owner, repo.Repo, err)
+		}
+		switch repo.AutoMerge.MethodOrDefault() {
+		case configuration.MergeMethodMerge, configuration.MergeMethodSquash, configuration.MergeMethodRebase:
+		default:
+			return fmt.Errorf("invalid configuration for %s/%s: unknown merge method %q", repo.Owner, repo.Repo, repo.AutoMerge.Method)
+		}
+	}
+	return nil
+}
+
+// restartRequired reports whether the new configuration changes the settings
+// that are applied only when the app starts.
+func restartRequired(old, new *configuration.Configuration) bool {
+	return old.AppID != new.AppID ||
+		old.InstallationID != new.InstallationID ||
+		!reflect.DeepEqual(old.TokenClients, new.TokenClients) ||
+		old.ConsistencyAudit != new.ConsistencyAudit
+}
+
+// configTarget is where a configuration is applied: the store, or a layer
+// that adds repositories to the configuration and applies it to the next
+// target, like the PolicyWatcher and the RepositoryDiscoverer.
+type configTarget interface {
+	Get() *configuration.Configuration
+	Set(cfg *configuration.Configuration)
+}
+
+// ConfigReloader reads the configuration from a ConfigMap through the
+// Kubernetes API. Unlike a mounted ConfigMap, which is updated by the kubelet
+// with a delay, the changes are applied as soon as they are watched.
+type ConfigReloader struct {
+	client    *kube.Client
+	namespace string
+	name      string
+	key       string
+
+	mutex           sync.Mutex
+	data            string
+	resourceVersion string
+}
+
+// NewConfigReloader returns a reloader for the ConfigMap name or
+// namespace/name. The namespace defaults to the namespace of the pod.
+func NewConfigReloader(configMap, key string) (*ConfigReloader, error) {
+	client, err := kube.NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace, name := "", configMap
+	if parts := strings.SplitN(configMap, "/", 2); len(parts) == 2 {
+		namespace, name = parts[0], parts[1]
+	}
+	if namespace == "" {
+		namespace, err = kube.InClusterNamespace()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the namespace of the pod: %w", err)
+		}
+	}
+	return &ConfigReloader{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		key:       key,
+	}, nil
+}
+
+func (cr *ConfigReloader) parse(cm *kube.ConfigMap) (*configuration.Configuration, error) {
+	data, ok := cm.Data[cr.key]
+	if !ok {
+		return nil, fmt.Errorf("the configmap %s/%s does not have the key %s", cr.namespace, cr.name, cr.key)
+	}
+	cfg, err := configuration.Load([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConfiguration(cfg); err != nil {
+		return nil, err
+	}
+	cfg.Source = fmt.Sprintf("configmap %s/%s, key %s, resource version %s", cr.namespace, cr.name, cr.key, cm.Metadata.ResourceVersion)
+	cfg.LoadedAt = time.Now()
+	return cfg, nil
+}
+
+// Load reads the current configuration.
+func (cr *ConfigReloader) Load(ctx context.Context) (*configuration.Configuration, error) {
+	cm, err := cr.client.GetConfigMap(ctx, cr.namespace, cr.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the configmap %s/%s: %w", cr.namespace, cr.name, err)
+	}
+	cfg, err := cr.parse(cm)
+	if err != nil {
+		return nil, err
+	}
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.data = cm.Data[cr.key]
+	cr.resourceVersion = cm.Metadata.ResourceVersion
+	return cfg, nil
+}
+
+// update applies the configuration from the ConfigMap to the target. An
+// invalid configuration is ignored, so that a bad edit doesn't stop the app.
+func (cr *ConfigReloader) update(ctx context.Context, cm *kube.ConfigMap, target configTarget, jiraCheck *checks.Jira) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	if cm.Data[cr.key] == cr.data {
+		return
+	}
+	cfg, err := cr.parse(cm)
+	if err != nil {
+		klog.Errorf("ignoring the invalid configuration from the configmap %s/%s (resource version %s): %v", cr.namespace, cr.name, cm.Metadata.ResourceVersion, err)
+		return
+	}
+	cr.data = cm.Data[cr.key]
+
+	old := target.Get()
+	target.Set(cfg)
+	klog.Infof("applied the configuration from the configmap %s/%s (resource version %s)", cr.namespace, cr.name, cm.Metadata.ResourceVersion)
+	if restartRequired(old, cfg) {
+		klog.Warningf("the changes of app_id, installation_id, token_clients and consistency_audit take effect after a restart")
+	}
+	if jiraCheck != nil {
+		for _, err := range jiraCheck.ValidateStatuses(ctx, cfg) {
+			klog.Warningf("invalid Jira configuration: %v", err)
+		}
+	}
+}
+
+// Watch applies the changes of the ConfigMap to the target until ctx is done.
+func (cr *ConfigReloader) Watch(ctx context.Context, target configTarget, jiraCheck *checks.Jira) {
+	cr.mutex.Lock()
+	resourceVersion := cr.resourceVersion
+	cr.mutex.Unlock()
+	cr.client.WatchConfigMap(ctx, cr.namespace, cr.name, resourceVersion, func(cm *kube.ConfigMap) {
+		cr.update(ctx, cm, target, jiraCheck)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/kube"
+)
+
+func TestConfigReloaderUpdate(t *testing.T) {
+	cr := &ConfigReloader{namespace: "ci", name: "quay-ci-app", key: "config.yaml"}
+	initial := &configuration.Configuration{AppID: 1}
+	store := configuration.NewStore(initial)
+	configMap := func(data string) *kube.ConfigMap {
+		return &kube.ConfigMap{Data: map[string]string{"config.yaml": data}}
+	}
+
+	cr.update(context.Background(), configMap("app_id: 1\nrepositories:\n- owner: quay\n  repo: quay\n"), store, nil)
+	if _, ok := store.Get().Repository("quay", "quay"); !ok {
+		t.Fatalf("the new configuration should be applied")
+	}
+	applied := store.Get()
+
+	for _, data := range []string{
+		"app_id: 1\nrepositories:\n- owner: quay\n  repo: quay\n",
+		"app_id: 1\nrepositories:\n- owner: quay\n  repo: quay\n  tag_pattern: nightly\n",
+		"app_id: 1\nrepostories: []\n",
+	} {
+		cr.update(context.Background(), configMap(data), store, nil)
+		if store.Get() != applied {
+			t.Errorf("%q: the configuration should not be replaced", data)
+		}
+	}
+	cr.update(context.Background(), &kube.ConfigMap{}, store, nil)
+	if store.Get() != applied {
+		t.Errorf("a configmap without the key should be ignored")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := &configuration.Configuration{AppID: 1, InstallationID: 2}
+	for _, tc := range []struct {
+		cfg  *configuration.Configuration
+		want bool
+	}{
+		{&configuration.Configuration{AppID: 1, InstallationID: 2, Repositories: []configuration.Repository{{Owner: "quay", Repo: "quay"}}}, false},
+		{&configuration.Configuration{AppID: 3, InstallationID: 2}, true},
+		{&configuration.Configuration{AppID: 1, InstallationID: 2, TokenClients: []configuration.TokenClient{{Name: "release-tool"}}}, true},
+		{&configuration.Configuration{AppID: 1, InstallationID: 2, ConsistencyAudit: configuration.ConsistencyAudit{Enabled: true}}, true},
+	} {
+		if got := restartRequired(old, tc.cfg); got != tc.want {
+			t.Errorf("%+v: got %t, want %t", tc.cfg, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// The names of the checks in the configuration.
+const (
+	CheckJira              = "jira"
+	CheckLabels            = "labels"
+	CheckSize              = "size"
+	CheckConventionalTitle = "conventional_title"
+	CheckDCO               = "dco"
+	CheckSignedCommits     = "signed_commits"
+	CheckCodeOwners        = "code_owners"
+)
+
+// checkOptions returns where the options of the checks are decoded to. Checks
+// without options are mapped to nil.
+func (r *Repository) checkOptions() map[string]interface{} {
+	return map[string]interface{}{
+		CheckJira:              &r.Jira,
+		CheckLabels:            &r.RequiredLabels,
+		CheckSize:              &r.Size,
+		CheckConventionalTitle: &r.ConventionalTitle,
+		CheckDCO:               nil,
+		CheckSignedCommits:     &r.SignedCommits,
+		CheckCodeOwners:        nil,
+	}
+}
+
+// applyCheckOptions validates the checks section and merges the options of the
+// checks into their sections of the repository configuration.
+func (r *Repository) applyCheckOptions() error {
+	options := r.checkOptions()
+	var names []string
+	for name := range r.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target, ok := options[name]
+		if !ok {
+			return fmt.Errorf("unknown check %q", name)
+		}
+		raw := r.Checks[name]
+		if len(raw) == 0 || string(raw) == "null" || string(raw) == "{}" {
+			continue
+		}
+		if target == nil {
+			return fmt.Errorf("the check %s does not have options", name)
+		}
+		if err := json.Unmarshal(raw, target); err != nil {
+			return fmt.Errorf("invalid options for the check %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// CheckEnabled reports whether the check is enabled for the repository. If
+// the repository doesn't have the checks section, the checks are enabled by
+// their own sections.
+func (r Repository) CheckEnabled(name string) bool {
+	if r.Checks != nil {
+		_, ok := r.Checks[name]
+		return ok
+	}
+	switch name {
+	case CheckJira:
+		return r.Jira.Key != ""
+	case CheckLabels:
+		return len(r.RequiredLabels) > 0
+	case CheckSize:
+		return r.Size.Enabled
+	case CheckConventionalTitle:
+		return r.ConventionalTitle.Enabled
+	case CheckDCO:
+		return r.DCO
+	case CheckSignedCommits:
+		return len(r.SignedCommits.Branches) > 0
+	case CheckCodeOwners:
+		return r.CodeOwners
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChecks(t *testing.T) {
+	cfg, err := Load([]byte(`
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+  dco: true
+- owner: quay
+  repo: quay-docs
+  jira:
+    key: PROJQUAY
+    release_versions: true
+  checks:
+    conventional_title:
+      types: [docs, fix]
+    labels: [kind/docs]
+    code_owners:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quay, _ := cfg.Repository("quay", "quay")
+	for name, want := range map[string]bool{CheckJira: true, CheckDCO: true, CheckConventionalTitle: false} {
+		if got := quay.CheckEnabled(name); got != want {
+			t.Errorf("quay/quay: %s: got %t, want %t", name, got, want)
+		}
+	}
+
+	docs, _ := cfg.Repository("quay", "quay-docs")
+	for name, want := range map[string]bool{CheckJira: false, CheckDCO: false, CheckConventionalTitle: true, CheckLabels: true, CheckCodeOwners: true} {
+		if got := docs.CheckEnabled(name); got != want {
+			t.Errorf("quay/quay-docs: %s: got %t, want %t", name, got, want)
+		}
+	}
+	if want := []string{"docs", "fix"}; !reflect.DeepEqual(docs.ConventionalTitle.Types, want) {
+		t.Errorf("got types %v, want %v", docs.ConventionalTitle.Types, want)
+	}
+	if want := []string{"kind/docs"}; !reflect.DeepEqual(docs.RequiredLabels, want) {
+		t.Errorf("got labels %v, want %v", docs.RequiredLabels, want)
+	}
+	if !docs.Jira.ReleaseVersions {
+		t.Errorf("the jira section should still be used for releases")
+	}
+
+	for _, input := range []string{
+		"repositories:\n- owner: quay\n  repo: quay\n  checks:\n    unknown: {}\n",
+		"repositories:\n- owner: quay\n  repo: quay\n  checks:\n    dco:\n      enabled: true\n",
+		"repositories:\n- owner: quay\n  repo: quay\n  checks:\n    size:\n      max_size: many\n",
+	} {
+		if _, err := Load([]byte(input)); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Defaults are the settings that the repositories inherit. A repository
+// overrides them field by field: objects are merged recursively, other values,
+// including lists, replace the defaults. In the checks section, a check that
+// is set to false is disabled for the repository, and true enables a check
+// with its default options.
+type Defaults struct {
+	Jira              Jira                       `json:"jira"`
+	Checks            map[string]json.RawMessage `json:"checks"`
+	TagCacheTTL       *Duration                  `json:"tag_cache_ttl"`
+	TagPattern        string                     `json:"tag_pattern"`
+	FlakyWorkflows    FlakyWorkflows             `json:"flaky_workflows"`
+	AutoMerge         AutoMerge                  `json:"auto_merge"`
+	RequiredLabels    []string                   `json:"required_labels"`
+	Size              Size                       `json:"size"`
+	ConventionalTitle ConventionalTitle          `json:"conventional_title"`
+	DCO               bool                       `json:"dco"`
+	SignedCommits     SignedCommits              `json:"signed_commits"`
+	CodeOwners        bool                       `json:"code_owners"`
+
+	// SyncCheck and SyncStatus are the defaults of sync_check and
+	// sync_status for the branches of the repositories, including release
+	// branches.
+	SyncCheck  *bool `json:"sync_check"`
+	SyncStatus *bool `json:"sync_status"`
+}
+
+// branchDefaults are the fields of Defaults that apply to the branches.
+var branchDefaults = []string{"sync_check", "sync_status"}
+
+// mergeObjects returns the defaults overridden by overrides.
+func mergeObjects(defaults, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults)+len(overrides))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if defaultObj, ok := merged[key].(map[string]interface{}); ok {
+			if obj, ok := value.(map[string]interface{}); ok {
+				merged[key] = mergeObjects(defaultObj, obj)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// setDefault sets the field of the object if it's not set.
+func setDefault(value interface{}, key string, defaultValue interface{}) {
+	if obj, ok := value.(map[string]interface{}); ok {
+		if _, ok := obj[key]; !ok {
+			obj[key] = defaultValue
+		}
+	}
+}
+
+// applyDefaults merges the defaults section of the decoded document into its
+// repositories.
+func applyDefaults(doc map[string]interface{}) {
+	defaults, _ := doc["defaults"].(map[string]interface{})
+	repos, _ := doc["repositories"].([]interface{})
+	repoDefaults := map[string]interface{}{}
+	for key, value := range defaults {
+		if !contains(branchDefaults, key) {
+			repoDefaults[key] = value
+		}
+	}
+
+	for i, value := range repos {
+		repo, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		repo = mergeObjects(repoDefaults, repo)
+
+		for _, key := range branchDefaults {
+			defaultValue, ok := defaults[key]
+			if !ok {
+				continue
+			}
+			branches, _ := repo["branches"].([]interface{})
+			for _, branch := range branches {
+				setDefault(branch, key, defaultValue)
+			}
+			setDefault(repo["release_branch"], key, defaultValue)
+		}
+
+		if checks, ok := repo["checks"].(map[string]interface{}); ok {
+			enabled := map[string]interface{}{}
+			for name, options := range checks {
+				switch options {
+				case false:
+				case true:
+					enabled[name] = nil
+				default:
+					enabled[name] = options
+				}
+			}
+			repo["checks"] = enabled
+		}
+		repos[i] = repo
+	}
+}
+
+// parseDocument parses the YAML document with the numbers kept as
+// json.Number, so that the IDs are not rounded.
+func parseDocument(buf []byte) (interface{}, error) {
+	jsonBuf, err := yaml.YAMLToJSON(buf)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBuf))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeDocument decodes the parsed document into cfg after merging the
+// defaults into the repositories.
+func decodeDocument(doc interface{}, cfg *Configuration) error {
+	var defaults map[string]interface{}
+	if obj, ok := doc.(map[string]interface{}); ok {
+		defaults, _ = obj["defaults"].(map[string]interface{})
+		applyDefaults(obj)
+	}
+	jsonBuf, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(jsonBuf, cfg); err != nil {
+		return err
+	}
+	for i := range cfg.Repositories {
+		repo := &cfg.Repositories[i]
+		if err := repo.applyCheckOptions(); err != nil {
+			return fmt.Errorf("%s/%s: %w", repo.Owner, repo.Repo, err)
+		}
+	}
+	cfg.defaults = defaults
+	return nil
+}
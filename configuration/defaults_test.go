@@ -0,0 +1,157 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+defaults:
+  jira:
+    key: PROJQUAY
+    sync_milestones: true
+    rules:
+    - when:
+        merged: true
+      transition_to: ON_QA
+  checks:
+    jira:
+    dco:
+    size:
+      max_size: 1000
+  size:
+    exclude: ["*.lock"]
+  sync_check: true
+  sync_status: true
+repositories:
+- owner: quay
+  repo: quay
+  branches:
+  - name: redhat-3.8
+    sync_from:
+      branch: release-3.8
+  - name: redhat-3.7
+    sync_from:
+      branch: release-3.7
+    sync_check: false
+  release_branch:
+    name: redhat-{version}
+    sync_from:
+      branch: release-{version}
+- owner: quay
+  repo: quay-docs
+  jira:
+    sync_milestones: false
+    rules:
+    - when:
+        merged: true
+      transition_to: Closed
+  checks:
+    dco: false
+    size:
+      max_size: 200
+    labels: [kind/docs]
+    code_owners: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quay, _ := cfg.Repository("quay", "quay")
+	if quay.Jira.Key != "PROJQUAY" || !quay.Jira.SyncMilestones || len(quay.Jira.Rules) != 1 || quay.Jira.Rules[0].TransitionTo != "ON_QA" {
+		t.Errorf("quay/quay should inherit the Jira section, got %+v", quay.Jira)
+	}
+	for name, want := range map[string]bool{CheckJira: true, CheckDCO: true, CheckSize: true, CheckLabels: false} {
+		if got := quay.CheckEnabled(name); got != want {
+			t.Errorf("quay/quay: %s: got %t, want %t", name, got, want)
+		}
+	}
+	if want := (Size{MaxSize: 1000, Exclude: []string{"*.lock"}}); !reflect.DeepEqual(quay.Size, want) {
+		t.Errorf("quay/quay: got size %+v, want %+v", quay.Size, want)
+	}
+	if !cfg.Branch("quay", "quay", "redhat-3.8").SyncCheck {
+		t.Errorf("redhat-3.8 should inherit sync_check")
+	}
+	if cfg.Branch("quay", "quay", "redhat-3.7").SyncCheck {
+		t.Errorf("redhat-3.7 overrides sync_check")
+	}
+	if !cfg.Branch("quay", "quay", "redhat-3.9").SyncCheck {
+		t.Errorf("the release branches should inherit sync_check")
+	}
+	if !cfg.Branch("quay", "quay", "redhat-3.7").SyncStatus || !cfg.Branch("quay", "quay", "redhat-3.9").SyncStatus {
+		t.Errorf("the branches should inherit sync_status")
+	}
+
+	docs, _ := cfg.Repository("quay", "quay-docs")
+	if docs.Jira.Key != "PROJQUAY" || docs.Jira.SyncMilestones {
+		t.Errorf("quay/quay-docs should override sync_milestones, got %+v", docs.Jira)
+	}
+	if len(docs.Jira.Rules) != 1 || docs.Jira.Rules[0].TransitionTo != "Closed" {
+		t.Errorf("quay/quay-docs should replace the rules, got %+v", docs.Jira.Rules)
+	}
+	for name, want := range map[string]bool{CheckJira: true, CheckDCO: false, CheckSize: true, CheckLabels: true, CheckCodeOwners: true} {
+		if got := docs.CheckEnabled(name); got != want {
+			t.Errorf("quay/quay-docs: %s: got %t, want %t", name, got, want)
+		}
+	}
+	if docs.Size.MaxSize != 200 {
+		t.Errorf("quay/quay-docs: got max_size %d, want 200", docs.Size.MaxSize)
+	}
+
+	if errs := cfg.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %q", errorStrings(errs))
+	}
+}
+
+func TestDefaultsUnknownFields(t *testing.T) {
+	_, err := Load([]byte(`
+defaults:
+  jira:
+    rules:
+    - when:
+        merged: true
+      transistion_to: ON_QA
+  branches: []
+  checks:
+    sise: {}
+`))
+	want := `3 problems in the configuration:
+  defaults.branches: unknown field
+  defaults.checks.sise: unknown check
+  defaults.jira.rules[0].transistion_to: unknown field`
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
+
+func TestDefaultsRulesWithoutKey(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+defaults:
+  jira:
+    rules:
+    - when:
+        merged: true
+      transition_to: ON_QA
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+- owner: quay
+  repo: quay-docs
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := cfg.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %q", errorStrings(errs))
+	}
+	if docs, _ := cfg.Repository("quay", "quay-docs"); docs.CheckEnabled(CheckJira) {
+		t.Errorf("the Jira check should not be enabled without a key")
+	}
+}
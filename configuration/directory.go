@@ -0,0 +1,197 @@
+package configuration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// repositorySource is where a repository is configured when the
+// configuration is loaded from a directory or the repository is added by
+// WithRepositories.
+type repositorySource struct {
+	File string
+	Path string
+}
+
+// document is a parsed file of a configuration directory.
+type document struct {
+	file string
+	obj  map[string]interface{}
+}
+
+// readDirectory parses the YAML files of the directory in the order of their
+// names. Subdirectories and other files are ignored.
+func readDirectory(dir string) ([]document, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var docs []document
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseDocument(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		obj, ok := doc.(map[string]interface{})
+		if !ok && doc != nil {
+			return nil, fmt.Errorf("%s: the configuration should be an object", file)
+		}
+		docs = append(docs, document{file: file, obj: obj})
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%s: no configuration files", dir)
+	}
+	return docs, nil
+}
+
+// listItemName returns the name of the item of a list that is merged by
+// mergeDocuments, e.g. owner/repo for repositories.
+func listItemName(key string, item interface{}) string {
+	obj, _ := item.(map[string]interface{})
+	switch key {
+	case "repositories":
+		return fmt.Sprintf("%v/%v", obj["owner"], obj["repo"])
+	case "token_clients":
+		return fmt.Sprintf("%v", obj["name"])
+	}
+	return ""
+}
+
+// mergeDocuments merges the files of a configuration directory. The
+// repositories and the token clients of the files are concatenated, and every
+// other field can be set only in one file. A repository or a token client
+// that is configured in several files is a conflict.
+func mergeDocuments(docs []document) (map[string]interface{}, []repositorySource, []*FieldError) {
+	merged := map[string]interface{}{}
+	var sources []repositorySource
+	var conflicts []*FieldError
+	fieldFiles := map[string]string{}
+	itemFiles := map[string]string{}
+	for _, doc := range docs {
+		for _, key := range sortedKeys(doc.obj) {
+			value := doc.obj[key]
+			switch key {
+			case "repositories", "token_clients":
+				items, ok := value.([]interface{})
+				if !ok {
+					merged[key] = value
+					continue
+				}
+				list, _ := merged[key].([]interface{})
+				for i, item := range items {
+					name := key + " " + listItemName(key, item)
+					if file, ok := itemFiles[name]; ok && file != doc.file {
+						conflicts = append(conflicts, &FieldError{
+							File:    doc.file,
+							Path:    indexPath(key, i),
+							Message: fmt.Sprintf("%s is already configured in %s", listItemName(key, item), file),
+						})
+						continue
+					}
+					itemFiles[name] = doc.file
+					list = append(list, item)
+					if key == "repositories" {
+						sources = append(sources, repositorySource{File: doc.file, Path: indexPath(key, i)})
+					}
+				}
+				merged[key] = list
+			default:
+				if file, ok := fieldFiles[key]; ok {
+					conflicts = append(conflicts, &FieldError{
+						File:    doc.file,
+						Path:    key,
+						Message: "is already set in " + file,
+					})
+					continue
+				}
+				fieldFiles[key] = doc.file
+				merged[key] = value
+			}
+		}
+	}
+	return merged, sources, conflicts
+}
+
+func decodeDirectory(dir string) (*Configuration, []*FieldError, error) {
+	docs, err := readDirectory(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unknown []*FieldError
+	for _, doc := range docs {
+		for _, fieldErr := range unknownFields("", doc.obj, reflect.TypeOf(Configuration{})) {
+			fieldErr.File = doc.file
+			unknown = append(unknown, fieldErr)
+		}
+	}
+	merged, sources, conflicts := mergeDocuments(docs)
+	if len(conflicts) > 0 {
+		return nil, unknown, FieldErrors(conflicts)
+	}
+	var cfg Configuration
+	if err := decodeDocument(merged, &cfg); err != nil {
+		return nil, unknown, err
+	}
+	cfg.sources = sources
+	return &cfg, unknown, nil
+}
+
+// DecodeFromFile reads the configuration like LoadFromFile, but returns the
+// unknown fields instead of rejecting them.
+func DecodeFromFile(filename string) (*Configuration, []*FieldError, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return decodeDirectory(filename)
+	}
+
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	unknown, err := UnknownFields(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := Decode(buf)
+	return cfg, unknown, err
+}
+
+// LoadFromFile reads the configuration from the YAML file or, if filename is
+// a directory, from its .yaml and .yml files. The files of a directory are
+// merged, so that each team can maintain the configuration of its
+// repositories in its own file. Unknown fields are rejected.
+func LoadFromFile(filename string) (*Configuration, error) {
+	cfg, unknown, err := DecodeFromFile(filename)
+	if len(unknown) > 0 {
+		return nil, FieldErrors(unknown)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg.Source = filename
+	cfg.LoadedAt = time.Now()
+	return cfg, nil
+}
+
+// repositoryLocation returns the file and the path of the i-th repository.
+func (c *Configuration) repositoryLocation(i int) (string, string) {
+	if i < len(c.sources) {
+		return c.sources[i].File, c.sources[i].Path
+	}
+	return "", indexPath("repositories", i)
+}
@@ -0,0 +1,103 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFromDirectory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"00-app.yaml": `
+app_id: 1
+installation_id: 2
+defaults:
+  jira:
+    key: PROJQUAY
+`,
+		"quay.yaml": `
+repositories:
+- owner: quay
+  repo: quay
+  branches:
+  - name: redhat-3.8
+    sync_from:
+      owner: quay
+      repo: quay-upstream
+      branch: release-3.8
+`,
+		"upstream.yml": `
+repositories:
+- owner: quay
+  repo: quay-upstream
+token_clients:
+- name: release-tool
+  permissions:
+    contents: read
+`,
+		"README.md":          "not a configuration file",
+		"old/ignored.yaml":   "app_id: 3",
+		"empty-for-now.yaml": "",
+	})
+
+	cfg, err := LoadFromFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AppID != 1 || cfg.InstallationID != 2 {
+		t.Errorf("got app_id %d, installation_id %d, want 1, 2", cfg.AppID, cfg.InstallationID)
+	}
+	if len(cfg.Repositories) != 2 || len(cfg.TokenClients) != 1 {
+		t.Fatalf("got %d repositories and %d token clients, want 2 and 1", len(cfg.Repositories), len(cfg.TokenClients))
+	}
+	if cfg.Jira("quay", "quay-upstream").Key != "PROJQUAY" {
+		t.Errorf("the defaults should apply to the repositories from other files")
+	}
+	if errs := cfg.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %q", errorStrings(errs))
+	}
+	if file, path := cfg.repositoryLocation(1); file != filepath.Join(dir, "upstream.yml") || path != "repositories[0]" {
+		t.Errorf("got location %s: %s, want upstream.yml: repositories[0]", file, path)
+	}
+}
+
+func TestLoadFromDirectoryErrors(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"empty": {"README.md": ""},
+		"conflict": {
+			"a.yaml": "repositories:\n- owner: quay\n  repo: quay\n",
+			"b.yaml": "repositories:\n- owner: quay\n  repo: quay\n",
+		},
+		"unknown field": {"a.yaml": "app_idd: 1\n"},
+		"not an object": {"a.yaml": "- app_id: 1\n"},
+	} {
+		if _, err := LoadFromFile(writeFiles(t, files)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"a.yaml": "consistency_audit:\n  enabled: true\n",
+		"b.yaml": "consistency_audit:\n  retry: true\n",
+	})
+	_, err := LoadFromFile(dir)
+	want := filepath.Join(dir, "b.yaml") + ": consistency_audit: is already set in " + filepath.Join(dir, "a.yaml")
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,35 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestDuration(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: `d: 90m`, want: 90 * time.Minute},
+		{input: `d: 72h`, want: 72 * time.Hour},
+		{input: `d: 90d`, want: 90 * 24 * time.Hour},
+		{input: `d: ninety days`, wantErr: true},
+		{input: `d: 5`, wantErr: true},
+	}
+	for _, tc := range testCases {
+		var v struct {
+			D Duration `json:"d"`
+		}
+		err := yaml.Unmarshal([]byte(tc.input), &v)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.input, err, tc.wantErr)
+			continue
+		}
+		if err == nil && v.D.Duration != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.input, v.D.Duration, tc.want)
+		}
+	}
+}
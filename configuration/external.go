@@ -0,0 +1,91 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ExternalRepository is a repository that is configured outside of the
+// configuration files, e.g. by a RepositoryPolicy resource. Source names the
+// repository in the errors, and Spec is the JSON or YAML object of the
+// repository, as it would be written in the repositories section.
+type ExternalRepository struct {
+	Source string
+	Spec   []byte
+}
+
+// decodeExternalRepository decodes the repository with the defaults of the
+// configuration merged into it.
+func (c *Configuration) decodeExternalRepository(er ExternalRepository) (Repository, []*FieldError) {
+	fail := func(path, format string, args ...interface{}) (Repository, []*FieldError) {
+		return Repository{}, []*FieldError{{File: er.Source, Path: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	doc, err := parseDocument(er.Spec)
+	if err != nil {
+		return fail("spec", "%v", err)
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return fail("spec", "should be an object")
+	}
+	if errs := unknownFields("spec", obj, repositoryType); len(errs) > 0 {
+		for _, err := range errs {
+			err.File = er.Source
+		}
+		return Repository{}, errs
+	}
+
+	repos := []interface{}{obj}
+	applyDefaults(map[string]interface{}{"defaults": c.defaults, "repositories": repos})
+	buf, err := json.Marshal(repos[0])
+	if err != nil {
+		return fail("spec", "%v", err)
+	}
+	var repo Repository
+	if err := json.Unmarshal(buf, &repo); err != nil {
+		return fail("spec", "%v", err)
+	}
+	if err := repo.applyCheckOptions(); err != nil {
+		return fail("spec.checks", "%v", err)
+	}
+	return repo, nil
+}
+
+// WithRepositories returns a copy of the configuration with the external
+// repositories added after the repositories of the configuration files. The
+// defaults apply to them as well. A repository that can't be decoded or
+// doesn't pass Validate, including a repository that is already configured,
+// is not added, and its problems are returned by its source.
+func (c *Configuration) WithRepositories(repos []ExternalRepository) (*Configuration, map[string][]*FieldError) {
+	cfg := *c
+	cfg.Repositories = append([]Repository(nil), c.Repositories...)
+	cfg.sources = make([]repositorySource, len(c.Repositories), len(c.Repositories)+len(repos))
+	for i := range c.Repositories {
+		cfg.sources[i].File, cfg.sources[i].Path = c.repositoryLocation(i)
+	}
+
+	problems := map[string][]*FieldError{}
+	for _, er := range repos {
+		repo, errs := c.decodeExternalRepository(er)
+		if len(errs) > 0 {
+			problems[er.Source] = errs
+			continue
+		}
+
+		candidate := cfg
+		candidate.Repositories = append(cfg.Repositories, repo)
+		candidate.sources = append(cfg.sources, repositorySource{File: er.Source, Path: "spec"})
+		for _, err := range candidate.Validate() {
+			if err.File == er.Source {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			problems[er.Source] = errs
+			continue
+		}
+		cfg = candidate
+	}
+	return &cfg, problems
+}
@@ -0,0 +1,70 @@
+package configuration
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWithRepositories(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+defaults:
+  jira:
+    key: PROJQUAY
+  checks:
+    labels: [approved]
+repositories:
+- owner: quay
+  repo: quay
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, problems := cfg.WithRepositories([]ExternalRepository{
+		{Source: "RepositoryPolicy ci/clair", Spec: []byte(`{"owner":"quay","repo":"clair","checks":{"labels":false}}`)},
+		{Source: "RepositoryPolicy ci/duplicate", Spec: []byte(`{"owner":"quay","repo":"quay"}`)},
+		{Source: "RepositoryPolicy ci/misspelled", Spec: []byte(`{"owner":"quay","repo":"quay-docs","tag_patern":"v*"}`)},
+		{Source: "RepositoryPolicy ci/sync", Spec: []byte(`{"owner":"quay","repo":"quay-docs","branches":[{"name":"main","sync_from":{"owner":"quay","repo":"unknown","branch":"main"}}]}`)},
+		{Source: "RepositoryPolicy ci/config-tool", Spec: []byte(`{"owner":"quay","repo":"config-tool","jira":{"key":"CONFIG"}}`)},
+		{Source: "RepositoryPolicy ci/another-clair", Spec: []byte(`{"owner":"quay","repo":"clair"}`)},
+	})
+
+	if len(cfg.Repositories) != 1 {
+		t.Errorf("the original configuration should not be changed, got %d repositories", len(cfg.Repositories))
+	}
+	var names []string
+	for _, repo := range got.Repositories {
+		names = append(names, repo.Owner+"/"+repo.Repo)
+	}
+	if want := "[quay/quay quay/clair quay/config-tool]"; fmt.Sprint(names) != want {
+		t.Errorf("got repositories %v, want %s", names, want)
+	}
+
+	clair, _ := got.Repository("quay", "clair")
+	if clair.Jira.Key != "PROJQUAY" {
+		t.Errorf("the defaults should apply to the external repositories, got Jira key %q", clair.Jira.Key)
+	}
+	if len(clair.RequiredLabels) > 0 {
+		t.Errorf("the labels check should be disabled, got %v", clair.RequiredLabels)
+	}
+	if configTool, _ := got.Repository("quay", "config-tool"); configTool.Jira.Key != "CONFIG" {
+		t.Errorf("got Jira key %q, want CONFIG", configTool.Jira.Key)
+	}
+
+	want := map[string]string{
+		"RepositoryPolicy ci/duplicate":     "[RepositoryPolicy ci/duplicate: spec: duplicate repository quay/quay, it is already configured in repositories[0]]",
+		"RepositoryPolicy ci/misspelled":    "[RepositoryPolicy ci/misspelled: spec.tag_patern: unknown field]",
+		"RepositoryPolicy ci/sync":          "[RepositoryPolicy ci/sync: spec.branches[0].sync_from: the source repository quay/unknown is not configured]",
+		"RepositoryPolicy ci/another-clair": "[RepositoryPolicy ci/another-clair: spec: duplicate repository quay/clair, it is already configured in RepositoryPolicy ci/clair]",
+	}
+	if len(problems) != len(want) {
+		t.Errorf("got problems %v", problems)
+	}
+	for source, errs := range want {
+		if got := fmt.Sprint(problems[source]); got != errs {
+			t.Errorf("%s: got %s, want %s", source, got, errs)
+		}
+	}
+}
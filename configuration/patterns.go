@@ -0,0 +1,48 @@
+package configuration
+
+import (
+	"path"
+	"strings"
+)
+
+// patternChars are the special characters of the repository patterns, see
+// path.Match.
+const patternChars = "*?["
+
+// IsPattern reports whether the owner or the name of the repository is a
+// glob pattern like "*" or "quay-*".
+func (r Repository) IsPattern() bool {
+	return strings.ContainsAny(r.Owner, patternChars) || strings.ContainsAny(r.Repo, patternChars)
+}
+
+// IsOwnerPattern reports whether the owner of the repository is a glob
+// pattern, in which case the repositories of the entry can't be searched by
+// their owner.
+func (r Repository) IsOwnerPattern() bool {
+	return strings.ContainsAny(r.Owner, patternChars)
+}
+
+func validPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// Matches reports whether the entry applies to the repository owner/repoName.
+func (r Repository) Matches(owner, repoName string) bool {
+	ownerMatch, _ := path.Match(r.Owner, owner)
+	repoMatch, _ := path.Match(r.Repo, repoName)
+	return ownerMatch && repoMatch
+}
+
+// ExplicitRepositories returns the repositories that are configured by name.
+// The periodic jobs, like syncing branches, only handle these repositories,
+// as the repositories that match patterns are known only from their events.
+func (c *Configuration) ExplicitRepositories() []Repository {
+	var repos []Repository
+	for _, repo := range c.Repositories {
+		if !repo.IsPattern() {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
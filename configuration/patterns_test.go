@@ -0,0 +1,67 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositoryPatterns(t *testing.T) {
+	cfg, err := Load([]byte(`
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+    fix_version_prefix: quay-v
+- owner: quay
+  repo: "quay-*"
+  jira:
+    key: PROJQUAY
+  branches:
+  - name: redhat-3.8
+    sync_from:
+      branch: release-3.8
+  mute:
+  - check: jira
+    until: 2100-01-01T00:00:00Z
+- owner: quay
+  repo: "*"
+  checks:
+    dco:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quay, ok := cfg.Repository("quay", "quay")
+	if !ok || quay.Jira.FixVersionPrefix != "quay-v" {
+		t.Errorf("the explicit entry should take precedence, got %+v", quay)
+	}
+
+	operator, ok := cfg.Repository("quay", "quay-operator")
+	if !ok || operator.Owner != "quay" || operator.Repo != "quay-operator" || operator.Jira.Key != "PROJQUAY" {
+		t.Errorf("quay/quay-operator should match quay/quay-*, got %+v", operator)
+	}
+	if source, _ := operator.SyncSource(cfg.Branch("quay", "quay-operator", "redhat-3.8")); source.String() != "quay/quay-operator:release-3.8" {
+		t.Errorf("got sync source %s, want the branch of the matched repository", source)
+	}
+	if _, ok := cfg.ActiveMute("quay", "quay-operator", CheckJira, time.Now()); !ok {
+		t.Errorf("the mute of the pattern should apply to quay/quay-operator")
+	}
+
+	clair, ok := cfg.Repository("quay", "clair")
+	if !ok || clair.Jira.Key != "" || !clair.CheckEnabled(CheckDCO) {
+		t.Errorf("quay/clair should match quay/*, got %+v", clair)
+	}
+	if _, ok := cfg.Repository("openshift", "clair"); ok {
+		t.Errorf("openshift/clair should not be configured")
+	}
+
+	explicit := cfg.ExplicitRepositories()
+	if len(explicit) != 1 || explicit[0].Repo != "quay" {
+		t.Errorf("got explicit repositories %+v, want quay/quay", explicit)
+	}
+	if refs := cfg.BranchesSyncedFrom("quay", "quay-operator", "release-3.8"); len(refs) != 0 {
+		t.Errorf("the branches of patterns should not be synced, got %v", refs)
+	}
+}
@@ -0,0 +1,71 @@
+package configuration
+
+import (
+	"regexp"
+	"strings"
+)
+
+// VersionPlaceholder is replaced with the y-stream, e.g. 3.9, in the release
+// branch template.
+const VersionPlaceholder = "{version}"
+
+var yStreamRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+
+// ReleaseBranch is the template of the branches that are created for new
+// y-streams. The {version} placeholder in Name, Version, FixVersion and
+// SyncFrom.Branch is replaced with the y-stream. If the branch is not synced
+// from another branch, it is created from From; the default is master.
+type ReleaseBranch struct {
+	Branch
+	From string `json:"from"`
+}
+
+func (rb ReleaseBranch) FromOrDefault() string {
+	if rb.From == "" {
+		return "master"
+	}
+	return rb.From
+}
+
+// ForVersion returns the configuration of the release branch for the
+// y-stream version.
+func (rb ReleaseBranch) ForVersion(version string) Branch {
+	branch := rb.Branch
+	branch.Name = strings.ReplaceAll(branch.Name, VersionPlaceholder, version)
+	branch.Version = strings.ReplaceAll(branch.Version, VersionPlaceholder, version)
+	branch.FixVersion = strings.ReplaceAll(branch.FixVersion, VersionPlaceholder, version)
+	branch.SyncFrom.Branch = strings.ReplaceAll(branch.SyncFrom.Branch, VersionPlaceholder, version)
+	return branch
+}
+
+// Match returns the y-stream of the release branch branchName.
+func (rb ReleaseBranch) Match(branchName string) (string, bool) {
+	return matchVersionTemplate(rb.Name, branchName)
+}
+
+// MatchSource returns the y-stream of the release branch that is synced from
+// the branch branchName.
+func (rb ReleaseBranch) MatchSource(branchName string) (string, bool) {
+	return matchVersionTemplate(rb.SyncFrom.Branch, branchName)
+}
+
+func matchVersionTemplate(template, s string) (string, bool) {
+	i := strings.Index(template, VersionPlaceholder)
+	if i == -1 {
+		return "", false
+	}
+	prefix, suffix := template[:i], template[i+len(VersionPlaceholder):]
+	if len(s) < len(prefix)+len(suffix) || !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) {
+		return "", false
+	}
+	version := s[len(prefix) : len(s)-len(suffix)]
+	if !yStreamRegex.MatchString(version) {
+		return "", false
+	}
+	return version, true
+}
+
+// ValidYStream reports whether version is a y-stream like 3.9.
+func ValidYStream(version string) bool {
+	return yStreamRegex.MatchString(version)
+}
@@ -0,0 +1,100 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReleaseBranch(t *testing.T) {
+	rb := ReleaseBranch{
+		Branch: Branch{
+			Name:    "redhat-{version}",
+			Version: "{version}",
+			SyncFrom: BranchReference{
+				Owner:  "quay",
+				Repo:   "quay",
+				Branch: "release-{version}",
+			},
+		},
+	}
+
+	got := rb.ForVersion("3.9")
+	want := Branch{
+		Name:    "redhat-3.9",
+		Version: "3.9",
+		SyncFrom: BranchReference{
+			Owner:  "quay",
+			Repo:   "quay",
+			Branch: "release-3.9",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	testCases := []struct {
+		branch  string
+		version string
+		ok      bool
+	}{
+		{branch: "redhat-3.9", version: "3.9", ok: true},
+		{branch: "redhat-3.10", version: "3.10", ok: true},
+		{branch: "redhat-3", ok: false},
+		{branch: "redhat-3.9.1", ok: false},
+		{branch: "redhat-", ok: false},
+		{branch: "master", ok: false},
+	}
+	for _, tc := range testCases {
+		version, ok := rb.Match(tc.branch)
+		if version != tc.version || ok != tc.ok {
+			t.Errorf("%s: got %q, %t, want %q, %t", tc.branch, version, ok, tc.version, tc.ok)
+		}
+	}
+
+	if version, ok := rb.MatchSource("release-3.9"); !ok || version != "3.9" {
+		t.Errorf("release-3.9: got %q, %t, want 3.9, true", version, ok)
+	}
+}
+
+func TestReleaseBranchLookup(t *testing.T) {
+	cfg := &Configuration{
+		Repositories: []Repository{
+			{
+				Owner: "quay",
+				Repo:  "quay",
+				ReleaseBranch: &ReleaseBranch{
+					Branch: Branch{Name: "release-{version}", Version: "{version}"},
+				},
+			},
+			{
+				Owner: "quay",
+				Repo:  "quay-downstream",
+				Branches: []Branch{
+					{Name: "redhat-3.8", Version: "3.8", SyncFrom: BranchReference{Owner: "quay", Repo: "quay", Branch: "release-3.8"}},
+				},
+				ReleaseBranch: &ReleaseBranch{
+					Branch: Branch{Name: "redhat-{version}", Version: "{version}", SyncFrom: BranchReference{Owner: "quay", Repo: "quay", Branch: "release-{version}"}},
+				},
+			},
+		},
+	}
+
+	if got := cfg.Branch("quay", "quay", "release-3.9"); got.Version != "3.9" {
+		t.Errorf("got version %q for release-3.9, want 3.9", got.Version)
+	}
+	if got := cfg.Branch("quay", "quay", "master"); got.Version != "" {
+		t.Errorf("got version %q for master, want none", got.Version)
+	}
+
+	got := cfg.BranchesSyncedFrom("quay", "quay", "release-3.9")
+	want := []BranchReference{{Owner: "quay", Repo: "quay-downstream", Branch: "redhat-3.9"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = cfg.BranchesSyncedFrom("quay", "quay", "release-3.8")
+	want = []BranchReference{{Owner: "quay", Repo: "quay-downstream", Branch: "redhat-3.8"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package configuration
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema that describes the configuration.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+
+	// Required and PreserveUnknownFields are only used in the schema of
+	// the RepositoryPolicy custom resource.
+	Required              []string `json:"required,omitempty"`
+	PreserveUnknownFields bool     `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+}
+
+var (
+	durationType = reflect.TypeOf(Duration{})
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// schemaEnums are the allowed values of the string fields, by the struct type
+// and the field name.
+var schemaEnums = map[reflect.Type]map[string][]string{
+	reflect.TypeOf(JiraCondition{}):    {"event": JiraRuleEvents},
+	reflect.TypeOf(JiraClosedIssues{}): {"action": {ClosedIssueActionWarn, ClosedIssueActionFail, ClosedIssueActionReopen}},
+	reflect.TypeOf(AutoMerge{}):        {"method": {MergeMethodMerge, MergeMethodSquash, MergeMethodRebase}},
+	reflect.TypeOf(CheckMute{}):        {"check": checkNames()},
+	reflect.TypeOf(Branch{}):           {"strategy": {SyncStrategyFFOnly, SyncStrategyForce, SyncStrategyMerge}},
+}
+
+func checkNames() []string {
+	var names []string
+	for name := range (&Repository{}).checkOptions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func typeSchema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case durationType:
+		return &jsonSchema{
+			Type:        "string",
+			Pattern:     `^([0-9]+d|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`,
+			Description: "A duration like 90m, 72h or 90d.",
+		}
+	case timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Struct:
+		s := &jsonSchema{
+			Type:                 "object",
+			Properties:           map[string]*jsonSchema{},
+			AdditionalProperties: false,
+		}
+		for name, typ := range jsonFields(t) {
+			if (t == repositoryType || t == defaultsType) && name == "checks" {
+				s.Properties[name] = checksSchema()
+				continue
+			}
+			field := typeSchema(typ)
+			if enum, ok := schemaEnums[t][name]; ok {
+				if field.Items != nil {
+					field.Items.Enum = enum
+				} else {
+					field.Enum = enum
+				}
+			}
+			s.Properties[name] = field
+		}
+		return s
+	}
+	return &jsonSchema{}
+}
+
+// checksSchema describes the checks section, where the checks are mapped to
+// the options of their sections. The options can be omitted, and the checks
+// without options only accept an empty object. A check can also be set to true
+// or false to enable or disable it, e.g. to opt out of a check from the
+// defaults.
+func checksSchema() *jsonSchema {
+	s := &jsonSchema{
+		Type:                 "object",
+		Properties:           map[string]*jsonSchema{},
+		AdditionalProperties: false,
+	}
+	for name, target := range (&Repository{}).checkOptions() {
+		if target == nil {
+			s.Properties[name] = &jsonSchema{
+				Type:                 []string{"object", "null", "boolean"},
+				AdditionalProperties: false,
+				Description:          "The check does not have options.",
+			}
+			continue
+		}
+		options := typeSchema(reflect.TypeOf(target))
+		options.Type = []interface{}{options.Type, "null", "boolean"}
+		s.Properties[name] = options
+	}
+	return s
+}
+
+// Schema returns the JSON Schema of the configuration file. It is published
+// as config.schema.json for editors.
+func Schema() ([]byte, error) {
+	s := typeSchema(reflect.TypeOf(Configuration{}))
+	s.Schema = "http://json-schema.org/draft-07/schema#"
+	s.Title = "Quay CI App configuration"
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+// structuralSchema converts the schema into a structural schema that
+// Kubernetes accepts for custom resources: every node has a single type, and
+// the values that can have several types, like the options of the checks, are
+// not pruned by the API server and are left to Validate.
+func structuralSchema(s *jsonSchema) *jsonSchema {
+	out := &jsonSchema{
+		Description: s.Description,
+		Format:      s.Format,
+		Pattern:     s.Pattern,
+		Enum:        s.Enum,
+	}
+	typ, ok := s.Type.(string)
+	if !ok {
+		out.PreserveUnknownFields = true
+		return out
+	}
+	out.Type = typ
+	if s.Items != nil {
+		out.Items = structuralSchema(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = map[string]*jsonSchema{}
+		for name, property := range s.Properties {
+			out.Properties[name] = structuralSchema(property)
+		}
+	}
+	if additional, ok := s.AdditionalProperties.(*jsonSchema); ok {
+		out.AdditionalProperties = structuralSchema(additional)
+	}
+	return out
+}
+
+// RepositoryResourceSchema returns the OpenAPI schema of a repository for the
+// spec of the RepositoryPolicy custom resource.
+func RepositoryResourceSchema() json.RawMessage {
+	s := structuralSchema(typeSchema(repositoryType))
+	s.Required = []string{"owner", "repo"}
+	buf, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
@@ -0,0 +1,60 @@
+package configuration
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestSchemaIsUpToDate(t *testing.T) {
+	want, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("config.schema.json is out of date, run make schema")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	buf, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties struct {
+			Repositories struct {
+				Items struct {
+					Properties map[string]struct {
+						Type       interface{}                `json:"type"`
+						Properties map[string]json.RawMessage `json:"properties"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"repositories"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(buf, &schema); err != nil {
+		t.Fatal(err)
+	}
+	repo := schema.Properties.Repositories.Items.Properties
+	for _, name := range []string{"owner", "jira", "release_branch", "checks", "tag_cache_ttl"} {
+		if _, ok := repo[name]; !ok {
+			t.Errorf("the repository schema does not have %s", name)
+		}
+	}
+	if _, ok := repo["release_branch"].Properties["from"]; !ok {
+		t.Errorf("the release branch schema should include the fields of the embedded branch and its own")
+	}
+	if _, ok := repo["release_branch"].Properties["sync_from"]; !ok {
+		t.Errorf("the release branch schema should include the fields of the embedded branch")
+	}
+	for _, name := range checkNames() {
+		if _, ok := repo["checks"].Properties[name]; !ok {
+			t.Errorf("the checks schema does not have %s", name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package configuration
+
+import (
+	"sync/atomic"
+)
+
+// Store holds the current configuration of the app. The configuration can be
+// replaced while the app is running, so the handlers get it from the store for
+// every event instead of keeping it. An event is handled with the
+// configuration that it got first, so that a reload in the middle doesn't mix
+// two configurations.
+type Store struct {
+	value atomic.Value
+}
+
+func NewStore(cfg *Configuration) *Store {
+	s := &Store{}
+	s.Set(cfg)
+	return s
+}
+
+// Get returns the current configuration.
+func (s *Store) Get() *Configuration {
+	return s.value.Load().(*Configuration)
+}
+
+// Set replaces the configuration.
+func (s *Store) Set(cfg *Configuration) {
+	s.value.Store(cfg)
+}
@@ -1,30 +1,134 @@
 package configuration
 
 import (
-	"os"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
-	"sigs.k8s.io/yaml"
+	"github.com/google/go-github/v42/github"
 )
 
 type JiraCondition struct {
 	Status        []string `json:"status"`
 	Merged        *bool    `json:"merged"`
 	HasFixVersion *bool    `json:"has_fix_version"`
-	Event         []string `json:"event"`
+	HasQAContact  *bool    `json:"has_qa_contact"`
+	// AllPullRequestsMerged matches if no other pull request referencing the
+	// same issue is open in the repositories of the same owner.
+	AllPullRequestsMerged *bool     `json:"all_pull_requests_merged"`
+	UpdatedWithin         *Duration `json:"updated_within"`
+	NotUpdatedFor         *Duration `json:"not_updated_for"`
+	Event                 []string  `json:"event"`
 }
 
 type JiraRule struct {
-	TransitionTo  string        `json:"transition_to"`
-	SetFixVersion bool          `json:"set_fix_version"`
-	When          JiraCondition `json:"when"`
-	Comment       string        `json:"comment"`
+	TransitionTo     string        `json:"transition_to"`
+	SetFixVersion    bool          `json:"set_fix_version"`
+	RemoveFixVersion bool          `json:"remove_fix_version"`
+	When             JiraCondition `json:"when"`
+	Comment          string        `json:"comment"`
+	// Notify sends a notification when the rule is applied, see
+	// Notifications.
+	Notify bool `json:"notify"`
+}
+
+// DefaultQAContactField is the Jira custom field that holds the QA contact
+// on issues.redhat.com.
+const DefaultQAContactField = "customfield_12315948"
+
+// JiraSecurityLevel describes how issues with a security level (for example,
+// embargoed CVEs) are handled. By default, the app doesn't add comments to
+// such issues so that pull request URLs are not leaked.
+type JiraSecurityLevel struct {
+	AllowComments bool `json:"allow_comments"`
+	FailCheck     bool `json:"fail_check"`
+}
+
+// JiraBackport configures how backports are tracked. If Clone is set, opening
+// a pull request against a release branch for an issue that is fixed in
+// another stream creates a clone of the issue for the branch stream.
+type JiraBackport struct {
+	Clone    bool   `json:"clone"`
+	LinkType string `json:"link_type"`
+}
+
+func (b JiraBackport) LinkTypeOrDefault() string {
+	if b.LinkType == "" {
+		return "Cloners"
+	}
+	return b.LinkType
+}
+
+const (
+	ClosedIssueActionWarn   = "warn"
+	ClosedIssueActionFail   = "fail"
+	ClosedIssueActionReopen = "reopen"
+)
+
+// JiraClosedIssues configures what happens when an open pull request
+// references an issue that is already in one of Statuses. The issue is
+// reopened only when the pull request is opened or edited, warn and fail
+// apply to every check of the open pull request.
+type JiraClosedIssues struct {
+	Statuses []string `json:"statuses"`
+	Action   string   `json:"action"`
+	ReopenTo string   `json:"reopen_to"`
+}
+
+func (ci JiraClosedIssues) StatusesOrDefault() []string {
+	if len(ci.Statuses) == 0 {
+		return []string{"Closed", "Verified"}
+	}
+	return ci.Statuses
 }
 
 type Jira struct {
-	Key              string     `json:"key"`
-	FixVersionPrefix string     `json:"fix_version_prefix"`
-	ValidIssueTypes  []string   `json:"valid_issue_types"`
-	Rules            []JiraRule `json:"rules"`
+	Key                 string            `json:"key"`
+	FixVersionPrefix    string            `json:"fix_version_prefix"`
+	ValidIssueTypes     []string          `json:"valid_issue_types"`
+	QAContactField      string            `json:"qa_contact_field"`
+	CheckAffectsVersion bool              `json:"check_affects_version"`
+	VersionContact      string            `json:"version_contact"`
+	CreateFixVersions   bool              `json:"create_fix_versions"`
+	ReleaseVersions     bool              `json:"release_versions"`
+	ReconcileVersions   bool              `json:"reconcile_versions"`
+	SyncMilestones      bool              `json:"sync_milestones"`
+	ReleaseNotes        bool              `json:"release_notes"`
+	StatusLabels        bool              `json:"status_labels"`
+	SecurityLevel       JiraSecurityLevel `json:"security_level"`
+	Backport            JiraBackport      `json:"backport"`
+	ClosedIssues        JiraClosedIssues  `json:"closed_issues"`
+	Rules               []JiraRule        `json:"rules"`
+}
+
+func (j Jira) UsesAllPullRequestsMerged() bool {
+	for _, rule := range j.Rules {
+		if rule.When.AllPullRequestsMerged != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (j Jira) SetsFixVersion() bool {
+	for _, rule := range j.Rules {
+		if rule.SetFixVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func (j Jira) QAContactFieldOrDefault() string {
+	if j.QAContactField == "" {
+		return DefaultQAContactField
+	}
+	return j.QAContactField
 }
 
 type BranchReference struct {
@@ -37,41 +141,449 @@ func (br BranchReference) String() string {
 	return br.Owner + "/" + br.Repo + ":" + br.Branch
 }
 
+// Branch is the configuration for a branch. FixVersion is the Jira fix version
+// for pull requests against the branch; if it is empty, the fix version is
+// computed from Version and the tags of the repository.
 type Branch struct {
-	Name     string          `json:"name"`
-	Version  string          `json:"version"`
-	SyncFrom BranchReference `json:"sync_from"`
+	Name       string          `json:"name"`
+	Version    string          `json:"version"`
+	FixVersion string          `json:"fix_version"`
+	SyncFrom   BranchReference `json:"sync_from"`
+	SyncCheck  bool            `json:"sync_check"`
+	// SyncStatus reports the sync state as a commit status on the head of
+	// the branch.
+	SyncStatus bool `json:"sync_status"`
+	// Strategy is how the branch is updated from SyncFrom, ff-only by
+	// default.
+	Strategy string `json:"strategy"`
+}
+
+const (
+	// SyncStrategyFFOnly only fast-forwards the branch, the sync fails if
+	// the branch has diverged from the source.
+	SyncStrategyFFOnly = "ff-only"
+	// SyncStrategyForce resets the branch to the source, dropping the
+	// commits that are only on the branch.
+	SyncStrategyForce = "force"
+	// SyncStrategyMerge fast-forwards the branch when possible and merges
+	// the source into it when the histories have diverged.
+	SyncStrategyMerge = "merge"
+)
+
+func (b Branch) StrategyOrDefault() string {
+	if b.Strategy == "" {
+		return SyncStrategyFFOnly
+	}
+	return b.Strategy
+}
+
+// MirrorTags creates the tags that are pushed to the repository Owner/Repo in
+// the repository that has the section. If Pattern is set, only the tags that
+// match it (see path.Match) are mirrored. Owner defaults to the owner of the
+// repository.
+type MirrorTags struct {
+	Owner   string `json:"owner"`
+	Repo    string `json:"repo"`
+	Pattern string `json:"pattern"`
+}
+
+// CheckMute disables the enforcement of the check Check until Until. Muted
+// checks are reported with the neutral conclusion.
+type CheckMute struct {
+	Check  string    `json:"check"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
 }
 
+// Repository is the configuration for a GitHub repository. Dispatch maps
+// repository_dispatch event types to the app handlers. TagCacheTTL overrides
+// how often the cached version tags of the repository are refreshed.
+// TagPattern is the template of the release tags, e.g. quay-v{version}; the
+// default is v{version}. ReleaseBranch configures the branches of the
+// y-streams that are not listed in Branches. Owner and Repo can be glob
+// patterns, e.g. "*", to configure the repositories that are not listed.
 type Repository struct {
-	Owner    string   `json:"owner"`
-	Repo     string   `json:"repo"`
-	Jira     Jira     `json:"jira"`
-	Branches []Branch `json:"branches"`
+	Owner             string            `json:"owner"`
+	Repo              string            `json:"repo"`
+	Jira              Jira              `json:"jira"`
+	Branches          []Branch          `json:"branches"`
+	ReleaseBranch     *ReleaseBranch    `json:"release_branch"`
+	Mute              []CheckMute       `json:"mute"`
+	Dispatch          map[string]string `json:"dispatch"`
+	TagCacheTTL       *Duration         `json:"tag_cache_ttl"`
+	TagPattern        string            `json:"tag_pattern"`
+	FlakyWorkflows    FlakyWorkflows    `json:"flaky_workflows"`
+	AutoMerge         AutoMerge         `json:"auto_merge"`
+	RequiredLabels    []string          `json:"required_labels"`
+	Size              Size              `json:"size"`
+	ConventionalTitle ConventionalTitle `json:"conventional_title"`
+	DCO               bool              `json:"dco"`
+	SignedCommits     SignedCommits     `json:"signed_commits"`
+	CodeOwners        bool              `json:"code_owners"`
+	SyncFailureIssue  *SyncFailureIssue `json:"sync_failure_issue"`
+	MirrorTags        *MirrorTags       `json:"mirror_tags"`
+	// Checks lists the checks that are enabled for the repository, mapped
+	// to their options. The options are merged into the sections of the
+	// checks, e.g. the options of the jira check into Jira.
+	Checks map[string]json.RawMessage `json:"checks"`
 }
 
-type Configuration struct {
-	AppID          int64        `json:"app_id"`
-	InstallationID int64        `json:"installation_id"`
-	Repositories   []Repository `json:"repositories"`
+// hasBranch reports whether the branch is listed in Branches.
+func (r Repository) hasBranch(branchName string) bool {
+	for _, branch := range r.Branches {
+		if branch.Name == branchName {
+			return true
+		}
+	}
+	return false
 }
 
-func (c *Configuration) Jira(owner, repoName string) Jira {
-	for _, repo := range c.Repositories {
-		if repo.Owner == owner && repo.Repo == repoName {
-			return repo.Jira
+func (r Repository) TagCacheTTLOrDefault(defaultTTL time.Duration) time.Duration {
+	if r.TagCacheTTL == nil {
+		return defaultTTL
+	}
+	return r.TagCacheTTL.Duration
+}
+
+// SyncFailureIssue opens an issue in the repository when one of its branches
+// has been failing to sync for After, and closes it when the branch is synced
+// again. The issues have the sync-failure label and Labels.
+type SyncFailureIssue struct {
+	After  Duration `json:"after"`
+	Labels []string `json:"labels"`
+}
+
+// FlakyWorkflows configures the automatic re-runs of failed GitHub Actions
+// workflows. The workflows with the given names are re-run up to MaxRetries
+// times. If MaxRetries is 0, they are never re-run and the pull requests are
+// notified of their first failure only.
+type FlakyWorkflows struct {
+	Names      []string `json:"names"`
+	MaxRetries int      `json:"max_retries"`
+}
+
+func (fw FlakyWorkflows) IsFlaky(name string) bool {
+	for _, n := range fw.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+)
+
+// AutoMerge configures the automatic merging of pull requests. A pull request
+// with Label is merged once RequiredChecks and the Jira check pass; if
+// RequiredChecks is empty, all check runs on the head commit have to pass. If
+// a check fails or the pull request can't be merged, the label is removed.
+type AutoMerge struct {
+	Label          string   `json:"label"`
+	Method         string   `json:"method"`
+	RequiredChecks []string `json:"required_checks"`
+}
+
+func (am AutoMerge) MethodOrDefault() string {
+	if am.Method == "" {
+		return MergeMethodMerge
+	}
+	return am.Method
+}
+
+// Size configures the size labels (size/XS to size/XXL) of pull requests. The
+// size is the number of added and deleted lines in the files that don't match
+// Exclude. If MaxSize is set, the size check fails for larger pull requests.
+type Size struct {
+	Enabled bool     `json:"enabled"`
+	Exclude []string `json:"exclude"`
+	MaxSize int      `json:"max_size"`
+}
+
+// ConventionalTitle configures the check of pull request titles against the
+// conventional commits format, e.g. "fix(api): handle timeouts". The Jira
+// issue key at the end of the title is ignored. If Types is empty, the common
+// types are allowed; if Scopes is empty, any scope is allowed.
+type ConventionalTitle struct {
+	Enabled      bool     `json:"enabled"`
+	Types        []string `json:"types"`
+	Scopes       []string `json:"scopes"`
+	RequireScope bool     `json:"require_scope"`
+}
+
+// SignedCommits configures the check that requires the commits of pull
+// requests against the branches that match one of the Branches patterns (e.g.
+// release-*) to have verified signatures.
+type SignedCommits struct {
+	Branches []string `json:"branches"`
+}
+
+// TokenClient is a trusted tool that is allowed to request installation
+// tokens from the app. TokenSHA256 is the hex-encoded SHA-256 hash of the
+// bearer token that the client uses.
+type TokenClient struct {
+	Name         string            `json:"name"`
+	TokenSHA256  string            `json:"token_sha256"`
+	Repositories []string          `json:"repositories"`
+	Permissions  map[string]string `json:"permissions"`
+}
+
+// TokenPermissionLevels are the levels of the permissions of the installation
+// tokens, from the lowest.
+var TokenPermissionLevels = []string{"read", "write", "admin"}
+
+// TokenPermissions are the names of the permissions that the installation
+// tokens can be requested with, the fields of github.InstallationPermissions.
+// The names that are not in the list would be dropped and give a token with
+// all permissions of the installation.
+var TokenPermissions = installationPermissions()
+
+func installationPermissions() map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(github.InstallationPermissions{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// CheckTokenPermissions checks that the permissions have the names and the
+// levels that GitHub knows.
+func CheckTokenPermissions(permissions map[string]string) error {
+	names := make([]string, 0, len(permissions))
+	for name := range permissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !TokenPermissions[name] {
+			return fmt.Errorf("unknown permission %q", name)
+		}
+		if !contains(TokenPermissionLevels, permissions[name]) {
+			return fmt.Errorf("invalid level %q for permission %s, expected one of %s", permissions[name], name, strings.Join(TokenPermissionLevels, ", "))
+		}
+	}
+	return nil
+}
+
+// ConsistencyAudit configures the nightly job that verifies that the Jira
+// issues of recently merged pull requests have been transitioned.
+type ConsistencyAudit struct {
+	Enabled bool `json:"enabled"`
+	Retry   bool `json:"retry"`
+}
+
+// AdminAPI configures who is allowed to use the /admin endpoints. The
+// requests are authenticated with the bearer token of one of Tokens or with an
+// OIDC ID token. If neither is configured, only the requests from the loopback
+// address, e.g. through kubectl port-forward, are allowed. If AllowedNetworks
+// is set, the client address must also be in one of the networks, e.g.
+// 10.0.0.0/8; the X-Forwarded-For header is used only if the request comes from
+// one of TrustedProxies, like the router of the cluster.
+type AdminAPI struct {
+	Tokens          []AdminToken `json:"tokens"`
+	OIDC            *OIDC        `json:"oidc"`
+	AllowedNetworks []string     `json:"allowed_networks"`
+	TrustedProxies  []string     `json:"trusted_proxies"`
+}
+
+// AdminToken is a static bearer token for the /admin endpoints. TokenSHA256 is
+// the hex-encoded SHA-256 hash of the token.
+type AdminToken struct {
+	Name        string `json:"name"`
+	TokenSHA256 string `json:"token_sha256"`
+}
+
+// OIDC configures the ID tokens that are accepted by the /admin endpoints.
+// The tokens must be signed by Issuer for Audience, and their sub claim, or
+// their email claim if email_verified is true, must be one of Subjects.
+type OIDC struct {
+	Issuer   string   `json:"issuer"`
+	Audience string   `json:"audience"`
+	Subjects []string `json:"subjects"`
+}
+
+// ParseNetwork parses a network of AllowedNetworks or TrustedProxies, like
+// 10.0.0.0/8 or a single address like 192.0.2.1.
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network %q, expected an address or a CIDR like 10.0.0.0/8", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+}
+
+// Secrets tells where the private key of the app, the Jira token and the
+// webhook secret of the app are read from if the -private-key, -jira-token and
+// -webhook-secret flags are not set. The values are
+// secret references, see ParseSecretRef, so that the configuration names the
+// secrets without containing them. PrivateKey can be a comma-separated list
+// of keys while the key is rotated.
+type Secrets struct {
+	PrivateKey    string `json:"private_key"`
+	JiraToken     string `json:"jira_token"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// SplitSecretRefs returns the secret references of a comma-separated list, e.g.
+// the private keys that are valid while the key is rotated.
+func SplitSecretRefs(refs string) []string {
+	var result []string
+	for _, ref := range strings.Split(refs, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// The sources of the secret references.
+const (
+	SecretSourceFile  = "file"
+	SecretSourceEnv   = "env"
+	SecretSourceVault = "vault"
+)
+
+// SecretRef is a parsed secret reference. Name is the file or the environment
+// variable, Path and Key locate the secret in Vault.
+type SecretRef struct {
+	Source string
+	Name   string
+	Path   string
+	Key    string
+}
+
+// ParseSecretRef parses a secret reference: env:NAME for a secret in an
+// environment variable, vault:<path>#<key> for a secret in Vault, and
+// file:<path>, or just the path, for a secret in a file.
+func ParseSecretRef(ref string) (SecretRef, error) {
+	switch {
+	case strings.HasPrefix(ref, SecretSourceEnv+":"):
+		name := strings.TrimPrefix(ref, SecretSourceEnv+":")
+		if name == "" {
+			return SecretRef{}, fmt.Errorf("invalid secret reference %q, expected env:NAME", ref)
+		}
+		return SecretRef{Source: SecretSourceEnv, Name: name}, nil
+	case strings.HasPrefix(ref, SecretSourceVault+":"):
+		rest := strings.TrimPrefix(ref, SecretSourceVault+":")
+		i := strings.LastIndex(rest, "#")
+		if i <= 0 || i == len(rest)-1 {
+			return SecretRef{}, fmt.Errorf("invalid secret reference %q, expected vault:<path>#<key>", ref)
+		}
+		return SecretRef{Source: SecretSourceVault, Path: rest[:i], Key: rest[i+1:]}, nil
+	}
+	name := strings.TrimPrefix(ref, SecretSourceFile+":")
+	if name == "" {
+		return SecretRef{}, fmt.Errorf("invalid secret reference %q, expected a file", ref)
+	}
+	return SecretRef{Source: SecretSourceFile, Name: name}, nil
+}
+
+// Notifications configures the messages that the app sends to Slack. If
+// SyncErrors is set, a message is sent when a branch sync enters the Error
+// state. If JiraErrors is set, a message is sent when the Jira check fails
+// with an internal error that many times in a row in a repository. If
+// Inconsistencies is set, a message is sent for each inconsistency that the
+// consistency audit finds. The Jira rules with notify send a message when they
+// are applied.
+type Notifications struct {
+	Slack           *SlackNotifications `json:"slack"`
+	SyncErrors      bool                `json:"sync_errors"`
+	JiraErrors      int                 `json:"jira_errors"`
+	Inconsistencies bool                `json:"inconsistencies"`
+}
+
+// SlackNotifications is the incoming webhook of Slack that the messages are
+// posted to. The URL of the webhook is a secret, so it's read from
+// WebhookURLFile. Channel overrides the channel of the webhook.
+type SlackNotifications struct {
+	WebhookURLFile string `json:"webhook_url_file"`
+	Channel        string `json:"channel"`
+}
+
+// Discovery configures the repositories that are added from the installation
+// of the app. If it's enabled, every repository that the app is installed on
+// and that is not configured gets the defaults, except the archived
+// repositories and the repositories that match Exclude, e.g. "quay/*-archive".
+type Discovery struct {
+	Enabled  bool      `json:"enabled"`
+	Exclude  []string  `json:"exclude"`
+	Interval *Duration `json:"interval"`
+}
+
+// IntervalOrDefault returns how often the repositories of the installation
+// are listed.
+func (d Discovery) IntervalOrDefault() time.Duration {
+	if d.Interval == nil {
+		return time.Hour
+	}
+	return d.Interval.Duration
+}
+
+// Excludes reports whether the repository is excluded from discovery.
+func (d Discovery) Excludes(owner, repoName string) bool {
+	for _, pattern := range d.Exclude {
+		if matched, _ := path.Match(pattern, owner+"/"+repoName); matched {
+			return true
 		}
 	}
-	return Jira{}
+	return false
+}
+
+type Configuration struct {
+	AppID            int64            `json:"app_id"`
+	InstallationID   int64            `json:"installation_id"`
+	Defaults         Defaults         `json:"defaults"`
+	Repositories     []Repository     `json:"repositories"`
+	TokenClients     []TokenClient    `json:"token_clients"`
+	ConsistencyAudit ConsistencyAudit `json:"consistency_audit"`
+	Discovery        Discovery        `json:"discovery"`
+	Admin            AdminAPI         `json:"admin"`
+	Notifications    Notifications    `json:"notifications"`
+	Secrets          Secrets          `json:"secrets"`
+
+	// Source and LoadedAt tell where and when the configuration was read.
+	// They are set by LoadFromFile and by the callers that load the
+	// configuration from other places.
+	Source   string    `json:"-"`
+	LoadedAt time.Time `json:"-"`
+
+	// sources are the locations of Repositories if the configuration is
+	// loaded from a directory.
+	sources []repositorySource
+
+	// defaults is the defaults section as it's written in the configuration,
+	// it's merged into the repositories that are added by WithRepositories.
+	defaults map[string]interface{}
+}
+
+func (c *Configuration) Jira(owner, repoName string) Jira {
+	repo, _ := c.Repository(owner, repoName)
+	return repo.Jira
 }
 
 func (c *Configuration) Branch(owner, repoName, branchName string) Branch {
-	for _, repo := range c.Repositories {
-		if repo.Owner == owner && repo.Repo == repoName {
-			for _, branch := range repo.Branches {
-				if branch.Name == branchName {
-					return branch
-				}
+	if repo, ok := c.Repository(owner, repoName); ok {
+		for _, branch := range repo.Branches {
+			if branch.Name == branchName {
+				return branch
+			}
+		}
+		if repo.ReleaseBranch != nil {
+			if version, ok := repo.ReleaseBranch.Match(branchName); ok {
+				return repo.ReleaseBranch.ForVersion(version)
 			}
 		}
 	}
@@ -80,20 +592,97 @@ func (c *Configuration) Branch(owner, repoName, branchName string) Branch {
 	}
 }
 
+// ActiveMute returns the mute for the check in the repository that is active
+// at the time now.
+func (c *Configuration) ActiveMute(owner, repoName, check string, now time.Time) (CheckMute, bool) {
+	repo, _ := c.Repository(owner, repoName)
+	for _, mute := range repo.Mute {
+		if mute.Check == check && now.Before(mute.Until) {
+			return mute, true
+		}
+	}
+	return CheckMute{}, false
+}
+
+// Repository returns the configuration of the repository. An entry for the
+// repository takes precedence over the entries with patterns; otherwise the
+// first matching pattern is used, and the returned configuration has the
+// owner and the name of the repository.
+func (c *Configuration) Repository(owner, repoName string) (Repository, bool) {
+	for _, repo := range c.Repositories {
+		if repo.Owner == owner && repo.Repo == repoName {
+			return repo, true
+		}
+	}
+	for _, repo := range c.Repositories {
+		if repo.IsPattern() && repo.Matches(owner, repoName) {
+			repo.Owner = owner
+			repo.Repo = repoName
+			return repo, true
+		}
+	}
+	return Repository{}, false
+}
+
+// SyncSource returns the reference to the branch that the branch is synced
+// from, or false if the branch is not synced.
+func (r Repository) SyncSource(branch Branch) (BranchReference, bool) {
+	syncFrom := branch.SyncFrom
+	if syncFrom.Branch == "" {
+		return BranchReference{}, false
+	}
+	if syncFrom.Owner == "" {
+		syncFrom.Owner = r.Owner
+	}
+	if syncFrom.Repo == "" {
+		syncFrom.Repo = r.Repo
+	}
+	return syncFrom, true
+}
+
+// TagMirrorSource returns the repository that the tags of the repository are
+// mirrored from.
+func (r Repository) TagMirrorSource() (owner, repo string, ok bool) {
+	if r.MirrorTags == nil || r.MirrorTags.Repo == "" {
+		return "", "", false
+	}
+	owner = r.MirrorTags.Owner
+	if owner == "" {
+		owner = r.Owner
+	}
+	return owner, r.MirrorTags.Repo, true
+}
+
+// TagMirrors returns the repositories that mirror the tag of the repository,
+// as branch references without the branch.
+func (c *Configuration) TagMirrors(owner, repoName, tag string) []BranchReference {
+	var refs []BranchReference
+	for _, repo := range c.ExplicitRepositories() {
+		sourceOwner, sourceRepo, ok := repo.TagMirrorSource()
+		if !ok || sourceOwner != owner || sourceRepo != repoName {
+			continue
+		}
+		if pattern := repo.MirrorTags.Pattern; pattern != "" {
+			if matched, _ := path.Match(pattern, tag); !matched {
+				continue
+			}
+		}
+		refs = append(refs, BranchReference{
+			Owner: repo.Owner,
+			Repo:  repo.Repo,
+		})
+	}
+	return refs
+}
+
 func (c *Configuration) BranchesSyncedFrom(owner, repoName, branchName string) []BranchReference {
 	var refs []BranchReference
-	for _, repo := range c.Repositories {
+	for _, repo := range c.ExplicitRepositories() {
 		for _, branch := range repo.Branches {
-			syncFrom := branch.SyncFrom
-			if syncFrom.Branch == "" {
+			syncFrom, ok := repo.SyncSource(branch)
+			if !ok {
 				continue
 			}
-			if syncFrom.Owner == "" {
-				syncFrom.Owner = repo.Owner
-			}
-			if syncFrom.Repo == "" {
-				syncFrom.Repo = repo.Repo
-			}
 			if syncFrom.Owner == owner && syncFrom.Repo == repoName && syncFrom.Branch == branchName {
 				refs = append(refs, BranchReference{
 					Owner:  repo.Owner,
@@ -102,18 +691,75 @@ func (c *Configuration) BranchesSyncedFrom(owner, repoName, branchName string) [
 				})
 			}
 		}
+		if repo.ReleaseBranch == nil {
+			continue
+		}
+		version, ok := repo.ReleaseBranch.MatchSource(branchName)
+		if !ok {
+			continue
+		}
+		branch := repo.ReleaseBranch.ForVersion(version)
+		if repo.hasBranch(branch.Name) {
+			continue
+		}
+		syncFrom, ok := repo.SyncSource(branch)
+		if ok && syncFrom.Owner == owner && syncFrom.Repo == repoName && syncFrom.Branch == branchName {
+			refs = append(refs, BranchReference{
+				Owner:  repo.Owner,
+				Repo:   repo.Repo,
+				Branch: branch.Name,
+			})
+		}
 	}
 	return refs
 }
 
-func LoadFromFile(filename string) (*Configuration, error) {
-	buf, err := os.ReadFile(filename)
+// Load parses the YAML configuration. Unknown fields, e.g. misspelled
+// options, are rejected, all of them are reported in a FieldErrors error.
+func Load(buf []byte) (*Configuration, error) {
+	unknown, err := UnknownFields(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		return nil, FieldErrors(unknown)
+	}
+	return Decode(buf)
+}
+
+// Decode parses the YAML configuration like Load, but ignores unknown fields.
+func Decode(buf []byte) (*Configuration, error) {
+	doc, err := parseDocument(buf)
 	if err != nil {
 		return nil, err
 	}
 	var cfg Configuration
-	if err := yaml.Unmarshal(buf, &cfg); err != nil {
+	if err := decodeDocument(doc, &cfg); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
+
+// redacted replaces the secrets in Sanitized.
+const redacted = "<redacted>"
+
+// Sanitized returns a copy of the configuration without the secrets, so that
+// it can be shown to the operators.
+func (c *Configuration) Sanitized() *Configuration {
+	cfg := *c
+	cfg.TokenClients = make([]TokenClient, len(c.TokenClients))
+	for i, client := range c.TokenClients {
+		if client.TokenSHA256 != "" {
+			client.TokenSHA256 = redacted
+		}
+		cfg.TokenClients[i] = client
+	}
+	cfg.Admin.Tokens = make([]AdminToken, len(c.Admin.Tokens))
+	for i, token := range c.Admin.Tokens {
+		if token.TokenSHA256 != "" {
+			token.TokenSHA256 = redacted
+		}
+		cfg.Admin.Tokens[i] = token
+	}
+	return &cfg
+}
@@ -0,0 +1,453 @@
+package configuration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// JiraRuleEvents are the events that the conditions of Jira rules can match.
+var JiraRuleEvents = []string{"opened", "edited", "sync", "closed", "recheck"}
+
+// FieldError is a problem with the field of the configuration at Path, e.g.
+// repositories[0].jira.rules[1].when.event[0]. File is set if the
+// configuration is loaded from a directory.
+type FieldError struct {
+	File    string
+	Path    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	msg := e.Message
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	if e.File != "" {
+		msg = e.File + ": " + msg
+	}
+	return msg
+}
+
+// FieldErrors are all problems found in the configuration.
+type FieldErrors []*FieldError
+
+func (errs FieldErrors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	msg := fmt.Sprintf("%d problems in the configuration:", len(errs))
+	for _, err := range errs {
+		msg += "\n  " + err.Error()
+	}
+	return msg
+}
+
+func fieldPath(parent, field string) string {
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
+
+func indexPath(parent string, i int) string {
+	return fmt.Sprintf("%s[%d]", parent, i)
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	repositoryType      = reflect.TypeOf(Repository{})
+	defaultsType        = reflect.TypeOf(Defaults{})
+)
+
+// jsonFields returns the types of the fields of the struct type t by their
+// names in the configuration. The fields of embedded structs are inlined.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, typ := range jsonFields(embedded) {
+					fields[name] = typ
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func validSyncStrategy(branch Branch) bool {
+	switch branch.StrategyOrDefault() {
+	case SyncStrategyFFOnly, SyncStrategyForce, SyncStrategyMerge:
+		return true
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unknownFields walks the decoded document value along the type t. The values
+// that don't have the expected shape are skipped, the decoder reports them.
+func unknownFields(path string, value interface{}, t reflect.Type) []*FieldError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == rawMessageType || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+
+	var errs []*FieldError
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(obj) {
+			if (t == repositoryType || t == defaultsType) && key == "checks" {
+				errs = append(errs, unknownCheckFields(fieldPath(path, key), obj[key])...)
+				continue
+			}
+			typ, ok := fields[key]
+			if !ok {
+				errs = append(errs, &FieldError{Path: fieldPath(path, key), Message: "unknown field"})
+				continue
+			}
+			errs = append(errs, unknownFields(fieldPath(path, key), obj[key], typ)...)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range arr {
+			errs = append(errs, unknownFields(indexPath(path, i), item, t.Elem())...)
+		}
+	case reflect.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(obj) {
+			errs = append(errs, unknownFields(fieldPath(path, key), obj[key], t.Elem())...)
+		}
+	}
+	return errs
+}
+
+// unknownCheckFields checks the options in the checks section against the
+// sections that they are merged into.
+func unknownCheckFields(path string, value interface{}) []*FieldError {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	options := (&Repository{}).checkOptions()
+	var errs []*FieldError
+	for _, name := range sortedKeys(obj) {
+		target, ok := options[name]
+		if !ok {
+			errs = append(errs, &FieldError{Path: fieldPath(path, name), Message: "unknown check"})
+			continue
+		}
+		if target == nil || obj[name] == false {
+			continue
+		}
+		errs = append(errs, unknownFields(fieldPath(path, name), obj[name], reflect.TypeOf(target))...)
+	}
+	return errs
+}
+
+// UnknownFields reports the fields of the YAML document that don't exist in
+// the configuration, for example misspelled options.
+func UnknownFields(buf []byte) ([]*FieldError, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	return unknownFields("", doc, reflect.TypeOf(Configuration{})), nil
+}
+
+// Validate reports the problems of the configuration that don't prevent it
+// from being loaded, but would make the app misbehave: empty required fields,
+// invalid values, duplicate repositories and branches, and sync sources that
+// are not configured.
+func (c *Configuration) Validate() []*FieldError {
+	var errs []*FieldError
+	add := func(path, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.AppID == 0 {
+		add("app_id", "is required")
+	}
+	if c.InstallationID == 0 {
+		add("installation_id", "is required")
+	}
+
+	// setFile sets the file of the repository on the errors that are added
+	// after start.
+	setFile := func(start int, file string) {
+		for _, err := range errs[start:] {
+			err.File = file
+		}
+	}
+
+	repos := map[string]int{}
+	for i, repo := range c.Repositories {
+		start := len(errs)
+		file, path := c.repositoryLocation(i)
+		if repo.Owner == "" {
+			add(fieldPath(path, "owner"), "is required")
+		}
+		if repo.Repo == "" {
+			add(fieldPath(path, "repo"), "is required")
+		}
+		if !validPattern(repo.Owner) {
+			add(fieldPath(path, "owner"), "invalid pattern %q", repo.Owner)
+		}
+		if !validPattern(repo.Repo) {
+			add(fieldPath(path, "repo"), "invalid pattern %q", repo.Repo)
+		}
+		name := repo.Owner + "/" + repo.Repo
+		if first, ok := repos[name]; ok {
+			firstFile, firstPath := c.repositoryLocation(first)
+			if firstFile != "" && firstFile != file {
+				firstPath = firstFile
+			}
+			add(path, "duplicate repository %s, it is already configured in %s", name, firstPath)
+		} else {
+			repos[name] = i
+		}
+		setFile(start, file)
+	}
+
+	for i, repo := range c.Repositories {
+		start := len(errs)
+		file, path := c.repositoryLocation(i)
+		errs = append(errs, repo.Jira.validate(fieldPath(path, "jira"), c.Defaults.Jira)...)
+
+		branches := map[string]int{}
+		for j, branch := range repo.Branches {
+			branchPath := indexPath(fieldPath(path, "branches"), j)
+			if branch.Name == "" {
+				add(fieldPath(branchPath, "name"), "is required")
+				continue
+			}
+			if first, ok := branches[branch.Name]; ok {
+				add(branchPath, "duplicate branch %s, it is already configured in branches[%d]", branch.Name, first)
+			} else {
+				branches[branch.Name] = j
+			}
+			errs = append(errs, c.validateSyncSource(fieldPath(branchPath, "sync_from"), repo, branch)...)
+			if !validSyncStrategy(branch) {
+				add(fieldPath(branchPath, "strategy"), "unknown sync strategy %q", branch.Strategy)
+			}
+		}
+		if repo.ReleaseBranch != nil {
+			releasePath := fieldPath(path, "release_branch")
+			if !strings.Contains(repo.ReleaseBranch.Name, VersionPlaceholder) {
+				add(fieldPath(releasePath, "name"), "should contain %s", VersionPlaceholder)
+			}
+			if !validSyncStrategy(repo.ReleaseBranch.Branch) {
+				add(fieldPath(releasePath, "strategy"), "unknown sync strategy %q", repo.ReleaseBranch.Strategy)
+			}
+			errs = append(errs, c.validateSyncSource(fieldPath(releasePath, "sync_from"), repo, repo.ReleaseBranch.ForVersion("0.0"))...)
+		}
+
+		if sourceOwner, sourceRepo, ok := repo.TagMirrorSource(); ok {
+			if sourceOwner == repo.Owner && sourceRepo == repo.Repo {
+				add(fieldPath(path, "mirror_tags"), "the tags are mirrored from the repository itself")
+			} else if _, ok := c.Repository(sourceOwner, sourceRepo); !ok {
+				add(fieldPath(path, "mirror_tags"), "the source repository %s/%s is not configured", sourceOwner, sourceRepo)
+			}
+			if !validPattern(repo.MirrorTags.Pattern) {
+				add(fieldPath(path, "mirror_tags.pattern"), "invalid pattern %q", repo.MirrorTags.Pattern)
+			}
+		} else if repo.MirrorTags != nil {
+			add(fieldPath(path, "mirror_tags.repo"), "is required")
+		}
+
+		if repo.SyncFailureIssue != nil && repo.SyncFailureIssue.After.Duration <= 0 {
+			add(fieldPath(path, "sync_failure_issue.after"), "should be a positive duration")
+		}
+
+		switch repo.AutoMerge.MethodOrDefault() {
+		case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		default:
+			add(fieldPath(path, "auto_merge.method"), "unknown merge method %q", repo.AutoMerge.Method)
+		}
+
+		checks := repo.checkOptions()
+		for j, mute := range repo.Mute {
+			if _, ok := checks[mute.Check]; !ok {
+				add(fieldPath(indexPath(fieldPath(path, "mute"), j), "check"), "unknown check %q", mute.Check)
+			}
+		}
+		setFile(start, file)
+	}
+
+	for i, pattern := range c.Discovery.Exclude {
+		if !validPattern(pattern) || !strings.Contains(pattern, "/") {
+			add(indexPath("discovery.exclude", i), "invalid pattern %q, expected owner/repo", pattern)
+		}
+	}
+
+	for i, client := range c.TokenClients {
+		path := fieldPath(indexPath("token_clients", i), "permissions")
+		if len(client.Permissions) == 0 {
+			add(path, "is required")
+		} else if err := CheckTokenPermissions(client.Permissions); err != nil {
+			add(path, "%v", err)
+		}
+	}
+
+	for i, token := range c.Admin.Tokens {
+		path := indexPath("admin.tokens", i)
+		if token.Name == "" {
+			add(fieldPath(path, "name"), "is required")
+		}
+		if sum, err := hex.DecodeString(token.TokenSHA256); err != nil || len(sum) != sha256.Size {
+			add(fieldPath(path, "token_sha256"), "should be a hex-encoded SHA-256 hash")
+		}
+	}
+	if oidc := c.Admin.OIDC; oidc != nil {
+		if u, err := url.Parse(oidc.Issuer); err != nil || u.Scheme != "https" || u.Host == "" {
+			add("admin.oidc.issuer", "invalid issuer %q, expected an https URL", oidc.Issuer)
+		}
+		if oidc.Audience == "" {
+			add("admin.oidc.audience", "is required")
+		}
+		if len(oidc.Subjects) == 0 {
+			add("admin.oidc.subjects", "is required")
+		}
+	}
+	for _, networks := range []struct {
+		path  string
+		value []string
+	}{
+		{"admin.allowed_networks", c.Admin.AllowedNetworks},
+		{"admin.trusted_proxies", c.Admin.TrustedProxies},
+	} {
+		for i, network := range networks.value {
+			if _, err := ParseNetwork(network); err != nil {
+				add(indexPath(networks.path, i), "%v", err)
+			}
+		}
+	}
+
+	if slack := c.Notifications.Slack; slack != nil && slack.WebhookURLFile == "" {
+		add("notifications.slack.webhook_url_file", "is required")
+	}
+	if c.Notifications.JiraErrors < 0 {
+		add("notifications.jira_errors", "should not be negative")
+	}
+	for _, ref := range []struct {
+		path  string
+		value string
+	}{
+		{"secrets.private_key", c.Secrets.PrivateKey},
+		{"secrets.jira_token", c.Secrets.JiraToken},
+		{"secrets.webhook_secret", c.Secrets.WebhookSecret},
+	} {
+		for _, value := range SplitSecretRefs(ref.value) {
+			if _, err := ParseSecretRef(value); err != nil {
+				add(ref.path, "%v", err)
+			}
+		}
+	}
+	return errs
+}
+
+// validateSyncSource checks that the branch is not synced from itself and
+// that the source repository is configured, the app doesn't see the pushes
+// to other repositories.
+func (c *Configuration) validateSyncSource(path string, repo Repository, branch Branch) []*FieldError {
+	source, ok := repo.SyncSource(branch)
+	if !ok {
+		return nil
+	}
+	if source.Owner == repo.Owner && source.Repo == repo.Repo && source.Branch == branch.Name {
+		return []*FieldError{{Path: path, Message: "the branch is synced from itself"}}
+	}
+	if _, ok := c.Repository(source.Owner, source.Repo); !ok {
+		return []*FieldError{{Path: path, Message: fmt.Sprintf("the source repository %s/%s is not configured", source.Owner, source.Repo)}}
+	}
+	return nil
+}
+
+// validate checks the Jira section of a repository. The rules that are
+// inherited from the defaults don't require the key, the repositories without
+// a Jira project ignore them.
+func (j Jira) validate(path string, defaults Jira) []*FieldError {
+	var errs []*FieldError
+	if j.Key == "" && len(j.Rules) > 0 && !reflect.DeepEqual(j.Rules, defaults.Rules) {
+		errs = append(errs, &FieldError{Path: fieldPath(path, "key"), Message: "is required for the rules"})
+	}
+	switch j.ClosedIssues.Action {
+	case "", ClosedIssueActionWarn, ClosedIssueActionFail:
+	case ClosedIssueActionReopen:
+		if j.ClosedIssues.ReopenTo == "" {
+			errs = append(errs, &FieldError{Path: fieldPath(path, "closed_issues.reopen_to"), Message: "is required for the reopen action"})
+		}
+	default:
+		errs = append(errs, &FieldError{Path: fieldPath(path, "closed_issues.action"), Message: fmt.Sprintf("unknown action %q", j.ClosedIssues.Action)})
+	}
+	for i, rule := range j.Rules {
+		rulePath := indexPath(fieldPath(path, "rules"), i)
+		if rule.TransitionTo == "" && !rule.SetFixVersion && !rule.RemoveFixVersion && rule.Comment == "" && !rule.Notify {
+			errs = append(errs, &FieldError{Path: rulePath, Message: "the rule does not do anything"})
+		}
+		for k, event := range rule.When.Event {
+			if !contains(JiraRuleEvents, event) {
+				errs = append(errs, &FieldError{
+					Path:    indexPath(fieldPath(rulePath, "when.event"), k),
+					Message: fmt.Sprintf("unknown event %q, expected one of %s", event, strings.Join(JiraRuleEvents, ", ")),
+				})
+			}
+		}
+	}
+	return errs
+}
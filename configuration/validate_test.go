@@ -0,0 +1,360 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func errorStrings(errs []*FieldError) []string {
+	var s []string
+	for _, err := range errs {
+		s = append(s, err.Error())
+	}
+	return s
+}
+
+func TestUnknownFields(t *testing.T) {
+	errs, err := UnknownFields([]byte(`
+app_id: 1
+installation_id: 2
+repository: []
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+    rules:
+    - when:
+        merged: true
+        updated_within: 72h
+      transition_to: ON_QA
+    - whenn:
+        merged: true
+  branches:
+  - name: test-release
+    syncFrom:
+      branch: master
+  release_branch:
+    name: release-{version}
+    from: master
+    version: "{version}"
+    form: master
+  mute:
+  - check: jira
+    until: 2022-12-01T00:00:00Z
+  checks:
+    size:
+      max_size: 500
+      max: 500
+    dco:
+    unknown: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"repositories[0].branches[0].syncFrom: unknown field",
+		"repositories[0].checks.size.max: unknown field",
+		"repositories[0].checks.unknown: unknown check",
+		"repositories[0].jira.rules[1].whenn: unknown field",
+		"repositories[0].release_branch.form: unknown field",
+		"repository: unknown field",
+	}
+	if got := errorStrings(errs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg, err := Load([]byte(`
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    rules:
+    - when:
+        event: [opened, merged]
+      transition_to: ON_QA
+    - when:
+        merged: true
+    closed_issues:
+      action: reopen
+  branches:
+  - name: redhat-3.8
+    sync_from:
+      owner: quay
+      repo: quay-upstream
+      branch: release-3.8
+  - name: master
+  - name: redhat-3.8
+  - name: master
+    sync_from:
+      branch: master
+    strategy: rebase
+  auto_merge:
+    method: fast-forward
+  sync_failure_issue:
+    labels: [ci]
+  mirror_tags:
+    repo: quay-upstream
+    pattern: "v[0-9"
+  mute:
+  - check: Jira
+- owner: quay
+  repo: quay
+- owner: quay
+  release_branch:
+    name: release
+    sync_from:
+      owner: quay
+      repo: quay
+      branch: release-{version}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"app_id: is required",
+		"installation_id: is required",
+		"repositories[1]: duplicate repository quay/quay, it is already configured in repositories[0]",
+		"repositories[2].repo: is required",
+		"repositories[0].jira.key: is required for the rules",
+		"repositories[0].jira.closed_issues.reopen_to: is required for the reopen action",
+		`repositories[0].jira.rules[0].when.event[1]: unknown event "merged", expected one of opened, edited, sync, closed, recheck`,
+		"repositories[0].jira.rules[1]: the rule does not do anything",
+		"repositories[0].branches[0].sync_from: the source repository quay/quay-upstream is not configured",
+		"repositories[0].branches[2]: duplicate branch redhat-3.8, it is already configured in branches[0]",
+		"repositories[0].branches[3]: duplicate branch master, it is already configured in branches[1]",
+		"repositories[0].branches[3].sync_from: the branch is synced from itself",
+		`repositories[0].branches[3].strategy: unknown sync strategy "rebase"`,
+		"repositories[0].mirror_tags: the source repository quay/quay-upstream is not configured",
+		`repositories[0].mirror_tags.pattern: invalid pattern "v[0-9"`,
+		"repositories[0].sync_failure_issue.after: should be a positive duration",
+		`repositories[0].auto_merge.method: unknown merge method "fast-forward"`,
+		`repositories[0].mute[0].check: unknown check "Jira"`,
+		"repositories[2].release_branch.name: should contain {version}",
+	}
+	if got := errorStrings(cfg.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	cfg, err = Load([]byte(`
+app_id: 1
+installation_id: 2
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+    rules:
+    - when:
+        event: [closed]
+        merged: true
+      transition_to: ON_QA
+  branches:
+  - name: redhat-3.8
+    sync_from:
+      branch: release-3.8
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := cfg.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %q", errorStrings(errs))
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	_, err := Load([]byte(`
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    rules:
+    - when:
+        merged: true
+      transistion_to: ON_QA
+  branchs: []
+`))
+	want := `2 problems in the configuration:
+  repositories[0].branchs: unknown field
+  repositories[0].jira.rules[0].transistion_to: unknown field`
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+	if _, ok := err.(FieldErrors); !ok {
+		t.Errorf("got %T, want FieldErrors", err)
+	}
+
+	cfg, err := Decode([]byte("app_id: 1\nap_id: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AppID != 1 {
+		t.Errorf("got app_id %d, want 1", cfg.AppID)
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+repositories:
+- owner: quay
+  repo: "quay-[docs"
+- owner: "*"
+  repo: quay
+discovery:
+  exclude: ["quay/*-archive", "*-archive"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`repositories[0].repo: invalid pattern "quay-[docs"`,
+		`discovery.exclude[1]: invalid pattern "*-archive", expected owner/repo`,
+	}
+	if got := errorStrings(cfg.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateAdmin(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+admin:
+  tokens:
+  - name: oncall
+    token_sha256: 2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b
+  - token_sha256: secret
+  oidc:
+    issuer: http://sso.example.com
+  allowed_networks: ["10.0.0.0/8", "192.0.2.1", "10.0.0.0/33"]
+  trusted_proxies: ["router"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`admin.tokens[1].name: is required`,
+		`admin.tokens[1].token_sha256: should be a hex-encoded SHA-256 hash`,
+		`admin.oidc.issuer: invalid issuer "http://sso.example.com", expected an https URL`,
+		`admin.oidc.audience: is required`,
+		`admin.oidc.subjects: is required`,
+		`admin.allowed_networks[2]: invalid network "10.0.0.0/33", expected an address or a CIDR like 10.0.0.0/8`,
+		`admin.trusted_proxies[0]: invalid network "router", expected an address or a CIDR like 10.0.0.0/8`,
+	}
+	if got := errorStrings(cfg.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateTokenClients(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+token_clients:
+- name: release-tool
+  permissions:
+    contents: write
+    pull_requests: read
+- name: typo
+  permissions:
+    content: write
+- name: level
+  permissions:
+    issues: owner
+- name: none
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`token_clients[1].permissions: unknown permission "content"`,
+		`token_clients[2].permissions: invalid level "owner" for permission issues, expected one of read, write, admin`,
+		`token_clients[3].permissions: is required`,
+	}
+	if got := errorStrings(cfg.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateNotifications(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+notifications:
+  slack:
+    channel: "#quay-ci"
+  jira_errors: -1
+secrets:
+  private_key: "env:"
+  jira_token: vault:secret/data/quay-ci-app
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`notifications.slack.webhook_url_file: is required`,
+		`notifications.jira_errors: should not be negative`,
+		`secrets.private_key: invalid secret reference "env:", expected env:NAME`,
+		`secrets.jira_token: invalid secret reference "vault:secret/data/quay-ci-app", expected vault:<path>#<key>`,
+	}
+	if got := errorStrings(cfg.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateNotifyOnlyRule(t *testing.T) {
+	cfg, err := Load([]byte(`
+app_id: 1
+installation_id: 2
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+    rules:
+    - when:
+        event: [closed]
+        merged: false
+      notify: true
+    - when:
+        event: [closed]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"repositories[0].jira.rules[1]: the rule does not do anything",
+	}
+	if got := errorStrings(cfg.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseSecretRef(t *testing.T) {
+	testCases := []struct {
+		ref  string
+		want SecretRef
+	}{
+		{ref: "/etc/quay-ci-app/jira-token", want: SecretRef{Source: SecretSourceFile, Name: "/etc/quay-ci-app/jira-token"}},
+		{ref: "file:jira-token", want: SecretRef{Source: SecretSourceFile, Name: "jira-token"}},
+		{ref: "env:JIRA_TOKEN", want: SecretRef{Source: SecretSourceEnv, Name: "JIRA_TOKEN"}},
+		{ref: "vault:secret/data/quay-ci-app#jira-token", want: SecretRef{Source: SecretSourceVault, Path: "secret/data/quay-ci-app", Key: "jira-token"}},
+	}
+	for _, tc := range testCases {
+		got, err := ParseSecretRef(tc.ref)
+		if err != nil {
+			t.Errorf("%s: %v", tc.ref, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.ref, got, tc.want)
+		}
+	}
+	for _, ref := range []string{"", "file:", "env:", "vault:secret/data/quay-ci-app", "vault:#key", "vault:path#"} {
+		if _, err := ParseSecretRef(ref); err == nil {
+			t.Errorf("%q: want an error", ref)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+const consistencyAuditPeriod = 24 * time.Hour
+
+type ConsistencyReport struct {
+	StartTime       time.Time              `json:"startTime"`
+	CompletionTime  time.Time              `json:"completionTime"`
+	Since           time.Time              `json:"since"`
+	Inconsistencies []checks.Inconsistency `json:"inconsistencies"`
+	Errors          []string               `json:"errors,omitempty"`
+}
+
+// ConsistencyAuditor cross-references recently merged pull requests with the
+// states of their Jira issues. It is a safety net for missed or failed
+// webhook deliveries.
+type ConsistencyAuditor struct {
+	client    *clients.GitHub
+	cfg       *configuration.Store
+	jiraCheck *checks.Jira
+	// onInconsistency is called for each inconsistency, e.g. to notify about
+	// it. It may be nil.
+	onInconsistency func(checks.Inconsistency)
+
+	mutex  sync.Mutex
+	report *ConsistencyReport
+}
+
+func (ca *ConsistencyAuditor) mergedPullRequests(ctx context.Context, owner, repo string, since time.Time) ([]*github.PullRequest, error) {
+	var merged []*github.PullRequest
+	opts := &github.PullRequestListOptions{
+		State:     "closed",
+		Sort:      "updated",
+		Direction: "desc",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		prs, resp, err := ca.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests for %s/%s: %w", owner, repo, err)
+		}
+		for _, pr := range prs {
+			if pr.GetUpdatedAt().Before(since) {
+				return merged, nil
+			}
+			if pr.GetMergedAt().After(since) {
+				merged = append(merged, pr)
+			}
+		}
+		if resp.NextPage == 0 {
+			return merged, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (ca *ConsistencyAuditor) Run(ctx context.Context, since time.Time) *ConsistencyReport {
+	report := &ConsistencyReport{
+		StartTime:       time.Now().UTC(),
+		Since:           since,
+		Inconsistencies: []checks.Inconsistency{},
+	}
+
+	cfg := ca.cfg.Get()
+	for _, repo := range cfg.ExplicitRepositories() {
+		if repo.Jira.Key == "" {
+			continue
+		}
+
+		prs, err := ca.mergedPullRequests(ctx, repo.Owner, repo.Repo, since)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+
+		for _, pr := range prs {
+			inconsistency, err := ca.jiraCheck.Reconcile(ctx, repo.Jira, cfg.Branch(repo.Owner, repo.Repo, pr.GetBase().GetRef()), pr, cfg.ConsistencyAudit.Retry)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s/%s#%d: %v", repo.Owner, repo.Repo, pr.GetNumber(), err))
+				continue
+			}
+			if inconsistency != nil {
+				klog.Warningf("Jira issue %s of %s is %s, expected %s", inconsistency.Issue, inconsistency.PullRequest, inconsistency.Status, inconsistency.ExpectedStatus)
+				report.Inconsistencies = append(report.Inconsistencies, *inconsistency)
+				if ca.onInconsistency != nil {
+					ca.onInconsistency(*inconsistency)
+				}
+			}
+		}
+	}
+
+	report.CompletionTime = time.Now().UTC()
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	ca.report = report
+
+	return report
+}
+
+// Loop runs the audit every period until ctx is done.
+func (ca *ConsistencyAuditor) Loop(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		report := ca.Run(ctx, time.Now().Add(-period))
+		klog.V(2).Infof("consistency audit completed: %d inconsistencies, %d errors", len(report.Inconsistencies), len(report.Errors))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ca *ConsistencyAuditor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ca.mutex.Lock()
+	report := ca.report
+	ca.mutex.Unlock()
+
+	if report == nil {
+		http.Error(w, "the consistency audit has not completed yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		klog.Errorf("failed to encode consistency report: %v", err)
+	}
+}
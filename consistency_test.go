@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+// newTestAuditor returns an auditor of quay/quay whose rule moves the issues
+// in POST to ON_QA on merge, and the pull request merged at mergedAt.
+func newTestAuditor(gh *fakes.GitHub, fakeJira *fakes.Jira, mergedAt time.Time) (*ConsistencyAuditor, *[]checks.Inconsistency) {
+	fakeJira.Transitions[""] = []jira.Transition{{ID: "51", Name: "Move to QA", To: jira.Status{Name: "ON_QA"}}}
+	pr := fakes.PullRequest("quay", "quay", 1234, "Fix the build (PROJQUAY-123)")
+	pr.State = github.String("closed")
+	pr.Merged = github.Bool(true)
+	pr.MergedAt = &mergedAt
+	pr.UpdatedAt = &mergedAt
+	gh.AddPullRequest(pr)
+
+	var notified []checks.Inconsistency
+	return &ConsistencyAuditor{
+		client: gh.Client(),
+		cfg: configuration.NewStore(&configuration.Configuration{
+			ConsistencyAudit: configuration.ConsistencyAudit{Enabled: true, Retry: true},
+			Repositories: []configuration.Repository{{
+				Owner: "quay",
+				Repo:  "quay",
+				Jira: configuration.Jira{
+					Key: "PROJQUAY",
+					Rules: []configuration.JiraRule{{
+						When:         configuration.JiraCondition{Merged: github.Bool(true), Status: []string{"POST"}},
+						TransitionTo: "ON_QA",
+					}},
+				},
+			}},
+		}),
+		jiraCheck: checks.NewJira(gh.Client(), gh.Client(), fakeJira.Client(), nil, nil, activity.NewRecorder(10), time.Minute, time.Minute),
+		onInconsistency: func(inconsistency checks.Inconsistency) {
+			notified = append(notified, inconsistency)
+		},
+	}, &notified
+}
+
+func TestConsistencyAuditRetry(t *testing.T) {
+	gh := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "POST")
+	mergedAt := time.Now().Add(-time.Hour)
+	auditor, notified := newTestAuditor(gh, fakeJira, mergedAt)
+
+	report := auditor.Run(context.Background(), mergedAt.Add(-time.Hour))
+	if len(report.Errors) != 0 {
+		t.Fatalf("got the errors %v", report.Errors)
+	}
+	if len(report.Inconsistencies) != 1 || !report.Inconsistencies[0].Retried || report.Inconsistencies[0].Error != "" {
+		t.Fatalf("got the inconsistencies %+v, want one that was retried", report.Inconsistencies)
+	}
+	if len(fakeJira.PerformedTransitions) != 1 || fakeJira.PerformedTransitions[0].To != "ON_QA" {
+		t.Errorf("the issue should be moved to ON_QA, got %+v", fakeJira.PerformedTransitions)
+	}
+	if len(*notified) != 1 || (*notified)[0].Issue != "PROJQUAY-123" {
+		t.Errorf("got the notifications %+v, want one for PROJQUAY-123", *notified)
+	}
+
+	// The retried transition is consistent for the next audit.
+	report = auditor.Run(context.Background(), mergedAt.Add(-time.Hour))
+	if len(report.Inconsistencies) != 0 {
+		t.Errorf("got the inconsistencies %+v after the retry", report.Inconsistencies)
+	}
+}
+
+func TestConsistencyAuditStatusAtMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		status      string
+		changes     []string
+		want        bool
+		wantRetried bool
+	}{
+		{
+			// The rule didn't apply at the merge, the issue was moved to
+			// POST for another pull request.
+			name:    "moved to the status of the rule after the merge",
+			status:  "New",
+			changes: []string{"POST"},
+		},
+		{
+			name:    "transitioned at the merge and moved on",
+			status:  "POST",
+			changes: []string{"ON_QA", "Verified"},
+		},
+		{
+			name:        "not transitioned",
+			status:      "POST",
+			want:        true,
+			wantRetried: true,
+		},
+		{
+			name:    "moved back by someone",
+			status:  "POST",
+			changes: []string{"In Progress"},
+			want:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gh := fakes.NewGitHub()
+			fakeJira := fakes.NewJira()
+			fakeJira.AddIssue("PROJQUAY-123", "Bug", tc.status)
+			mergedAt := time.Now().Add(-time.Hour)
+			for i, status := range tc.changes {
+				fakeJira.ChangeStatus("PROJQUAY-123", status, mergedAt.Add(time.Duration(i+1)*time.Minute))
+			}
+			auditor, _ := newTestAuditor(gh, fakeJira, mergedAt)
+
+			report := auditor.Run(context.Background(), mergedAt.Add(-time.Hour))
+			if len(report.Errors) != 0 {
+				t.Fatalf("got the errors %v", report.Errors)
+			}
+			if got := len(report.Inconsistencies) == 1; got != tc.want {
+				t.Fatalf("got the inconsistencies %+v, want an inconsistency: %t", report.Inconsistencies, tc.want)
+			}
+			if tc.want && report.Inconsistencies[0].Retried != tc.wantRetried {
+				t.Errorf("got retried %t, want %t", report.Inconsistencies[0].Retried, tc.wantRetried)
+			}
+		})
+	}
+}
+
+func TestConsistencyAuditLoop(t *testing.T) {
+	gh := fakes.NewGitHub()
+	auditor := &ConsistencyAuditor{client: gh.Client(), cfg: configuration.NewStore(&configuration.Configuration{})}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		auditor.Loop(ctx, time.Hour)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the loop should return once the context is done")
+	}
+
+	w := httptest.NewRecorder()
+	auditor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/consistency", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d for the report of the first audit, want %d", w.Code, http.StatusOK)
+	}
+}
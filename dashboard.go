@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/taginformer"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// The sync loop runs every 5 minutes, so a branch that hasn't been synced
+	// for longer than syncStaleAfter has missed at least one round.
+	syncStaleAfter = 10 * time.Minute
+	syncOldAfter   = 30 * time.Minute
+
+	eventStaleAfter = time.Hour
+	eventOldAfter   = 24 * time.Hour
+
+	dashboardEvents = 50
+	dashboardChecks = 20
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"age": func(now, t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return formatAge(now.Sub(t)) + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Quay CI App</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+.fresh { background: #e3f6e3; }
+.stale { background: #fff4d6; }
+.old, .error { background: #fbe0e0; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>Quay CI App</h1>
+<p class="muted">Configuration from {{.Source}}, loaded {{age .Now .LoadedAt}}.</p>
+
+<h2>Branches</h2>
+{{if .Branches}}
+<table>
+<tr><th>Branch</th><th>Sync</th><th>Last sync</th><th>Fix version</th></tr>
+{{range .Branches}}
+<tr>
+<td>{{.Branch}}</td>
+{{with .SyncStatus}}<td class="{{if eq .Status "Synced"}}fresh{{else if eq .Status "Error"}}error{{else}}stale{{end}}">{{.Status}}<br><span class="muted">{{.Message}}</span></td>
+<td class="{{$.SyncFreshness .LastHeartbeatTime}}">{{age $.Now .LastHeartbeatTime}}</td>
+{{else}}<td class="muted">not synced</td><td></td>{{end}}
+<td>{{.FixVersion}}{{if .FixVersionMessage}}<br><span class="muted">{{.FixVersionMessage}}</span>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">No synced branches or fix versions.</p>
+{{end}}
+
+{{if .Mutes}}
+<h2>Muted checks</h2>
+<table>
+<tr><th>Repository</th><th>Check</th><th>Until</th><th>Reason</th></tr>
+{{range .Mutes}}
+<tr><td>{{.Repository}}</td><td>{{.Check}}</td><td>{{.Until.Format "2006-01-02 15:04 MST"}}</td><td>{{.Reason}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+<h2>Recent checks</h2>
+{{template "events" (.WithEvents .Checks)}}
+
+<h2>Recent events</h2>
+{{template "events" (.WithEvents .Recent)}}
+</body>
+</html>
+
+{{define "events"}}
+{{if .Recent}}
+<table>
+<tr><th>Time</th><th>Repository</th><th>Type</th><th>Subject</th><th>Summary</th></tr>
+{{range .Recent}}
+<tr class="{{$.Freshness .Time}}">
+<td title="{{.Time.Format "2006-01-02 15:04:05 MST"}}">{{age $.Now .Time}}</td>
+<td>{{.Repository}}</td>
+<td>{{.Type}}</td>
+<td>{{if .PullRequest}}<a href="https://github.com/{{.Repository}}/pull/{{.PullRequest}}">#{{.PullRequest}}</a>{{else if .Branch}}{{.Branch}}{{else}}{{.Issue}}{{end}}</td>
+<td>{{.Summary}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">Nothing yet.</p>
+{{end}}
+{{end}}
+`))
+
+// formatAge formats d with its largest unit, like 5m or 3h.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// freshness returns the CSS class of something that happened at t.
+func freshness(now, t time.Time, staleAfter, oldAfter time.Duration) string {
+	switch age := now.Sub(t); {
+	case age < staleAfter:
+		return "fresh"
+	case age < oldAfter:
+		return "stale"
+	}
+	return "old"
+}
+
+type dashboardData struct {
+	Status
+	Source   string
+	LoadedAt time.Time
+	Now      time.Time
+	Recent   []activity.RepositoryEvent
+	Checks   []activity.RepositoryEvent
+}
+
+func (d dashboardData) SyncFreshness(t time.Time) string {
+	return freshness(d.Now, t, syncStaleAfter, syncOldAfter)
+}
+
+func (d dashboardData) Freshness(t time.Time) string {
+	return freshness(d.Now, t, eventStaleAfter, eventOldAfter)
+}
+
+// WithEvents returns the data for the events template, which renders Recent.
+func (d dashboardData) WithEvents(events []activity.RepositoryEvent) dashboardData {
+	d.Recent = events
+	return d
+}
+
+// DashboardHandler serves the status of the app and its recent activity as
+// an HTML page for humans at /ui.
+type DashboardHandler struct {
+	cfg            *configuration.Store
+	statusInformer *StatusInformer
+	tagInformer    *taginformer.TagInformer
+	jiraCheck      *checks.Jira
+	activity       *activity.Recorder
+	now            func() time.Time
+}
+
+func (dh *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now
+	if dh.now != nil {
+		now = dh.now
+	}
+	cfg := dh.cfg.Get()
+	data := dashboardData{
+		Status:   dh.statusInformer.GetStatus(cfg, dh.tagInformer, dh.jiraCheck),
+		Source:   cfg.Source,
+		LoadedAt: cfg.LoadedAt,
+		Now:      now(),
+		Recent:   dh.activity.Recent(dashboardEvents),
+		Checks:   dh.activity.Recent(dashboardChecks, activity.TypeCheck),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		klog.Errorf("failed to render the dashboard: %v", err)
+	}
+}
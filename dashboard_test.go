@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	si := &StatusInformer{}
+	si.UpdateBranchSyncStatus("quay/quay:redhat-3.9", "Synced", "synched from quay/quay:master, commit: 2219d5a")
+	si.UpdateBranchSyncStatus("quay/quay:redhat-3.8", "Error", "failed to get <branch>")
+	recorder := activity.NewRecorder(10)
+	recorder.Record("quay", "quay", activity.Event{Type: activity.TypeCheck, PullRequest: 1234, Summary: "Pull Request Title: success: PROJQUAY-123 is valid"})
+	recorder.Record("quay", "quay", activity.Event{Type: activity.TypeSync, Branch: "redhat-3.9", Summary: "synced from master"})
+
+	dh := &DashboardHandler{
+		cfg:            configuration.NewStore(&configuration.Configuration{Source: "config.yaml"}),
+		statusInformer: si,
+		activity:       recorder,
+		now: func() time.Time {
+			return time.Now().Add(20 * time.Minute)
+		},
+	}
+	w := httptest.NewRecorder()
+	dh.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"Configuration from config.yaml",
+		`<td class="fresh">Synced`,
+		`<td class="error">Error`,
+		"failed to get &lt;branch&gt;",
+		`<td class="stale">20m ago</td>`,
+		`<a href="https://github.com/quay/quay/pull/1234">#1234</a>`,
+		"synced from master",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the dashboard should contain %q:\n%s", want, body)
+		}
+	}
+	if checks := body[strings.Index(body, "Recent checks"):strings.Index(body, "Recent events")]; strings.Contains(checks, "synced from master") {
+		t.Errorf("the recent checks should only have the check events:\n%s", checks)
+	}
+
+	w = httptest.NewRecorder()
+	dh.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ui", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d for POST, want 405", w.Code)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/breaker"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/storage"
+	"k8s.io/klog/v2"
+)
+
+// maxDeferredAttempts is how many times a deferred event is handled before it
+// is dropped, so that an update that Jira always rejects is not retried
+// forever.
+const maxDeferredAttempts = 10
+
+type pendingRecheck struct {
+	org    string
+	repo   string
+	number int
+	event  checks.Event
+	// deferred is when the event was deferred the first time.
+	deferred time.Time
+}
+
+func (p pendingRecheck) String() string {
+	return fmt.Sprintf("%s/%s#%d (%s)", p.org, p.repo, p.number, p.event)
+}
+
+// DeferredRechecks keeps the events of the pull requests whose Jira check
+// couldn't be completed because Jira was unavailable or rejected an update,
+// and handles them again with their original event once the circuit breaker
+// lets requests through again. The events of the closed and merged pull
+// requests are handled too, so that their transitions are not lost.
+type DeferredRechecks struct {
+	breaker *breaker.Breaker
+	now     func() time.Time
+
+	mutex    sync.Mutex
+	pending  map[string]pendingRecheck
+	attempts map[string]int
+	storage  *storage.DB
+}
+
+func NewDeferredRechecks(b *breaker.Breaker) *DeferredRechecks {
+	return &DeferredRechecks{
+		breaker:  b,
+		now:      time.Now,
+		pending:  map[string]pendingRecheck{},
+		attempts: map[string]int{},
+	}
+}
+
+// SetStorage loads the deferred events from db and saves the changes to it,
+// so that the events are not lost when the app is restarted.
+func (d *DeferredRechecks) SetStorage(db *storage.DB) error {
+	events, err := db.LoadDeferredEvents()
+	if err != nil {
+		return fmt.Errorf("failed to load the deferred events: %w", err)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, e := range events {
+		p := pendingRecheck{org: e.Owner, repo: e.Repo, number: e.Number, event: checks.Event(e.Event), deferred: e.Time}
+		d.pending[p.String()] = p
+	}
+	d.storage = db
+	return nil
+}
+
+func (p pendingRecheck) deferredEvent() storage.DeferredEvent {
+	return storage.DeferredEvent{
+		PullRequest: storage.PullRequest{Owner: p.org, Repo: p.repo, Number: p.number},
+		Event:       string(p.event),
+		Time:        p.deferred,
+	}
+}
+
+// Add defers the handling of event for the pull request.
+func (d *DeferredRechecks) Add(org, repo string, number int, event checks.Event) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	p := pendingRecheck{org: org, repo: repo, number: number, event: event, deferred: d.now()}
+	key := p.String()
+	if existing, ok := d.pending[key]; ok {
+		p.deferred = existing.deferred
+	}
+	d.attempts[key]++
+	if d.attempts[key] >= maxDeferredAttempts {
+		klog.Errorf("dropping the deferred %s event of %s/%s#%d after %d attempts", event, org, repo, number, maxDeferredAttempts)
+		delete(d.pending, key)
+		delete(d.attempts, key)
+		d.deleteStored(p)
+		return
+	}
+	klog.V(2).Infof("deferring %s until Jira is available", p)
+	d.pending[key] = p
+	if d.storage != nil {
+		if err := d.storage.SaveDeferredEvent(p.deferredEvent()); err != nil {
+			klog.Errorf("failed to save the deferred event %s: %v", p, err)
+		}
+	}
+}
+
+// done forgets the event unless it was deferred again.
+func (d *DeferredRechecks) done(p pendingRecheck) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.pending[p.String()]; ok {
+		return
+	}
+	delete(d.attempts, p.String())
+	d.deleteStored(p)
+}
+
+func (d *DeferredRechecks) deleteStored(p pendingRecheck) {
+	if d.storage == nil {
+		return
+	}
+	if err := d.storage.DeleteDeferredEvent(p.deferredEvent()); err != nil {
+		klog.Errorf("failed to delete the deferred event %s: %v", p, err)
+	}
+}
+
+func (d *DeferredRechecks) Len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.pending)
+}
+
+// take returns the pending events in the order in which they were deferred.
+func (d *DeferredRechecks) take() []pendingRecheck {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var pending []pendingRecheck
+	for key, p := range d.pending {
+		pending = append(pending, p)
+		delete(d.pending, key)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].deferred.Equal(pending[j].deferred) {
+			return pending[i].deferred.Before(pending[j].deferred)
+		}
+		return pending[i].String() < pending[j].String()
+	})
+	return pending
+}
+
+// requeue puts the events back without counting an attempt.
+func (d *DeferredRechecks) requeue(pending []pendingRecheck) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, p := range pending {
+		d.pending[p.String()] = p
+	}
+}
+
+func (d *DeferredRechecks) run(ctx context.Context, r *reactor, timeout time.Duration) {
+	pending := d.take()
+	for i, p := range pending {
+		if !d.breaker.Ready() {
+			d.requeue(pending[i:])
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		d.recheck(checkCtx, r, p)
+		cancel()
+	}
+}
+
+// recheck handles the deferred event of the pull request again.
+func (d *DeferredRechecks) recheck(ctx context.Context, r *reactor, p pendingRecheck) {
+	pr, err := r.getPullRequest(ctx, p.org, p.repo, p.number)
+	if err != nil {
+		r.errorLog.Errorf(err, "failed to get pull request %s/%s#%d for a deferred event: %v", p.org, p.repo, p.number, err)
+		d.Add(p.org, p.repo, p.number, p.event)
+		return
+	}
+
+	klog.V(2).Infof("handling the deferred event %s", p)
+	// runJiraCheck defers the event again if the check still fails.
+	err = r.runJiraCheck(ctx, r.cfg.Get(), p.event, p.org, p.repo, pr)
+	if err != nil && !errors.Is(err, checks.ErrJiraUnavailable) {
+		r.errorLog.Errorf(err, "deferred event %s failed: %v", p, err)
+	}
+	d.done(p)
+}
+
+// Loop handles the deferred events every interval while Jira is available,
+// until ctx is done. Each event is cancelled after timeout.
+func (d *DeferredRechecks) Loop(ctx context.Context, r *reactor, interval, timeout time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if d.Len() > 0 && d.breaker.Ready() {
+			d.run(ctx, r, timeout)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/breaker"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+	"github.com/quay/quay-ci-app/storage"
+)
+
+// newDeferredTestReactor returns a reactor whose Jira rules move the issues of
+// the merged pull requests to ON_QA.
+func newDeferredTestReactor(gh *fakes.GitHub, fakeJira *fakes.Jira) *reactor {
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{
+			Owner: "quay",
+			Repo:  "quay",
+			Jira: configuration.Jira{
+				Key: "PROJQUAY",
+				Rules: []configuration.JiraRule{{
+					When:         configuration.JiraCondition{Merged: github.Bool(true)},
+					TransitionTo: "ON_QA",
+				}},
+			},
+		}},
+	})
+	r.jiraCheck = checks.NewJira(gh.Client(), gh.Client(), fakeJira.Client(), nil, nil, r.activity, time.Minute, time.Minute)
+	r.deferredRechecks = NewDeferredRechecks(breaker.New("jira", 5, time.Minute))
+	fakeJira.Transitions[""] = []jira.Transition{{ID: "51", Name: "Move to QA", To: jira.Status{Name: "ON_QA"}}}
+	return r
+}
+
+func mergedPullRequest(gh *fakes.GitHub) *github.PullRequest {
+	pr := fakes.PullRequest("quay", "quay", 1234, "Fix the build (PROJQUAY-123)")
+	pr.State = github.String("closed")
+	pr.Merged = github.Bool(true)
+	mergedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	pr.MergedAt = &mergedAt
+	gh.AddPullRequest(pr)
+	return pr
+}
+
+func TestDeferredMergeTransition(t *testing.T) {
+	gh := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "POST")
+	r := newDeferredTestReactor(gh, fakeJira)
+	pr := mergedPullRequest(gh)
+	ctx := context.Background()
+
+	// The transition of the merge fails.
+	fakeJira.TransitionErrors["PROJQUAY-123"] = http.StatusServiceUnavailable
+	if err := r.runJiraCheck(ctx, r.cfg.Get(), checks.EventClosed, "quay", "quay", pr); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.deferredRechecks.Len(); n != 1 {
+		t.Fatalf("the merge should be deferred, got %d deferred events", n)
+	}
+	pending := r.deferredRechecks.take()
+	if pending[0].event != checks.EventClosed {
+		t.Errorf("got the deferred event %s, want %s", pending[0].event, checks.EventClosed)
+	}
+	r.deferredRechecks.requeue(pending)
+
+	// The merged pull request is handled again with its close event.
+	delete(fakeJira.TransitionErrors, "PROJQUAY-123")
+	r.deferredRechecks.run(ctx, r, time.Minute)
+	if len(fakeJira.PerformedTransitions) != 1 || fakeJira.PerformedTransitions[0].To != "ON_QA" {
+		t.Errorf("the issue should be moved to ON_QA, got %+v", fakeJira.PerformedTransitions)
+	}
+	if n := r.deferredRechecks.Len(); n != 0 {
+		t.Errorf("got %d deferred events after the transition", n)
+	}
+}
+
+func TestDeferredEventsDropped(t *testing.T) {
+	gh := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "POST")
+	r := newDeferredTestReactor(gh, fakeJira)
+	pr := mergedPullRequest(gh)
+	ctx := context.Background()
+
+	fakeJira.TransitionErrors["PROJQUAY-123"] = http.StatusBadRequest
+	if err := r.runJiraCheck(ctx, r.cfg.Get(), checks.EventClosed, "quay", "quay", pr); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < maxDeferredAttempts; i++ {
+		if n := r.deferredRechecks.Len(); n != 1 {
+			t.Fatalf("attempt %d: got %d deferred events, want 1", i, n)
+		}
+		r.deferredRechecks.run(ctx, r, time.Minute)
+	}
+	if n := r.deferredRechecks.Len(); n != 0 {
+		t.Errorf("the event should be dropped after %d attempts, got %d deferred events", maxDeferredAttempts, n)
+	}
+}
+
+func TestDeferredEventsStorage(t *testing.T) {
+	db, err := storage.Open(storage.DriverSQLite, filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d := NewDeferredRechecks(breaker.New("jira", 5, time.Minute))
+	if err := d.SetStorage(db); err != nil {
+		t.Fatal(err)
+	}
+	d.Add("quay", "quay", 1234, checks.EventSync)
+	d.Add("quay", "quay", 1234, checks.EventClosed)
+
+	// The events survive a restart, in the order in which they were deferred.
+	restarted := NewDeferredRechecks(breaker.New("jira", 5, time.Minute))
+	if err := restarted.SetStorage(db); err != nil {
+		t.Fatal(err)
+	}
+	pending := restarted.take()
+	if len(pending) != 2 || pending[0].event != checks.EventSync || pending[1].event != checks.EventClosed {
+		t.Fatalf("got the deferred events %v", pending)
+	}
+	restarted.done(pending[0])
+	restarted.done(pending[1])
+	events, err := db.LoadDeferredEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("the handled events should be deleted, got %+v", events)
+	}
+}
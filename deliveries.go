@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/cache"
+	"github.com/quay/quay-ci-app/storage"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// deliveryRetention is how long the handled webhook deliveries are
+	// remembered. GitHub lets users redeliver the webhooks of the last 3
+	// days.
+	deliveryRetention = 7 * 24 * time.Hour
+
+	maxDeliveries = 50000
+)
+
+// DeliveryTracker skips the webhook deliveries that were already handled, so
+// that a redelivery, by GitHub or by replay tools, doesn't post the same
+// comments or make the same Jira transitions again. The deliveries are
+// identified by their X-GitHub-Delivery header. Failed deliveries are not
+// remembered, so that they can be redelivered.
+type DeliveryTracker struct {
+	handled *cache.Cache
+
+	mutex     sync.Mutex
+	inFlight  map[string]bool
+	storage   *storage.DB
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewDeliveryTracker() *DeliveryTracker {
+	return &DeliveryTracker{
+		handled:  cache.New("webhook_deliveries", maxDeliveries, deliveryRetention),
+		inFlight: map[string]bool{},
+		now:      time.Now,
+	}
+}
+
+// SetStorage makes the tracker remember the handled deliveries in db, so that
+// they are skipped after a restart too.
+func (d *DeliveryTracker) SetStorage(db *storage.DB) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.storage = db
+}
+
+// Start returns false if the delivery was already handled or is being handled.
+// Otherwise Finish must be called once the delivery is handled. Deliveries
+// without an ID are never skipped.
+func (d *DeliveryTracker) Start(id string) bool {
+	if id == "" {
+		return true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.inFlight[id] {
+		return false
+	}
+	if _, ok := d.handled.Get(id); ok {
+		return false
+	}
+	if d.storage != nil {
+		found, err := d.storage.HasDelivery(id)
+		if err != nil {
+			klog.Errorf("failed to check if the delivery %s was handled: %v", id, err)
+		}
+		if found {
+			d.handled.Add(id, true)
+			return false
+		}
+	}
+	d.inFlight[id] = true
+	return true
+}
+
+// Finish records the outcome of the delivery that Start returned true for.
+func (d *DeliveryTracker) Finish(id string, ok bool) {
+	if id == "" {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.inFlight, id)
+	if !ok {
+		return
+	}
+	d.handled.Add(id, true)
+	if d.storage == nil {
+		return
+	}
+	now := d.now()
+	if err := d.storage.SaveDelivery(id, now); err != nil {
+		klog.Errorf("failed to save the delivery %s: %v", id, err)
+	}
+	if now.Sub(d.lastPrune) > time.Hour {
+		d.lastPrune = now
+		if err := d.storage.DeleteDeliveriesBefore(now.Add(-deliveryRetention)); err != nil {
+			klog.Errorf("failed to delete the old deliveries: %v", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/quay/quay-ci-app/storage"
+)
+
+func TestDeliveryTracker(t *testing.T) {
+	d := NewDeliveryTracker()
+	if !d.Start("72d3162e-cc78-11e3-81ab-4c9367dc0958") {
+		t.Fatal("the first delivery should be handled")
+	}
+	if d.Start("72d3162e-cc78-11e3-81ab-4c9367dc0958") {
+		t.Error("a delivery that is being handled should be skipped")
+	}
+	d.Finish("72d3162e-cc78-11e3-81ab-4c9367dc0958", true)
+	if d.Start("72d3162e-cc78-11e3-81ab-4c9367dc0958") {
+		t.Error("a handled delivery should be skipped")
+	}
+
+	if !d.Start("failed") {
+		t.Fatal("the first delivery should be handled")
+	}
+	d.Finish("failed", false)
+	if !d.Start("failed") {
+		t.Error("a failed delivery should be handled again")
+	}
+
+	if !d.Start("") || !d.Start("") {
+		t.Error("the deliveries without an ID should always be handled")
+	}
+}
+
+func TestDeliveryTrackerStorage(t *testing.T) {
+	db, err := storage.Open(storage.DriverSQLite, filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d := NewDeliveryTracker()
+	d.SetStorage(db)
+	d.Start("72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	d.Finish("72d3162e-cc78-11e3-81ab-4c9367dc0958", true)
+
+	// The tracker of the restarted app skips the delivery too.
+	restarted := NewDeliveryTracker()
+	restarted.SetStorage(db)
+	if restarted.Start("72d3162e-cc78-11e3-81ab-4c9367dc0958") {
+		t.Error("a delivery handled before the restart should be skipped")
+	}
+	if !restarted.Start("5a1b2c3d") {
+		t.Error("a new delivery should be handled")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+// RepositoryDiscoverer adds the repositories of the installation of the app
+// that are not configured to the configuration, so that installing the app on
+// a repository is enough to enable the checks with the defaults.
+type RepositoryDiscoverer struct {
+	client *clients.GitHub
+	target configTarget
+
+	mutex sync.Mutex
+	base  *configuration.Configuration
+	repos []*github.Repository
+}
+
+// NewRepositoryDiscoverer returns a discoverer that applies the configuration
+// to target. The current configuration of target is used as the base
+// configuration.
+func NewRepositoryDiscoverer(client *clients.GitHub, target configTarget) *RepositoryDiscoverer {
+	return &RepositoryDiscoverer{
+		client: client,
+		target: target,
+		base:   target.Get(),
+	}
+}
+
+// Get returns the base configuration without the discovered repositories.
+func (rd *RepositoryDiscoverer) Get() *configuration.Configuration {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	return rd.base
+}
+
+// Set replaces the base configuration and adds the discovered repositories to
+// it again.
+func (rd *RepositoryDiscoverer) Set(cfg *configuration.Configuration) {
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	rd.base = cfg
+	rd.apply()
+}
+
+// apply sets the base configuration with the discovered repositories to the
+// target. The mutex must be held.
+func (rd *RepositoryDiscoverer) apply() {
+	discovery := rd.base.Discovery
+	if !discovery.Enabled {
+		rd.target.Set(rd.base)
+		return
+	}
+	var repos []configuration.ExternalRepository
+	for _, repo := range rd.repos {
+		owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+		if repo.GetArchived() || discovery.Excludes(owner, name) {
+			continue
+		}
+		if _, ok := rd.base.Repository(owner, name); ok {
+			continue
+		}
+		repos = append(repos, configuration.ExternalRepository{
+			Source: "discovered repository " + owner + "/" + name,
+			Spec:   []byte(fmt.Sprintf(`{"owner":%q,"repo":%q}`, owner, name)),
+		})
+	}
+	cfg, problems := rd.base.WithRepositories(repos)
+	for _, errs := range problems {
+		for _, err := range errs {
+			klog.Warningf("ignoring the %v", err)
+		}
+	}
+	rd.target.Set(cfg)
+}
+
+// installationRepos lists the repositories that the app is installed on.
+func (rd *RepositoryDiscoverer) installationRepos(ctx context.Context) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		list, resp, err := rd.client.Apps.ListRepos(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the repositories of the installation: %w", err)
+		}
+		repos = append(repos, list.Repositories...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].GetFullName() < repos[j].GetFullName()
+	})
+	return repos, nil
+}
+
+// Refresh lists the repositories of the installation again if discovery is
+// enabled.
+func (rd *RepositoryDiscoverer) Refresh(ctx context.Context) error {
+	if !rd.Get().Discovery.Enabled {
+		return nil
+	}
+	repos, err := rd.installationRepos(ctx)
+	if err != nil {
+		return err
+	}
+
+	rd.mutex.Lock()
+	defer rd.mutex.Unlock()
+	rd.repos = repos
+	rd.apply()
+	klog.V(2).Infof("discovered %d repositories of the installation", len(repos))
+	return nil
+}
+
+// Run refreshes the repositories at the interval of the configuration until
+// ctx is done.
+func (rd *RepositoryDiscoverer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rd.Get().Discovery.IntervalOrDefault()):
+		}
+		if err := rd.Refresh(ctx); err != nil {
+			klog.Errorf("failed to discover the repositories: %v", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func installationRepo(owner, name string, archived bool) *github.Repository {
+	return &github.Repository{
+		Owner:    &github.User{Login: github.String(owner)},
+		Name:     github.String(name),
+		FullName: github.String(owner + "/" + name),
+		Archived: github.Bool(archived),
+	}
+}
+
+func TestRepositoryDiscoverer(t *testing.T) {
+	gh := fakes.NewGitHub()
+	for i := 0; i < 150; i++ {
+		gh.InstallationRepos = append(gh.InstallationRepos, installationRepo("quay", fmt.Sprintf("repo-%03d", i), false))
+	}
+	gh.InstallationRepos = append(gh.InstallationRepos,
+		installationRepo("quay", "quay", false),
+		installationRepo("quay", "old", true),
+		installationRepo("quay", "docs-archive", false),
+		installationRepo("quay-sandbox", "test", false),
+	)
+
+	cfg, err := configuration.Load([]byte(`
+app_id: 1
+installation_id: 2
+defaults:
+  jira:
+    key: PROJQUAY
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: QUAY
+- owner: quay-sandbox
+  repo: "*"
+  dco: true
+discovery:
+  exclude: ["quay/*-archive"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := configuration.NewStore(cfg)
+	rd := NewRepositoryDiscoverer(gh.Client(), store)
+	if err := rd.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if store.Get() != cfg {
+		t.Fatalf("the repositories should not be discovered if discovery is disabled")
+	}
+
+	enabled := *cfg
+	enabled.Discovery.Enabled = true
+	rd.Set(&enabled)
+	if err := rd.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := store.Get()
+	if n := len(got.ExplicitRepositories()); n != 151 {
+		t.Errorf("got %d explicit repositories, want 151", n)
+	}
+	if repo, ok := got.Repository("quay", "repo-149"); !ok || repo.Jira.Key != "PROJQUAY" {
+		t.Errorf("the discovered repository should have the defaults, got %+v", repo)
+	}
+	if repo, _ := got.Repository("quay", "quay"); repo.Jira.Key != "QUAY" {
+		t.Errorf("the configured repository should not be replaced, got Jira key %q", repo.Jira.Key)
+	}
+	for _, name := range []string{"old", "docs-archive"} {
+		if _, ok := got.Repository("quay", name); ok {
+			t.Errorf("quay/%s should not be discovered", name)
+		}
+	}
+	if repo, _ := got.Repository("quay-sandbox", "test"); !repo.DCO {
+		t.Errorf("the repository that matches a pattern should use the pattern")
+	}
+	if rd.Get() != &enabled {
+		t.Errorf("the base configuration should not have the discovered repositories")
+	}
+}
+
+func TestHandleInstallationChange(t *testing.T) {
+	gh := fakes.NewGitHub()
+	cfg := &configuration.Configuration{AppID: 1, InstallationID: 2, Discovery: configuration.Discovery{Enabled: true}}
+	store := configuration.NewStore(cfg)
+	r := reactor{cfg: store, discoverer: NewRepositoryDiscoverer(gh.Client(), store)}
+
+	gh.InstallationRepos = []*github.Repository{installationRepo("quay", "clair", false)}
+	if err := r.HandleInstallationChange(context.Background(), 3, "added"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get().Repository("quay", "clair"); ok {
+		t.Errorf("the events of other installations should be ignored")
+	}
+	if err := r.HandleInstallationChange(context.Background(), 2, "added"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get().Repository("quay", "clair"); !ok {
+		t.Errorf("the added repository should be discovered")
+	}
+
+	gh.InstallationRepos = nil
+	if err := r.HandleInstallationChange(context.Background(), 2, "removed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get().Repository("quay", "clair"); ok {
+		t.Errorf("the removed repository should be dropped")
+	}
+}
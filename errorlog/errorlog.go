@@ -0,0 +1,148 @@
+// Package errorlog dedupes the repeated error logs. When a service is down,
+// every event fails with the same error, so an error is logged the first time
+// it's seen and then at most once per interval with the number of times it
+// was repeated. The summary of the recent errors is served on /status.
+package errorlog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// maxErrors is how many distinct errors are tracked, the errors that were
+// seen least recently are forgotten first.
+const maxErrors = 100
+
+// Summary is how many times an error was seen and when. Message is the last
+// log line of the error and Cause its root cause, which is the same for all
+// its occurrences.
+type Summary struct {
+	Message   string    `json:"message"`
+	Cause     string    `json:"cause"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type entry struct {
+	Summary
+	loggedAt   time.Time
+	suppressed int
+}
+
+// Aggregator logs the errors, but an error that was already logged less than
+// interval ago is only counted. The errors are the same if they are logged
+// with the same format and have the same root cause, so that the errors of
+// different pull requests and deliveries are grouped. It is safe to call the
+// methods of a nil Aggregator, which logs every error.
+type Aggregator struct {
+	interval time.Duration
+	now      func() time.Time
+	log      func(message string)
+
+	mutex  sync.Mutex
+	errors map[string]*entry
+}
+
+func New(interval time.Duration) *Aggregator {
+	return &Aggregator{
+		interval: interval,
+		now:      time.Now,
+		log: func(message string) {
+			klog.ErrorDepth(2, message)
+		},
+		errors: map[string]*entry{},
+	}
+}
+
+// Errorf logs the error err with the message of format and args, unless the
+// same error was logged less than the interval ago.
+func (a *Aggregator) Errorf(err error, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if a == nil {
+		klog.ErrorDepth(1, message)
+		return
+	}
+	cause := rootCause(err)
+	key := format + "\x00" + cause
+
+	a.mutex.Lock()
+	now := a.now()
+	e, ok := a.errors[key]
+	if !ok {
+		a.forgetOldest()
+		e = &entry{Summary: Summary{Cause: cause, FirstSeen: now}}
+		a.errors[key] = e
+	}
+	e.Message = message
+	e.Count++
+	e.LastSeen = now
+	if ok && now.Sub(e.loggedAt) < a.interval {
+		e.suppressed++
+		a.mutex.Unlock()
+		return
+	}
+	if e.suppressed > 0 {
+		message = fmt.Sprintf("%s (repeated %d more times since %s)", message, e.suppressed, e.loggedAt.Format(time.RFC3339))
+	}
+	e.loggedAt = now
+	e.suppressed = 0
+	a.mutex.Unlock()
+
+	a.log(message)
+}
+
+// forgetOldest drops the least recently seen error if there are too many.
+func (a *Aggregator) forgetOldest() {
+	if len(a.errors) < maxErrors {
+		return
+	}
+	var oldestKey string
+	var oldest time.Time
+	for key, e := range a.errors {
+		if oldestKey == "" || e.LastSeen.Before(oldest) {
+			oldestKey, oldest = key, e.LastSeen
+		}
+	}
+	delete(a.errors, oldestKey)
+}
+
+// Summaries returns the tracked errors, the most recently seen first.
+func (a *Aggregator) Summaries() []Summary {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	result := make([]Summary, 0, len(a.errors))
+	for _, e := range a.errors {
+		result = append(result, e.Summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastSeen.Equal(result[j].LastSeen) {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return result[i].Message < result[j].Message
+	})
+	return result
+}
+
+// rootCause returns the message of the innermost wrapped error.
+func rootCause(err error) string {
+	if err == nil {
+		return ""
+	}
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err.Error()
+		}
+		err = next
+	}
+}
@@ -0,0 +1,77 @@
+package errorlog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	var logged []string
+	a := New(time.Minute)
+	a.now = func() time.Time { return now }
+	a.log = func(message string) { logged = append(logged, message) }
+
+	jiraDown := errors.New("jira: 503 Service Unavailable")
+	for i, delivery := range []string{"a", "b", "c"} {
+		now = now.Add(time.Duration(i) * 10 * time.Second)
+		a.Errorf(fmt.Errorf("failed to get issue PROJQUAY-%d: %w", i, jiraDown), "failed to handle event %s (delivery: %s): %v", "pull_request", delivery, jiraDown)
+	}
+	a.Errorf(errors.New("not found"), "failed to refresh tags for %s: %v", "quay/quay", "not found")
+
+	want := []string{
+		"failed to handle event pull_request (delivery: a): jira: 503 Service Unavailable",
+		"failed to refresh tags for quay/quay: not found",
+	}
+	if !reflect.DeepEqual(logged, want) {
+		t.Errorf("got the logs %q, want %q", logged, want)
+	}
+
+	now = now.Add(time.Minute)
+	a.Errorf(jiraDown, "failed to handle event %s (delivery: %s): %v", "pull_request", "d", jiraDown)
+	if len(logged) != 3 || logged[2] != "failed to handle event pull_request (delivery: d): jira: 503 Service Unavailable (repeated 2 more times since 2022-03-01T12:00:00Z)" {
+		t.Errorf("the error should be logged again with the number of repetitions, got %q", logged)
+	}
+
+	summaries := a.Summaries()
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	jira := summaries[0]
+	if jira.Count != 4 || jira.Cause != "jira: 503 Service Unavailable" || !jira.FirstSeen.Equal(time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)) || !jira.LastSeen.Equal(now) {
+		t.Errorf("got the summary %+v", jira)
+	}
+	if summaries[1].Count != 1 {
+		t.Errorf("got the summary %+v", summaries[1])
+	}
+}
+
+func TestAggregatorForgetsOldest(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := New(time.Minute)
+	a.now = func() time.Time { return now }
+	a.log = func(string) {}
+
+	for i := 0; i <= maxErrors; i++ {
+		now = now.Add(time.Second)
+		a.Errorf(fmt.Errorf("error %d", i), "failed: %d", i)
+	}
+	summaries := a.Summaries()
+	if len(summaries) != maxErrors {
+		t.Fatalf("got %d summaries, want %d", len(summaries), maxErrors)
+	}
+	if last := summaries[len(summaries)-1]; last.Cause != "error 1" {
+		t.Errorf("the oldest error should be forgotten, got %q as the oldest", last.Cause)
+	}
+}
+
+func TestNilAggregator(t *testing.T) {
+	var a *Aggregator
+	a.Errorf(errors.New("error"), "failed: %v", "error")
+	if summaries := a.Summaries(); summaries != nil {
+		t.Errorf("got %v", summaries)
+	}
+}
@@ -0,0 +1,163 @@
+package fakes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/v42/github"
+)
+
+// The event builders return the event type and the body of a webhook
+// delivery, in the form that is accepted by EventHandler.HandleEvent.
+
+// Repository returns a repository with its owner set.
+func Repository(owner, repo string) *github.Repository {
+	return &github.Repository{
+		Name:     github.String(repo),
+		FullName: github.String(owner + "/" + repo),
+		Owner: &github.User{
+			Name:  github.String(owner),
+			Login: github.String(owner),
+		},
+	}
+}
+
+// PullRequest returns an open pull request from the author against the
+// master branch. The head SHA is derived from the repository and the number.
+func PullRequest(owner, repo string, number int, title string) *github.PullRequest {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s/%s#%d", owner, repo, number)))
+	return &github.PullRequest{
+		Number:  github.Int(number),
+		Title:   github.String(title),
+		State:   github.String("open"),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/pull/%d", owner, repo, number)),
+		User:    &github.User{Login: github.String("author")},
+		Base: &github.PullRequestBranch{
+			Ref:  github.String("master"),
+			Repo: Repository(owner, repo),
+		},
+		Head: &github.PullRequestBranch{
+			Ref:  github.String(fmt.Sprintf("pr-%d", number)),
+			SHA:  github.String(hex.EncodeToString(sum[:])),
+			Repo: Repository(owner, repo),
+		},
+	}
+}
+
+func mustMarshal(v interface{}) string {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(buf)
+}
+
+// PullRequestEvent returns a pull_request event, e.g. for the opened or the
+// synchronize action.
+func PullRequestEvent(action string, pr *github.PullRequest) (string, string) {
+	return "pull_request", mustMarshal(&github.PullRequestEvent{
+		Action:      github.String(action),
+		Number:      pr.Number,
+		PullRequest: pr,
+		Repo:        pr.GetBase().GetRepo(),
+	})
+}
+
+// PullRequestEditEvent returns a pull_request event for a title edit.
+func PullRequestEditEvent(pr *github.PullRequest, oldTitle string) (string, string) {
+	return "pull_request", mustMarshal(&github.PullRequestEvent{
+		Action:      github.String("edited"),
+		Number:      pr.Number,
+		PullRequest: pr,
+		Repo:        pr.GetBase().GetRepo(),
+		Changes: &github.EditChange{
+			Title: &github.EditTitle{From: github.String(oldTitle)},
+		},
+	})
+}
+
+// PullRequestLabelEvent returns a pull_request event for the labeled or the
+// unlabeled action.
+func PullRequestLabelEvent(action string, pr *github.PullRequest, label string) (string, string) {
+	return "pull_request", mustMarshal(&github.PullRequestEvent{
+		Action:      github.String(action),
+		Number:      pr.Number,
+		PullRequest: pr,
+		Repo:        pr.GetBase().GetRepo(),
+		Label:       &github.Label{Name: github.String(label)},
+	})
+}
+
+// PullRequestReviewEvent returns a pull_request_review event.
+func PullRequestReviewEvent(action string, pr *github.PullRequest, reviewer, state string) (string, string) {
+	return "pull_request_review", mustMarshal(&github.PullRequestReviewEvent{
+		Action:      github.String(action),
+		PullRequest: pr,
+		Repo:        pr.GetBase().GetRepo(),
+		Review: &github.PullRequestReview{
+			User:     &github.User{Login: github.String(reviewer)},
+			State:    github.String(state),
+			CommitID: pr.GetHead().SHA,
+		},
+	})
+}
+
+// IssueCommentEvent returns an issue_comment event for a new comment on the
+// pull request.
+func IssueCommentEvent(pr *github.PullRequest, author, body string) (string, string) {
+	return "issue_comment", mustMarshal(&github.IssueCommentEvent{
+		Action: github.String("created"),
+		Issue: &github.Issue{
+			Number:           pr.Number,
+			Title:            pr.Title,
+			State:            pr.State,
+			PullRequestLinks: &github.PullRequestLinks{HTMLURL: pr.HTMLURL},
+		},
+		Comment: &github.IssueComment{
+			User: &github.User{Login: github.String(author)},
+			Body: github.String(body),
+		},
+		Repo: pr.GetBase().GetRepo(),
+	})
+}
+
+// CheckSuiteRerequestEvent returns a check_suite event for a re-run of the
+// checks of the pull requests.
+func CheckSuiteRerequestEvent(owner, repo string, prs ...*github.PullRequest) (string, string) {
+	return "check_suite", mustMarshal(&github.CheckSuiteEvent{
+		Action: github.String("rerequested"),
+		CheckSuite: &github.CheckSuite{
+			PullRequests: prs,
+		},
+		Repo: Repository(owner, repo),
+	})
+}
+
+// PushEvent returns a push event for the ref, e.g. refs/heads/master or
+// refs/tags/v3.8.0.
+func PushEvent(owner, repo, ref string) (string, string) {
+	return "push", mustMarshal(&github.PushEvent{
+		Ref: github.String(ref),
+		Repo: &github.PushEventRepository{
+			Name:     github.String(repo),
+			FullName: github.String(owner + "/" + repo),
+			Owner: &github.User{
+				Name:  github.String(owner),
+				Login: github.String(owner),
+			},
+		},
+	})
+}
+
+// RepositoryDispatchEvent returns a repository_dispatch event with the client
+// payload.
+func RepositoryDispatchEvent(owner, repo, action string, payload interface{}) (string, string) {
+	return "repository_dispatch", mustMarshal(&github.RepositoryDispatchEvent{
+		Action:        github.String(action),
+		Branch:        github.String("master"),
+		ClientPayload: json.RawMessage(mustMarshal(payload)),
+		Repo:          Repository(owner, repo),
+	})
+}
@@ -0,0 +1,723 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/clients"
+)
+
+// GitHub is an in-memory GitHub. The exported fields hold the state of the
+// fake: tests populate them before the code under test runs and inspect them
+// afterwards. Pull requests and issues are keyed by IssueKey, repository
+// scoped objects by RepoKey.
+//
+// The raw request service is not faked, so the GraphQL queries are not
+// available.
+type GitHub struct {
+	mutex  sync.Mutex
+	nextID int64
+
+	App         *github.App
+	AppConfig   *github.AppConfig
+	RerunRunIDs []int64
+
+	// InstallationRepos are the repositories that the app is installed on.
+	InstallationRepos []*github.Repository
+
+	// CheckRuns are the check runs in the order they were created.
+	CheckRuns []*github.CheckRun
+
+	// Refs are keyed by RepoKey and the ref without the refs/ prefix, e.g.
+	// "quay/quay:heads/master".
+	Refs map[string]*github.Reference
+	// DivergedRefs are the refs, keyed like Refs, that can't be fast-forwarded:
+	// UpdateRef fails on them unless it is forced.
+	DivergedRefs map[string]bool
+	// Tags are the annotated tag objects, keyed by RepoKey and the SHA of the
+	// object, e.g. "quay/quay:0123abc".
+	Tags map[string]*github.Tag
+	// Merges are the merges in the order they were made, keyed by RepoKey.
+	Merges map[string][]*github.RepositoryMergeRequest
+
+	Comments   map[string][]*github.IssueComment
+	Labels     map[string][]string
+	RepoLabels map[string][]string
+	Milestones map[string][]*github.Milestone
+	// MilestoneErrors are the statuses of the failing milestone requests,
+	// keyed by RepoKey.
+	MilestoneErrors map[string]int
+	IssueEdits      map[string][]*github.IssueRequest
+	SearchItems     map[string][]*github.Issue
+	// SearchQueries are the queries of the issue searches in the order they
+	// were made.
+	SearchQueries []string
+	// Issues are the issues that were created, keyed by IssueKey.
+	Issues map[string]*github.Issue
+
+	PullRequests map[string]*github.PullRequest
+	Commits      map[string][]*github.RepositoryCommit
+	Files        map[string][]*github.CommitFile
+	Reviews      map[string][]*github.PullRequestReview
+	Merged       []string
+
+	// Contents are keyed by RepoKey and the path, e.g.
+	// "quay/quay:.github/CODEOWNERS".
+	Contents map[string]string
+	Releases map[string][]*github.RepositoryRelease
+	// Statuses are the commit statuses in the order they were created, keyed
+	// by RepoKey and the SHA, e.g. "quay/quay:0123abc".
+	Statuses map[string][]*github.RepoStatus
+
+	// TeamMembers are keyed by the organization and the team slug, e.g.
+	// "quay/maintainers".
+	TeamMembers map[string][]string
+}
+
+func NewGitHub() *GitHub {
+	return &GitHub{
+		App:             &github.App{Slug: github.String("quay-ci-app")},
+		Refs:            map[string]*github.Reference{},
+		DivergedRefs:    map[string]bool{},
+		Merges:          map[string][]*github.RepositoryMergeRequest{},
+		Tags:            map[string]*github.Tag{},
+		Comments:        map[string][]*github.IssueComment{},
+		Labels:          map[string][]string{},
+		RepoLabels:      map[string][]string{},
+		Milestones:      map[string][]*github.Milestone{},
+		MilestoneErrors: map[string]int{},
+		IssueEdits:      map[string][]*github.IssueRequest{},
+		SearchItems:     map[string][]*github.Issue{},
+		Issues:          map[string]*github.Issue{},
+		PullRequests:    map[string]*github.PullRequest{},
+		Commits:         map[string][]*github.RepositoryCommit{},
+		Files:           map[string][]*github.CommitFile{},
+		Reviews:         map[string][]*github.PullRequestReview{},
+		Contents:        map[string]string{},
+		Releases:        map[string][]*github.RepositoryRelease{},
+		Statuses:        map[string][]*github.RepoStatus{},
+		TeamMembers:     map[string][]string{},
+	}
+}
+
+// Client returns the client that is backed by the fake.
+func (f *GitHub) Client() *clients.GitHub {
+	return &clients.GitHub{
+		Actions:      &actionsService{f},
+		Apps:         &appsService{f},
+		Checks:       &checksService{f},
+		Git:          &gitService{f},
+		Issues:       &issuesService{f},
+		PullRequests: &pullRequestsService{f},
+		Repositories: &repositoriesService{f},
+		Search:       &searchService{f},
+		Teams:        &teamsService{f},
+	}
+}
+
+// RepoKey returns the key of the repository in the maps of the fake.
+func RepoKey(owner, repo string) string {
+	return owner + "/" + repo
+}
+
+// IssueKey returns the key of the issue or the pull request in the maps of
+// the fake.
+func IssueKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// AddPullRequest stores the pull request. It should be built with
+// PullRequest, so that its base repository is set.
+func (f *GitHub) AddPullRequest(pr *github.PullRequest) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repo := pr.GetBase().GetRepo().GetName()
+	f.PullRequests[IssueKey(owner, repo, pr.GetNumber())] = pr
+}
+
+// LatestCheckRun returns the latest check run with the name on the commit, or
+// nil if there is none.
+func (f *GitHub) LatestCheckRun(headSHA, name string) *github.CheckRun {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i := len(f.CheckRuns) - 1; i >= 0; i-- {
+		if f.CheckRuns[i].GetHeadSHA() == headSHA && f.CheckRuns[i].GetName() == name {
+			return f.CheckRuns[i]
+		}
+	}
+	return nil
+}
+
+func (f *GitHub) id() int64 {
+	f.nextID++
+	return f.nextID
+}
+
+func okResponse() *github.Response {
+	return &github.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+}
+
+func errorResponse(status int, format string, args ...interface{}) (*github.Response, error) {
+	resp := &github.Response{Response: &http.Response{StatusCode: status}}
+	return resp, fmt.Errorf("%d %s: %s", status, http.StatusText(status), fmt.Sprintf(format, args...))
+}
+
+type actionsService struct{ f *GitHub }
+
+func (s *actionsService) RerunWorkflowByID(ctx context.Context, owner, repo string, runID int64) (*github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	s.f.RerunRunIDs = append(s.f.RerunRunIDs, runID)
+	return okResponse(), nil
+}
+
+type appsService struct{ f *GitHub }
+
+func (s *appsService) Get(ctx context.Context, appSlug string) (*github.App, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	if appSlug != "" && appSlug != s.f.App.GetSlug() {
+		resp, err := errorResponse(http.StatusNotFound, "app %s", appSlug)
+		return nil, resp, err
+	}
+	return s.f.App, okResponse(), nil
+}
+
+func (s *appsService) CompleteAppManifest(ctx context.Context, code string) (*github.AppConfig, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	if s.f.AppConfig == nil {
+		resp, err := errorResponse(http.StatusNotFound, "app manifest %s", code)
+		return nil, resp, err
+	}
+	return s.f.AppConfig, okResponse(), nil
+}
+
+func (s *appsService) ListRepos(ctx context.Context, opts *github.ListOptions) (*github.ListRepositories, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	page, perPage := 1, 30
+	if opts != nil && opts.Page > 0 {
+		page = opts.Page
+	}
+	if opts != nil && opts.PerPage > 0 {
+		perPage = opts.PerPage
+	}
+	start := (page - 1) * perPage
+	if start > len(s.f.InstallationRepos) {
+		start = len(s.f.InstallationRepos)
+	}
+	end := start + perPage
+	resp := okResponse()
+	if end < len(s.f.InstallationRepos) {
+		resp.NextPage = page + 1
+	} else {
+		end = len(s.f.InstallationRepos)
+	}
+	return &github.ListRepositories{
+		TotalCount:   github.Int(len(s.f.InstallationRepos)),
+		Repositories: s.f.InstallationRepos[start:end],
+	}, resp, nil
+}
+
+type checksService struct{ f *GitHub }
+
+func (s *checksService) CreateCheckRun(ctx context.Context, owner, repo string, opts github.CreateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	checkRun := &github.CheckRun{
+		ID:          github.Int64(s.f.id()),
+		Name:        github.String(opts.Name),
+		HeadSHA:     github.String(opts.HeadSHA),
+		Status:      opts.Status,
+		Conclusion:  opts.Conclusion,
+		Output:      opts.Output,
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+	}
+	s.f.CheckRuns = append(s.f.CheckRuns, checkRun)
+	return checkRun, okResponse(), nil
+}
+
+func (s *checksService) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string, opts *github.ListCheckRunsOptions) (*github.ListCheckRunsResults, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	result := &github.ListCheckRunsResults{}
+	for _, checkRun := range s.f.CheckRuns {
+		if checkRun.GetHeadSHA() != ref {
+			continue
+		}
+		if opts != nil && opts.CheckName != nil && checkRun.GetName() != *opts.CheckName {
+			continue
+		}
+		result.CheckRuns = append(result.CheckRuns, checkRun)
+	}
+	result.Total = github.Int(len(result.CheckRuns))
+	return result, okResponse(), nil
+}
+
+type gitService struct{ f *GitHub }
+
+func refKey(owner, repo, ref string) string {
+	return RepoKey(owner, repo) + ":" + strings.TrimPrefix(ref, "refs/")
+}
+
+func (s *gitService) CreateRef(ctx context.Context, owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := refKey(owner, repo, ref.GetRef())
+	if _, ok := s.f.Refs[key]; ok {
+		resp, err := errorResponse(http.StatusUnprocessableEntity, "reference %s already exists", key)
+		return nil, resp, err
+	}
+	s.f.Refs[key] = ref
+	return ref, okResponse(), nil
+}
+
+// CreateTag stores the tag object. Its SHA is derived from the name and the
+// object of the tag, so that the same tag gets the same SHA.
+func (s *gitService) CreateTag(ctx context.Context, owner string, repo string, tag *github.Tag) (*github.Tag, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	created := *tag
+	created.SHA = github.String(fmt.Sprintf("tag-%s-%s", tag.GetTag(), tag.GetObject().GetSHA()))
+	s.f.Tags[RepoKey(owner, repo)+":"+created.GetSHA()] = &created
+	return &created, okResponse(), nil
+}
+
+func (s *gitService) GetRef(ctx context.Context, owner string, repo string, ref string) (*github.Reference, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	reference, ok := s.f.Refs[refKey(owner, repo, ref)]
+	if !ok {
+		resp, err := errorResponse(http.StatusNotFound, "reference %s", refKey(owner, repo, ref))
+		return nil, resp, err
+	}
+	return reference, okResponse(), nil
+}
+
+func (s *gitService) GetTag(ctx context.Context, owner string, repo string, sha string) (*github.Tag, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	tag, ok := s.f.Tags[RepoKey(owner, repo)+":"+sha]
+	if !ok {
+		resp, err := errorResponse(http.StatusNotFound, "tag %s in %s", sha, RepoKey(owner, repo))
+		return nil, resp, err
+	}
+	return tag, okResponse(), nil
+}
+
+func (s *gitService) UpdateRef(ctx context.Context, owner string, repo string, ref *github.Reference, force bool) (*github.Reference, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := refKey(owner, repo, ref.GetRef())
+	if _, ok := s.f.Refs[key]; !ok {
+		resp, err := errorResponse(http.StatusUnprocessableEntity, "reference %s does not exist", key)
+		return nil, resp, err
+	}
+	if s.f.DivergedRefs[key] && !force {
+		resp, err := errorResponse(http.StatusUnprocessableEntity, "update of %s is not a fast forward", key)
+		return nil, resp, err
+	}
+	s.f.Refs[key] = ref
+	return ref, okResponse(), nil
+}
+
+type issuesService struct{ f *GitHub }
+
+func (s *issuesService) AddLabelsToIssue(ctx context.Context, owner string, repo string, number int, labels []string) ([]*github.Label, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := IssueKey(owner, repo, number)
+	for _, label := range labels {
+		if !containsString(s.f.Labels[key], label) {
+			s.f.Labels[key] = append(s.f.Labels[key], label)
+		}
+	}
+	var result []*github.Label
+	for _, label := range s.f.Labels[key] {
+		result = append(result, &github.Label{Name: github.String(label)})
+	}
+	return result, okResponse(), nil
+}
+
+func (s *issuesService) Create(ctx context.Context, owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	created := &github.Issue{
+		Number: github.Int(int(s.f.id())),
+		Title:  issue.Title,
+		Body:   issue.Body,
+		State:  github.String("open"),
+	}
+	if issue.Labels != nil {
+		for _, label := range *issue.Labels {
+			created.Labels = append(created.Labels, &github.Label{Name: github.String(label)})
+		}
+	}
+	s.f.Issues[IssueKey(owner, repo, created.GetNumber())] = created
+	return created, okResponse(), nil
+}
+
+func (s *issuesService) CreateComment(ctx context.Context, owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := IssueKey(owner, repo, number)
+	now := time.Now()
+	created := &github.IssueComment{
+		ID:        github.Int64(s.f.id()),
+		Body:      comment.Body,
+		User:      &github.User{Login: github.String(s.f.App.GetSlug() + "[bot]")},
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	s.f.Comments[key] = append(s.f.Comments[key], created)
+	return created, okResponse(), nil
+}
+
+func (s *issuesService) CreateMilestone(ctx context.Context, owner string, repo string, milestone *github.Milestone) (*github.Milestone, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := RepoKey(owner, repo)
+	for _, existing := range s.f.Milestones[key] {
+		if existing.GetTitle() == milestone.GetTitle() {
+			resp, err := errorResponse(http.StatusUnprocessableEntity, "milestone %s already exists", milestone.GetTitle())
+			return nil, resp, err
+		}
+	}
+	created := &github.Milestone{
+		Number: github.Int(len(s.f.Milestones[key]) + 1),
+		Title:  milestone.Title,
+		State:  github.String("open"),
+	}
+	s.f.Milestones[key] = append(s.f.Milestones[key], created)
+	return created, okResponse(), nil
+}
+
+func (s *issuesService) DeleteComment(ctx context.Context, owner string, repo string, commentID int64) (*github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	prefix := RepoKey(owner, repo) + "#"
+	for key, comments := range s.f.Comments {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for i, comment := range comments {
+			if comment.GetID() == commentID {
+				s.f.Comments[key] = append(comments[:i:i], comments[i+1:]...)
+				return okResponse(), nil
+			}
+		}
+	}
+	return errorResponse(http.StatusNotFound, "comment %d", commentID)
+}
+
+func (s *issuesService) Edit(ctx context.Context, owner string, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := IssueKey(owner, repo, number)
+	s.f.IssueEdits[key] = append(s.f.IssueEdits[key], issue)
+	if existing, ok := s.f.Issues[key]; ok && issue.State != nil {
+		existing.State = issue.State
+	}
+	if pr, ok := s.f.PullRequests[key]; ok && issue.Milestone != nil {
+		for _, milestone := range s.f.Milestones[RepoKey(owner, repo)] {
+			if milestone.GetNumber() == *issue.Milestone {
+				pr.Milestone = milestone
+			}
+		}
+	}
+	return &github.Issue{Number: github.Int(number)}, okResponse(), nil
+}
+
+func (s *issuesService) EditMilestone(ctx context.Context, owner string, repo string, number int, milestone *github.Milestone) (*github.Milestone, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	for _, existing := range s.f.Milestones[RepoKey(owner, repo)] {
+		if existing.GetNumber() != number {
+			continue
+		}
+		if milestone.Title != nil {
+			existing.Title = milestone.Title
+		}
+		if milestone.State != nil {
+			existing.State = milestone.State
+		}
+		return existing, okResponse(), nil
+	}
+	resp, err := errorResponse(http.StatusNotFound, "milestone %d", number)
+	return nil, resp, err
+}
+
+func (s *issuesService) GetLabel(ctx context.Context, owner string, repo string, name string) (*github.Label, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	if !containsString(s.f.RepoLabels[RepoKey(owner, repo)], name) {
+		resp, err := errorResponse(http.StatusNotFound, "label %s", name)
+		return nil, resp, err
+	}
+	return &github.Label{Name: github.String(name)}, okResponse(), nil
+}
+
+func (s *issuesService) ListByRepo(ctx context.Context, owner string, repo string, opts *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	state := "open"
+	if opts != nil && opts.State != "" {
+		state = opts.State
+	}
+	prefix := RepoKey(owner, repo) + "#"
+	var issues []*github.Issue
+	for key, issue := range s.f.Issues {
+		if !strings.HasPrefix(key, prefix) || (state != "all" && issue.GetState() != state) {
+			continue
+		}
+		hasLabels := true
+		if opts != nil {
+			for _, label := range opts.Labels {
+				found := false
+				for _, l := range issue.Labels {
+					found = found || l.GetName() == label
+				}
+				hasLabels = hasLabels && found
+			}
+		}
+		if hasLabels {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, okResponse(), nil
+}
+
+func (s *issuesService) ListComments(ctx context.Context, owner string, repo string, number int, opts *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	comments := append([]*github.IssueComment(nil), s.f.Comments[IssueKey(owner, repo, number)]...)
+	return comments, okResponse(), nil
+}
+
+func (s *issuesService) ListMilestones(ctx context.Context, owner string, repo string, opts *github.MilestoneListOptions) ([]*github.Milestone, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	if status, ok := s.f.MilestoneErrors[RepoKey(owner, repo)]; ok {
+		resp, err := errorResponse(status, "milestones of %s/%s", owner, repo)
+		return nil, resp, err
+	}
+	var milestones []*github.Milestone
+	for _, milestone := range s.f.Milestones[RepoKey(owner, repo)] {
+		state := "open"
+		if opts != nil && opts.State != "" {
+			state = opts.State
+		}
+		if state == "all" || milestone.GetState() == state {
+			milestones = append(milestones, milestone)
+		}
+	}
+	return milestones, okResponse(), nil
+}
+
+func (s *issuesService) RemoveLabelForIssue(ctx context.Context, owner string, repo string, number int, label string) (*github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := IssueKey(owner, repo, number)
+	for i, existing := range s.f.Labels[key] {
+		if existing == label {
+			s.f.Labels[key] = append(s.f.Labels[key][:i:i], s.f.Labels[key][i+1:]...)
+			return okResponse(), nil
+		}
+	}
+	return errorResponse(http.StatusNotFound, "label %s on %s", label, key)
+}
+
+type pullRequestsService struct{ f *GitHub }
+
+func (s *pullRequestsService) Get(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	pr, ok := s.f.PullRequests[IssueKey(owner, repo, number)]
+	if !ok {
+		resp, err := errorResponse(http.StatusNotFound, "pull request %s", IssueKey(owner, repo, number))
+		return nil, resp, err
+	}
+	return pr, okResponse(), nil
+}
+
+func (s *pullRequestsService) List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	prefix := RepoKey(owner, repo) + "#"
+	var prs []*github.PullRequest
+	for key, pr := range s.f.PullRequests {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		state := "open"
+		if opts != nil && opts.State != "" {
+			state = opts.State
+		}
+		if state != "all" && pr.GetState() != state {
+			continue
+		}
+		if opts != nil && opts.Base != "" && pr.GetBase().GetRef() != opts.Base {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, okResponse(), nil
+}
+
+func (s *pullRequestsService) ListCommits(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	return s.f.Commits[IssueKey(owner, repo, number)], okResponse(), nil
+}
+
+func (s *pullRequestsService) ListFiles(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	return s.f.Files[IssueKey(owner, repo, number)], okResponse(), nil
+}
+
+func (s *pullRequestsService) ListReviews(ctx context.Context, owner, repo string, number int, opts *github.ListOptions) ([]*github.PullRequestReview, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	return s.f.Reviews[IssueKey(owner, repo, number)], okResponse(), nil
+}
+
+func (s *pullRequestsService) Merge(ctx context.Context, owner string, repo string, number int, commitMessage string, options *github.PullRequestOptions) (*github.PullRequestMergeResult, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := IssueKey(owner, repo, number)
+	pr, ok := s.f.PullRequests[key]
+	if !ok || pr.GetState() != "open" {
+		resp, err := errorResponse(http.StatusMethodNotAllowed, "pull request %s is not mergeable", key)
+		return nil, resp, err
+	}
+	if options != nil && options.SHA != "" && options.SHA != pr.GetHead().GetSHA() {
+		resp, err := errorResponse(http.StatusConflict, "head of %s was modified", key)
+		return nil, resp, err
+	}
+	pr.State = github.String("closed")
+	pr.Merged = github.Bool(true)
+	s.f.Merged = append(s.f.Merged, key)
+	return &github.PullRequestMergeResult{Merged: github.Bool(true), SHA: pr.GetHead().SHA}, okResponse(), nil
+}
+
+type repositoriesService struct{ f *GitHub }
+
+func (s *repositoriesService) CreateRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := RepoKey(owner, repo)
+	created := *release
+	created.ID = github.Int64(s.f.id())
+	s.f.Releases[key] = append(s.f.Releases[key], &created)
+	return &created, okResponse(), nil
+}
+
+func (s *repositoriesService) CreateStatus(ctx context.Context, owner, repo, ref string, status *github.RepoStatus) (*github.RepoStatus, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := RepoKey(owner, repo) + ":" + ref
+	created := *status
+	created.ID = github.Int64(s.f.id())
+	s.f.Statuses[key] = append(s.f.Statuses[key], &created)
+	return &created, okResponse(), nil
+}
+
+func (s *repositoriesService) GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	content, ok := s.f.Contents[RepoKey(owner, repo)+":"+path]
+	if !ok {
+		resp, err := errorResponse(http.StatusNotFound, "%s in %s", path, RepoKey(owner, repo))
+		return nil, nil, resp, err
+	}
+	return &github.RepositoryContent{
+		Type:    github.String("file"),
+		Path:    github.String(path),
+		Content: github.String(content),
+	}, nil, okResponse(), nil
+}
+
+func (s *repositoriesService) GetReleaseByTag(ctx context.Context, owner, repo, tag string) (*github.RepositoryRelease, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	for _, release := range s.f.Releases[RepoKey(owner, repo)] {
+		if release.GetTagName() == tag {
+			return release, okResponse(), nil
+		}
+	}
+	resp, err := errorResponse(http.StatusNotFound, "release %s in %s", tag, RepoKey(owner, repo))
+	return nil, resp, err
+}
+
+// Merge merges the head into the base branch with a new commit. The head is
+// considered merged if it was merged into the base before.
+func (s *repositoriesService) Merge(ctx context.Context, owner, repo string, request *github.RepositoryMergeRequest) (*github.RepositoryCommit, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	key := RepoKey(owner, repo)
+	for _, merge := range s.f.Merges[key] {
+		if merge.GetBase() == request.GetBase() && merge.GetHead() == request.GetHead() {
+			return &github.RepositoryCommit{}, &github.Response{Response: &http.Response{StatusCode: http.StatusNoContent}}, nil
+		}
+	}
+	ref := refKey(owner, repo, "heads/"+request.GetBase())
+	if _, ok := s.f.Refs[ref]; !ok {
+		resp, err := errorResponse(http.StatusNotFound, "branch %s in %s", request.GetBase(), key)
+		return nil, resp, err
+	}
+	sha := fmt.Sprintf("merge-%d", s.f.id())
+	s.f.Refs[ref] = &github.Reference{
+		Ref:    github.String("refs/heads/" + request.GetBase()),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+	s.f.Merges[key] = append(s.f.Merges[key], request)
+	return &github.RepositoryCommit{SHA: github.String(sha)}, &github.Response{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+}
+
+type searchService struct{ f *GitHub }
+
+// Issues returns the items that are stored for the exact query.
+func (s *searchService) Issues(ctx context.Context, query string, opts *github.SearchOptions) (*github.IssuesSearchResult, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	s.f.SearchQueries = append(s.f.SearchQueries, query)
+	items := s.f.SearchItems[query]
+	return &github.IssuesSearchResult{
+		Total:  github.Int(len(items)),
+		Issues: items,
+	}, okResponse(), nil
+}
+
+type teamsService struct{ f *GitHub }
+
+func (s *teamsService) ListTeamMembersBySlug(ctx context.Context, org, slug string, opts *github.TeamListTeamMembersOptions) ([]*github.User, *github.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	logins, ok := s.f.TeamMembers[org+"/"+slug]
+	if !ok {
+		resp, err := errorResponse(http.StatusNotFound, "team %s/%s", org, slug)
+		return nil, resp, err
+	}
+	var users []*github.User
+	for _, login := range logins {
+		users = append(users, &github.User{Login: github.String(login)})
+	}
+	return users, okResponse(), nil
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,382 @@
+package fakes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/quay/quay-ci-app/clients"
+)
+
+// PerformedTransition is a transition that was performed on a Jira issue.
+type PerformedTransition struct {
+	Issue        string
+	TransitionID string
+	To           string
+}
+
+// Jira is an in-memory Jira. Like GitHub, the exported fields hold the state
+// of the fake.
+type Jira struct {
+	mutex  sync.Mutex
+	nextID int
+
+	BaseURL url.URL
+
+	// Issues are keyed by the issue key.
+	Issues map[string]*jira.Issue
+
+	// Transitions are the transitions that are available for an issue. The
+	// transitions for the empty key are available for all issues.
+	Transitions map[string][]jira.Transition
+
+	// TransitionErrors are the status codes that the transitions of the
+	// issues fail with, keyed by the issue key.
+	TransitionErrors map[string]int
+
+	PerformedTransitions []PerformedTransition
+	Updates              map[string][]map[string]interface{}
+	Links                []*jira.IssueLink
+
+	// Projects and Statuses are keyed by the project key.
+	Projects map[string]*jira.Project
+	Statuses map[string][]string
+
+	// SearchResults are the issues that are returned for the exact JQL query.
+	SearchResults map[string][]jira.Issue
+}
+
+func NewJira() *Jira {
+	return &Jira{
+		BaseURL:          url.URL{Scheme: "https", Host: "issues.example.com", Path: "/"},
+		Issues:           map[string]*jira.Issue{},
+		Transitions:      map[string][]jira.Transition{},
+		TransitionErrors: map[string]int{},
+		Updates:          map[string][]map[string]interface{}{},
+		Projects:         map[string]*jira.Project{},
+		Statuses:         map[string][]string{},
+		SearchResults:    map[string][]jira.Issue{},
+	}
+}
+
+// Client returns the client that is backed by the fake.
+func (f *Jira) Client() *clients.Jira {
+	return &clients.Jira{
+		JiraRequestService: &jiraRequestService{f},
+		Issue:              &jiraIssueService{f},
+		Project:            &jiraProjectService{f},
+		Version:            &jiraVersionService{f},
+	}
+}
+
+// AddIssue stores an issue of the given type in the given status.
+func (f *Jira) AddIssue(key, issueType, status string) *jira.Issue {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	issue := &jira.Issue{
+		Key: key,
+		Fields: &jira.IssueFields{
+			Type:   jira.IssueType{Name: issueType},
+			Status: &jira.Status{Name: status},
+		},
+	}
+	f.Issues[key] = issue
+	return issue
+}
+
+// ChangeStatus moves the issue to status at the given time and records the
+// change in the changelog of the issue, like a change made in the Jira UI.
+func (f *Jira) ChangeStatus(key, status string, at time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	addStatusChange(f.Issues[key], status, at)
+}
+
+func addStatusChange(issue *jira.Issue, status string, at time.Time) {
+	if issue.Changelog == nil {
+		issue.Changelog = &jira.Changelog{}
+	}
+	issue.Changelog.Histories = append(issue.Changelog.Histories, jira.ChangelogHistory{
+		Created: at.Format("2006-01-02T15:04:05.999-0700"),
+		Items: []jira.ChangelogItems{{
+			Field:      "status",
+			FromString: issue.Fields.Status.Name,
+			ToString:   status,
+		}},
+	})
+	issue.Fields.Status = &jira.Status{Name: status}
+}
+
+// AddProject stores a project with the given versions. The statuses are
+// reported for all issue types of the project.
+func (f *Jira) AddProject(key string, statuses []string, versions ...string) *jira.Project {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.nextID++
+	projectID := f.nextID
+	project := &jira.Project{
+		ID:  strconv.Itoa(projectID),
+		Key: key,
+	}
+	for _, name := range versions {
+		f.nextID++
+		project.Versions = append(project.Versions, jira.Version{
+			ID:        strconv.Itoa(f.nextID),
+			Name:      name,
+			ProjectID: projectID,
+		})
+	}
+	f.Projects[key] = project
+	f.Statuses[key] = statuses
+	return project
+}
+
+func jiraResponse(status int) *jira.Response {
+	return &jira.Response{Response: &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader("")),
+	}}
+}
+
+func jiraError(status int, format string, args ...interface{}) (*jira.Response, error) {
+	return jiraResponse(status), fmt.Errorf("%d %s: %s", status, http.StatusText(status), fmt.Sprintf(format, args...))
+}
+
+type jiraRequestService struct{ f *Jira }
+
+func (s *jiraRequestService) NewRequestWithContext(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error) {
+	u, err := s.f.BaseURL.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), &buf)
+}
+
+// Do serves the endpoints that the app calls without go-jira services.
+func (s *jiraRequestService) Do(req *http.Request, v interface{}) (*jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, s.f.BaseURL.Path)
+	parts := strings.Split(path, "/")
+	if req.Method != http.MethodGet || len(parts) != 6 || strings.Join(parts[:4], "/") != "rest/api/2/project" || parts[5] != "statuses" {
+		return jiraError(http.StatusNotFound, "%s %s", req.Method, path)
+	}
+	statuses, ok := s.f.Statuses[parts[4]]
+	if !ok {
+		return jiraError(http.StatusNotFound, "project %s", parts[4])
+	}
+
+	type status struct {
+		Name string `json:"name"`
+	}
+	type issueType struct {
+		Name     string   `json:"name"`
+		Statuses []status `json:"statuses"`
+	}
+	result := []issueType{{Name: "Bug"}}
+	for _, name := range statuses {
+		result[0].Statuses = append(result[0].Statuses, status{Name: name})
+	}
+	buf, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return nil, err
+	}
+	return jiraResponse(http.StatusOK), nil
+}
+
+func (s *jiraRequestService) GetBaseURL() url.URL {
+	return s.f.BaseURL
+}
+
+type jiraIssueService struct{ f *Jira }
+
+func (s *jiraIssueService) AddLinkWithContext(ctx context.Context, issueLink *jira.IssueLink) (*jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	s.f.Links = append(s.f.Links, issueLink)
+	return jiraResponse(http.StatusCreated), nil
+}
+
+func (s *jiraIssueService) CreateWithContext(ctx context.Context, issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	project := ""
+	if issue.Fields != nil {
+		project = issue.Fields.Project.Key
+	}
+	s.f.nextID++
+	created := *issue
+	created.ID = strconv.Itoa(s.f.nextID)
+	created.Key = fmt.Sprintf("%s-%d", project, s.f.nextID)
+	s.f.Issues[created.Key] = &created
+	return &created, jiraResponse(http.StatusCreated), nil
+}
+
+func (s *jiraIssueService) DoTransitionWithContext(ctx context.Context, ticketID, transitionID string) (*jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	issue, ok := s.f.Issues[ticketID]
+	if !ok {
+		return jiraError(http.StatusNotFound, "issue %s", ticketID)
+	}
+	if status, ok := s.f.TransitionErrors[ticketID]; ok {
+		return jiraError(status, "transition %s of %s", transitionID, ticketID)
+	}
+	for _, transition := range s.f.transitions(ticketID) {
+		if transition.ID != transitionID {
+			continue
+		}
+		to := transition.To
+		addStatusChange(issue, to.Name, time.Now())
+		s.f.PerformedTransitions = append(s.f.PerformedTransitions, PerformedTransition{
+			Issue:        ticketID,
+			TransitionID: transitionID,
+			To:           to.Name,
+		})
+		return jiraResponse(http.StatusNoContent), nil
+	}
+	return jiraError(http.StatusBadRequest, "transition %s is not available for %s", transitionID, ticketID)
+}
+
+func (f *Jira) transitions(key string) []jira.Transition {
+	if transitions, ok := f.Transitions[key]; ok {
+		return transitions
+	}
+	return f.Transitions[""]
+}
+
+func (s *jiraIssueService) GetTransitionsWithContext(ctx context.Context, id string) ([]jira.Transition, *jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	if _, ok := s.f.Issues[id]; !ok {
+		resp, err := jiraError(http.StatusNotFound, "issue %s", id)
+		return nil, resp, err
+	}
+	return s.f.transitions(id), jiraResponse(http.StatusOK), nil
+}
+
+func (s *jiraIssueService) GetWithContext(ctx context.Context, issueID string, options *jira.GetQueryOptions) (*jira.Issue, *jira.Response, error) {
+	// Like the real client, the request is not sent if ctx is done.
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	issue, ok := s.f.Issues[issueID]
+	if !ok {
+		resp, err := jiraError(http.StatusNotFound, "issue %s", issueID)
+		return nil, resp, err
+	}
+	copied := *issue
+	if issue.Fields != nil {
+		fields := *issue.Fields
+		copied.Fields = &fields
+	}
+	return &copied, jiraResponse(http.StatusOK), nil
+}
+
+func (s *jiraIssueService) SearchWithContext(ctx context.Context, jql string, options *jira.SearchOptions) ([]jira.Issue, *jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	issues := s.f.SearchResults[jql]
+	start := 0
+	if options != nil {
+		start = options.StartAt
+	}
+	if start > len(issues) {
+		start = len(issues)
+	}
+	resp := jiraResponse(http.StatusOK)
+	resp.Total = len(issues)
+	resp.StartAt = start
+	resp.MaxResults = len(issues) - start
+	return issues[start:], resp, nil
+}
+
+func (s *jiraIssueService) UpdateIssueWithContext(ctx context.Context, jiraID string, data map[string]interface{}) (*jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	if _, ok := s.f.Issues[jiraID]; !ok {
+		return jiraError(http.StatusNotFound, "issue %s", jiraID)
+	}
+	s.f.Updates[jiraID] = append(s.f.Updates[jiraID], data)
+	return jiraResponse(http.StatusNoContent), nil
+}
+
+type jiraProjectService struct{ f *Jira }
+
+func (s *jiraProjectService) GetWithContext(ctx context.Context, projectID string) (*jira.Project, *jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	project, ok := s.f.Projects[projectID]
+	if !ok {
+		resp, err := jiraError(http.StatusNotFound, "project %s", projectID)
+		return nil, resp, err
+	}
+	copied := *project
+	copied.Versions = append([]jira.Version(nil), project.Versions...)
+	return &copied, jiraResponse(http.StatusOK), nil
+}
+
+type jiraVersionService struct{ f *Jira }
+
+func (s *jiraVersionService) CreateWithContext(ctx context.Context, version *jira.Version) (*jira.Version, *jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	for _, project := range s.f.Projects {
+		if project.ID != strconv.Itoa(version.ProjectID) {
+			continue
+		}
+		s.f.nextID++
+		created := *version
+		created.ID = strconv.Itoa(s.f.nextID)
+		project.Versions = append(project.Versions, created)
+		return &created, jiraResponse(http.StatusCreated), nil
+	}
+	resp, err := jiraError(http.StatusNotFound, "project %d", version.ProjectID)
+	return nil, resp, err
+}
+
+func (s *jiraVersionService) UpdateWithContext(ctx context.Context, version *jira.Version) (*jira.Version, *jira.Response, error) {
+	s.f.mutex.Lock()
+	defer s.f.mutex.Unlock()
+	for _, project := range s.f.Projects {
+		for i := range project.Versions {
+			existing := &project.Versions[i]
+			if existing.ID != version.ID {
+				continue
+			}
+			if version.Released != nil {
+				existing.Released = version.Released
+			}
+			if version.ReleaseDate != "" {
+				existing.ReleaseDate = version.ReleaseDate
+			}
+			if version.Archived != nil {
+				existing.Archived = version.Archived
+			}
+			updated := *existing
+			return &updated, jiraResponse(http.StatusOK), nil
+		}
+	}
+	resp, err := jiraError(http.StatusNotFound, "version %s", version.ID)
+	return nil, resp, err
+}
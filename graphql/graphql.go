@@ -0,0 +1,288 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+)
+
+const pullRequestQuery = `query($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      number
+      title
+      body
+      state
+      merged
+      mergedAt
+      updatedAt
+      isDraft
+      url
+      author { __typename login }
+      headRefName
+      headRefOid
+      baseRefName
+      baseRepository { name owner { login } }
+      labels(first: 100) { nodes { name } }
+      milestone { number title state }
+      comments(last: 100) {
+        totalCount
+        nodes {
+          databaseId
+          body
+          createdAt
+          author { __typename login }
+        }
+      }
+      commits(last: 1) {
+        nodes {
+          commit {
+            checkSuites(first: 20) {
+              nodes {
+                app { slug }
+                checkRuns(first: 50) {
+                  nodes { databaseId name status conclusion title completedAt }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+type actor struct {
+	Typename string `json:"__typename"`
+	Login    string `json:"login"`
+}
+
+// login returns the login in the same format as the REST API, which adds the
+// [bot] suffix to the logins of apps.
+func (a *actor) login() string {
+	if a == nil {
+		return ""
+	}
+	if a.Typename == "Bot" {
+		return a.Login + "[bot]"
+	}
+	return a.Login
+}
+
+type pullRequestResponse struct {
+	Repository struct {
+		PullRequest *struct {
+			Number         int        `json:"number"`
+			Title          string     `json:"title"`
+			Body           string     `json:"body"`
+			State          string     `json:"state"`
+			Merged         bool       `json:"merged"`
+			MergedAt       *time.Time `json:"mergedAt"`
+			UpdatedAt      time.Time  `json:"updatedAt"`
+			IsDraft        bool       `json:"isDraft"`
+			URL            string     `json:"url"`
+			Author         *actor     `json:"author"`
+			HeadRefName    string     `json:"headRefName"`
+			HeadRefOid     string     `json:"headRefOid"`
+			BaseRefName    string     `json:"baseRefName"`
+			BaseRepository struct {
+				Name  string `json:"name"`
+				Owner struct {
+					Login string `json:"login"`
+				} `json:"owner"`
+			} `json:"baseRepository"`
+			Labels struct {
+				Nodes []struct {
+					Name string `json:"name"`
+				} `json:"nodes"`
+			} `json:"labels"`
+			Milestone *struct {
+				Number int    `json:"number"`
+				Title  string `json:"title"`
+				State  string `json:"state"`
+			} `json:"milestone"`
+			Comments struct {
+				TotalCount int `json:"totalCount"`
+				Nodes      []struct {
+					DatabaseID int64     `json:"databaseId"`
+					Body       string    `json:"body"`
+					CreatedAt  time.Time `json:"createdAt"`
+					Author     *actor    `json:"author"`
+				} `json:"nodes"`
+			} `json:"comments"`
+			Commits struct {
+				Nodes []struct {
+					Commit struct {
+						CheckSuites struct {
+							Nodes []struct {
+								App *struct {
+									Slug string `json:"slug"`
+								} `json:"app"`
+								CheckRuns struct {
+									Nodes []struct {
+										DatabaseID  int64      `json:"databaseId"`
+										Name        string     `json:"name"`
+										Status      string     `json:"status"`
+										Conclusion  string     `json:"conclusion"`
+										Title       string     `json:"title"`
+										CompletedAt *time.Time `json:"completedAt"`
+									} `json:"nodes"`
+								} `json:"checkRuns"`
+							} `json:"nodes"`
+						} `json:"checkSuites"`
+					} `json:"commit"`
+				} `json:"nodes"`
+			} `json:"commits"`
+		} `json:"pullRequest"`
+	} `json:"repository"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   *pullRequestResponse `json:"data"`
+	Errors []graphQLError       `json:"errors"`
+}
+
+// PullRequest is a pull request with its comments and the check runs of its
+// head commit. Comments is nil if the pull request has too many comments to
+// be fetched in one query.
+type PullRequest struct {
+	PullRequest *github.PullRequest
+	Comments    []*github.IssueComment
+	CheckRuns   []*github.CheckRun
+}
+
+// Client sends GraphQL requests. It is implemented by *github.Client.
+type Client interface {
+	NewRequest(method, urlStr string, body interface{}) (*http.Request, error)
+	Do(ctx context.Context, req *http.Request, v interface{}) (*github.Response, error)
+}
+
+// GetPullRequest fetches the pull request, its comments (if there are no more
+// than 100 of them) and the check runs of its head commit with a single GraphQL query. The result is
+// converted into the go-github types, but only the fields that the app uses
+// are set.
+func GetPullRequest(ctx context.Context, client Client, owner, repo string, number int) (*PullRequest, error) {
+	req, err := client.NewRequest("POST", "graphql", map[string]interface{}{
+		"query": pullRequestQuery,
+		"variables": map[string]interface{}{
+			"owner":  owner,
+			"repo":   repo,
+			"number": number,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp graphQLResponse
+	_, err = client.Do(ctx, req, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pull request %s/%s#%d: %w", owner, repo, number, err)
+	}
+	if len(resp.Errors) > 0 {
+		var messages []string
+		for _, e := range resp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("failed to query pull request %s/%s#%d: %s", owner, repo, number, strings.Join(messages, "; "))
+	}
+	if resp.Data == nil || resp.Data.Repository.PullRequest == nil {
+		return nil, fmt.Errorf("pull request %s/%s#%d not found", owner, repo, number)
+	}
+
+	return convertPullRequest(resp.Data), nil
+}
+
+func convertPullRequest(data *pullRequestResponse) *PullRequest {
+	p := data.Repository.PullRequest
+
+	state := "open"
+	if p.State != "OPEN" {
+		state = "closed"
+	}
+	pr := &github.PullRequest{
+		Number:  github.Int(p.Number),
+		Title:   github.String(p.Title),
+		Body:    github.String(p.Body),
+		State:   github.String(state),
+		Merged:  github.Bool(p.Merged),
+		Draft:   github.Bool(p.IsDraft),
+		HTMLURL: github.String(p.URL),
+		User:    &github.User{Login: github.String(p.Author.login())},
+		Head: &github.PullRequestBranch{
+			Ref: github.String(p.HeadRefName),
+			SHA: github.String(p.HeadRefOid),
+		},
+		Base: &github.PullRequestBranch{
+			Ref: github.String(p.BaseRefName),
+			Repo: &github.Repository{
+				Name:     github.String(p.BaseRepository.Name),
+				FullName: github.String(p.BaseRepository.Owner.Login + "/" + p.BaseRepository.Name),
+				Owner:    &github.User{Login: github.String(p.BaseRepository.Owner.Login)},
+			},
+		},
+	}
+	if p.MergedAt != nil {
+		pr.MergedAt = p.MergedAt
+	}
+	if !p.UpdatedAt.IsZero() {
+		updatedAt := p.UpdatedAt
+		pr.UpdatedAt = &updatedAt
+	}
+	for _, label := range p.Labels.Nodes {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(label.Name)})
+	}
+	if p.Milestone != nil {
+		pr.Milestone = &github.Milestone{
+			Number: github.Int(p.Milestone.Number),
+			Title:  github.String(p.Milestone.Title),
+			State:  github.String(strings.ToLower(p.Milestone.State)),
+		}
+	}
+
+	result := &PullRequest{PullRequest: pr}
+	if p.Comments.TotalCount <= len(p.Comments.Nodes) {
+		result.Comments = make([]*github.IssueComment, 0, len(p.Comments.Nodes))
+		for _, c := range p.Comments.Nodes {
+			createdAt := c.CreatedAt
+			result.Comments = append(result.Comments, &github.IssueComment{
+				ID:        github.Int64(c.DatabaseID),
+				Body:      github.String(c.Body),
+				CreatedAt: &createdAt,
+				User:      &github.User{Login: github.String(c.Author.login())},
+			})
+		}
+	}
+	for _, commit := range p.Commits.Nodes {
+		for _, suite := range commit.Commit.CheckSuites.Nodes {
+			var app *github.App
+			if suite.App != nil {
+				app = &github.App{Slug: github.String(suite.App.Slug)}
+			}
+			for _, run := range suite.CheckRuns.Nodes {
+				checkRun := &github.CheckRun{
+					ID:         github.Int64(run.DatabaseID),
+					Name:       github.String(run.Name),
+					HeadSHA:    github.String(p.HeadRefOid),
+					Status:     github.String(strings.ToLower(run.Status)),
+					Conclusion: github.String(strings.ToLower(run.Conclusion)),
+					Output:     &github.CheckRunOutput{Title: github.String(run.Title)},
+					App:        app,
+				}
+				if run.CompletedAt != nil {
+					checkRun.CompletedAt = &github.Timestamp{Time: *run.CompletedAt}
+				}
+				result.CheckRuns = append(result.CheckRuns, checkRun)
+			}
+		}
+	}
+	return result
+}
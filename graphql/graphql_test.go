@@ -0,0 +1,132 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+)
+
+const pullRequestResponseJSON = `{
+  "data": {
+    "repository": {
+      "pullRequest": {
+        "number": 42,
+        "title": "Fix the bug (PROJQUAY-123)",
+        "body": "",
+        "state": "MERGED",
+        "merged": true,
+        "mergedAt": "2022-03-01T12:00:00Z",
+        "updatedAt": "2022-03-01T12:05:00Z",
+        "isDraft": true,
+        "url": "https://github.com/quay/quay/pull/42",
+        "author": {"__typename": "User", "login": "dev"},
+        "headRefName": "fix",
+        "headRefOid": "abc123",
+        "baseRefName": "master",
+        "baseRepository": {"name": "quay", "owner": {"login": "quay"}},
+        "labels": {"nodes": [{"name": "size/S"}, {"name": "jira/on-qa"}]},
+        "milestone": {"number": 3, "title": "quay-v3.8.2", "state": "OPEN"},
+        "comments": {
+          "totalCount": 1,
+          "nodes": [
+            {"databaseId": 7, "body": "error", "createdAt": "2022-03-01T11:00:00Z", "author": {"__typename": "Bot", "login": "quay-ci-app"}}
+          ]
+        },
+        "commits": {
+          "nodes": [
+            {"commit": {"checkSuites": {"nodes": [
+              {"app": {"slug": "quay-ci-app"}, "checkRuns": {"nodes": [
+                {"databaseId": 9, "name": "Pull Request Title", "status": "COMPLETED", "conclusion": "SUCCESS", "title": "ok", "completedAt": "2022-03-01T11:30:00Z"}
+              ]}}
+            ]}}}
+          ]
+        }
+      }
+    }
+  }
+}`
+
+func TestGetPullRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, pullRequestResponseJSON)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	pr, err := GetPullRequest(context.Background(), client, "quay", "quay", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := pr.PullRequest.GetState(), "closed"; got != want {
+		t.Errorf("got state %q, want %q", got, want)
+	}
+	if !pr.PullRequest.GetMerged() || pr.PullRequest.MergedAt == nil {
+		t.Errorf("the pull request should be merged")
+	}
+	if got, want := pr.PullRequest.GetBase().GetRepo().GetFullName(), "quay/quay"; got != want {
+		t.Errorf("got base repository %q, want %q", got, want)
+	}
+	if len(pr.PullRequest.Labels) != 2 || pr.PullRequest.Labels[0].GetName() != "size/S" || pr.PullRequest.Labels[1].GetName() != "jira/on-qa" {
+		t.Errorf("unexpected labels: %+v", pr.PullRequest.Labels)
+	}
+	if milestone := pr.PullRequest.GetMilestone(); milestone.GetNumber() != 3 || milestone.GetTitle() != "quay-v3.8.2" || milestone.GetState() != "open" {
+		t.Errorf("unexpected milestone: %+v", milestone)
+	}
+	if !pr.PullRequest.GetDraft() || pr.PullRequest.GetUpdatedAt().IsZero() {
+		t.Errorf("the pull request should be a draft with its update time, got %+v", pr.PullRequest)
+	}
+	if got, want := pr.PullRequest.GetHead().GetSHA(), "abc123"; got != want {
+		t.Errorf("got head SHA %q, want %q", got, want)
+	}
+	if len(pr.Comments) != 1 || pr.Comments[0].GetUser().GetLogin() != "quay-ci-app[bot]" || pr.Comments[0].GetID() != 7 {
+		t.Errorf("unexpected comments: %+v", pr.Comments)
+	}
+	if len(pr.CheckRuns) != 1 {
+		t.Fatalf("got %d check runs, want 1", len(pr.CheckRuns))
+	}
+	checkRun := pr.CheckRuns[0]
+	if checkRun.GetStatus() != "completed" || checkRun.GetConclusion() != "success" || checkRun.GetApp().GetSlug() != "quay-ci-app" || checkRun.GetHeadSHA() != "abc123" {
+		t.Errorf("unexpected check run: %+v", checkRun)
+	}
+}
+
+func TestGetPullRequestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"data": {"repository": {"pullRequest": null}}, "errors": [{"message": "Could not resolve to a PullRequest with the number of 42."}]}`)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	_, err := GetPullRequest(context.Background(), client, "quay", "quay", 42)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestConvertPullRequestTooManyComments(t *testing.T) {
+	var data pullRequestResponse
+	err := json.Unmarshal([]byte(`{"repository": {"pullRequest": {"number": 42, "state": "OPEN", "comments": {"totalCount": 150, "nodes": [{"databaseId": 7, "body": "hello"}]}}}}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := convertPullRequest(&data)
+	if pr.Comments != nil {
+		t.Errorf("got %d comments, want nil as not all comments are fetched", len(pr.Comments))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthHandler serves the liveness probe. It fails when the sync loop has
+// not made progress for longer than the threshold, e.g. because it deadlocked, so
+// that the app is restarted.
+type HealthHandler struct {
+	statusInformer *StatusInformer
+	threshold      time.Duration
+	started        time.Time
+	now            func() time.Time
+}
+
+func NewHealthHandler(si *StatusInformer, threshold time.Duration) *HealthHandler {
+	return &HealthHandler{
+		statusInformer: si,
+		threshold:      threshold,
+		started:        time.Now(),
+		now:            time.Now,
+	}
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	heartbeat := h.statusInformer.SyncLoopHeartbeatTime()
+	if heartbeat.IsZero() {
+		// The sync loop has not started yet.
+		heartbeat = h.started
+	}
+	if age := h.now().Sub(heartbeat); age > h.threshold {
+		http.Error(w, fmt.Sprintf("the sync loop has not made progress for %s", age.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	si := &StatusInformer{}
+	h := NewHealthHandler(si, 15*time.Minute)
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	probe := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return w.Code
+	}
+
+	// The sync loop has not started yet.
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("got %d before the first heartbeat, want 200", code)
+	}
+	now = now.Add(time.Hour)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("got %d without heartbeats, want 503", code)
+	}
+
+	si.SyncLoopHeartbeat()
+	now = time.Now().Add(10 * time.Minute)
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("got %d after a heartbeat, want 200", code)
+	}
+	now = now.Add(10 * time.Minute)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("got %d after a stale heartbeat, want 503", code)
+	}
+}
@@ -0,0 +1,119 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/quay/quay-ci-app/cache"
+)
+
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	status       string
+	header       http.Header
+	body         []byte
+}
+
+// Transport caches successful GET responses that have an ETag or a
+// Last-Modified header and revalidates them with conditional requests. If the
+// server responds with 304 Not Modified, the cached response is returned
+// instead. GitHub doesn't count conditional requests that return 304 against
+// the rate limit.
+type Transport struct {
+	Base  http.RoundTripper
+	Cache *cache.Cache
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Header.Get("Accept") + " " + req.URL.String()
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Cache == nil || req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+
+	var cached *cachedResponse
+	if value, ok := t.Cache.Get(key); ok {
+		cached = value.(*cachedResponse)
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return cached.response(req, resp.Header), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if cached != nil {
+			t.Cache.Remove(key)
+		}
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.Cache.Add(key, &cachedResponse{
+		etag:         etag,
+		lastModified: lastModified,
+		status:       resp.Status,
+		header:       resp.Header.Clone(),
+		body:         body,
+	})
+	return resp, nil
+}
+
+// response builds a response from the cached one. The headers of the 304
+// response (e.g. the rate limit headers) take precedence over the cached
+// headers.
+func (c *cachedResponse) response(req *http.Request, header http.Header) *http.Response {
+	h := c.header.Clone()
+	for k, v := range header {
+		h[k] = v
+	}
+	return &http.Response{
+		Status:        c.status,
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,68 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quay/quay-ci-app/cache"
+)
+
+func TestTransport(t *testing.T) {
+	version := "v1"
+	requests := 0
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + version + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = io.WriteString(w, version)
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			Cache: cache.New("test-httpcache", 10, 0),
+		},
+	}
+
+	get := func() string {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	if got := get(); got != "v1" {
+		t.Errorf("got %q, want v1", got)
+	}
+	if got := get(); got != "v1" {
+		t.Errorf("got %q, want v1 from the cache", got)
+	}
+	if notModified != 1 {
+		t.Errorf("got %d not modified responses, want 1", notModified)
+	}
+
+	version = "v2"
+	if got := get(); got != "v2" {
+		t.Errorf("got %q, want v2", got)
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"k8s.io/klog/v2"
+)
+
+// installationTransport authenticates the requests as the app installation.
+// The installation token is cached until it expires, so Reset replaces the
+// transport when the installation changes, and the next request gets a token
+// that covers the current repositories and permissions of the installation.
+type installationTransport struct {
+	newTransport func(ctx context.Context) (*ghinstallation.Transport, error)
+
+	mutex   sync.Mutex
+	current *ghinstallation.Transport
+}
+
+// keyCheckTimeout bounds how long newKeyedTransport waits for GitHub to mint a
+// token with each key.
+const keyCheckTimeout = 30 * time.Second
+
+// newKeyedTransport returns the transport of the installation that mints its
+// tokens with the first of keys that GitHub accepts. While the private key of
+// the app is rotated, both the old and the new keys are valid, and once the
+// old key is deleted, the next key is used.
+func newKeyedTransport(ctx context.Context, base http.RoundTripper, appID, installationID int64, keys []*secret) (*ghinstallation.Transport, error) {
+	var lastErr error
+	for i, key := range keys {
+		tr, err := ghinstallation.New(base, appID, installationID, key.Value())
+		if err != nil {
+			lastErr = fmt.Errorf("the %s: %w", key.name, err)
+			continue
+		}
+		if i == len(keys)-1 {
+			return tr, nil
+		}
+		tokenCtx, cancel := context.WithTimeout(ctx, keyCheckTimeout)
+		_, err = tr.Token(tokenCtx)
+		cancel()
+		if tokenRejected(err) {
+			klog.Warningf("GitHub rejected the %s, trying the next key", key.name)
+			lastErr = err
+			continue
+		}
+		return tr, nil
+	}
+	return nil, lastErr
+}
+
+// tokenRejected reports whether err is GitHub refusing to mint an
+// installation token because it doesn't accept the private key.
+func tokenRejected(err error) bool {
+	var httpErr *ghinstallation.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Response != nil && httpErr.Response.StatusCode == http.StatusUnauthorized
+}
+
+func newInstallationTransport(ctx context.Context, newTransport func(ctx context.Context) (*ghinstallation.Transport, error)) (*installationTransport, error) {
+	current, err := newTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &installationTransport{
+		newTransport: newTransport,
+		current:      current,
+	}, nil
+}
+
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	current := t.current
+	t.mutex.Unlock()
+
+	// The token is minted again with the next private key if GitHub rejects
+	// the key of the current transport, e.g. after a rotation.
+	attempt, err := rewindableRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := current.RoundTrip(attempt)
+	if !tokenRejected(err) {
+		return resp, err
+	}
+	if resetErr := t.Reset(req.Context()); resetErr != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	current = t.current
+	t.mutex.Unlock()
+	return current.RoundTrip(req)
+}
+
+// Reset drops the cached installation token. The keys are tried again until
+// ctx is done.
+func (t *installationTransport) Reset(ctx context.Context) error {
+	current, err := t.newTransport(ctx)
+	if err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.current = current
+	return nil
+}
+
+// HandleInstallationChange reacts to the installation and
+// installation_repositories events of the installation of the app: the app
+// is installed on more or fewer repositories, or its permissions changed.
+// The installation token is renewed and the discovered repositories are
+// listed again.
+func (r reactor) HandleInstallationChange(ctx context.Context, installationID int64, action string) error {
+	if installationID != r.cfg.Get().InstallationID {
+		klog.V(4).Infof("skipping the %s event of the installation %d", action, installationID)
+		return nil
+	}
+	switch action {
+	case "deleted":
+		klog.Warningf("the installation %d of the app is deleted", installationID)
+		return nil
+	case "suspend":
+		klog.Warningf("the installation %d of the app is suspended", installationID)
+		return nil
+	}
+
+	klog.Infof("the installation %d has changed (%s), renewing the installation token", installationID, action)
+	if r.installationTransport != nil {
+		if err := r.installationTransport.Reset(ctx); err != nil {
+			return err
+		}
+	}
+	if r.discoverer != nil {
+		return r.discoverer.Refresh(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// jiraWebhookEvent is the part of a Jira webhook that the app uses.
+type jiraWebhookEvent struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Issue        struct {
+		Key string `json:"key"`
+	} `json:"issue"`
+	Changelog struct {
+		Items []struct {
+			Field   string `json:"field"`
+			FieldID string `json:"fieldId"`
+		} `json:"items"`
+	} `json:"changelog"`
+}
+
+// changesChecks reports whether the event changed the status or the fix
+// versions of the issue, which the Jira check depends on.
+func (e jiraWebhookEvent) changesChecks() bool {
+	if e.WebhookEvent != "jira:issue_updated" || e.Issue.Key == "" {
+		return false
+	}
+	for _, item := range e.Changelog.Items {
+		switch {
+		case strings.EqualFold(item.Field, "status"), item.FieldID == "status":
+			return true
+		case strings.EqualFold(item.Field, "Fix Version"), item.FieldID == "fixVersions":
+			return true
+		}
+	}
+	return false
+}
+
+// JiraWebhookHandler handles the webhooks of Jira on /jira-webhook. When the
+// status or the fix versions of an issue change in Jira, the checks of its open
+// pull requests are run again, without waiting for a GitHub event. Jira
+// doesn't sign its webhooks, so the URL of the webhook has the secret in its
+// secret query parameter.
+type JiraWebhookHandler struct {
+	reactor *reactor
+	secret  *secret
+	timeout time.Duration
+}
+
+func (h *JiraWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := readWebhookBody(w, r)
+	if !ok {
+		return
+	}
+	want := strings.TrimSpace(string(h.secret.Value()))
+	if want == "" {
+		klog.Errorf("rejecting a Jira webhook from %s, the %s is empty", r.RemoteAddr, h.secret.name)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("secret")), []byte(want)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var event jiraWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "failed to decode the webhook", http.StatusBadRequest)
+		return
+	}
+	if !event.changesChecks() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ctx := logctx.WithDelivery(context.Background(), r.Header.Get("X-Atlassian-Webhook-Identifier"))
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	if err := h.reactor.HandleJiraIssueUpdate(ctx, event.Issue.Key); err != nil {
+		h.reactor.errorLog.Errorf(err, "failed to handle the Jira update of %s: %v", event.Issue.Key, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleJiraIssueUpdate runs the checks of the open pull requests of the Jira
+// issue again, so that their check runs and labels reflect the issue. The pull
+// requests are searched in the owners of the repositories of the Jira project,
+// except the owners that are patterns.
+func (r reactor) HandleJiraIssueUpdate(ctx context.Context, key string) error {
+	i := strings.LastIndex(key, "-")
+	if i <= 0 {
+		return fmt.Errorf("invalid Jira issue key %q", key)
+	}
+	project := key[:i]
+	r.jiraCheck.InvalidateIssue(key)
+
+	cfg := r.cfg.Get()
+	var owners []string
+	for _, repo := range cfg.Repositories {
+		if repo.Jira.Key == project && !repo.IsOwnerPattern() && !contains(owners, repo.Owner) {
+			owners = append(owners, repo.Owner)
+		}
+	}
+
+	var errs []error
+	for _, owner := range owners {
+		issues, err := r.jiraCheck.OpenPullRequests(ctx, owner, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, issue := range issues {
+			org, repo, ok := searchResultRepository(issue.GetRepositoryURL())
+			if !ok {
+				continue
+			}
+			if repoConfig, ok := cfg.Repository(org, repo); !ok || repoConfig.Jira.Key != project {
+				continue
+			}
+			klog.V(2).Infof("%s%s was updated in Jira, rechecking %s/%s#%d", logctx.Prefix(ctx), key, org, repo, issue.GetNumber())
+			pr, err := r.getPullRequest(ctx, org, repo, issue.GetNumber())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get pull request %s/%s#%d: %w", org, repo, issue.GetNumber(), err))
+				continue
+			}
+			if err := r.runChecks(ctx, checks.EventRecheck, org, repo, pr); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+// searchResultRepository returns the repository of a search result from its
+// API URL, e.g. https://api.github.com/repos/quay/quay.
+func searchResultRepository(repositoryURL string) (string, string, bool) {
+	i := strings.LastIndex(repositoryURL, "/repos/")
+	if i < 0 {
+		return "", "", false
+	}
+	parts := strings.Split(repositoryURL[i+len("/repos/"):], "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
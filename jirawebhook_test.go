@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestJiraWebhook(t *testing.T) {
+	gh := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{Owner: "quay", Repo: "quay", Jira: configuration.Jira{Key: "PROJQUAY"}}},
+	})
+	r.jiraCheck = checks.NewJira(gh.Client(), gh.Client(), fakeJira.Client(), nil, nil, r.activity, time.Minute, time.Minute)
+	pr := fakes.PullRequest("quay", "quay", 1234, "Fix the build (PROJQUAY-123)")
+	gh.AddPullRequest(pr)
+	gh.SearchItems[`is:pr is:open in:title user:quay "PROJQUAY-123"`] = []*github.Issue{{
+		Number:        github.Int(1234),
+		Title:         pr.Title,
+		RepositoryURL: github.String("https://api.github.com/repos/quay/quay"),
+	}}
+	h := &JiraWebhookHandler{reactor: r, secret: &secret{name: "jira webhook secret", value: []byte("s3cret\n")}, timeout: time.Minute}
+
+	post := func(target, body string) int {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	const fieldChanged = `{"webhookEvent":"jira:issue_updated","issue":{"key":"PROJQUAY-123"},"changelog":{"items":[{"field":%q}]}}`
+	statusChanged := fmt.Sprintf(fieldChanged, "status")
+
+	if code := post("/jira-webhook?secret=wrong", statusChanged); code != http.StatusUnauthorized {
+		t.Errorf("got status %d for a wrong secret, want %d", code, http.StatusUnauthorized)
+	}
+	h.secret.value = []byte(" \n")
+	if code := post("/jira-webhook", statusChanged); code != http.StatusUnauthorized {
+		t.Errorf("got status %d without a secret while the secret is empty, want %d", code, http.StatusUnauthorized)
+	}
+	h.secret.value = []byte("s3cret\n")
+	if code := post("/jira-webhook?secret=s3cret", fmt.Sprintf(fieldChanged, "assignee")); code != http.StatusNoContent {
+		t.Errorf("got status %d for an unrelated change, want %d", code, http.StatusNoContent)
+	}
+	if run := gh.LatestCheckRun(pr.GetHead().GetSHA(), checks.TitleCheckRunName); run != nil {
+		t.Errorf("an unrelated change should not recheck the pull request, got %+v", run)
+	}
+	if code := post("/jira-webhook?secret=s3cret", "{"); code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid body, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := post("/jira-webhook?secret=s3cret", statusChanged); code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", code, http.StatusNoContent)
+	}
+	if run := gh.LatestCheckRun(pr.GetHead().GetSHA(), checks.TitleCheckRunName); run == nil {
+		t.Errorf("the pull request of the issue should be rechecked")
+	}
+}
+
+func TestHandleJiraIssueUpdatePatterns(t *testing.T) {
+	gh := fakes.NewGitHub()
+	fakeJira := fakes.NewJira()
+	fakeJira.AddIssue("PROJQUAY-123", "Bug", "New")
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{
+			{Owner: "*", Repo: "quay-*", Jira: configuration.Jira{Key: "PROJQUAY"}},
+			{Owner: "quay", Repo: "*", Jira: configuration.Jira{Key: "PROJQUAY"}},
+		},
+	})
+	r.jiraCheck = checks.NewJira(gh.Client(), gh.Client(), fakeJira.Client(), nil, nil, r.activity, time.Minute, time.Minute)
+
+	if err := r.HandleJiraIssueUpdate(context.Background(), "PROJQUAY-123"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`is:pr is:open in:title user:quay "PROJQUAY-123"`}; !reflect.DeepEqual(gh.SearchQueries, want) {
+		t.Errorf("got the searches %q, want %q", gh.SearchQueries, want)
+	}
+}
+
+func TestSearchResultRepository(t *testing.T) {
+	for _, tc := range []struct {
+		url  string
+		org  string
+		repo string
+		ok   bool
+	}{
+		{"https://api.github.com/repos/quay/quay", "quay", "quay", true},
+		{"https://github.example.com/api/v3/repos/quay/clair", "quay", "clair", true},
+		{"https://api.github.com/repos/quay", "", "", false},
+		{"https://api.github.com/users/quay", "", "", false},
+	} {
+		org, repo, ok := searchResultRepository(tc.url)
+		if org != tc.org || repo != tc.repo || ok != tc.ok {
+			t.Errorf("%s: got %q, %q, %v", tc.url, org, repo, ok)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package kube is a minimal client for the Kubernetes API that can read and
+// watch the objects that hold the configuration of the app.
+package kube
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// watchTimeout is how long a watch request lasts before it's renewed.
+const watchTimeout = 5 * time.Minute
+
+// watchRetryDelay is how long to wait after a failed list or watch request.
+const watchRetryDelay = 10 * time.Second
+
+// Client sends requests to the Kubernetes API server at BaseURL. The bearer
+// token is read from TokenFile for every request, as service account tokens
+// are rotated.
+type Client struct {
+	BaseURL    string
+	TokenFile  string
+	HTTPClient *http.Client
+}
+
+// NewInClusterClient returns a client that uses the service account of the
+// pod.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("failed to parse the cluster CA certificate")
+	}
+	return &Client{
+		BaseURL:   "https://" + net.JoinHostPort(host, port),
+		TokenFile: serviceAccountDir + "/token",
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// InClusterNamespace returns the namespace of the pod.
+func InClusterNamespace() (string, error) {
+	buf, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// StatusError is an error response of the API server.
+type StatusError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("the Kubernetes API returned %d", e.Code)
+	}
+	return fmt.Sprintf("the Kubernetes API returned %d: %s", e.Code, e.Message)
+}
+
+// IsGone reports whether the resource version of a watch is too old, and the
+// objects have to be listed again.
+func IsGone(err error) bool {
+	statusErr, ok := err.(*StatusError)
+	return ok && statusErr.Code == http.StatusGone
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = strings.NewReader(string(buf))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.TokenFile != "" {
+		token, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		statusErr := &StatusError{}
+		buf, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(buf, statusErr); err != nil || statusErr.Code == 0 {
+			statusErr = &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(buf))}
+		}
+		return nil, statusErr
+	}
+	return resp, nil
+}
+
+// Get reads the object at path into v.
+func (c *Client) Get(ctx context.Context, path string, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// WatchEvent is a change of a watched object.
+type WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Watch watches the objects of the collection at path that match the query
+// from resourceVersion, and calls handle for every event until the watch
+// times out or handle returns an error.
+func (c *Client) Watch(ctx context.Context, path string, query url.Values, resourceVersion string, handle func(WatchEvent) error) error {
+	q := url.Values{}
+	for key, values := range query {
+		q[key] = values
+	}
+	q.Set("watch", "true")
+	q.Set("resourceVersion", resourceVersion)
+	q.Set("timeoutSeconds", fmt.Sprint(int(watchTimeout.Seconds())))
+	resp, err := c.do(ctx, http.MethodGet, path, q, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var event WatchEvent
+		if err := decoder.Decode(&event); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if event.Type == "ERROR" {
+			statusErr := &StatusError{}
+			if err := json.Unmarshal(event.Object, statusErr); err != nil {
+				return err
+			}
+			return statusErr
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+// ObjectMeta is the metadata of an object.
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+}
+
+// ConfigMap is a Kubernetes ConfigMap.
+type ConfigMap struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Data     map[string]string `json:"data"`
+}
+
+func configMapsPath(namespace string) string {
+	return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/configmaps"
+}
+
+// GetConfigMap returns the ConfigMap.
+func (c *Client) GetConfigMap(ctx context.Context, namespace, name string) (*ConfigMap, error) {
+	cm := &ConfigMap{}
+	if err := c.Get(ctx, configMapsPath(namespace)+"/"+url.PathEscape(name), cm); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// WatchConfigMap calls handle with the ConfigMap every time it changes, until
+// ctx is done. The ConfigMap is read again after the watch fails, so no
+// changes are missed.
+func (c *Client) WatchConfigMap(ctx context.Context, namespace, name, resourceVersion string, handle func(*ConfigMap)) {
+	query := url.Values{"fieldSelector": {"metadata.name=" + name}}
+	for ctx.Err() == nil {
+		if resourceVersion == "" {
+			cm, err := c.GetConfigMap(ctx, namespace, name)
+			if err != nil {
+				klog.Errorf("failed to get the configmap %s/%s: %v", namespace, name, err)
+				sleep(ctx, watchRetryDelay)
+				continue
+			}
+			resourceVersion = cm.Metadata.ResourceVersion
+			handle(cm)
+		}
+
+		err := c.Watch(ctx, configMapsPath(namespace), query, resourceVersion, func(event WatchEvent) error {
+			cm := &ConfigMap{}
+			if err := json.Unmarshal(event.Object, cm); err != nil {
+				return err
+			}
+			if cm.Metadata.ResourceVersion != "" {
+				resourceVersion = cm.Metadata.ResourceVersion
+			}
+			switch event.Type {
+			case "ADDED", "MODIFIED":
+				handle(cm)
+			case "DELETED":
+				klog.Warningf("the configmap %s/%s is deleted, keeping the current configuration", namespace, name)
+			}
+			return nil
+		})
+		if IsGone(err) {
+			resourceVersion = ""
+		} else if err != nil && ctx.Err() == nil {
+			klog.Errorf("failed to watch the configmap %s/%s: %v", namespace, name, err)
+			resourceVersion = ""
+			sleep(ctx, watchRetryDelay)
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
@@ -0,0 +1,202 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &Client{BaseURL: server.URL, TokenFile: tokenFile}
+}
+
+func writeConfigMap(w http.ResponseWriter, resourceVersion, data string) {
+	json.NewEncoder(w).Encode(&ConfigMap{
+		Metadata: ObjectMeta{Name: "quay-ci-app", Namespace: "ci", ResourceVersion: resourceVersion},
+		Data:     map[string]string{"config.yaml": data},
+	})
+}
+
+func TestGetConfigMap(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/ci/configmaps/quay-ci-app":
+			writeConfigMap(w, "10", "app_id: 1")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","code":404,"reason":"NotFound","message":"configmaps \"unknown\" not found"}`)
+		}
+	}))
+
+	cm, err := client.GetConfigMap(context.Background(), "ci", "quay-ci-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Metadata.ResourceVersion != "10" || cm.Data["config.yaml"] != "app_id: 1" {
+		t.Errorf("got %+v", cm)
+	}
+
+	_, err = client.GetConfigMap(context.Background(), "ci", "unknown")
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.Code != http.StatusNotFound || statusErr.Reason != "NotFound" {
+		t.Errorf("got %v, want a not found error", err)
+	}
+}
+
+func TestWatchConfigMap(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string
+	watching := make(chan struct{}, 1)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, r.URL.Path+" "+r.URL.Query().Get("resourceVersion"))
+		n := len(requests)
+		mutex.Unlock()
+
+		if r.URL.Query().Get("watch") != "true" {
+			writeConfigMap(w, "20", "app_id: 3")
+			return
+		}
+		if r.URL.Query().Get("fieldSelector") != "metadata.name=quay-ci-app" {
+			t.Errorf("unexpected field selector %q", r.URL.Query().Get("fieldSelector"))
+		}
+		switch n {
+		case 1:
+			// The first watch gets a change and then the resource
+			// version expires.
+			fmt.Fprint(w, `{"type":"MODIFIED","object":{"metadata":{"name":"quay-ci-app","resourceVersion":"11"},"data":{"config.yaml":"app_id: 2"}}}`+"\n")
+			fmt.Fprint(w, `{"type":"BOOKMARK","object":{"metadata":{"resourceVersion":"12"}}}`+"\n")
+			fmt.Fprint(w, `{"type":"ERROR","object":{"kind":"Status","code":410,"reason":"Expired","message":"too old resource version"}}`+"\n")
+		default:
+			// The watch after the list blocks until the test is done.
+			w.(http.Flusher).Flush()
+			watching <- struct{}{}
+			<-r.Context().Done()
+		}
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		client.WatchConfigMap(ctx, "ci", "quay-ci-app", "10", func(cm *ConfigMap) {
+			updates <- cm.Metadata.ResourceVersion + " " + cm.Data["config.yaml"]
+		})
+		close(done)
+	}()
+
+	for _, want := range []string{"11 app_id: 2", "20 app_id: 3"} {
+		select {
+		case got := <-updates:
+			if got != want {
+				t.Errorf("got update %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+	select {
+	case <-watching:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watch to be renewed")
+	}
+	cancel()
+	<-done
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	want := []string{
+		"/api/v1/namespaces/ci/configmaps 10",
+		"/api/v1/namespaces/ci/configmaps/quay-ci-app ",
+		"/api/v1/namespaces/ci/configmaps 20",
+	}
+	if fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
+
+func TestRepositoryPolicies(t *testing.T) {
+	var mutex sync.Mutex
+	var status RepositoryPolicy
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/ci.quay.io/v1alpha1/namespaces/ci/repositorypolicies":
+			if r.URL.Query().Get("watch") == "true" {
+				fmt.Fprint(w, `{"type":"ADDED","object":{"metadata":{"name":"clair","resourceVersion":"11"},"spec":{"owner":"quay","repo":"clair"}}}`+"\n")
+				fmt.Fprint(w, `{"type":"DELETED","object":{"metadata":{"name":"quay","resourceVersion":"12"},"spec":{"owner":"quay","repo":"quay"}}}`+"\n")
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+			fmt.Fprint(w, `{"metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"quay","resourceVersion":"9"},"spec":{"owner":"quay","repo":"quay"}}]}`)
+		case r.Method == http.MethodPut && r.URL.Path == "/apis/ci.quay.io/v1alpha1/namespaces/ci/repositorypolicies/quay/status":
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+				t.Error(err)
+			}
+			json.NewEncoder(w).Encode(&status)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	err := client.UpdateRepositoryPolicyStatus(context.Background(), &RepositoryPolicy{
+		Metadata: ObjectMeta{Name: "quay", Namespace: "ci", ResourceVersion: "9"},
+		Status:   RepositoryPolicyStatus{ObservedGeneration: 1, Conditions: []Condition{{Type: "Accepted", Status: "True"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	if status.APIVersion != "ci.quay.io/v1alpha1" || status.Kind != "RepositoryPolicy" || status.Status.ObservedGeneration != 1 {
+		t.Errorf("got the status update %+v", status)
+	}
+	mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		client.WatchRepositoryPolicies(ctx, "ci", func(policies []RepositoryPolicy) {
+			var names []string
+			for _, p := range policies {
+				names = append(names, p.Metadata.Name)
+			}
+			updates <- fmt.Sprint(names)
+		})
+		close(done)
+	}()
+	for _, want := range []string{"[quay]", "[clair quay]", "[clair]"} {
+		select {
+		case got := <-updates:
+			if got != want {
+				t.Errorf("got policies %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,145 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"k8s.io/klog/v2"
+)
+
+// The API of the RepositoryPolicy custom resource.
+const (
+	PolicyGroup    = "ci.quay.io"
+	PolicyVersion  = "v1alpha1"
+	PolicyKind     = "RepositoryPolicy"
+	PolicyResource = "repositorypolicies"
+)
+
+// Condition is a condition in the status of an object.
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// RepositoryPolicyStatus is the status of a RepositoryPolicy that is set by
+// the app.
+type RepositoryPolicyStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// RepositoryPolicy is the configuration of a repository as a cluster object.
+// The spec has the same fields as an item of the repositories section of the
+// configuration file.
+type RepositoryPolicy struct {
+	APIVersion string                 `json:"apiVersion,omitempty"`
+	Kind       string                 `json:"kind,omitempty"`
+	Metadata   ObjectMeta             `json:"metadata"`
+	Spec       json.RawMessage        `json:"spec"`
+	Status     RepositoryPolicyStatus `json:"status"`
+}
+
+type listMeta struct {
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type repositoryPolicyList struct {
+	Metadata listMeta           `json:"metadata"`
+	Items    []RepositoryPolicy `json:"items"`
+}
+
+func repositoryPoliciesPath(namespace string) string {
+	return "/apis/" + PolicyGroup + "/" + PolicyVersion + "/namespaces/" + url.PathEscape(namespace) + "/" + PolicyResource
+}
+
+// ListRepositoryPolicies returns the RepositoryPolicies of the namespace and
+// the resource version of the list.
+func (c *Client) ListRepositoryPolicies(ctx context.Context, namespace string) ([]RepositoryPolicy, string, error) {
+	list := &repositoryPolicyList{}
+	if err := c.Get(ctx, repositoryPoliciesPath(namespace), list); err != nil {
+		return nil, "", err
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// UpdateRepositoryPolicyStatus replaces the status of the RepositoryPolicy.
+// The update fails with a conflict if the policy has changed since it was
+// read.
+func (c *Client) UpdateRepositoryPolicyStatus(ctx context.Context, policy *RepositoryPolicy) error {
+	p := *policy
+	p.APIVersion = PolicyGroup + "/" + PolicyVersion
+	p.Kind = PolicyKind
+	resp, err := c.do(ctx, http.MethodPut, repositoryPoliciesPath(p.Metadata.Namespace)+"/"+url.PathEscape(p.Metadata.Name)+"/status", nil, &p)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// WatchRepositoryPolicies calls handle with all RepositoryPolicies of the
+// namespace, sorted by name, every time one of them changes, until ctx is
+// done.
+func (c *Client) WatchRepositoryPolicies(ctx context.Context, namespace string, handle func([]RepositoryPolicy)) {
+	policies := map[string]RepositoryPolicy{}
+	notify := func() {
+		list := make([]RepositoryPolicy, 0, len(policies))
+		for _, p := range policies {
+			list = append(list, p)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Metadata.Name < list[j].Metadata.Name
+		})
+		handle(list)
+	}
+
+	resourceVersion := ""
+	for ctx.Err() == nil {
+		if resourceVersion == "" {
+			items, rv, err := c.ListRepositoryPolicies(ctx, namespace)
+			if err != nil {
+				klog.Errorf("failed to list the repository policies in %s: %v", namespace, err)
+				sleep(ctx, watchRetryDelay)
+				continue
+			}
+			resourceVersion = rv
+			policies = map[string]RepositoryPolicy{}
+			for _, p := range items {
+				policies[p.Metadata.Name] = p
+			}
+			notify()
+		}
+
+		err := c.Watch(ctx, repositoryPoliciesPath(namespace), nil, resourceVersion, func(event WatchEvent) error {
+			p := RepositoryPolicy{}
+			if err := json.Unmarshal(event.Object, &p); err != nil {
+				return err
+			}
+			if p.Metadata.ResourceVersion != "" {
+				resourceVersion = p.Metadata.ResourceVersion
+			}
+			switch event.Type {
+			case "ADDED", "MODIFIED":
+				policies[p.Metadata.Name] = p
+			case "DELETED":
+				delete(policies, p.Metadata.Name)
+			default:
+				return nil
+			}
+			notify()
+			return nil
+		})
+		if IsGone(err) {
+			resourceVersion = ""
+		} else if err != nil && ctx.Err() == nil {
+			klog.Errorf("failed to watch the repository policies in %s: %v", namespace, err)
+			resourceVersion = ""
+			sleep(ctx, watchRetryDelay)
+		}
+	}
+}
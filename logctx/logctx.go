@@ -0,0 +1,32 @@
+// Package logctx carries the webhook delivery that caused some work in its
+// context, so that the log lines of the work, in the app and in the checks,
+// can be correlated with the request log.
+package logctx
+
+import "context"
+
+type deliveryKey struct{}
+
+// WithDelivery returns a context for the work on the webhook delivery.
+func WithDelivery(ctx context.Context, delivery string) context.Context {
+	if delivery == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, deliveryKey{}, delivery)
+}
+
+// Delivery returns the webhook delivery of ctx, or "" if the work is not
+// caused by a webhook.
+func Delivery(ctx context.Context) string {
+	delivery, _ := ctx.Value(deliveryKey{}).(string)
+	return delivery
+}
+
+// Prefix returns the prefix of the log lines about the work in ctx, which
+// names the webhook delivery if there is one.
+func Prefix(ctx context.Context) string {
+	if delivery := Delivery(ctx); delivery != "" {
+		return "[delivery " + delivery + "] "
+	}
+	return ""
+}
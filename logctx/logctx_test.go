@@ -0,0 +1,19 @@
+package logctx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrefix(t *testing.T) {
+	if prefix := Prefix(context.Background()); prefix != "" {
+		t.Errorf("got %q without a delivery", prefix)
+	}
+	if ctx := WithDelivery(context.Background(), ""); Delivery(ctx) != "" {
+		t.Errorf("got the delivery %q for an empty delivery", Delivery(ctx))
+	}
+	ctx := WithDelivery(context.Background(), "abc")
+	if prefix := Prefix(ctx); prefix != "[delivery abc] " {
+		t.Errorf("got %q", prefix)
+	}
+}
@@ -3,37 +3,111 @@ package main
 import (
 	"context"
 	"encoding/json"
+	goerrors "errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/audit"
+	"github.com/quay/quay-ci-app/breaker"
+	"github.com/quay/quay-ci-app/cache"
 	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/cloudevents"
 	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/errorlog"
+	"github.com/quay/quay-ci-app/graphql"
+	"github.com/quay/quay-ci-app/httpcache"
+	"github.com/quay/quay-ci-app/logctx"
+	"github.com/quay/quay-ci-app/notify"
+	"github.com/quay/quay-ci-app/ratelimit"
+	"github.com/quay/quay-ci-app/retry"
+	"github.com/quay/quay-ci-app/slo"
+	"github.com/quay/quay-ci-app/storage"
 	"github.com/quay/quay-ci-app/taginformer"
-	"golang.org/x/oauth2"
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 )
 
 var (
-	addr          = flag.String("addr", ":8080", "listen address")
-	configFile    = flag.String("config", "./config.yaml", "configuration file")
-	jiraTokenFile = flag.String("jira-token", "./jira-token", "jira token file")
-	jiraEndpoint  = flag.String("jira-endpoint", "https://issues.redhat.com", "jira endpoint")
-	privateKey    = flag.String("private-key", "./private-key.pem", "private key file for the GitHub application")
+	addr                 = flag.String("addr", ":8080", "listen address")
+	tlsCert              = flag.String("tls-cert", "", "path to the TLS certificate, the app serves HTTPS if it is set; the file is reloaded when it changes")
+	tlsKey               = flag.String("tls-key", "", "path to the private key of the TLS certificate")
+	configFile           = flag.String("config", "./config.yaml", "configuration file, or a directory of configuration files")
+	configMap            = flag.String("config-map", "", "read the configuration from this ConfigMap, name or namespace/name, instead of -config and apply its changes without a restart")
+	configMapKey         = flag.String("config-map-key", "config.yaml", "key of the configuration in the ConfigMap")
+	adminAddr            = flag.String("admin-addr", "", "listen address of the operational endpoints (/admin, /audit and /consistency), by default they are served on -addr")
+	adminAPI             = flag.Bool("admin-api", false, "serve the /admin endpoints that run the jobs of the app on request, for the clients allowed by the admin section of the configuration")
+	repositoryPolicies   = flag.Bool("repository-policies", false, "add the repositories of the RepositoryPolicy resources in the namespace of the pod to the configuration")
+	jiraTokenFile        = flag.String("jira-token", "./jira-token", "jira token file, env:NAME to read it from an environment variable, or vault:<path>#<key> to read it from Vault; overrides secrets.jira_token of the configuration")
+	jiraWebhookSecret    = flag.String("jira-webhook-secret", "", "serve /jira-webhook for the Jira webhooks with this secret in their URL: a file, env:NAME or vault:<path>#<key>")
+	jiraEndpoint         = flag.String("jira-endpoint", "https://issues.redhat.com", "jira endpoint")
+	privateKey           = flag.String("private-key", "./private-key.pem", "private key file for the GitHub application; env:NAME or vault:<path>#<key> like -jira-token; a comma-separated list of keys that are tried in order while the key is rotated; overrides secrets.private_key of the configuration")
+	vaultAddr            = flag.String("vault-addr", os.Getenv("VAULT_ADDR"), "address of the Vault server of the vault: secrets, VAULT_ADDR by default; the token is read from VAULT_TOKEN")
+	vaultRole            = flag.String("vault-role", "", "log in to Vault as this role with the Kubernetes auth method instead of using VAULT_TOKEN")
+	vaultAuthPath        = flag.String("vault-auth-path", "kubernetes", "mount path of the Kubernetes auth method in Vault")
+	vaultRefresh         = flag.Duration("vault-refresh-interval", 10*time.Minute, "how often the Vault token is renewed and the vault: secrets are read again")
+	issueCacheTTL        = flag.Duration("jira-issue-cache-ttl", 30*time.Second, "how long fetched Jira issues are cached, 0 disables the cache")
+	jiraMaxRetries       = flag.Int("jira-max-retries", 4, "how many times failed Jira requests are retried, the POST and PATCH requests only if they were never sent")
+	jiraBreakerThreshold = flag.Int("jira-breaker-threshold", 5, "number of consecutive failed Jira requests that opens the circuit breaker")
+	jiraBreakerCooldown  = flag.Duration("jira-breaker-cooldown", time.Minute, "how long the Jira circuit breaker stays open before probing Jira again")
+	projectCacheTTL      = flag.Duration("jira-project-cache-ttl", 10*time.Minute, "how long Jira project metadata is cached, 0 disables the cache")
+	proxyURL             = flag.String("proxy", "", "send the requests to GitHub and Jira through this proxy URL instead of the one of HTTPS_PROXY")
+	noProxy              = flag.String("no-proxy", "", "comma-separated hosts, domains and networks that are reached without -proxy, like NO_PROXY")
+	githubMaxRetries     = flag.Int("github-max-retries", 3, "how many times transient GitHub API errors are retried, the POST and PATCH requests only if they were never sent or hit a rate limit")
+	githubMaxRetryAfter  = flag.Duration("github-max-retry-after", 2*time.Minute, "the longest Retry-After delay that is waited out before a GitHub request is retried")
+	githubWriteRate      = flag.Float64("github-write-rate", 1, "how many mutating GitHub requests per second are allowed on average")
+	githubWriteBurst     = flag.Int("github-write-burst", 10, "how many mutating GitHub requests can be sent in a burst")
+	syncInterval         = flag.Duration("sync-interval", 5*time.Minute, "how often the sync loop syncs all branches")
+	syncJitter           = flag.Duration("sync-jitter", 30*time.Second, "the longest random delay of the passes of the sync loop")
+	syncTimeout          = flag.Duration("sync-timeout", 5*time.Minute, "how long a pass of the sync loop can take before its GitHub requests are cancelled")
+	syncHeartbeatMaxAge  = flag.Duration("sync-liveness-threshold", 15*time.Minute, "how long the sync loop can go without progress before /healthz fails")
+	syncWorkers          = flag.Int("sync-workers", 4, "how many repositories are synced at the same time by the sync loop")
+	tagCacheTTL          = flag.Duration("tag-cache-ttl", time.Hour, "how often cached version tags are refreshed, can be overridden per repository")
+	activityFeedSize     = flag.Int("activity-feed-size", 200, "how many recent actions are kept for the activity feed of each repository")
+	githubGraphQL        = flag.Bool("github-graphql", false, "fetch pull requests with their comments and check runs using a single GraphQL query")
+	webhookSecretRef     = flag.String("webhook-secret", "./webhook-secret", "webhook secret file of the GitHub application, which signs its webhooks; env:NAME or vault:<path>#<key> like -jira-token; the setup mode stores the secret there; overrides secrets.webhook_secret of the configuration")
+	setup                = flag.Bool("setup", false, "serve the GitHub App manifest flow to create the app instead of running it")
+	setupURL             = flag.String("setup-url", "", "public URL of the instance that the created app sends webhooks to")
+	setupOrg             = flag.String("setup-org", "", "organization that owns the created app, the current user if empty")
+	setupAppName         = flag.String("setup-app-name", "Quay CI", "name of the created app")
+	setupAppID           = flag.String("setup-app-id", "", "where the setup mode stores the ID of the created app: a file or vault:<path>#<key>, only shown if empty")
+	auditLogFile         = flag.String("audit-log", "", "append the audit log of the mutations of the app to this file as JSON lines")
+	auditLogSize         = flag.Int("audit-log-size", 1000, "how many audit log entries are kept in memory for GET /audit")
+	cloudEventsSink      = flag.String("cloudevents-sink", "", "publish the handled webhooks and the actions of the app as CloudEvents to this http(s) URL or kafka://broker:9092/topic")
+	cloudEventsQueueSize = flag.Int("cloudevents-queue-size", 1000, "how many CloudEvents are queued before they are dropped")
+	storageDriver        = flag.String("storage-driver", storage.DriverSQLite, "database of -storage: sqlite or postgres")
+	storageDSN           = flag.String("storage", "", "keep the audit log, the deferred rechecks, the sync status and the handled webhook deliveries in this database (a file for sqlite, a URL for postgres) instead of only in memory")
+	eventTimeout         = flag.Duration("event-timeout", 2*time.Minute, "how long the handling of a webhook event or of a deferred recheck can take before its GitHub and Jira requests are cancelled")
+	errorLogInterval     = flag.Duration("error-log-interval", time.Minute, "how long a repeated error is only counted before it's logged again, 0 logs every error")
+	githubETagCacheSize  = flag.Int("github-etag-cache-size", 2000, "how many GitHub responses are cached for conditional requests, 0 disables the cache")
 )
 
 var recheckRegex = regexp.MustCompile(`(?mi)^/recheck\s*$`)
 
+const (
+	// syncPausedFile is a marker file that pauses syncing into the branch
+	// that contains it.
+	syncPausedFile = ".ci/sync-paused"
+
+	// syncPausedLabel is a label that pauses syncing into all branches of
+	// the repository while it exists.
+	syncPausedLabel = "sync-paused"
+)
+
 type BranchSyncStatus struct {
 	Status             string    `json:"status"`
 	Message            string    `json:"message"`
@@ -42,21 +116,47 @@ type BranchSyncStatus struct {
 }
 
 type BranchStatus struct {
-	Branch     string            `json:"branch"`
-	FixVersion string            `json:"fixVersion,omitempty"`
-	SyncStatus *BranchSyncStatus `json:"syncStatus,omitempty"`
+	Branch            string            `json:"branch"`
+	FixVersion        string            `json:"fixVersion,omitempty"`
+	FixVersionMessage string            `json:"fixVersionMessage,omitempty"`
+	SyncStatus        *BranchSyncStatus `json:"syncStatus,omitempty"`
 }
 
-type Status struct {
-	Branches []BranchStatus `json:"branches"`
+type MuteStatus struct {
+	Repository string    `json:"repository"`
+	Check      string    `json:"check"`
+	Until      time.Time `json:"until"`
+	Reason     string    `json:"reason,omitempty"`
 }
 
-func (s Status) DeepCopy() Status {
-	branches := make([]BranchStatus, len(s.Branches))
-	copy(branches, s.Branches)
-	return Status{
-		Branches: branches,
-	}
+// StreamTagsStatus is the latest release of a y-stream in the tag cache.
+type StreamTagsStatus struct {
+	Stream        string `json:"stream"`
+	LatestVersion string `json:"latestVersion,omitempty"`
+	PreReleases   int    `json:"preReleases,omitempty"`
+}
+
+// TagsStatus is what the tag cache knows about a repository and when the tags
+// were fetched.
+type TagsStatus struct {
+	Repository string             `json:"repository"`
+	TagPattern string             `json:"tagPattern"`
+	FetchedAt  time.Time          `json:"fetchedAt"`
+	Streams    []StreamTagsStatus `json:"streams"`
+}
+
+type Status struct {
+	Branches []BranchStatus `json:"branches"`
+	Mutes    []MuteStatus   `json:"mutes,omitempty"`
+	Caches   []cache.Stats  `json:"caches,omitempty"`
+	Tags     []TagsStatus   `json:"tags,omitempty"`
+	// Checks are the recent results of the Jira check in each repository.
+	Checks []checks.RepositoryResults `json:"checks,omitempty"`
+	// SyncLoopHeartbeatTime is when the sync loop last made progress.
+	SyncLoopHeartbeatTime *time.Time `json:"syncLoopHeartbeatTime,omitempty"`
+	// Errors are the recent errors of the app with how many times they were
+	// seen.
+	Errors []errorlog.Summary `json:"errors,omitempty"`
 }
 
 func (s *Status) SetFixVersion(branch, fixVersion string) {
@@ -73,22 +173,110 @@ func (s *Status) SetFixVersion(branch, fixVersion string) {
 	})
 }
 
+// maxBranchStatuses is how many branches StatusInformer keeps the status of.
+// The least recently updated branches, e.g. the branches that were removed
+// from the configuration, are forgotten first.
+const maxBranchStatuses = 10000
+
 type StatusInformer struct {
-	mutex  sync.Mutex
-	status Status
+	mutex sync.Mutex
+	// branches maps the branches to their BranchStatus. It is created on
+	// first use, so that the zero StatusInformer is ready to use.
+	branches          *cache.Cache
+	syncLoopHeartbeat *time.Time
+	storage           *storage.DB
+
+	// onChange is called in the background when a branch enters a new sync
+	// status, but not when only the message changes.
+	onChange func(branch, status, message string)
+
+	// errorLog has the summary of the recent errors.
+	errorLog *errorlog.Aggregator
+}
+
+// SetStorage loads the sync status of the branches from db and saves the
+// changes of the status to it.
+func (si *StatusInformer) SetStorage(db *storage.DB) error {
+	statuses, err := db.LoadSyncStatuses()
+	if err != nil {
+		return fmt.Errorf("failed to load the sync status: %w", err)
+	}
+
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	for _, s := range statuses {
+		si.setBranchStatus(BranchStatus{
+			Branch: s.Branch,
+			SyncStatus: &BranchSyncStatus{
+				Status:             s.Status,
+				Message:            s.Message,
+				LastHeartbeatTime:  s.LastHeartbeatTime,
+				LastTransitionTime: s.LastTransitionTime,
+			},
+		})
+	}
+	si.storage = db
+	return nil
+}
+
+func (bs BranchStatus) deepCopy() BranchStatus {
+	if bs.SyncStatus != nil {
+		syncStatus := *bs.SyncStatus
+		bs.SyncStatus = &syncStatus
+	}
+	return bs
+}
+
+// branchCache returns the statuses of the branches. The caller must hold the
+// mutex.
+func (si *StatusInformer) branchCache() *cache.Cache {
+	if si.branches == nil {
+		si.branches = cache.New("branch-statuses", maxBranchStatuses, 0)
+	}
+	return si.branches
+}
+
+// branchStatus returns a copy of the status of the branch. The caller must
+// hold the mutex.
+func (si *StatusInformer) branchStatus(branch string) BranchStatus {
+	value, ok := si.branchCache().Get(branch)
+	if !ok {
+		return BranchStatus{Branch: branch}
+	}
+	return value.(BranchStatus).deepCopy()
+}
+
+// setBranchStatus records the status of the branch. The caller must hold the
+// mutex.
+func (si *StatusInformer) setBranchStatus(branchStatus BranchStatus) {
+	si.branchCache().Add(branchStatus.Branch, branchStatus)
 }
 
 func (si *StatusInformer) statusSnapshot() Status {
 	si.mutex.Lock()
 	defer si.mutex.Unlock()
-	return si.status.DeepCopy()
+
+	status := Status{Branches: []BranchStatus{}}
+	keys := si.branchCache().Keys()
+	sort.Strings(keys)
+	for _, branch := range keys {
+		// Peek doesn't make the branches recently used.
+		if value, ok := si.branchCache().Peek(branch); ok {
+			status.Branches = append(status.Branches, value.(BranchStatus).deepCopy())
+		}
+	}
+	if si.syncLoopHeartbeat != nil {
+		heartbeat := *si.syncLoopHeartbeat
+		status.SyncLoopHeartbeatTime = &heartbeat
+	}
+	return status
 }
 
-func (si *StatusInformer) GetStatus(cfg *configuration.Configuration, ti *taginformer.TagInformer) Status {
+func (si *StatusInformer) GetStatus(cfg *configuration.Configuration, ti *taginformer.TagInformer, jc *checks.Jira) Status {
 	status := si.statusSnapshot()
 	for _, repo := range cfg.Repositories {
 		for _, branch := range repo.Branches {
-			if branch.Version == "" {
+			if branch.Version == "" || repo.IsPattern() {
 				continue
 			}
 			fixVersion, err := ti.NextVersion(repo.Owner, repo.Repo, branch.Version)
@@ -101,63 +289,295 @@ func (si *StatusInformer) GetStatus(cfg *configuration.Configuration, ti *taginf
 				repo.Jira.FixVersionPrefix+fixVersion,
 			)
 		}
+		now := time.Now()
+		for _, mute := range repo.Mute {
+			if now.Before(mute.Until) {
+				status.Mutes = append(status.Mutes, MuteStatus{
+					Repository: repo.Owner + "/" + repo.Repo,
+					Check:      mute.Check,
+					Until:      mute.Until,
+					Reason:     mute.Reason,
+				})
+			}
+		}
 	}
+	status.Caches = cache.AllStats()
+	status.Tags = tagsStatus(ti)
+	status.Checks = jc.RecentResults()
+	status.Errors = si.errorLog.Summaries()
 	return status
 }
 
-func (si *StatusInformer) UpdateBranchSyncStatus(branch, status, message string) {
+// tagsStatus returns the contents of the tag cache, the y-streams are sorted
+// by version.
+func tagsStatus(ti *taginformer.TagInformer) []TagsStatus {
+	if ti == nil {
+		return nil
+	}
+	var result []TagsStatus
+	for _, name := range ti.Repositories() {
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		streams, fetchedAt, ok := ti.Snapshot(parts[0], parts[1])
+		if !ok {
+			continue
+		}
+		repoStatus := TagsStatus{
+			Repository: name,
+			TagPattern: ti.Pattern(parts[0], parts[1]).String(),
+			FetchedAt:  fetchedAt.UTC(),
+			Streams:    []StreamTagsStatus{},
+		}
+		for xy, stream := range streams {
+			streamStatus := StreamTagsStatus{Stream: xy}
+			if n := len(stream.PatchVersions); n > 0 {
+				streamStatus.LatestVersion = fmt.Sprintf("%s.%d", xy, stream.PatchVersions[n-1])
+			}
+			for _, preReleases := range stream.PreReleases {
+				streamStatus.PreReleases += len(preReleases)
+			}
+			repoStatus.Streams = append(repoStatus.Streams, streamStatus)
+		}
+		sort.Slice(repoStatus.Streams, func(i, j int) bool {
+			a, _ := taginformer.ParseVersion(repoStatus.Streams[i].Stream + ".0")
+			b, _ := taginformer.ParseVersion(repoStatus.Streams[j].Stream + ".0")
+			if a.Major != b.Major {
+				return a.Major < b.Major
+			}
+			return a.Minor < b.Minor
+		})
+		result = append(result, repoStatus)
+	}
+	return result
+}
+
+// UpdateBranchSyncStatus records the sync status of the branch and returns
+// true if the status or the message have changed.
+func (si *StatusInformer) UpdateBranchSyncStatus(branch, status, message string) bool {
 	si.mutex.Lock()
 	defer si.mutex.Unlock()
 
 	now := time.Now().UTC()
 
-	for i := range si.status.Branches {
-		branchStatus := &si.status.Branches[i]
-		if branchStatus.Branch == branch {
-			if branchStatus.SyncStatus == nil {
-				branchStatus.SyncStatus = &BranchSyncStatus{}
-			}
-			syncStatus := branchStatus.SyncStatus
-			if syncStatus.Status != status || syncStatus.Message != message {
-				syncStatus.Status = status
-				syncStatus.Message = message
-				syncStatus.LastTransitionTime = now
-			}
-			syncStatus.LastHeartbeatTime = now
-			return
+	branchStatus := si.branchStatus(branch)
+	if branchStatus.SyncStatus == nil {
+		branchStatus.SyncStatus = &BranchSyncStatus{}
+	}
+	syncStatus := branchStatus.SyncStatus
+
+	statusChanged := syncStatus.LastTransitionTime.IsZero() || syncStatus.Status != status
+	changed := statusChanged || syncStatus.Message != message
+	// The transition time is when the status changed, a new message with the
+	// same status doesn't restart the time the branch has been failing for.
+	if statusChanged {
+		syncStatus.LastTransitionTime = now
+	}
+	if changed {
+		syncStatus.Status = status
+		syncStatus.Message = message
+	}
+	syncStatus.LastHeartbeatTime = now
+	si.setBranchStatus(branchStatus)
+
+	if si.storage != nil {
+		err := si.storage.SaveSyncStatus(storage.SyncStatus{
+			Branch:             branch,
+			Status:             syncStatus.Status,
+			Message:            syncStatus.Message,
+			LastHeartbeatTime:  syncStatus.LastHeartbeatTime,
+			LastTransitionTime: syncStatus.LastTransitionTime,
+		}, changed)
+		if err != nil {
+			klog.Errorf("failed to save the sync status of %s: %v", branch, err)
 		}
 	}
-	si.status.Branches = append(si.status.Branches, BranchStatus{
-		Branch: branch,
-		SyncStatus: &BranchSyncStatus{
-			Status:             status,
-			Message:            message,
-			LastHeartbeatTime:  now,
-			LastTransitionTime: now,
-		},
-	})
+	if statusChanged && si.onChange != nil {
+		go si.onChange(branch, status, message)
+	}
+	return changed
+}
+
+// maxSyncHistory is how many transitions of the sync status GET
+// /status/history returns.
+const maxSyncHistory = 100
+
+// serveSyncHistory serves GET /status/history?branch={owner}/{repo}:{branch},
+// the transitions of the sync status of the branch, newest first. The history
+// is only kept with -storage.
+func (si *StatusInformer) serveSyncHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if si.storage == nil {
+		http.Error(w, "the sync history is only kept with -storage", http.StatusNotFound)
+		return
+	}
+	branch := r.URL.Query().Get("branch")
+	if branch == "" {
+		http.Error(w, "branch is required", http.StatusBadRequest)
+		return
+	}
+	history, err := si.storage.SyncHistory(branch, maxSyncHistory)
+	if err != nil {
+		klog.Errorf("failed to get the sync history of %s: %v", branch, err)
+		http.Error(w, "failed to get the sync history", http.StatusInternalServerError)
+		return
+	}
+	result := []BranchSyncStatus{}
+	for _, s := range history {
+		result = append(result, BranchSyncStatus{
+			Status:             s.Status,
+			Message:            s.Message,
+			LastTransitionTime: s.LastTransitionTime,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		klog.Errorf("failed to encode the sync history: %v", err)
+	}
+}
+
+// SyncLoopHeartbeat records that the sync loop is making progress.
+func (si *StatusInformer) SyncLoopHeartbeat() {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	now := time.Now().UTC()
+	si.syncLoopHeartbeat = &now
+}
+
+// SyncLoopHeartbeatTime returns when the sync loop last made progress, or the
+// zero time if it has not started yet.
+func (si *StatusInformer) SyncLoopHeartbeatTime() time.Time {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	if si.syncLoopHeartbeat == nil {
+		return time.Time{}
+	}
+	return *si.syncLoopHeartbeat
+}
+
+// BranchSyncStatus returns the sync status of the branch, or nil if the
+// branch has not been synced yet.
+func (si *StatusInformer) BranchSyncStatus(branch string) *BranchSyncStatus {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	return si.branchStatus(branch).SyncStatus
+}
+
+func (si *StatusInformer) UpdateBranchFixVersionMessage(branch, message string) {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+
+	branchStatus := si.branchStatus(branch)
+	if branchStatus.FixVersionMessage == message {
+		return
+	}
+	branchStatus.FixVersionMessage = message
+	si.setBranchStatus(branchStatus)
 }
 
 type Reactor interface {
 	HandleBranchPush(ctx context.Context, org, repo string, branch string) error
 	HandleTagPush(ctx context.Context, org, repo string, tag string) error
+	HandleTagDelete(ctx context.Context, org, repo string, tag string) error
+	HandleReleasePublish(ctx context.Context, org, repo string, release *github.RepositoryRelease) error
+	HandleWorkflowRunComplete(ctx context.Context, org, repo string, run *github.WorkflowRun) error
 	HandleCheckSuiteRerequest(ctx context.Context, org, repo string, checkSuite *github.CheckSuite) error
 	HandleIssueCommentCreate(ctx context.Context, org, repo string, issue *github.Issue, comment *github.IssueComment) error
 	HandlePullRequestClose(ctx context.Context, org, repo string, pr *github.PullRequest) error
 	HandlePullRequestCreate(ctx context.Context, org, repo string, pr *github.PullRequest) error
 	HandlePullRequestEdit(ctx context.Context, org, repo string, pr *github.PullRequest) error
 	HandlePullRequestSynchronize(ctx context.Context, org, repo string, pr *github.PullRequest) error
+	HandlePullRequestLabel(ctx context.Context, org, repo string, pr *github.PullRequest, label string) error
+	HandlePullRequestUnlabel(ctx context.Context, org, repo string, pr *github.PullRequest, label string) error
+	HandlePullRequestReview(ctx context.Context, org, repo string, pr *github.PullRequest, review *github.PullRequestReview) error
+	HandleCheckRunComplete(ctx context.Context, org, repo string, checkRun *github.CheckRun) error
+	HandleRepositoryDispatch(ctx context.Context, org, repo string, eventType string, payload json.RawMessage) error
+	HandleInstallationChange(ctx context.Context, installationID int64, action string) error
 }
 
 type reactor struct {
-	client             *github.Client
-	cfg                *configuration.Configuration
-	jiraCheck          *checks.Jira
-	statusInformer     *StatusInformer
-	invalidateTagCache func()
+	client           *clients.GitHub
+	cfg              *configuration.Store
+	jiraCheck        *checks.Jira
+	labelsCheck      *checks.Labels
+	sizeCheck        *checks.Size
+	titleCheck       *checks.ConventionalTitle
+	dcoCheck         *checks.DCO
+	signedCheck      *checks.SignedCommits
+	codeOwnersCheck  *checks.CodeOwners
+	statusInformer   *StatusInformer
+	tagInformer      *taginformer.TagInformer
+	deferredRechecks *DeferredRechecks
+	syncIssues       *SyncFailureIssues
+	syncStatuses     *SyncStatusReports
+	syncLocks        *BranchLocks
+	mergeLocks       *BranchLocks
+	pullRequestHeads *PullRequestHeads
+	slo              *slo.Tracker
+	activity         *activity.Recorder
+	errorLog         *errorlog.Aggregator
+	useGraphQL       bool
+
+	installationTransport *installationTransport
+	discoverer            *RepositoryDiscoverer
+}
+
+func compareURL(ref configuration.BranchReference, base, head string) string {
+	return fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s", ref.Owner, ref.Repo, base, head)
+}
+
+func (r reactor) reportSyncCheck(ctx context.Context, branchConfig configuration.Branch, dest configuration.BranchReference, headSHA, conclusion, title, summary string) {
+	if !branchConfig.SyncCheck {
+		return
+	}
+	_, _, err := r.client.Checks.CreateCheckRun(ctx, dest.Owner, dest.Repo, github.CreateCheckRunOptions{
+		Name:       "Upstream Sync",
+		HeadSHA:    headSHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+		Output: &github.CheckRunOutput{
+			Title:   github.String(title),
+			Summary: github.String(summary),
+		},
+	})
+	if err != nil {
+		klog.V(2).Infof("%sfailed to report sync check for %s: %v", logctx.Prefix(ctx), dest, err)
+	}
 }
 
-func (r reactor) sync(ctx context.Context, dest, src configuration.BranchReference) error {
+// syncPaused checks if the maintainers of the destination repository have
+// paused syncing into dest, and returns the reason if so.
+func (r reactor) syncPaused(ctx context.Context, dest configuration.BranchReference) (string, error) {
+	_, _, resp, err := r.client.Repositories.GetContents(ctx, dest.Owner, dest.Repo, syncPausedFile, &github.RepositoryContentGetOptions{
+		Ref: dest.Branch,
+	})
+	if err == nil {
+		return fmt.Sprintf("the branch contains %s", syncPausedFile), nil
+	} else if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", fmt.Errorf("failed to check for %s: %w", syncPausedFile, err)
+	}
+
+	_, resp, err = r.client.Issues.GetLabel(ctx, dest.Owner, dest.Repo, syncPausedLabel)
+	if err == nil {
+		return fmt.Sprintf("the repository has the %s label", syncPausedLabel), nil
+	} else if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", fmt.Errorf("failed to check for the %s label: %w", syncPausedLabel, err)
+	}
+
+	return "", nil
+}
+
+func (r reactor) sync(ctx context.Context, dest, src configuration.BranchReference) (err error) {
+	defer r.syncLocks.Lock(dest.String())()
+	defer func() {
+		r.slo.Record(slo.BranchSync, err == nil)
+	}()
+
+	branchConfig := r.cfg.Get().Branch(dest.Owner, dest.Repo, dest.Branch)
 	sourceRef, _, err := r.client.Git.GetRef(ctx, src.Owner, src.Repo, "heads/"+src.Branch)
 	if err != nil {
 		err = fmt.Errorf("failed to get source ref: %w", err)
@@ -172,35 +592,220 @@ func (r reactor) sync(ctx context.Context, dest, src configuration.BranchReferen
 		return err
 	}
 
-	klog.V(4).Infof("checking if %s (%s) is synced with %s (%s)...", dest, destinationRef.GetObject().GetSHA(), src, sourceRef.GetObject().GetSHA())
+	sourceSHA := sourceRef.GetObject().GetSHA()
+	destinationSHA := destinationRef.GetObject().GetSHA()
 
-	if destinationRef.Object.GetSHA() != sourceRef.Object.GetSHA() {
-		klog.V(2).Infof("updating %s (%s -> %s)...", dest, destinationRef.Object.GetSHA(), sourceRef.Object.GetSHA())
-		_, _, err := r.client.Git.UpdateRef(ctx, dest.Owner, dest.Repo, &github.Reference{
-			Ref: github.String("heads/" + dest.Branch),
-			Object: &github.GitObject{
-				SHA: sourceRef.Object.SHA,
-			},
-		}, false)
+	klog.V(4).Infof("%schecking if %s (%s) is synced with %s (%s)...", logctx.Prefix(ctx), dest, destinationSHA, src, sourceSHA)
+
+	headSHA := sourceSHA
+	updated := false
+	if destinationSHA != sourceSHA {
+		reason, err := r.syncPaused(ctx, dest)
 		if err != nil {
-			err = fmt.Errorf("failed to update %s: %w", dest, err)
 			r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Error", err.Error())
 			return err
 		}
+		if reason != "" {
+			klog.V(4).Infof("%ssyncing %s is paused: %s", logctx.Prefix(ctx), dest, reason)
+			if r.statusInformer.UpdateBranchSyncStatus(dest.String(), "PausedByRepo", fmt.Sprintf("syncing from %s is paused: %s", src, reason)) {
+				r.reportSyncCheck(ctx, branchConfig, dest, destinationSHA, "neutral", "Syncing from "+src.String()+" is paused", fmt.Sprintf("Syncing is paused: %s.\n\nPending changes: %s\n", reason, compareURL(src, destinationSHA, sourceSHA)))
+			}
+			r.reportSyncStatus(ctx, branchConfig, dest, destinationSHA, "pending", "Syncing from "+src.String()+" is paused", compareURL(src, destinationSHA, sourceSHA))
+			return nil
+		}
+
+		headSHA, err = r.updateBranch(ctx, branchConfig, dest, src, destinationSHA, sourceSHA)
+		if err != nil {
+			if r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Error", err.Error()) {
+				r.reportSyncCheck(ctx, branchConfig, dest, destinationSHA, "failure", "Failed to sync from "+src.String(), fmt.Sprintf("%s.\n\nPending changes: %s\n", err, compareURL(src, destinationSHA, sourceSHA)))
+			}
+			r.reportSyncStatus(ctx, branchConfig, dest, destinationSHA, "failure", "Failed to sync from "+src.String(), compareURL(src, destinationSHA, sourceSHA))
+			return err
+		}
+		if headSHA != destinationSHA {
+			updated = true
+			r.activity.Record(dest.Owner, dest.Repo, activity.Event{
+				Type:    activity.TypeSync,
+				Branch:  dest.Branch,
+				Summary: fmt.Sprintf("Synced %s from %s (%s -> %s)", dest.Branch, src, destinationSHA, headSHA),
+			})
+		}
 	}
 
-	r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Synced", fmt.Sprintf("synched from %s, commit: %s", src, sourceRef.Object.GetSHA()))
+	changed := r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Synced", fmt.Sprintf("synched from %s, commit: %s", src, sourceSHA))
+	if updated || changed {
+		summary := fmt.Sprintf("The branch is synced from %s, commit: %s.\n", src, sourceSHA)
+		if updated {
+			summary += fmt.Sprintf("\nChanges: %s\n", compareURL(dest, destinationSHA, headSHA))
+		}
+		r.reportSyncCheck(ctx, branchConfig, dest, headSHA, "success", "Synced from "+src.String(), summary)
+	}
+	r.reportSyncStatus(ctx, branchConfig, dest, headSHA, "success", "Mirror of "+src.String()+", synced", fmt.Sprintf("https://github.com/%s/%s/tree/%s", src.Owner, src.Repo, src.Branch))
 
 	return nil
 }
 
+// updateBranch brings dest up to date with sourceSHA, the head of src,
+// according to the strategy of the branch, and returns the new head of dest.
+func (r reactor) updateBranch(ctx context.Context, branchConfig configuration.Branch, dest, src configuration.BranchReference, destinationSHA, sourceSHA string) (string, error) {
+	strategy := branchConfig.StrategyOrDefault()
+
+	klog.V(2).Infof("%supdating %s (%s -> %s, %s)...", logctx.Prefix(ctx), dest, destinationSHA, sourceSHA, strategy)
+	_, resp, err := r.client.Git.UpdateRef(ctx, dest.Owner, dest.Repo, &github.Reference{
+		Ref: github.String("heads/" + dest.Branch),
+		Object: &github.GitObject{
+			SHA: github.String(sourceSHA),
+		},
+	}, strategy == configuration.SyncStrategyForce)
+	if err == nil {
+		return sourceSHA, nil
+	}
+	// GitHub responds with 422 Unprocessable Entity when the update is not a
+	// fast forward.
+	if strategy != configuration.SyncStrategyMerge || resp == nil || resp.StatusCode != http.StatusUnprocessableEntity {
+		return "", fmt.Errorf("failed to update %s: %w", dest, err)
+	}
+
+	klog.V(2).Infof("%s%s has diverged from %s, merging %s...", logctx.Prefix(ctx), dest, src, sourceSHA)
+	commit, resp, err := r.client.Repositories.Merge(ctx, dest.Owner, dest.Repo, &github.RepositoryMergeRequest{
+		Base:          github.String(dest.Branch),
+		Head:          github.String(sourceSHA),
+		CommitMessage: github.String(fmt.Sprintf("Merge %s (%s) into %s", src, sourceSHA, dest.Branch)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to merge %s into %s: %w", src, dest, err)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		// The source is already merged.
+		return destinationSHA, nil
+	}
+	return commit.GetSHA(), nil
+}
+
+func (r reactor) runJiraCheck(ctx context.Context, cfg *configuration.Configuration, event checks.Event, org, repo string, pr *github.PullRequest) error {
+	if repoConfig, ok := cfg.Repository(org, repo); ok && !repoConfig.CheckEnabled(checks.JiraCheckName) {
+		return nil
+	}
+	if mute, ok := cfg.ActiveMute(org, repo, checks.JiraCheckName, time.Now()); ok {
+		klog.V(4).Infof("%sthe %s check is muted for %s/%s until %s", logctx.Prefix(ctx), checks.JiraCheckName, org, repo, mute.Until)
+		err := r.jiraCheck.ReportMuted(ctx, event, cfg.Jira(org, repo), cfg.Branch(org, repo, pr.GetBase().GetRef()), pr, mute)
+		return r.deferJiraCheck(org, repo, pr, event, err)
+	}
+	err := r.jiraCheck.Run(ctx, event, cfg.Jira(org, repo), cfg.Branch(org, repo, pr.GetBase().GetRef()), pr)
+	r.slo.Record(slo.CheckDelivery, err == nil)
+	return r.deferJiraCheck(org, repo, pr, event, err)
+}
+
+// deferJiraCheck defers event if the Jira check failed because of Jira, so
+// that it is handled again once Jira is available.
+func (r reactor) deferJiraCheck(org, repo string, pr *github.PullRequest, event checks.Event, err error) error {
+	if (goerrors.Is(err, checks.ErrJiraUnavailable) || goerrors.Is(err, checks.ErrJiraUpdateFailed)) && r.deferredRechecks != nil {
+		r.deferredRechecks.Add(org, repo, pr.GetNumber(), event)
+		return nil
+	}
+	return err
+}
+
+// mutableCheck is a check that can report the check run of a pull request as
+// muted.
+type mutableCheck interface {
+	ReportMuted(ctx context.Context, pr *github.PullRequest, mute configuration.CheckMute) error
+}
+
+// runCheck runs the check name for the pull request with run, unless the check
+// is not enabled for the repository. While the check is muted, it's reported as
+// muted by check instead.
+func (r reactor) runCheck(ctx context.Context, cfg *configuration.Configuration, org, repo, name string, pr *github.PullRequest, check mutableCheck, run func(repoConfig configuration.Repository) error) error {
+	repoConfig, _ := cfg.Repository(org, repo)
+	if !repoConfig.CheckEnabled(name) {
+		return nil
+	}
+	if mute, ok := cfg.ActiveMute(org, repo, name, time.Now()); ok {
+		klog.V(4).Infof("%sthe %s check is muted for %s/%s until %s", logctx.Prefix(ctx), name, org, repo, mute.Until)
+		return check.ReportMuted(ctx, pr, mute)
+	}
+	err := run(repoConfig)
+	r.slo.Record(slo.CheckDelivery, err == nil)
+	return err
+}
+
+func (r reactor) runLabelsCheck(ctx context.Context, cfg *configuration.Configuration, org, repo string, pr *github.PullRequest) error {
+	return r.runCheck(ctx, cfg, org, repo, checks.LabelsCheckName, pr, r.labelsCheck, func(repoConfig configuration.Repository) error {
+		return r.labelsCheck.Run(ctx, repoConfig.RequiredLabels, pr)
+	})
+}
+
+func (r reactor) runCodeOwnersCheck(ctx context.Context, cfg *configuration.Configuration, org, repo string, pr *github.PullRequest) error {
+	return r.runCheck(ctx, cfg, org, repo, checks.CodeOwnersCheckName, pr, r.codeOwnersCheck, func(configuration.Repository) error {
+		return r.codeOwnersCheck.Run(ctx, pr)
+	})
+}
+
+// runChecks runs all checks for the pull request. The checks are cancelled
+// if the pull request gets a newer head in the meantime.
+func (r reactor) runChecks(ctx context.Context, event checks.Event, org, repo string, pr *github.PullRequest) error {
+	workCtx, done, ok := r.pullRequestHeads.Start(ctx, org, repo, pr, false)
+	if !ok {
+		// The superseded head is the current head again if the pull request
+		// was force-pushed back to it.
+		current, err := r.getPullRequest(ctx, org, repo, pr.GetNumber())
+		if err != nil {
+			return fmt.Errorf("failed to get pull request %s/%s#%d: %w", org, repo, pr.GetNumber(), err)
+		}
+		if current.GetHead().GetSHA() != pr.GetHead().GetSHA() {
+			klog.V(2).Infof("%sskipping the checks of %s/%s#%d for %s, the pull request has a newer head", logctx.Prefix(ctx), org, repo, pr.GetNumber(), pr.GetHead().GetSHA())
+			return nil
+		}
+		workCtx, done, _ = r.pullRequestHeads.Start(ctx, org, repo, pr, true)
+	}
+	defer done()
+	ctx = workCtx
+
+	cfg := r.cfg.Get()
+	var errs []error
+	if err := r.runJiraCheck(ctx, cfg, event, org, repo, pr); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.JiraCheckName, err))
+	}
+	if err := r.runLabelsCheck(ctx, cfg, org, repo, pr); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.LabelsCheckName, err))
+	}
+	if err := r.runCheck(ctx, cfg, org, repo, checks.SizeCheckName, pr, r.sizeCheck, func(repoConfig configuration.Repository) error {
+		return r.sizeCheck.Run(ctx, repoConfig.Size, pr)
+	}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.SizeCheckName, err))
+	}
+	if err := r.runCheck(ctx, cfg, org, repo, checks.ConventionalTitleCheckName, pr, r.titleCheck, func(repoConfig configuration.Repository) error {
+		return r.titleCheck.Run(ctx, repoConfig.ConventionalTitle, pr)
+	}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.ConventionalTitleCheckName, err))
+	}
+	if err := r.runCheck(ctx, cfg, org, repo, checks.DCOCheckName, pr, r.dcoCheck, func(configuration.Repository) error {
+		return r.dcoCheck.Run(ctx, pr)
+	}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.DCOCheckName, err))
+	}
+	if err := r.runCheck(ctx, cfg, org, repo, checks.SignedCommitsCheckName, pr, r.signedCheck, func(repoConfig configuration.Repository) error {
+		return r.signedCheck.Run(ctx, repoConfig.SignedCommits, pr)
+	}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.SignedCommitsCheckName, err))
+	}
+	if err := r.runCodeOwnersCheck(ctx, cfg, org, repo, pr); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.CodeOwnersCheckName, err))
+	}
+	if len(errs) > 0 && r.pullRequestHeads.Superseded(org, repo, pr.GetNumber(), pr.GetHead().GetSHA()) {
+		klog.V(2).Infof("%sthe checks of %s/%s#%d for %s were cancelled, the pull request has a newer head", logctx.Prefix(ctx), org, repo, pr.GetNumber(), pr.GetHead().GetSHA())
+		return nil
+	}
+	return errors.NewAggregate(errs)
+}
+
 func (r reactor) HandleBranchPush(ctx context.Context, org, repo string, branch string) error {
 	from := configuration.BranchReference{
 		Owner:  org,
 		Repo:   repo,
 		Branch: branch,
 	}
-	syncTo := r.cfg.BranchesSyncedFrom(org, repo, branch)
+	syncTo := r.cfg.Get().BranchesSyncedFrom(org, repo, branch)
 	var errs []error
 	for _, to := range syncTo {
 		err := r.sync(ctx, to, from)
@@ -211,24 +816,156 @@ func (r reactor) HandleBranchPush(ctx context.Context, org, repo string, branch
 	return errors.NewAggregate(errs)
 }
 
-func (r reactor) HandleTagPush(ctx context.Context, org, repo string, branch string) error {
-	r.invalidateTagCache()
+func (r reactor) HandleTagPush(ctx context.Context, org, repo string, tag string) error {
+	r.tagInformer.AddTag(org, repo, tag)
+	cfg := r.cfg.Get()
+	var errs []error
+	if err := r.releaseVersion(ctx, cfg, org, repo, tag); err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.publishReleaseNotes(ctx, cfg, org, repo, tag); err != nil {
+		errs = append(errs, err)
+	}
+	if err := r.mirrorTag(ctx, cfg, org, repo, tag); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.NewAggregate(errs)
+}
+
+func (r reactor) HandleTagDelete(ctx context.Context, org, repo string, tag string) error {
+	r.tagInformer.RemoveTag(org, repo, tag)
+	return nil
+}
+
+func (r reactor) releaseVersion(ctx context.Context, cfg *configuration.Configuration, org, repo string, tag string) error {
+	jiraConfig := cfg.Jira(org, repo)
+	if jiraConfig.Key == "" || !jiraConfig.ReleaseVersions && !jiraConfig.SyncMilestones {
+		return nil
+	}
+	xy, z, ok := r.tagInformer.Pattern(org, repo).ParseTag(tag)
+	if !ok {
+		return nil
+	}
+	if jiraConfig.ReleaseVersions {
+		if err := r.jiraCheck.ReleaseVersion(ctx, jiraConfig, xy, z); err != nil {
+			return fmt.Errorf("failed to release Jira version for %s/%s:%s: %w", org, repo, tag, err)
+		}
+	}
+	if jiraConfig.SyncMilestones {
+		milestone := fmt.Sprintf("%s%s.%d", jiraConfig.FixVersionPrefix, xy, z)
+		if err := r.jiraCheck.CloseMilestone(ctx, org, repo, milestone); err != nil {
+			return fmt.Errorf("failed to close milestone for %s/%s:%s: %w", org, repo, tag, err)
+		}
+	}
+	return nil
+}
+
+// HandleReleasePublish handles releases that are published in the GitHub UI
+// or by release tooling. The tag of the release may have been created with the
+// release, so the tag cache is updated before the release is processed.
+func (r reactor) HandleReleasePublish(ctx context.Context, org, repo string, release *github.RepositoryRelease) error {
+	tag := release.GetTagName()
+	if tag == "" {
+		return nil
+	}
+	r.tagInformer.AddTag(org, repo, tag)
+	return r.releaseVersion(ctx, r.cfg.Get(), org, repo, tag)
+}
+
+// publishReleaseNotes creates a GitHub release for the tag with the Jira
+// issues of the corresponding fix version, unless the release already exists.
+func (r reactor) publishReleaseNotes(ctx context.Context, cfg *configuration.Configuration, org, repo string, tag string) error {
+	jiraConfig := cfg.Jira(org, repo)
+	if jiraConfig.Key == "" || !jiraConfig.ReleaseNotes {
+		return nil
+	}
+	xy, z, ok := r.tagInformer.Pattern(org, repo).ParseTag(tag)
+	if !ok {
+		return nil
+	}
+
+	_, resp, err := r.client.Repositories.GetReleaseByTag(ctx, org, repo, tag)
+	if err == nil {
+		klog.V(4).Infof("%srelease %s already exists in %s/%s", logctx.Prefix(ctx), tag, org, repo)
+		return nil
+	} else if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to get release %s in %s/%s: %w", tag, org, repo, err)
+	}
+
+	fixVersion := fmt.Sprintf("%s%s.%d", jiraConfig.FixVersionPrefix, xy, z)
+	body, err := r.jiraCheck.ReleaseNotes(ctx, jiraConfig.Key, fixVersion)
+	if err != nil {
+		return fmt.Errorf("failed to generate release notes for %s/%s:%s: %w", org, repo, tag, err)
+	}
+
+	klog.V(2).Infof("%screating release %s in %s/%s...", logctx.Prefix(ctx), tag, org, repo)
+	_, _, err = r.client.Repositories.CreateRelease(ctx, org, repo, &github.RepositoryRelease{
+		TagName: github.String(tag),
+		Name:    github.String(tag),
+		Body:    github.String(body),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create release %s in %s/%s: %w", tag, org, repo, err)
+	}
 	return nil
 }
 
+// syncRepository syncs the branches of the repository from their sources. If
+// branchName is not empty, only this branch is synced.
+func (r reactor) syncRepository(ctx context.Context, repo configuration.Repository, branchName string) error {
+	var errs []error
+	for _, branch := range repo.Branches {
+		if branchName != "" && branch.Name != branchName {
+			continue
+		}
+		syncFrom, ok := repo.SyncSource(branch)
+		if !ok {
+			continue
+		}
+		syncTo := configuration.BranchReference{
+			Owner:  repo.Owner,
+			Repo:   repo.Repo,
+			Branch: branch.Name,
+		}
+		if err := r.sync(ctx, syncTo, syncFrom); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync %s: %w", syncTo, err))
+		}
+		if err := r.trackSyncFailure(ctx, repo, syncTo, syncFrom); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+// getPullRequest fetches the pull request. With GraphQL, its comments and
+// check runs are fetched in the same round trip and handed over to the Jira
+// check.
+func (r reactor) getPullRequest(ctx context.Context, org, repo string, number int) (*github.PullRequest, error) {
+	if !r.useGraphQL {
+		pr, _, err := r.client.PullRequests.Get(ctx, org, repo, number)
+		return pr, err
+	}
+	pr, err := graphql.GetPullRequest(ctx, r.client, org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	r.jiraCheck.Prefetch(pr)
+	return pr.PullRequest, nil
+}
+
 func (r reactor) HandleCheckSuiteRerequest(ctx context.Context, org, repo string, checkSuite *github.CheckSuite) error {
-	if checkSuite.GetApp().GetID() != r.cfg.AppID {
+	if checkSuite.GetApp().GetID() != r.cfg.Get().AppID {
 		return nil
 	}
 
 	for _, partialPR := range checkSuite.PullRequests {
-		pr, _, err := r.client.PullRequests.Get(ctx, org, repo, partialPR.GetNumber())
+		pr, err := r.getPullRequest(ctx, org, repo, partialPR.GetNumber())
 		if err != nil {
 			return fmt.Errorf("failed to get pull request: %w", err)
 		}
 
-		if err := r.jiraCheck.Run(checks.EventRecheck, r.cfg.Jira(org, repo), r.cfg.Branch(org, repo, pr.GetBase().GetRef()), pr); err != nil {
-			return fmt.Errorf("failed to run jira check: %w", err)
+		if err := r.runChecks(ctx, checks.EventRecheck, org, repo, pr); err != nil {
+			return err
 		}
 	}
 
@@ -245,14 +982,14 @@ func (r reactor) HandleIssueCommentCreate(ctx context.Context, org, repo string,
 	}
 
 	if recheckRegex.MatchString(comment.GetBody()) {
-		pr, _, err := r.client.PullRequests.Get(ctx, org, repo, issue.GetNumber())
+		pr, err := r.getPullRequest(ctx, org, repo, issue.GetNumber())
 		if err != nil {
 			return fmt.Errorf("failed to get pull request: %w", err)
 		}
 
-		err = r.jiraCheck.Run(checks.EventRecheck, r.cfg.Jira(org, repo), r.cfg.Branch(org, repo, pr.GetBase().GetRef()), pr)
+		err = r.runChecks(ctx, checks.EventRecheck, org, repo, pr)
 		if err != nil {
-			return fmt.Errorf("failed to run jira check: %w", err)
+			return err
 		}
 	}
 
@@ -260,26 +997,213 @@ func (r reactor) HandleIssueCommentCreate(ctx context.Context, org, repo string,
 }
 
 func (r reactor) HandlePullRequestClose(ctx context.Context, org, repo string, pr *github.PullRequest) error {
-	return r.jiraCheck.Run(checks.EventClosed, r.cfg.Jira(org, repo), r.cfg.Branch(org, repo, pr.GetBase().GetRef()), pr)
+	return r.runJiraCheck(ctx, r.cfg.Get(), checks.EventClosed, org, repo, pr)
 }
 
 func (r reactor) HandlePullRequestCreate(ctx context.Context, org, repo string, pr *github.PullRequest) error {
-	return r.jiraCheck.Run(checks.EventOpened, r.cfg.Jira(org, repo), r.cfg.Branch(org, repo, pr.GetBase().GetRef()), pr)
+	return r.runChecks(ctx, checks.EventOpened, org, repo, pr)
 }
 
 func (r reactor) HandlePullRequestEdit(ctx context.Context, org, repo string, pr *github.PullRequest) error {
-	return r.jiraCheck.Run(checks.EventEdited, r.cfg.Jira(org, repo), r.cfg.Branch(org, repo, pr.GetBase().GetRef()), pr)
+	return r.runChecks(ctx, checks.EventEdited, org, repo, pr)
 }
 
 func (r reactor) HandlePullRequestSynchronize(ctx context.Context, org, repo string, pr *github.PullRequest) error {
-	return r.jiraCheck.Run(checks.EventSync, r.cfg.Jira(org, repo), r.cfg.Branch(org, repo, pr.GetBase().GetRef()), pr)
+	return r.runChecks(ctx, checks.EventSync, org, repo, pr)
+}
+
+func (r reactor) HandlePullRequestLabel(ctx context.Context, org, repo string, pr *github.PullRequest, label string) error {
+	cfg := r.cfg.Get()
+	var errs []error
+	if err := r.runLabelsCheck(ctx, cfg, org, repo, pr); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run the %s check: %w", checks.LabelsCheckName, err))
+	}
+	repoConfig, _ := cfg.Repository(org, repo)
+	if repoConfig.AutoMerge.Label != "" && label == repoConfig.AutoMerge.Label {
+		if err := r.tryMerge(ctx, cfg, org, repo, pr); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+func (r reactor) HandlePullRequestUnlabel(ctx context.Context, org, repo string, pr *github.PullRequest, label string) error {
+	if err := r.runLabelsCheck(ctx, r.cfg.Get(), org, repo, pr); err != nil {
+		return fmt.Errorf("failed to run the %s check: %w", checks.LabelsCheckName, err)
+	}
+	return nil
+}
+
+func (r reactor) HandlePullRequestReview(ctx context.Context, org, repo string, pr *github.PullRequest, review *github.PullRequestReview) error {
+	if err := r.runCodeOwnersCheck(ctx, r.cfg.Get(), org, repo, pr); err != nil {
+		return fmt.Errorf("failed to run the %s check: %w", checks.CodeOwnersCheckName, err)
+	}
+	return nil
+}
+
+const (
+	DispatchHandlerSync            = "sync"
+	DispatchHandlerRecheck         = "recheck"
+	DispatchHandlerRefreshTags     = "refresh_tags"
+	DispatchHandlerReleaseVersion  = "release_version"
+	DispatchHandlerReleaseBranches = "create_release_branches"
+)
+
+type dispatchPayload struct {
+	Branch      string `json:"branch"`
+	PullRequest int    `json:"pull_request"`
+	Tag         string `json:"tag"`
+	Version     string `json:"version"`
+}
+
+func (r reactor) HandleRepositoryDispatch(ctx context.Context, org, repo string, eventType string, payload json.RawMessage) error {
+	cfg := r.cfg.Get()
+	repoConfig, ok := cfg.Repository(org, repo)
+	if !ok {
+		return nil
+	}
+	handler, ok := repoConfig.Dispatch[eventType]
+	if !ok {
+		klog.V(4).Infof("%sno handler for repository_dispatch event %s in %s/%s", logctx.Prefix(ctx), eventType, org, repo)
+		return nil
+	}
+
+	var p dispatchPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return fmt.Errorf("failed to decode client payload for %s: %w", eventType, err)
+		}
+	}
+
+	klog.V(2).Infof("%shandling repository_dispatch event %s in %s/%s with %s", logctx.Prefix(ctx), eventType, org, repo, handler)
+	switch handler {
+	case DispatchHandlerSync:
+		return r.syncRepository(ctx, repoConfig, p.Branch)
+	case DispatchHandlerRecheck:
+		if p.PullRequest == 0 {
+			return fmt.Errorf("%s: client payload does not have pull_request", eventType)
+		}
+		pr, err := r.getPullRequest(ctx, org, repo, p.PullRequest)
+		if err != nil {
+			return fmt.Errorf("failed to get pull request: %w", err)
+		}
+		return r.runChecks(ctx, checks.EventRecheck, org, repo, pr)
+	case DispatchHandlerRefreshTags:
+		r.tagInformer.InvalidateRepository(org, repo)
+		return nil
+	case DispatchHandlerReleaseVersion:
+		if p.Tag == "" {
+			return fmt.Errorf("%s: client payload does not have tag", eventType)
+		}
+		return r.releaseVersion(ctx, cfg, org, repo, p.Tag)
+	case DispatchHandlerReleaseBranches:
+		if p.Version == "" {
+			return fmt.Errorf("%s: client payload does not have version", eventType)
+		}
+		return r.createReleaseBranches(ctx, cfg, p.Version)
+	}
+	return fmt.Errorf("unknown handler %q for repository_dispatch event %s in %s/%s", handler, eventType, org, repo)
 }
 
 type EventHandler struct {
 	reactor Reactor
 }
 
-func (eh *EventHandler) HandleEvent(eventType string, body string) error {
+// webhookTrigger returns the event with its action, the sender and the
+// repository of the webhook.
+func webhookTrigger(eventType string, body string) audit.Trigger {
+	var envelope struct {
+		Action string `json:"action"`
+		Sender struct {
+			Login string `json:"login"`
+		} `json:"sender"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	// The payload is decoded again for the event, which reports the errors.
+	_ = json.Unmarshal([]byte(body), &envelope)
+	event := eventType
+	if envelope.Action != "" {
+		event += "." + envelope.Action
+	}
+	return audit.Trigger{
+		Event:      event,
+		Actor:      envelope.Sender.Login,
+		Repository: envelope.Repository.FullName,
+	}
+}
+
+// eventContext returns the context for handling the webhook event, the
+// mutations are audited with the event, its sender and its repository.
+func eventContext(ctx context.Context, eventType string, body string) context.Context {
+	return audit.WithTrigger(ctx, webhookTrigger(eventType, body))
+}
+
+// webhookEvent is the data of the CloudEvents of the handled webhooks.
+type webhookEvent struct {
+	Delivery   string `json:"delivery,omitempty"`
+	Event      string `json:"event"`
+	Sender     string `json:"sender,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+}
+
+// webhookSubject returns the subject of the CloudEvent of the webhook: the pull
+// request or the issue, e.g. pull/1234, or the branch of a push, e.g.
+// heads/master. It's empty for the other events, which are only keyed by
+// their repository.
+func webhookSubject(body string) string {
+	var envelope struct {
+		Number      int `json:"number"`
+		PullRequest struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+		Issue struct {
+			Number      int              `json:"number"`
+			PullRequest *json.RawMessage `json:"pull_request"`
+		} `json:"issue"`
+		Ref string `json:"ref"`
+	}
+	_ = json.Unmarshal([]byte(body), &envelope)
+	switch {
+	case envelope.PullRequest.Number != 0:
+		return fmt.Sprintf("pull/%d", envelope.PullRequest.Number)
+	case envelope.Issue.Number != 0 && envelope.Issue.PullRequest != nil:
+		return fmt.Sprintf("pull/%d", envelope.Issue.Number)
+	case envelope.Issue.Number != 0:
+		return fmt.Sprintf("issues/%d", envelope.Issue.Number)
+	case envelope.Number != 0:
+		return fmt.Sprintf("pull/%d", envelope.Number)
+	case strings.HasPrefix(envelope.Ref, "refs/"):
+		return strings.TrimPrefix(envelope.Ref, "refs/")
+	}
+	return ""
+}
+
+// publishWebhook publishes the CloudEvent of the handled webhook.
+func publishWebhook(publisher *cloudevents.Publisher, delivery, eventType, body string, err error) {
+	if publisher == nil {
+		return
+	}
+	trigger := webhookTrigger(eventType, body)
+	data := webhookEvent{
+		Delivery:   delivery,
+		Event:      trigger.Event,
+		Sender:     trigger.Actor,
+		Repository: trigger.Repository,
+		Outcome:    audit.OutcomeSuccess,
+	}
+	if err != nil {
+		data.Outcome = audit.OutcomeError
+		data.Error = err.Error()
+	}
+	publisher.Publish("webhook."+eventType, trigger.Repository, webhookSubject(body), data)
+}
+
+func (eh *EventHandler) HandleEvent(ctx context.Context, eventType string, body string) error {
+	ctx = eventContext(ctx, eventType, body)
 	switch eventType {
 	case "check_suite":
 		var checkSuiteEvent github.CheckSuiteEvent
@@ -290,7 +1214,7 @@ func (eh *EventHandler) HandleEvent(eventType string, body string) error {
 
 		switch checkSuiteEvent.GetAction() {
 		case "rerequested":
-			return eh.reactor.HandleCheckSuiteRerequest(context.Background(), checkSuiteEvent.GetRepo().GetOwner().GetLogin(), checkSuiteEvent.GetRepo().GetName(), checkSuiteEvent.GetCheckSuite())
+			return eh.reactor.HandleCheckSuiteRerequest(ctx, checkSuiteEvent.GetRepo().GetOwner().GetLogin(), checkSuiteEvent.GetRepo().GetName(), checkSuiteEvent.GetCheckSuite())
 		}
 	case "issue_comment":
 		var issueCommentEvent github.IssueCommentEvent
@@ -300,7 +1224,7 @@ func (eh *EventHandler) HandleEvent(eventType string, body string) error {
 		}
 
 		if issueCommentEvent.GetAction() == "created" {
-			return eh.reactor.HandleIssueCommentCreate(context.Background(), issueCommentEvent.Repo.Owner.GetLogin(), issueCommentEvent.Repo.GetName(), issueCommentEvent.Issue, issueCommentEvent.Comment)
+			return eh.reactor.HandleIssueCommentCreate(ctx, issueCommentEvent.Repo.Owner.GetLogin(), issueCommentEvent.Repo.GetName(), issueCommentEvent.Issue, issueCommentEvent.Comment)
 		}
 	case "pull_request":
 		var prEvent github.PullRequestEvent
@@ -311,13 +1235,86 @@ func (eh *EventHandler) HandleEvent(eventType string, body string) error {
 
 		switch prEvent.GetAction() {
 		case "opened":
-			return eh.reactor.HandlePullRequestCreate(context.Background(), prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
+			return eh.reactor.HandlePullRequestCreate(ctx, prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
 		case "edited":
-			return eh.reactor.HandlePullRequestEdit(context.Background(), prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
+			if changes := prEvent.GetChanges(); changes != nil && changes.Title == nil && changes.Base == nil {
+				klog.V(4).Infof("%sskipping edited event for %s#%d: neither title nor base changed", logctx.Prefix(ctx), prEvent.GetRepo().GetFullName(), prEvent.GetPullRequest().GetNumber())
+				return nil
+			}
+			return eh.reactor.HandlePullRequestEdit(ctx, prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
 		case "closed":
-			return eh.reactor.HandlePullRequestClose(context.Background(), prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
+			return eh.reactor.HandlePullRequestClose(ctx, prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
 		case "synchronize":
-			return eh.reactor.HandlePullRequestSynchronize(context.Background(), prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
+			return eh.reactor.HandlePullRequestSynchronize(ctx, prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest)
+		case "labeled":
+			return eh.reactor.HandlePullRequestLabel(ctx, prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest, prEvent.GetLabel().GetName())
+		case "unlabeled":
+			return eh.reactor.HandlePullRequestUnlabel(ctx, prEvent.Repo.Owner.GetLogin(), prEvent.Repo.GetName(), prEvent.PullRequest, prEvent.GetLabel().GetName())
+		}
+	case "pull_request_review":
+		var reviewEvent github.PullRequestReviewEvent
+		err := json.Unmarshal([]byte(body), &reviewEvent)
+		if err != nil {
+			return err
+		}
+
+		switch reviewEvent.GetAction() {
+		case "submitted", "dismissed":
+			return eh.reactor.HandlePullRequestReview(ctx, reviewEvent.GetRepo().GetOwner().GetLogin(), reviewEvent.GetRepo().GetName(), reviewEvent.GetPullRequest(), reviewEvent.GetReview())
+		}
+	case "check_run":
+		var checkRunEvent github.CheckRunEvent
+		err := json.Unmarshal([]byte(body), &checkRunEvent)
+		if err != nil {
+			return err
+		}
+
+		if checkRunEvent.GetAction() == "completed" {
+			return eh.reactor.HandleCheckRunComplete(ctx, checkRunEvent.GetRepo().GetOwner().GetLogin(), checkRunEvent.GetRepo().GetName(), checkRunEvent.GetCheckRun())
+		}
+	case "repository_dispatch":
+		var dispatchEvent github.RepositoryDispatchEvent
+		err := json.Unmarshal([]byte(body), &dispatchEvent)
+		if err != nil {
+			return err
+		}
+
+		return eh.reactor.HandleRepositoryDispatch(ctx, dispatchEvent.GetRepo().GetOwner().GetLogin(), dispatchEvent.GetRepo().GetName(), dispatchEvent.GetAction(), dispatchEvent.ClientPayload)
+	case "installation":
+		var installationEvent github.InstallationEvent
+		err := json.Unmarshal([]byte(body), &installationEvent)
+		if err != nil {
+			return err
+		}
+
+		return eh.reactor.HandleInstallationChange(ctx, installationEvent.GetInstallation().GetID(), installationEvent.GetAction())
+	case "installation_repositories":
+		var reposEvent github.InstallationRepositoriesEvent
+		err := json.Unmarshal([]byte(body), &reposEvent)
+		if err != nil {
+			return err
+		}
+
+		return eh.reactor.HandleInstallationChange(ctx, reposEvent.GetInstallation().GetID(), reposEvent.GetAction())
+	case "release":
+		var releaseEvent github.ReleaseEvent
+		err := json.Unmarshal([]byte(body), &releaseEvent)
+		if err != nil {
+			return err
+		}
+
+		if releaseEvent.GetAction() == "published" && !releaseEvent.GetRelease().GetDraft() {
+			return eh.reactor.HandleReleasePublish(ctx, releaseEvent.GetRepo().GetOwner().GetLogin(), releaseEvent.GetRepo().GetName(), releaseEvent.GetRelease())
+		}
+	case "workflow_run":
+		var workflowRunEvent github.WorkflowRunEvent
+		err := json.Unmarshal([]byte(body), &workflowRunEvent)
+		if err != nil {
+			return err
+		}
+
+		if workflowRunEvent.GetAction() == "completed" {
+			return eh.reactor.HandleWorkflowRunComplete(ctx, workflowRunEvent.GetRepo().GetOwner().GetLogin(), workflowRunEvent.GetRepo().GetName(), workflowRunEvent.GetWorkflowRun())
 		}
 	case "push":
 		var pushEvent github.PushEvent
@@ -329,83 +1326,385 @@ func (eh *EventHandler) HandleEvent(eventType string, body string) error {
 		ref := pushEvent.GetRef()
 		if strings.HasPrefix(ref, "refs/heads/") {
 			branch := strings.TrimPrefix(ref, "refs/heads/")
-			return eh.reactor.HandleBranchPush(context.Background(), pushEvent.Repo.Owner.GetLogin(), pushEvent.Repo.GetName(), branch)
+			return eh.reactor.HandleBranchPush(ctx, pushEvent.Repo.Owner.GetLogin(), pushEvent.Repo.GetName(), branch)
 		}
 		if strings.HasPrefix(ref, "refs/tags/") {
 			tag := strings.TrimPrefix(ref, "refs/tags/")
-			return eh.reactor.HandleTagPush(context.Background(), pushEvent.Repo.Owner.GetLogin(), pushEvent.Repo.GetName(), tag)
+			if pushEvent.GetDeleted() {
+				return eh.reactor.HandleTagDelete(ctx, pushEvent.Repo.Owner.GetLogin(), pushEvent.Repo.GetName(), tag)
+			}
+			return eh.reactor.HandleTagPush(ctx, pushEvent.Repo.Owner.GetLogin(), pushEvent.Repo.GetName(), tag)
 		}
 	}
 	return nil
 }
 
-func newJiraClient(tokenFile string) (*jira.Client, error) {
-	f, err := os.Open(tokenFile)
+func newJiraClient(token *secret, b *breaker.Breaker, auditLog *audit.Log) (*jira.Client, error) {
+	outbound, err := newOutboundTransport(*proxyURL, *noProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{}
+	httpClient.Transport = &audit.Transport{
+		Base: &breaker.Transport{
+			Base: &retry.Transport{
+				Base:           newJiraTokenTransport(token, outbound),
+				MaxRetries:     *jiraMaxRetries,
+				InitialBackoff: 500 * time.Millisecond,
+				MaxBackoff:     8 * time.Second,
+			},
+			Breaker: b,
+		},
+		Log:     auditLog,
+		Service: audit.ServiceJira,
+	}
+	return jira.NewClient(
+		httpClient,
+		*jiraEndpoint,
+	)
+}
+
+// newGitHubClients returns the clients that act as the app installation and
+// as the app itself. Their mutations are recorded to auditLog if it's not nil.
+func newGitHubClients(ctx context.Context, cfg *configuration.Configuration, keys []*secret, auditLog *audit.Log) (*github.Client, *github.Client, *installationTransport, error) {
+	outbound, err := newOutboundTransport(*proxyURL, *noProxy)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open jira token file: %w", err)
+		return nil, nil, nil, err
+	}
+	tr := &ratelimit.Transport{
+		Base: &retry.Transport{
+			Base:           outbound,
+			MaxRetries:     *githubMaxRetries,
+			InitialBackoff: time.Second,
+			MaxBackoff:     16 * time.Second,
+			MaxRetryAfter:  *githubMaxRetryAfter,
+			ShouldRetry:    retry.GitHubErrors,
+			RetryAfter:     retry.GitHubRetryAfter,
+		},
+		Limiter: ratelimit.NewTokenBucket(*githubWriteRate, *githubWriteBurst),
 	}
-	defer f.Close()
 
-	buf, err := io.ReadAll(f)
+	var etagCache *cache.Cache
+	if *githubETagCacheSize > 0 {
+		etagCache = cache.New("github-etags", *githubETagCacheSize, 0)
+	}
+	itr, err := newInstallationTransport(ctx, func(ctx context.Context) (*ghinstallation.Transport, error) {
+		return newKeyedTransport(ctx, &httpcache.Transport{Base: tr, Cache: etagCache}, cfg.AppID, cfg.InstallationID, keys)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read jira token file: %w", err)
+		return nil, nil, nil, err
 	}
 
-	token := strings.TrimSpace(string(buf))
+	apptr, err := newAppsTransport(tr, cfg.AppID, keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	tokenSource := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	return jira.NewClient(
-		oauth2.NewClient(context.Background(), tokenSource),
-		*jiraEndpoint,
-	)
+	rawClient := github.NewClient(&http.Client{Transport: &audit.Transport{Base: itr, Log: auditLog, Service: audit.ServiceGitHub}})
+	appClient := github.NewClient(&http.Client{Transport: &audit.Transport{Base: apptr, Log: auditLog, Service: audit.ServiceGitHub}})
+	return rawClient, appClient, itr, nil
+}
+
+func runSetup() {
+	if *setupURL == "" {
+		klog.Exit("-setup-url is required in the setup mode")
+	}
+	appSetup, err := NewAppSetup(*setupAppName, *setupURL, *setupOrg, *setupAppID, *privateKey, *webhookSecretRef, newVaultClient())
+	if err != nil {
+		klog.Exit(err)
+	}
+	klog.Infof("open %s to create the GitHub App", *setupURL)
+	if err := newServer(*addr, appSetup).ListenAndServe(); err != nil {
+		klog.Fatal(err)
+	}
 }
 
 func main() {
-	ctx := context.Background()
-	tr := http.DefaultTransport
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	klog.InitFlags(nil)
 	flag.Parse()
 
-	cfg, err := configuration.LoadFromFile(*configFile)
+	if *setup {
+		runSetup()
+		return
+	}
+
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "check":
+			runCheck(ctx, flag.Args()[1:])
+		case "validate":
+			runValidate(flag.Args()[1:])
+		case "schema":
+			runSchema()
+		case "crd":
+			runCRD()
+		default:
+			klog.Exitf("unknown command %q", flag.Arg(0))
+		}
+		return
+	}
+
+	if *syncInterval <= 0 || *syncTimeout <= 0 || *syncJitter < 0 {
+		klog.Exitf("-sync-interval and -sync-timeout should be positive, -sync-jitter can't be negative")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		klog.Exitf("-tls-cert and -tls-key should be set together")
+	}
+	if *syncHeartbeatMaxAge < *syncInterval+*syncJitter || *syncHeartbeatMaxAge < *syncTimeout {
+		klog.Exitf("-sync-liveness-threshold should be longer than -sync-interval plus -sync-jitter and than -sync-timeout")
+	}
+	if *vaultRefresh <= 0 {
+		klog.Exitf("-vault-refresh-interval should be positive")
+	}
+	if *eventTimeout <= 0 {
+		klog.Exitf("-event-timeout should be positive")
+	}
+
+	var cfg *configuration.Configuration
+	var configReloader *ConfigReloader
+	var err error
+	if *configMap != "" {
+		configReloader, err = NewConfigReloader(*configMap, *configMapKey)
+		if err != nil {
+			klog.Exit(err)
+		}
+		cfg, err = configReloader.Load(ctx)
+	} else {
+		cfg, err = configuration.LoadFromFile(*configFile)
+		if err == nil {
+			err = checkConfiguration(cfg)
+		}
+	}
 	if err != nil {
 		klog.Exitf("failed to load configuration: %v", err)
 	}
+	cfgStore := configuration.NewStore(cfg)
+	info := buildInfo(cfg)
+	klog.Infof("starting quay-ci-app %s built at %s with %s for the app %d", info.GitCommit, info.BuildDate, info.GoVersion, info.AppID)
+
+	jiraBreaker := breaker.New("jira", *jiraBreakerThreshold, *jiraBreakerCooldown)
+	var auditFile io.Writer
+	if *auditLogFile != "" {
+		f, err := os.OpenFile(*auditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			klog.Exitf("failed to open the audit log: %v", err)
+		}
+		defer f.Close()
+		auditFile = f
+	}
+	auditLog := audit.NewLog(*auditLogSize, auditFile)
+	var publisher *cloudevents.Publisher
+	if *cloudEventsSink != "" {
+		sink, err := cloudevents.NewSink(*cloudEventsSink)
+		if err != nil {
+			klog.Exit(err)
+		}
+		publisher = cloudevents.NewPublisher(sink, *cloudEventsQueueSize)
+		go publisher.Run(ctx)
+		auditLog.OnRecord(func(e audit.Entry) {
+			publisher.Publish("action."+e.Action, e.Repository, e.Target, e)
+		})
+	}
+	notifier := notify.New(cfgStore)
+	errorLog := errorlog.New(*errorLogInterval)
+	statusInformer := &StatusInformer{onChange: notifier.SyncStatus, errorLog: errorLog}
+	deferredRechecks := NewDeferredRechecks(jiraBreaker)
+	deliveries := NewDeliveryTracker()
+	var db *storage.DB
+	if *storageDSN != "" {
+		db, err = storage.Open(*storageDriver, *storageDSN)
+		if err != nil {
+			klog.Exit(err)
+		}
+		defer db.Close()
+		if err := auditLog.SetBackend(db); err != nil {
+			klog.Exit(err)
+		}
+		if err := statusInformer.SetStorage(db); err != nil {
+			klog.Exit(err)
+		}
+		if err := deferredRechecks.SetStorage(db); err != nil {
+			klog.Exit(err)
+		}
+		deliveries.SetStorage(db)
+	}
 
-	jiraClient, err := newJiraClient(*jiraTokenFile)
+	vaultClient := newVaultClient()
+	keys, jiraToken, err := loadAppSecrets(ctx, cfg.Secrets, vaultClient)
+	if err != nil {
+		klog.Exit(err)
+	}
+	jiraClient, err := newJiraClient(jiraToken, jiraBreaker, auditLog)
 	if err != nil {
 		klog.Exitf("failed to create jira client: %v", err)
 	}
 
-	itr, err := ghinstallation.NewKeyFromFile(tr, cfg.AppID, cfg.InstallationID, *privateKey)
+	rawClient, appClient, itr, err := newGitHubClients(ctx, cfg, keys, auditLog)
 	if err != nil {
 		klog.Fatal(err)
 	}
-
-	apptr, err := ghinstallation.NewAppsTransportKeyFromFile(tr, cfg.AppID, *privateKey)
+	for _, key := range keys {
+		key.OnChange(func() {
+			if err := itr.Reset(ctx); err != nil {
+				klog.Errorf("failed to use the new private key: %v", err)
+			}
+		})
+	}
+	githubWebhookSecret, err := loadSecret(ctx, "webhook secret", secretRef("webhook-secret", cfg.Secrets.WebhookSecret), vaultClient)
 	if err != nil {
-		klog.Fatal(err)
+		klog.Exit(err)
+	}
+	refreshed := append([]*secret{jiraToken, githubWebhookSecret}, keys...)
+	var webhookSecret *secret
+	if *jiraWebhookSecret != "" {
+		webhookSecret, err = loadSecret(ctx, "jira webhook secret", *jiraWebhookSecret, vaultClient)
+		if err != nil {
+			klog.Exit(err)
+		}
+		refreshed = append(refreshed, webhookSecret)
+	}
+	if vaultClient != nil {
+		go refreshSecrets(ctx, vaultClient, *vaultRefresh, refreshed...)
+	}
+	client := clients.NewGitHub(rawClient)
+
+	discoverer := NewRepositoryDiscoverer(client, cfgStore)
+	if err := discoverer.Refresh(ctx); err != nil {
+		klog.Errorf("failed to discover the repositories: %v", err)
+	}
+	var target configTarget = discoverer
+	var policyWatcher *PolicyWatcher
+	if *repositoryPolicies {
+		policyWatcher, err = NewPolicyWatcher(target)
+		if err == nil {
+			err = policyWatcher.Load(ctx)
+		}
+		if err != nil {
+			klog.Exitf("failed to load repository policies: %v", err)
+		}
+		target = policyWatcher
+	}
+	tagInformer := taginformer.New(rawClient, func(org, repo string) taginformer.TagPattern {
+		repoConfig, _ := cfgStore.Get().Repository(org, repo)
+		pattern, _ := taginformer.ParseTagPattern(repoConfig.TagPattern)
+		return pattern
+	})
+	activityRecorder := activity.NewRecorder(*activityFeedSize)
+	jiraCheck := checks.NewJira(client, clients.NewGitHub(appClient), clients.NewJira(jiraClient), tagInformer, statusInformer.UpdateBranchFixVersionMessage, activityRecorder, *issueCacheTTL, *projectCacheTTL)
+	jiraCheck.OnResult(notifier.JiraCheck)
+	if db != nil {
+		jiraCheck.SetStorage(db)
+	}
+	for _, err := range jiraCheck.ValidateStatuses(ctx, cfgStore.Get()) {
+		klog.Warningf("invalid Jira configuration: %v", err)
+	}
+	if configReloader != nil {
+		go configReloader.Watch(ctx, target, jiraCheck)
+	}
+	if policyWatcher != nil {
+		go policyWatcher.Watch(ctx)
 	}
+	go discoverer.Run(ctx)
 
-	client := github.NewClient(&http.Client{Transport: itr})
-	appClient := github.NewClient(&http.Client{Transport: apptr})
-	tagInformer := taginformer.New(client)
-	statusInformer := &StatusInformer{}
+	sloTracker := slo.NewTracker()
 	r := &reactor{
-		client:             client,
-		cfg:                cfg,
-		jiraCheck:          checks.NewJira(client, appClient, jiraClient, tagInformer),
-		statusInformer:     statusInformer,
-		invalidateTagCache: tagInformer.InvalidateCache,
+		client:           client,
+		cfg:              cfgStore,
+		jiraCheck:        jiraCheck,
+		labelsCheck:      checks.NewLabels(client, activityRecorder),
+		sizeCheck:        checks.NewSize(client, activityRecorder),
+		titleCheck:       checks.NewConventionalTitle(client, activityRecorder),
+		dcoCheck:         checks.NewDCO(client, activityRecorder),
+		signedCheck:      checks.NewSignedCommits(client, activityRecorder),
+		codeOwnersCheck:  checks.NewCodeOwners(client, activityRecorder),
+		statusInformer:   statusInformer,
+		tagInformer:      tagInformer,
+		deferredRechecks: deferredRechecks,
+		syncIssues:       NewSyncFailureIssues(),
+		syncStatuses:     NewSyncStatusReports(),
+		syncLocks:        NewBranchLocks(),
+		mergeLocks:       NewBranchLocks(),
+		pullRequestHeads: NewPullRequestHeads(),
+		slo:              sloTracker,
+		activity:         activityRecorder,
+		errorLog:         errorLog,
+		useGraphQL:       *githubGraphQL,
+
+		installationTransport: itr,
+		discoverer:            discoverer,
 	}
+	go r.deferredRechecks.Loop(ctx, r, 30*time.Second, *eventTimeout)
 	eh := &EventHandler{reactor: r}
+	if webhookSecret != nil {
+		http.Handle("/jira-webhook", &JiraWebhookHandler{reactor: r, secret: webhookSecret, timeout: *eventTimeout})
+	}
+
+	if len(cfg.TokenClients) > 0 {
+		http.Handle("/token", &TokenMinter{
+			appClient:      appClient,
+			installationID: cfg.InstallationID,
+			clients:        cfg.TokenClients,
+		})
+	}
+
+	// adminMux serves the operational endpoints, on their own listener if
+	// -admin-addr is set. They are authenticated like the admin API, also on
+	// the listener of the webhooks.
+	adminMux := http.DefaultServeMux
+	if *adminAddr != "" {
+		adminMux = http.NewServeMux()
+	}
+	adminAuth := newAdminAuth(cfgStore)
+
+	if cfg.ConsistencyAudit.Enabled {
+		auditor := &ConsistencyAuditor{
+			client:          client,
+			cfg:             cfgStore,
+			jiraCheck:       jiraCheck,
+			onInconsistency: notifier.Inconsistency,
+		}
+		adminMux.Handle("/consistency", adminAuth.Wrap(auditor))
+		go auditor.Loop(ctx, consistencyAuditPeriod)
+	}
+
+	adminMux.Handle("/api/v1/slo", adminAuth.Wrap(sloTracker))
+	http.Handle("/api/v1/activity/", activityRecorder)
+	adminMux.Handle("/config", adminAuth.Wrap(&ConfigHandler{cfg: cfgStore}))
+	adminMux.Handle("/status/history", adminAuth.Wrap(http.HandlerFunc(statusInformer.serveSyncHistory)))
+	http.Handle("/healthz", NewHealthHandler(statusInformer, *syncHeartbeatMaxAge))
+	adminMux.Handle("/audit", adminAuth.Wrap(auditLog))
+	http.Handle("/ui", &DashboardHandler{
+		cfg:            cfgStore,
+		statusInformer: statusInformer,
+		tagInformer:    tagInformer,
+		jiraCheck:      jiraCheck,
+		activity:       activityRecorder,
+	})
+	if *adminAPI {
+		adminMux.Handle("/admin/", NewAdminHandler(r))
+	}
+	http.Handle("/version", &BuildInfoHandler{cfg: cfgStore})
+	adminMux.Handle("/versions", adminAuth.Wrap(&VersionsHandler{
+		cfg:         cfgStore,
+		jiraCheck:   jiraCheck,
+		tagInformer: tagInformer,
+	}))
+
+	var certificates *CertificateReloader
+	if *tlsCert != "" {
+		certificates, err = NewCertificateReloader(*tlsCert, *tlsKey)
+		if err != nil {
+			klog.Exit(err)
+		}
+	}
 
 	go func() {
 		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodGet && r.URL.Path == "/status" {
-				status := statusInformer.GetStatus(cfg, tagInformer)
+				status := statusInformer.GetStatus(cfgStore.Get(), tagInformer, jiraCheck)
 				w.Header().Set("Content-Type", "application/json")
 				err := json.NewEncoder(w).Encode(status)
 				if err != nil {
@@ -413,9 +1712,17 @@ func main() {
 				}
 				return
 			}
-			body, err := io.ReadAll(r.Body)
-			if err != nil {
-				klog.Errorf("failed to read request body for %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			if r.Method == http.MethodGet && r.URL.Path == "/" {
+				http.Redirect(w, r, "/ui", http.StatusFound)
+				return
+			}
+			body, ok := readWebhookBody(w, r)
+			if !ok {
+				klog.V(4).Infof("rejected request from %s: %s %s (content-type: %s)", r.RemoteAddr, r.Method, r.URL, r.Header.Get("Content-Type"))
+				return
+			}
+			// The delivery ID is only trusted once the payload is signed.
+			if !verifyWebhookSignature(w, r, body, githubWebhookSecret) {
 				return
 			}
 			if len(body) > 0 {
@@ -426,9 +1733,24 @@ func main() {
 				} else {
 					klog.V(4).Infof("request from %s: %s %s: (content-type: %s, event: %s) [%d bytes]", r.RemoteAddr, r.Method, r.URL, contentType, event, len(body))
 				}
-				err := eh.HandleEvent(event, string(body))
+				delivery := r.Header.Get("X-GitHub-Delivery")
+				if !deliveries.Start(delivery) {
+					klog.V(2).Infof("skipping the delivery %s of the event %s, it was already handled", delivery, event)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				// The event is not handled with the context of the request:
+				// GitHub hangs up after 10 seconds, but the work on the event
+				// should go on. It is cancelled after -event-timeout instead,
+				// so that a stuck request to GitHub or Jira doesn't hang it.
+				eventCtx, cancel := context.WithTimeout(logctx.WithDelivery(ctx, delivery), *eventTimeout)
+				err := eh.HandleEvent(eventCtx, event, string(body))
+				cancel()
+				deliveries.Finish(delivery, err == nil)
+				publishWebhook(publisher, delivery, event, string(body), err)
+				sloTracker.Record(slo.WebhookHandling, err == nil)
 				if err != nil {
-					klog.Errorf("failed to handle event %s: %v", event, err)
+					errorLog.Errorf(err, "failed to handle event %s (delivery: %s): %v", event, delivery, err)
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
@@ -438,36 +1760,30 @@ func main() {
 				w.WriteHeader(http.StatusNotImplemented)
 			}
 		})
-		if err := http.ListenAndServe(*addr, nil); err != nil {
+		if err := serve(newServer(*addr, logRequests(http.DefaultServeMux)), certificates); err != nil {
 			klog.Fatal(err)
 		}
 	}()
-
-	for {
-		for _, repo := range cfg.Repositories {
-			for _, branch := range repo.Branches {
-				syncFrom := branch.SyncFrom
-				if syncFrom.Branch == "" {
-					continue
-				}
-				if syncFrom.Owner == "" {
-					syncFrom.Owner = repo.Owner
-				}
-				if syncFrom.Repo == "" {
-					syncFrom.Repo = repo.Repo
-				}
-				syncTo := configuration.BranchReference{
-					Owner:  repo.Owner,
-					Repo:   repo.Repo,
-					Branch: branch.Name,
-				}
-				err := r.sync(ctx, syncTo, syncFrom)
-				if err != nil {
-					klog.Errorf("failed to sync %s: %v", syncTo, err)
-				}
+	if *adminAddr != "" {
+		go func() {
+			if err := serve(newServer(*adminAddr, logRequests(adminMux)), certificates); err != nil {
+				klog.Fatal(err)
 			}
-		}
-
-		time.Sleep(5 * time.Minute)
+		}()
 	}
+
+	syncCtx := audit.WithTrigger(ctx, audit.Trigger{Event: "sync_loop"})
+	runSyncLoop(syncCtx, *syncInterval, *syncJitter, *syncTimeout, func(passCtx context.Context) {
+		statusInformer.SyncLoopHeartbeat()
+		syncRepositories(cfgStore.Get().ExplicitRepositories(), *syncWorkers, func(repo configuration.Repository) {
+			if err := r.syncRepository(passCtx, repo, ""); err != nil {
+				errorLog.Errorf(err, "%v", err)
+			}
+			if err := tagInformer.Refresh(repo.Owner, repo.Repo, repo.TagCacheTTLOrDefault(*tagCacheTTL)); err != nil {
+				errorLog.Errorf(err, "failed to refresh tags for %s/%s: %v", repo.Owner, repo.Repo, err)
+			}
+			statusInformer.SyncLoopHeartbeat()
+		})
+	})
+	klog.Info("shutting down")
 }
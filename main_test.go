@@ -2,12 +2,22 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/cloudevents"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+	"github.com/quay/quay-ci-app/storage"
+	"github.com/quay/quay-ci-app/taginformer"
 )
 
 type dummyReactor struct {
@@ -24,6 +34,41 @@ func (r *dummyReactor) HandleTagPush(ctx context.Context, org, repo string, tag
 	return nil
 }
 
+func (r *dummyReactor) HandleTagDelete(ctx context.Context, org, repo string, tag string) error {
+	r.events = append(r.events, fmt.Sprintf("tag_delete:%s/%s:%s", org, repo, tag))
+	return nil
+}
+
+func (r *dummyReactor) HandleReleasePublish(ctx context.Context, org, repo string, release *github.RepositoryRelease) error {
+	r.events = append(r.events, fmt.Sprintf("release_publish:%s/%s:%s", org, repo, release.GetTagName()))
+	return nil
+}
+
+func (r *dummyReactor) HandleWorkflowRunComplete(ctx context.Context, org, repo string, run *github.WorkflowRun) error {
+	r.events = append(r.events, fmt.Sprintf("workflow_run_complete:%s/%s:%s:%d", org, repo, run.GetName(), run.GetRunAttempt()))
+	return nil
+}
+
+func (r *dummyReactor) HandlePullRequestLabel(ctx context.Context, org, repo string, pr *github.PullRequest, label string) error {
+	r.events = append(r.events, fmt.Sprintf("pull_request_label:%s/%s:%d:%s", org, repo, pr.GetNumber(), label))
+	return nil
+}
+
+func (r *dummyReactor) HandlePullRequestUnlabel(ctx context.Context, org, repo string, pr *github.PullRequest, label string) error {
+	r.events = append(r.events, fmt.Sprintf("pull_request_unlabel:%s/%s:%d:%s", org, repo, pr.GetNumber(), label))
+	return nil
+}
+
+func (r *dummyReactor) HandlePullRequestReview(ctx context.Context, org, repo string, pr *github.PullRequest, review *github.PullRequestReview) error {
+	r.events = append(r.events, fmt.Sprintf("pull_request_review:%s/%s:%d:%s", org, repo, pr.GetNumber(), review.GetState()))
+	return nil
+}
+
+func (r *dummyReactor) HandleCheckRunComplete(ctx context.Context, org, repo string, checkRun *github.CheckRun) error {
+	r.events = append(r.events, fmt.Sprintf("check_run_complete:%s/%s:%s:%s", org, repo, checkRun.GetName(), checkRun.GetConclusion()))
+	return nil
+}
+
 func (r *dummyReactor) HandleCheckSuiteRerequest(ctx context.Context, org, repo string, suite *github.CheckSuite) error {
 	var prs []string
 	for _, pr := range suite.PullRequests {
@@ -58,6 +103,16 @@ func (r *dummyReactor) HandlePullRequestSynchronize(ctx context.Context, org, re
 	return nil
 }
 
+func (r *dummyReactor) HandleRepositoryDispatch(ctx context.Context, org, repo string, eventType string, payload json.RawMessage) error {
+	r.events = append(r.events, fmt.Sprintf("repository_dispatch:%s/%s:%s:%s", org, repo, eventType, payload))
+	return nil
+}
+
+func (r *dummyReactor) HandleInstallationChange(ctx context.Context, installationID int64, action string) error {
+	r.events = append(r.events, fmt.Sprintf("installation_change:%d:%s", installationID, action))
+	return nil
+}
+
 func TestPushEvent(t *testing.T) {
 	const pushEvent = `{"ref":"refs/heads/master","before":"5a1fa17a799800f09a9bf447a5c83e3b01bd3ef1","after":"2219d5aed22f28546df28fac4a4c7d0cc783f9d6","repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
 
@@ -65,7 +120,7 @@ func TestPushEvent(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("push", pushEvent)
+	err := eh.HandleEvent(context.Background(), "push", pushEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -81,7 +136,7 @@ func TestPushTagEvent(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("push", pushEvent)
+	err := eh.HandleEvent(context.Background(), "push", pushEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -90,6 +145,54 @@ func TestPushTagEvent(t *testing.T) {
 	}
 }
 
+func TestDeleteTagEvent(t *testing.T) {
+	const pushEvent = `{"ref":"refs/tags/v3.8.0","before":"2219d5aed22f28546df28fac4a4c7d0cc783f9d6","after":"0000000000000000000000000000000000000000","deleted":true,"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	err := eh.HandleEvent(context.Background(), "push", pushEvent)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.events, []string{"tag_delete:quay/quay:v3.8.0"}) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
+func TestReleaseEvent(t *testing.T) {
+	const releaseEvent = `{"action":"published","release":{"tag_name":"v3.8.1","draft":false},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	err := eh.HandleEvent(context.Background(), "release", releaseEvent)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.events, []string{"release_publish:quay/quay:v3.8.1"}) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
+func TestWorkflowRunEvent(t *testing.T) {
+	const workflowRunEvent = `{"action":"completed","workflow_run":{"id":1,"name":"e2e","run_attempt":2,"conclusion":"failure"},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	err := eh.HandleEvent(context.Background(), "workflow_run", workflowRunEvent)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.events, []string{"workflow_run_complete:quay/quay:e2e:2"}) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
 func TestCheckSuiteRerequest(t *testing.T) {
 	const suiteEvent = `{"action":"rerequested","check_suite":{"pull_requests":[{"number":1}]},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
 
@@ -97,7 +200,7 @@ func TestCheckSuiteRerequest(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("check_suite", suiteEvent)
+	err := eh.HandleEvent(context.Background(), "check_suite", suiteEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -113,7 +216,7 @@ func TestPullRequestCommentRecheck(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("issue_comment", commentEvent)
+	err := eh.HandleEvent(context.Background(), "issue_comment", commentEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -129,7 +232,7 @@ func TestPullRequestMerged(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("pull_request", prEvent)
+	err := eh.HandleEvent(context.Background(), "pull_request", prEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -145,7 +248,7 @@ func TestPullRequestCreate(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("pull_request", prEvent)
+	err := eh.HandleEvent(context.Background(), "pull_request", prEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -161,7 +264,23 @@ func TestPullRequestEdit(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("pull_request", prEvent)
+	err := eh.HandleEvent(context.Background(), "pull_request", prEvent)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.events, []string{"pull_request_edit:quay/quay:1:[chore: Test PR (PROJQUAY-1234)]"}) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
+func TestPullRequestEditTitle(t *testing.T) {
+	const prEvent = `{"action":"edited","changes":{"title":{"from":"chore: Test PR"}},"pull_request":{"number":1,"title":"chore: Test PR (PROJQUAY-1234)","state":"open"},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	err := eh.HandleEvent(context.Background(), "pull_request", prEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -170,6 +289,22 @@ func TestPullRequestEdit(t *testing.T) {
 	}
 }
 
+func TestPullRequestEditBodyOnly(t *testing.T) {
+	const prEvent = `{"action":"edited","changes":{"body":{"from":"old description"}},"pull_request":{"number":1,"title":"chore: Test PR (PROJQUAY-1234)","state":"open"},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	err := eh.HandleEvent(context.Background(), "pull_request", prEvent)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(r.events) != 0 {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
 func TestPullRequestSynchronize(t *testing.T) {
 	const prEvent = `{"action":"synchronize","pull_request":{"number":1,"title":"chore: Test PR (PROJQUAY-1234)","state":"open"},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
 
@@ -177,7 +312,7 @@ func TestPullRequestSynchronize(t *testing.T) {
 	eh := &EventHandler{
 		reactor: r,
 	}
-	err := eh.HandleEvent("pull_request", prEvent)
+	err := eh.HandleEvent(context.Background(), "pull_request", prEvent)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -185,3 +320,387 @@ func TestPullRequestSynchronize(t *testing.T) {
 		t.Errorf("unexpected events: %v", r.events)
 	}
 }
+
+func TestRepositoryDispatch(t *testing.T) {
+	const dispatchEvent = `{"action":"release-cut","branch":"master","client_payload":{"tag":"v3.8.1"},"repository":{"name":"quay","full_name":"quay/quay","private":false,"owner":{"name":"quay","login":"quay"}}}`
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	err := eh.HandleEvent(context.Background(), "repository_dispatch", dispatchEvent)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.events, []string{`repository_dispatch:quay/quay:release-cut:{"tag":"v3.8.1"}`}) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
+func TestInstallationEvents(t *testing.T) {
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	for _, event := range [][2]string{
+		{"installation", `{"action":"new_permissions_accepted","installation":{"id":42}}`},
+		{"installation_repositories", `{"action":"added","installation":{"id":42},"repositories_added":[{"name":"clair","full_name":"quay/clair"}]}`},
+	} {
+		if err := eh.HandleEvent(context.Background(), event[0], event[1]); err != nil {
+			t.Errorf("unexpected error for %s: %s", event[0], err)
+		}
+	}
+	want := []string{"installation_change:42:new_permissions_accepted", "installation_change:42:added"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
+func TestFakeEvents(t *testing.T) {
+	pr := fakes.PullRequest("quay", "quay", 42, "Fix the build (PROJQUAY-123)")
+
+	r := &dummyReactor{}
+	eh := &EventHandler{
+		reactor: r,
+	}
+	for _, event := range [][2]string{
+		pack(fakes.PullRequestEvent("opened", pr)),
+		pack(fakes.PullRequestLabelEvent("labeled", pr, "kind/bug")),
+		pack(fakes.PullRequestReviewEvent("submitted", pr, "reviewer", "approved")),
+		pack(fakes.IssueCommentEvent(pr, "reviewer", "/recheck")),
+		pack(fakes.CheckSuiteRerequestEvent("quay", "quay", pr)),
+		pack(fakes.PushEvent("quay", "quay", "refs/heads/master")),
+	} {
+		if err := eh.HandleEvent(context.Background(), event[0], event[1]); err != nil {
+			t.Errorf("unexpected error for %s: %s", event[0], err)
+		}
+	}
+	want := []string{
+		"pull_request_create:quay/quay:42:[Fix the build (PROJQUAY-123)]",
+		"pull_request_label:quay/quay:42:kind/bug",
+		"pull_request_review:quay/quay:42:approved",
+		"issue_comment_create:quay/quay:42:[Fix the build (PROJQUAY-123)]:[/recheck]",
+		"check_suite_rerequest:quay/quay:[42]",
+		"branch_push:quay/quay:master",
+	}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("unexpected events: %v", r.events)
+	}
+}
+
+func pack(eventType, body string) [2]string {
+	return [2]string{eventType, body}
+}
+
+func TestTagsStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var refs []*github.Reference
+		for _, tag := range []string{"v3.10.0", "v3.9.0", "v3.9.1", "v3.9.2-rc.1", "v3.11.0-rc.1"} {
+			refs = append(refs, &github.Reference{Ref: github.String("refs/tags/" + tag)})
+		}
+		json.NewEncoder(w).Encode(refs)
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	ti := taginformer.New(client, nil)
+	if _, err := ti.NextVersion("quay", "quay", "3.9"); err != nil {
+		t.Fatal(err)
+	}
+
+	status := (&StatusInformer{}).GetStatus(&configuration.Configuration{}, ti, nil)
+	if len(status.Tags) != 1 || status.Tags[0].Repository != "quay/quay" || status.Tags[0].TagPattern != "v{version}" {
+		t.Fatalf("got tags status %+v", status.Tags)
+	}
+	if status.Tags[0].FetchedAt.IsZero() {
+		t.Errorf("the tags status should have the fetch time")
+	}
+	want := []StreamTagsStatus{
+		{Stream: "3.9", LatestVersion: "3.9.1", PreReleases: 1},
+		{Stream: "3.10", LatestVersion: "3.10.0"},
+		{Stream: "3.11", PreReleases: 1},
+	}
+	if !reflect.DeepEqual(status.Tags[0].Streams, want) {
+		t.Errorf("got streams %+v, want %+v", status.Tags[0].Streams, want)
+	}
+}
+
+func TestStatusInformerBounded(t *testing.T) {
+	si := &StatusInformer{}
+	for i := 0; i <= maxBranchStatuses; i++ {
+		si.UpdateBranchSyncStatus(fmt.Sprintf("quay/quay:branch-%05d", i), "Synced", "synched from quay/quay:master")
+	}
+	if s := si.BranchSyncStatus("quay/quay:branch-00000"); s != nil {
+		t.Errorf("the least recently updated branch should be forgotten, got %+v", s)
+	}
+	branches := si.statusSnapshot().Branches
+	if len(branches) != maxBranchStatuses || branches[0].Branch != "quay/quay:branch-00001" {
+		t.Errorf("got %d branches starting with %s, want %d starting with quay/quay:branch-00001", len(branches), branches[0].Branch, maxBranchStatuses)
+	}
+}
+
+func TestStatusInformerStorage(t *testing.T) {
+	db, err := storage.Open(storage.DriverSQLite, filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	si := &StatusInformer{}
+	if err := si.SetStorage(db); err != nil {
+		t.Fatal(err)
+	}
+	si.UpdateBranchSyncStatus("quay/quay:redhat-3.9", "Synced", "synched from quay/quay:master")
+	si.UpdateBranchSyncStatus("quay/quay:redhat-3.9", "Synced", "synched from quay/quay:master")
+	si.UpdateBranchSyncStatus("quay/quay:redhat-3.9", "PausedByRepo", "syncing from quay/quay:master is paused")
+
+	// The status informer of the restarted app has the last status.
+	restarted := &StatusInformer{}
+	if err := restarted.SetStorage(db); err != nil {
+		t.Fatal(err)
+	}
+	if s := restarted.BranchSyncStatus("quay/quay:redhat-3.9"); s == nil || s.Status != "PausedByRepo" {
+		t.Errorf("got the sync status %+v, want PausedByRepo", s)
+	}
+
+	w := httptest.NewRecorder()
+	restarted.serveSyncHistory(w, httptest.NewRequest(http.MethodGet, "/status/history?branch=quay/quay:redhat-3.9", nil))
+	var history []BranchSyncStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	var got []string
+	for _, s := range history {
+		got = append(got, s.Status)
+	}
+	if want := []string{"PausedByRepo", "Synced"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the history %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	(&StatusInformer{}).serveSyncHistory(w, httptest.NewRequest(http.MethodGet, "/status/history?branch=quay/quay:redhat-3.9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %d without storage, want 404", w.Code)
+	}
+}
+
+type fakeSink struct {
+	events chan cloudevents.Event
+}
+
+func (s *fakeSink) Send(ctx context.Context, e cloudevents.Event) error {
+	s.events <- e
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+func TestPublishWebhook(t *testing.T) {
+	sink := &fakeSink{events: make(chan cloudevents.Event, 1)}
+	publisher := cloudevents.NewPublisher(sink, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go publisher.Run(ctx)
+
+	body := `{"action":"opened","number":1234,"pull_request":{"number":1234},"sender":{"login":"octocat"},"repository":{"full_name":"quay/quay"}}`
+	publishWebhook(publisher, "72d3162e", "pull_request", body, fmt.Errorf("failed to get pull request"))
+
+	e := <-sink.events
+	if e.Type != "com.github.quay.quay-ci-app.webhook.pull_request" || e.Source != "https://github.com/quay/quay" || e.Subject != "pull/1234" {
+		t.Errorf("got the event %+v", e)
+	}
+	want := webhookEvent{
+		Delivery:   "72d3162e",
+		Event:      "pull_request.opened",
+		Sender:     "octocat",
+		Repository: "quay/quay",
+		Outcome:    "error",
+		Error:      "failed to get pull request",
+	}
+	if !reflect.DeepEqual(e.Data, want) {
+		t.Errorf("got the data %+v, want %+v", e.Data, want)
+	}
+}
+
+func TestWebhookSubject(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		want string
+	}{
+		{body: `{"action":"submitted","pull_request":{"number":1234}}`, want: "pull/1234"},
+		{body: `{"action":"created","issue":{"number":1234,"pull_request":{"url":"https://api.github.com/repos/quay/quay/pulls/1234"}}}`, want: "pull/1234"},
+		{body: `{"action":"opened","issue":{"number":42}}`, want: "issues/42"},
+		{body: `{"ref":"refs/heads/master"}`, want: "heads/master"},
+		{body: `{"action":"completed","check_suite":{"id":1}}`, want: ""},
+	} {
+		if got := webhookSubject(tc.body); got != tc.want {
+			t.Errorf("%s: got the subject %q, want %q", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestSyncStrategies(t *testing.T) {
+	testCases := []struct {
+		strategy string
+		wantHead string
+		wantErr  bool
+	}{
+		{strategy: "", wantErr: true},
+		{strategy: configuration.SyncStrategyFFOnly, wantErr: true},
+		{strategy: configuration.SyncStrategyForce, wantHead: "new"},
+		{strategy: configuration.SyncStrategyMerge, wantHead: "merge-1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			gh := fakes.NewGitHub()
+			gh.Refs["quay/quay:heads/master"] = ref("new")
+			gh.Refs["quay/quay:heads/integration"] = ref("old")
+			gh.DivergedRefs["quay/quay:heads/integration"] = true
+			repo := configuration.Repository{
+				Owner: "quay",
+				Repo:  "quay",
+				Branches: []configuration.Branch{
+					{Name: "integration", SyncFrom: configuration.BranchReference{Branch: "master"}, Strategy: tc.strategy},
+				},
+			}
+			r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+
+			// The second sync finds the source already merged.
+			for i := 0; i < 2; i++ {
+				err := r.syncRepository(context.Background(), repo, "")
+				if tc.wantErr {
+					if err == nil || !strings.Contains(err.Error(), "not a fast forward") {
+						t.Fatalf("got %v, want an error about the fast forward", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if head := gh.Refs["quay/quay:heads/integration"].GetObject().GetSHA(); head != tc.wantHead {
+				t.Errorf("got the head %s, want %s", head, tc.wantHead)
+			}
+			if status := r.statusInformer.BranchSyncStatus("quay/quay:integration"); status.Status != "Synced" {
+				t.Errorf("got the status %s: %s", status.Status, status.Message)
+			}
+			if merges := gh.Merges["quay/quay"]; tc.strategy == configuration.SyncStrategyMerge && len(merges) != 1 {
+				t.Errorf("got %d merges, want 1", len(merges))
+			}
+		})
+	}
+}
+
+func TestSyncCheck(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay:heads/master"] = ref("new")
+	gh.Refs["quay/quay:heads/redhat-3.9"] = ref("old")
+	repo := configuration.Repository{
+		Owner: "quay",
+		Repo:  "quay",
+		Branches: []configuration.Branch{
+			{Name: "redhat-3.9", SyncFrom: configuration.BranchReference{Branch: "master"}, SyncCheck: true},
+		},
+	}
+	r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+	ctx := context.Background()
+
+	// The branch has diverged and can't be fast-forwarded.
+	gh.DivergedRefs["quay/quay:heads/redhat-3.9"] = true
+	if err := r.syncRepository(ctx, repo, ""); err == nil {
+		t.Fatal("the sync of the diverged branch should fail")
+	}
+	checkRun := gh.LatestCheckRun("old", "Upstream Sync")
+	if checkRun.GetConclusion() != "failure" || !strings.Contains(checkRun.GetOutput().GetSummary(), "https://github.com/quay/quay/compare/old...new") {
+		t.Errorf("got the check run %s: %s, want a failure with the pending changes", checkRun.GetConclusion(), checkRun.GetOutput().GetSummary())
+	}
+
+	delete(gh.DivergedRefs, "quay/quay:heads/redhat-3.9")
+	for i := 0; i < 2; i++ {
+		if err := r.syncRepository(ctx, repo, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRun = gh.LatestCheckRun("new", "Upstream Sync")
+	if checkRun.GetConclusion() != "success" || checkRun.GetOutput().GetTitle() != "Synced from quay/quay:master" {
+		t.Errorf("got the check run %s: %s, want a success", checkRun.GetConclusion(), checkRun.GetOutput().GetTitle())
+	}
+	if len(gh.CheckRuns) != 2 {
+		t.Errorf("got %d check runs, the sync that changes nothing should not report one", len(gh.CheckRuns))
+	}
+
+	// Without sync_check, nothing is reported.
+	repo.Branches[0].SyncCheck = false
+	r.cfg.Set(&configuration.Configuration{Repositories: []configuration.Repository{repo}})
+	gh.Refs["quay/quay:heads/master"] = ref("newer")
+	if err := r.syncRepository(ctx, repo, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.CheckRuns) != 2 {
+		t.Errorf("got %d check runs without sync_check, want 2", len(gh.CheckRuns))
+	}
+}
+
+func TestSyncPausedByRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		pause func(gh *fakes.GitHub)
+	}{
+		{
+			name: "marker file",
+			pause: func(gh *fakes.GitHub) {
+				gh.Contents["quay/quay:"+syncPausedFile] = ""
+			},
+		},
+		{
+			name: "label",
+			pause: func(gh *fakes.GitHub) {
+				gh.RepoLabels["quay/quay"] = []string{syncPausedLabel}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gh := fakes.NewGitHub()
+			gh.Refs["quay/quay:heads/master"] = ref("new")
+			gh.Refs["quay/quay:heads/redhat-3.9"] = ref("old")
+			repo := configuration.Repository{
+				Owner: "quay",
+				Repo:  "quay",
+				Branches: []configuration.Branch{
+					{Name: "redhat-3.9", SyncFrom: configuration.BranchReference{Branch: "master"}, SyncCheck: true},
+				},
+			}
+			r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+			ctx := context.Background()
+
+			tc.pause(gh)
+			if err := r.syncRepository(ctx, repo, ""); err != nil {
+				t.Fatal(err)
+			}
+			if head := gh.Refs["quay/quay:heads/redhat-3.9"].GetObject().GetSHA(); head != "old" {
+				t.Errorf("the paused branch should not be updated, got the head %s", head)
+			}
+			if status := r.statusInformer.BranchSyncStatus("quay/quay:redhat-3.9"); status == nil || status.Status != "PausedByRepo" {
+				t.Errorf("got the sync status %+v, want PausedByRepo", status)
+			}
+			if checkRun := gh.LatestCheckRun("old", "Upstream Sync"); checkRun.GetConclusion() != "neutral" {
+				t.Errorf("got the conclusion %q for the paused branch, want neutral", checkRun.GetConclusion())
+			}
+
+			// The maintainers resume syncing.
+			delete(gh.Contents, "quay/quay:"+syncPausedFile)
+			delete(gh.RepoLabels, "quay/quay")
+			if err := r.syncRepository(ctx, repo, ""); err != nil {
+				t.Fatal(err)
+			}
+			if head := gh.Refs["quay/quay:heads/redhat-3.9"].GetObject().GetSHA(); head != "new" {
+				t.Errorf("got the head %s after resuming, want new", head)
+			}
+			if status := r.statusInformer.BranchSyncStatus("quay/quay:redhat-3.9"); status.Status != "Synced" {
+				t.Errorf("got the sync status %s after resuming, want Synced", status.Status)
+			}
+		})
+	}
+}
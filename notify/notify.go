@@ -0,0 +1,157 @@
+// Package notify sends the notifications of the app to Slack: when a branch
+// sync fails, when the Jira check keeps failing with internal errors and when
+// the Jira rules with notify are applied, and when the consistency audit finds
+// an issue that the rules missed.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+// Notifier decides which events are worth a notification according to the
+// notifications section of the configuration and posts them to Slack. It is
+// safe to call the methods of a nil Notifier.
+type Notifier struct {
+	cfg    *configuration.Store
+	client *http.Client
+
+	mutex sync.Mutex
+	// jiraErrors is the number of consecutive internal errors of the Jira
+	// check in each repository.
+	jiraErrors map[string]int
+}
+
+func New(cfg *configuration.Store) *Notifier {
+	return &Notifier{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		jiraErrors: map[string]int{},
+	}
+}
+
+// SyncStatus is called when the sync status of the branch changes. It notifies
+// about the branches that enter the Error state.
+func (n *Notifier) SyncStatus(branch, status, message string) {
+	if n == nil {
+		return
+	}
+	cfg := n.cfg.Get().Notifications
+	if status != "Error" || !cfg.SyncErrors {
+		return
+	}
+	n.send(cfg.Slack, fmt.Sprintf(":x: Syncing *%s* failed: %s", branch, message))
+}
+
+// JiraCheck is called with every result of the Jira check in the repository.
+// It notifies when the internal errors reach the configured number in a row,
+// and when a rule with notify was applied.
+func (n *Notifier) JiraCheck(repo string, result checks.Result) {
+	if n == nil {
+		return
+	}
+	cfg := n.cfg.Get().Notifications
+	prURL := fmt.Sprintf("https://github.com/%s/pull/%d", repo, result.PullRequest)
+
+	if result.Notify {
+		text := fmt.Sprintf(":information_source: The Jira rule %s was applied to <%s|%s#%d>", result.Rule, prURL, repo, result.PullRequest)
+		if result.Error != "" {
+			text += ", but it failed: " + result.Error
+		}
+		n.send(cfg.Slack, text)
+	}
+
+	if result.Rule != "" {
+		// The errors of the rules are not internal errors of the check, they
+		// are reported with the rules above.
+		return
+	}
+	n.mutex.Lock()
+	if result.Error == "" {
+		delete(n.jiraErrors, repo)
+		n.mutex.Unlock()
+		return
+	}
+	n.jiraErrors[repo]++
+	count := n.jiraErrors[repo]
+	n.mutex.Unlock()
+
+	if cfg.JiraErrors > 0 && count == cfg.JiraErrors {
+		n.send(cfg.Slack, fmt.Sprintf(":warning: The Jira check failed %d times in a row in *%s*, the last time for <%s|#%d>: %s", count, repo, prURL, result.PullRequest, result.Error))
+	}
+}
+
+// Inconsistency is called for each inconsistency that the consistency audit
+// finds.
+func (n *Notifier) Inconsistency(inconsistency checks.Inconsistency) {
+	if n == nil {
+		return
+	}
+	cfg := n.cfg.Get().Notifications
+	if !cfg.Inconsistencies {
+		return
+	}
+	text := fmt.Sprintf(":mag: The Jira issue %s of <%s|%s> is %s, expected %s", inconsistency.Issue, inconsistency.URL, inconsistency.PullRequest, inconsistency.Status, inconsistency.ExpectedStatus)
+	if inconsistency.Retried {
+		if inconsistency.Error != "" {
+			text += ", the transition failed again: " + inconsistency.Error
+		} else {
+			text += ", the transition was retried"
+		}
+	}
+	n.send(cfg.Slack, text)
+}
+
+type slackMessage struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// send posts the message to the Slack webhook. The errors are logged, the
+// notifications are best effort.
+func (n *Notifier) send(slack *configuration.SlackNotifications, text string) {
+	if slack == nil {
+		return
+	}
+	buf, err := os.ReadFile(slack.WebhookURLFile)
+	if err != nil {
+		klog.Errorf("failed to read the Slack webhook URL: %v", err)
+		return
+	}
+	webhookURL := strings.TrimSpace(string(buf))
+
+	body, err := json.Marshal(slackMessage{
+		Text:    text,
+		Channel: slack.Channel,
+	})
+	if err != nil {
+		klog.Errorf("failed to encode the Slack message: %v", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("failed to create the Slack request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		klog.Errorf("failed to send the Slack notification: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		klog.Errorf("failed to send the Slack notification: %s", resp.Status)
+	}
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func newTestNotifier(t *testing.T, notifications configuration.Notifications) (*Notifier, *[]slackMessage) {
+	var messages []slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("failed to decode the message: %v", err)
+		}
+		messages = append(messages, msg)
+	}))
+	t.Cleanup(server.Close)
+
+	urlFile := filepath.Join(t.TempDir(), "slack-webhook")
+	if err := os.WriteFile(urlFile, []byte(server.URL+"/services/T000/B000/XXX\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notifications.Slack = &configuration.SlackNotifications{WebhookURLFile: urlFile, Channel: "#quay-ci"}
+	n := New(configuration.NewStore(&configuration.Configuration{Notifications: notifications}))
+	return n, &messages
+}
+
+func texts(messages []slackMessage) []string {
+	var result []string
+	for _, msg := range messages {
+		result = append(result, msg.Text)
+	}
+	return result
+}
+
+func TestSyncStatus(t *testing.T) {
+	n, messages := newTestNotifier(t, configuration.Notifications{SyncErrors: true})
+	n.SyncStatus("quay/quay:redhat-3.9", "Synced", "synched from quay/quay:master")
+	n.SyncStatus("quay/quay:redhat-3.9", "Error", "failed to merge")
+
+	want := []slackMessage{{Text: ":x: Syncing *quay/quay:redhat-3.9* failed: failed to merge", Channel: "#quay-ci"}}
+	if !reflect.DeepEqual(*messages, want) {
+		t.Errorf("got %+v, want %+v", *messages, want)
+	}
+}
+
+func TestSyncStatusDisabled(t *testing.T) {
+	n, messages := newTestNotifier(t, configuration.Notifications{})
+	n.SyncStatus("quay/quay:redhat-3.9", "Error", "failed to merge")
+	if len(*messages) != 0 {
+		t.Errorf("got %+v, want no messages", *messages)
+	}
+}
+
+func TestJiraCheck(t *testing.T) {
+	n, messages := newTestNotifier(t, configuration.Notifications{JiraErrors: 2})
+	for _, result := range []checks.Result{
+		{PullRequest: 1, Error: "failed to get Jira issue PROJQUAY-1: 502"},
+		{PullRequest: 2, Conclusion: "success"},
+		{PullRequest: 3, Error: "failed to get Jira issue PROJQUAY-3: 502"},
+		{PullRequest: 4, Error: "failed to get Jira issue PROJQUAY-4: 502"},
+		{PullRequest: 5, Error: "failed to get Jira issue PROJQUAY-5: 502"},
+		{PullRequest: 6, Conclusion: "success", Rule: "rules[0]", Notify: true},
+		{PullRequest: 7, Conclusion: "success", Rule: "rules[1]", Notify: true, Error: "transition Closed is not available"},
+		{PullRequest: 8, Conclusion: "success", Rule: "rules[2]"},
+	} {
+		n.JiraCheck("quay/quay", result)
+	}
+
+	want := []string{
+		":warning: The Jira check failed 2 times in a row in *quay/quay*, the last time for <https://github.com/quay/quay/pull/4|#4>: failed to get Jira issue PROJQUAY-4: 502",
+		":information_source: The Jira rule rules[0] was applied to <https://github.com/quay/quay/pull/6|quay/quay#6>",
+		":information_source: The Jira rule rules[1] was applied to <https://github.com/quay/quay/pull/7|quay/quay#7>, but it failed: transition Closed is not available",
+	}
+	if got := texts(*messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *Notifier
+	n.SyncStatus("quay/quay:redhat-3.9", "Error", "failed to merge")
+	n.JiraCheck("quay/quay", checks.Result{Error: "failed"})
+}
+
+func TestInconsistency(t *testing.T) {
+	n, messages := newTestNotifier(t, configuration.Notifications{Inconsistencies: true})
+	n.Inconsistency(checks.Inconsistency{
+		PullRequest:    "quay/quay#1234",
+		URL:            "https://github.com/quay/quay/pull/1234",
+		Issue:          "PROJQUAY-123",
+		Status:         "POST",
+		ExpectedStatus: "ON_QA",
+		Retried:        true,
+	})
+
+	want := []string{":mag: The Jira issue PROJQUAY-123 of <https://github.com/quay/quay/pull/1234|quay/quay#1234> is POST, expected ON_QA, the transition was retried"}
+	if got := texts(*messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	disabled, messages := newTestNotifier(t, configuration.Notifications{})
+	disabled.Inconsistency(checks.Inconsistency{Issue: "PROJQUAY-123"})
+	if len(*messages) != 0 {
+		t.Errorf("got %+v, want no messages", *messages)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/kube"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// policyConditionAccepted is the condition of a RepositoryPolicy that tells
+// whether its repository is added to the configuration.
+const policyConditionAccepted = "Accepted"
+
+func policySource(p kube.RepositoryPolicy) string {
+	return kube.PolicyKind + " " + p.Metadata.Namespace + "/" + p.Metadata.Name
+}
+
+// PolicyWatcher adds the repositories of the RepositoryPolicy resources of a
+// namespace to the configuration. The configuration from the config file or
+// the ConfigMap provides the app settings and the defaults, and the target
+// gets it with the repositories of the accepted policies.
+type PolicyWatcher struct {
+	client    *kube.Client
+	namespace string
+	target    configTarget
+	changed   chan struct{}
+
+	mutex    sync.Mutex
+	base     *configuration.Configuration
+	policies []kube.RepositoryPolicy
+	problems map[string][]*configuration.FieldError
+}
+
+// NewPolicyWatcher returns a watcher for the RepositoryPolicies in the
+// namespace of the pod. The current configuration of target is used as the
+// base configuration.
+func NewPolicyWatcher(target configTarget) (*PolicyWatcher, error) {
+	client, err := kube.NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := kube.InClusterNamespace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the namespace of the pod: %w", err)
+	}
+	return newPolicyWatcher(client, namespace, target), nil
+}
+
+func newPolicyWatcher(client *kube.Client, namespace string, target configTarget) *PolicyWatcher {
+	return &PolicyWatcher{
+		client:    client,
+		namespace: namespace,
+		target:    target,
+		changed:   make(chan struct{}, 1),
+		base:      target.Get(),
+	}
+}
+
+// Get returns the base configuration without the policies.
+func (pw *PolicyWatcher) Get() *configuration.Configuration {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	return pw.base
+}
+
+// Set replaces the base configuration and adds the policies to it again.
+func (pw *PolicyWatcher) Set(cfg *configuration.Configuration) {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	pw.base = cfg
+	pw.apply()
+}
+
+// setPolicies replaces the policies. The configuration is rebuilt only if
+// their specs have changed, the other changes, like the updates of the
+// statuses, only cause the statuses to be checked.
+func (pw *PolicyWatcher) setPolicies(policies []kube.RepositoryPolicy) {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	specsChanged := len(policies) != len(pw.policies)
+	for i := 0; !specsChanged && i < len(policies); i++ {
+		specsChanged = policies[i].Metadata.Name != pw.policies[i].Metadata.Name ||
+			string(policies[i].Spec) != string(pw.policies[i].Spec)
+	}
+	pw.policies = policies
+	if specsChanged {
+		pw.apply()
+		klog.Infof("applied %d repository policies from %s, %d rejected", len(policies)-len(pw.problems), pw.namespace, len(pw.problems))
+	} else {
+		pw.notify()
+	}
+}
+
+// apply sets the base configuration with the repositories of the policies to
+// the target. The mutex must be held.
+func (pw *PolicyWatcher) apply() {
+	repos := make([]configuration.ExternalRepository, 0, len(pw.policies))
+	for _, p := range pw.policies {
+		repos = append(repos, configuration.ExternalRepository{Source: policySource(p), Spec: p.Spec})
+	}
+	cfg, problems := pw.base.WithRepositories(repos)
+	for _, p := range pw.policies {
+		if !reflect.DeepEqual(problems[policySource(p)], pw.problems[policySource(p)]) {
+			for _, err := range problems[policySource(p)] {
+				klog.Warningf("rejected the repository policy: %v", err)
+			}
+		}
+	}
+	pw.problems = problems
+	pw.target.Set(cfg)
+	pw.notify()
+}
+
+// notify wakes up the status updates.
+func (pw *PolicyWatcher) notify() {
+	select {
+	case pw.changed <- struct{}{}:
+	default:
+	}
+}
+
+// policyStatus returns the status that the policy should have.
+func policyStatus(p kube.RepositoryPolicy, problems []*configuration.FieldError, now time.Time) kube.RepositoryPolicyStatus {
+	condition := kube.Condition{
+		Type:   policyConditionAccepted,
+		Status: "True",
+		Reason: "Applied",
+	}
+	if len(problems) > 0 {
+		messages := make([]string, 0, len(problems))
+		for _, err := range problems {
+			messages = append(messages, err.Path+": "+err.Message)
+		}
+		condition.Status = "False"
+		condition.Reason = "Invalid"
+		condition.Message = strings.Join(messages, "; ")
+	}
+
+	condition.LastTransitionTime = now.UTC().Format(time.RFC3339)
+	for _, c := range p.Status.Conditions {
+		if c.Type == condition.Type && c.Status == condition.Status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+	return kube.RepositoryPolicyStatus{
+		ObservedGeneration: p.Metadata.Generation,
+		Conditions:         []kube.Condition{condition},
+	}
+}
+
+// updateStatuses updates the statuses of the policies that are out of date.
+// A failed update is retried when the policy changes, and a conflict means
+// that it has already changed.
+func (pw *PolicyWatcher) updateStatuses(ctx context.Context) {
+	pw.mutex.Lock()
+	policies, problems := pw.policies, pw.problems
+	pw.mutex.Unlock()
+
+	for _, p := range policies {
+		status := policyStatus(p, problems[policySource(p)], time.Now())
+		if reflect.DeepEqual(status, p.Status) {
+			continue
+		}
+		p.Status = status
+		if err := pw.client.UpdateRepositoryPolicyStatus(ctx, &p); err != nil {
+			klog.Errorf("failed to update the status of %s: %v", policySource(p), err)
+		}
+	}
+}
+
+// Load reads the current policies.
+func (pw *PolicyWatcher) Load(ctx context.Context) error {
+	policies, _, err := pw.client.ListRepositoryPolicies(ctx, pw.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list the repository policies in %s: %w", pw.namespace, err)
+	}
+	pw.setPolicies(policies)
+	return nil
+}
+
+// Watch applies the changes of the policies to the target and updates their
+// statuses until ctx is done.
+func (pw *PolicyWatcher) Watch(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pw.changed:
+				pw.updateStatuses(ctx)
+			}
+		}
+	}()
+	pw.client.WatchRepositoryPolicies(ctx, pw.namespace, pw.setPolicies)
+}
+
+// customResourceDefinition returns the CustomResourceDefinition of
+// RepositoryPolicy. The spec is validated by the API server against the
+// schema of the repositories, the rest is checked by the app and reported in
+// the status.
+func customResourceDefinition() ([]byte, error) {
+	str := map[string]interface{}{"type": "string"}
+	crd := map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata": map[string]interface{}{
+			"name": kube.PolicyResource + "." + kube.PolicyGroup,
+		},
+		"spec": map[string]interface{}{
+			"group": kube.PolicyGroup,
+			"scope": "Namespaced",
+			"names": map[string]interface{}{
+				"kind":       kube.PolicyKind,
+				"listKind":   kube.PolicyKind + "List",
+				"plural":     kube.PolicyResource,
+				"singular":   strings.ToLower(kube.PolicyKind),
+				"shortNames": []string{"repopolicy"},
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":         kube.PolicyVersion,
+					"served":       true,
+					"storage":      true,
+					"subresources": map[string]interface{}{"status": map[string]interface{}{}},
+					"additionalPrinterColumns": []interface{}{
+						map[string]interface{}{"name": "Owner", "type": "string", "jsonPath": ".spec.owner"},
+						map[string]interface{}{"name": "Repo", "type": "string", "jsonPath": ".spec.repo"},
+						map[string]interface{}{"name": policyConditionAccepted, "type": "string", "jsonPath": `.status.conditions[?(@.type=="` + policyConditionAccepted + `")].status`},
+						map[string]interface{}{"name": "Age", "type": "date", "jsonPath": ".metadata.creationTimestamp"},
+					},
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"spec"},
+							"properties": map[string]interface{}{
+								"spec": configuration.RepositoryResourceSchema(),
+								"status": map[string]interface{}{
+									"type": "object",
+									"properties": map[string]interface{}{
+										"observedGeneration": map[string]interface{}{"type": "integer"},
+										"conditions": map[string]interface{}{
+											"type": "array",
+											"items": map[string]interface{}{
+												"type":     "object",
+												"required": []string{"type", "status"},
+												"properties": map[string]interface{}{
+													"type":               str,
+													"status":             str,
+													"reason":             str,
+													"message":            str,
+													"lastTransitionTime": map[string]interface{}{"type": "string", "format": "date-time"},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	return yaml.Marshal(crd)
+}
+
+// runCRD writes the CustomResourceDefinition of RepositoryPolicy to stdout.
+func runCRD() {
+	buf, err := customResourceDefinition()
+	if err != nil {
+		klog.Exit(err)
+	}
+	os.Stdout.Write(buf)
+}
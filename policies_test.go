@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/kube"
+)
+
+func TestCustomResourceDefinitionIsUpToDate(t *testing.T) {
+	want, err := customResourceDefinition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("repositorypolicy.crd.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("repositorypolicy.crd.yaml is out of date, run make schema")
+	}
+}
+
+func repositoryPolicy(name, spec string) kube.RepositoryPolicy {
+	return kube.RepositoryPolicy{
+		Metadata: kube.ObjectMeta{Name: name, Namespace: "ci", Generation: 2},
+		Spec:     []byte(spec),
+	}
+}
+
+func TestPolicyWatcher(t *testing.T) {
+	store := configuration.NewStore(&configuration.Configuration{AppID: 1, InstallationID: 2})
+	pw := newPolicyWatcher(nil, "ci", store)
+
+	pw.setPolicies([]kube.RepositoryPolicy{
+		repositoryPolicy("clair", `{"owner":"quay","repo":"clair"}`),
+		repositoryPolicy("quay", `{"owner":"quay","repo":"quay","tag_patern":"v*"}`),
+	})
+	if _, ok := store.Get().Repository("quay", "clair"); !ok {
+		t.Errorf("the accepted policy should be added to the configuration")
+	}
+	if _, ok := store.Get().Repository("quay", "quay"); ok {
+		t.Errorf("the rejected policy should not be added to the configuration")
+	}
+	if len(pw.Get().Repositories) != 0 {
+		t.Errorf("the base configuration should not have the policies")
+	}
+
+	pw.Set(&configuration.Configuration{AppID: 1, InstallationID: 2, Repositories: []configuration.Repository{{Owner: "quay", Repo: "clair"}}})
+	if got := pw.problems["RepositoryPolicy ci/clair"]; len(got) != 1 {
+		t.Errorf("the policy should conflict with the new base configuration, got %v", got)
+	}
+	if len(store.Get().Repositories) != 1 {
+		t.Errorf("got %d repositories, want 1", len(store.Get().Repositories))
+	}
+}
+
+func TestPolicyStatus(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := repositoryPolicy("quay", `{"owner":"quay","repo":"quay"}`)
+
+	status := policyStatus(p, nil, now)
+	if status.ObservedGeneration != 2 || len(status.Conditions) != 1 {
+		t.Fatalf("got %+v", status)
+	}
+	if c := status.Conditions[0]; c.Type != "Accepted" || c.Status != "True" || c.LastTransitionTime != "2022-03-01T12:00:00Z" {
+		t.Errorf("got condition %+v", c)
+	}
+
+	p.Status = status
+	if got := policyStatus(p, nil, now.Add(time.Hour)); got.Conditions[0].LastTransitionTime != "2022-03-01T12:00:00Z" {
+		t.Errorf("the transition time should be kept, got %s", got.Conditions[0].LastTransitionTime)
+	}
+
+	problems := []*configuration.FieldError{
+		{File: "RepositoryPolicy ci/quay", Path: "spec.tag_patern", Message: "unknown field"},
+		{File: "RepositoryPolicy ci/quay", Path: "spec.jira.key", Message: "is required"},
+	}
+	c := policyStatus(p, problems, now.Add(time.Hour)).Conditions[0]
+	if c.Status != "False" || c.Reason != "Invalid" || c.LastTransitionTime != "2022-03-01T13:00:00Z" {
+		t.Errorf("got condition %+v", c)
+	}
+	if want := "spec.tag_patern: unknown field; spec.jira.key: is required"; c.Message != want {
+		t.Errorf("got message %q, want %q", c.Message, want)
+	}
+}
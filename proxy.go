@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newOutboundTransport returns the transport of the requests to GitHub and
+// Jira. The requests go through proxy, except for the hosts of noProxy, which
+// has the format of NO_PROXY. If proxy is empty, the proxy is taken from the
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+func newOutboundTransport(proxy, noProxy string) (*http.Transport, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy == "" {
+		tr.Proxy = http.ProxyFromEnvironment
+		return tr, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q, expected a URL like http://proxy.example.com:3128", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q, the scheme should be http, https or socks5", proxy)
+	}
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  proxy,
+		HTTPSProxy: proxy,
+		NoProxy:    noProxy,
+	}).ProxyFunc()
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	return tr, nil
+}
@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tb := NewTokenBucket(2, 3)
+	tb.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if delay := tb.reserve(); delay != 0 {
+			t.Errorf("request %d within the burst: got delay %s, want 0", i, delay)
+		}
+	}
+	if delay, want := tb.reserve(), 500*time.Millisecond; delay != want {
+		t.Errorf("got delay %s, want %s", delay, want)
+	}
+	if delay, want := tb.reserve(), time.Second; delay != want {
+		t.Errorf("got delay %s, want %s", delay, want)
+	}
+
+	now = now.Add(10 * time.Second)
+	if delay := tb.reserve(); delay != 0 {
+		t.Errorf("after refill: got delay %s, want 0", delay)
+	}
+}
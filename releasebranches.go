@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// createReleaseBranches creates the branches for the new y-stream version in
+// all repositories that have a release branch template. The repositories are
+// processed in the order of the configuration, so repositories that release
+// branches are synced from should be listed before the repositories that sync
+// from them.
+func (r reactor) createReleaseBranches(ctx context.Context, cfg *configuration.Configuration, version string) error {
+	if !configuration.ValidYStream(version) {
+		return fmt.Errorf("invalid y-stream %q", version)
+	}
+	var errs []error
+	for _, repo := range cfg.ExplicitRepositories() {
+		if repo.ReleaseBranch == nil {
+			continue
+		}
+		if err := r.createReleaseBranch(ctx, repo, version); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create the %s release branch in %s/%s: %w", version, repo.Owner, repo.Repo, err))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+func (r reactor) createReleaseBranch(ctx context.Context, repo configuration.Repository, version string) error {
+	branch := repo.ReleaseBranch.ForVersion(version)
+	dest := configuration.BranchReference{
+		Owner:  repo.Owner,
+		Repo:   repo.Repo,
+		Branch: branch.Name,
+	}
+
+	_, resp, err := r.client.Git.GetRef(ctx, dest.Owner, dest.Repo, "heads/"+dest.Branch)
+	if err == nil {
+		klog.V(2).Infof("%srelease branch %s already exists", logctx.Prefix(ctx), dest)
+		return nil
+	} else if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to get destination ref: %w", err)
+	}
+
+	src := configuration.BranchReference{
+		Owner:  repo.Owner,
+		Repo:   repo.Repo,
+		Branch: repo.ReleaseBranch.FromOrDefault(),
+	}
+	if syncFrom, ok := repo.SyncSource(branch); ok {
+		src = syncFrom
+	}
+	sourceRef, _, err := r.client.Git.GetRef(ctx, src.Owner, src.Repo, "heads/"+src.Branch)
+	if err != nil {
+		return fmt.Errorf("failed to get source ref %s: %w", src, err)
+	}
+	sourceSHA := sourceRef.GetObject().GetSHA()
+
+	klog.V(2).Infof("%screating release branch %s from %s (%s)...", logctx.Prefix(ctx), dest, src, sourceSHA)
+	_, _, err = r.client.Git.CreateRef(ctx, dest.Owner, dest.Repo, &github.Reference{
+		Ref: github.String("refs/heads/" + dest.Branch),
+		Object: &github.GitObject{
+			SHA: github.String(sourceSHA),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	r.activity.Record(dest.Owner, dest.Repo, activity.Event{
+		Type:    activity.TypeBranch,
+		Branch:  dest.Branch,
+		Summary: fmt.Sprintf("Created the release branch %s from %s (%s)", dest.Branch, src, sourceSHA),
+	})
+	if branch.SyncFrom.Branch != "" {
+		r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Synced", fmt.Sprintf("created from %s, commit: %s", src, sourceSHA))
+	}
+	return nil
+}
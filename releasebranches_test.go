@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestCreateReleaseBranches(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay:heads/master"] = ref("quay-master")
+	gh.Refs["quay/quay-ui:heads/main"] = ref("ui-main")
+	gh.Refs["quay/quay-ui:heads/release-3.10"] = ref("ui-release")
+	gh.Refs["quay/quay-docs:heads/redhat-3.10"] = ref("existing")
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{
+			{
+				Owner: "quay",
+				Repo:  "quay",
+				ReleaseBranch: &configuration.ReleaseBranch{
+					Branch: configuration.Branch{Name: "redhat-{version}", Version: "{version}"},
+				},
+			},
+			{
+				// The release branch is created from the branch that it is
+				// synced from.
+				Owner: "quay",
+				Repo:  "quay-ui",
+				ReleaseBranch: &configuration.ReleaseBranch{
+					Branch: configuration.Branch{
+						Name:     "redhat-{version}",
+						SyncFrom: configuration.BranchReference{Branch: "release-{version}"},
+					},
+					From: "main",
+				},
+			},
+			{
+				Owner: "quay",
+				Repo:  "quay-docs",
+				ReleaseBranch: &configuration.ReleaseBranch{
+					Branch: configuration.Branch{Name: "redhat-{version}"},
+				},
+			},
+			{
+				Owner: "quay",
+				Repo:  "quay-builder",
+			},
+		},
+	})
+
+	if err := r.createReleaseBranches(context.Background(), r.cfg.Get(), "3.10"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"quay/quay:heads/redhat-3.10":      "quay-master",
+		"quay/quay-ui:heads/redhat-3.10":   "ui-release",
+		"quay/quay-docs:heads/redhat-3.10": "existing",
+	} {
+		if got := gh.Refs[key].GetObject().GetSHA(); got != want {
+			t.Errorf("%s: got %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := gh.Refs["quay/quay-builder:heads/redhat-3.10"]; ok {
+		t.Errorf("the branch should not be created in a repository without a release branch template")
+	}
+	if status := r.statusInformer.BranchSyncStatus("quay/quay-ui:redhat-3.10"); status == nil || status.Status != "Synced" {
+		t.Errorf("got the sync status %+v for the synced release branch, want Synced", status)
+	}
+	if page := r.activity.Events("quay", "quay", 1, 10); len(page.Events) != 1 || page.Events[0].Branch != "redhat-3.10" {
+		t.Errorf("got the events %+v, want one for the created branch", page.Events)
+	}
+}
+
+func TestCreateReleaseBranchesErrors(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay-ui:heads/master"] = ref("ui-master")
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{
+			{
+				// The source branch doesn't exist.
+				Owner: "quay",
+				Repo:  "quay",
+				ReleaseBranch: &configuration.ReleaseBranch{
+					Branch: configuration.Branch{Name: "redhat-{version}"},
+					From:   "main",
+				},
+			},
+			{
+				Owner: "quay",
+				Repo:  "quay-ui",
+				ReleaseBranch: &configuration.ReleaseBranch{
+					Branch: configuration.Branch{Name: "redhat-{version}"},
+				},
+			},
+		},
+	})
+
+	if err := r.createReleaseBranches(context.Background(), r.cfg.Get(), "3.x"); err == nil {
+		t.Error("an invalid y-stream should be rejected")
+	}
+	if err := r.createReleaseBranches(context.Background(), r.cfg.Get(), "3.10"); err == nil {
+		t.Error("the missing source branch should be reported")
+	}
+	// The other repositories are still handled.
+	if got := gh.Refs["quay/quay-ui:heads/redhat-3.10"].GetObject().GetSHA(); got != "ui-master" {
+		t.Errorf("got %q for the release branch of quay/quay-ui, want ui-master", got)
+	}
+	if _, ok := gh.Refs["quay/quay:heads/redhat-3.10"]; ok {
+		t.Error("the release branch should not be created without its source")
+	}
+}
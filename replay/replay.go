@@ -0,0 +1,220 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// recordedHeaders are the response headers that are kept in the fixtures.
+// The other headers are dropped, so that the fixtures don't change with
+// every recording and don't contain rate limit or session details.
+var recordedHeaders = []string{"Content-Type", "Link"}
+
+// Interaction is a recorded request and its response. Request headers are
+// never recorded, so the fixtures don't contain credentials.
+type Interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+// Cassette is the content of a fixture file. Values hold the inputs of the
+// test that produced the interactions, e.g. the pull request that was
+// checked, so that the replay uses the same inputs.
+type Cassette struct {
+	Values       map[string]string `json:"values,omitempty"`
+	Interactions []Interaction     `json:"interactions"`
+}
+
+// requestKey is what a replayed request is matched on. The host is not part
+// of it, so the fixtures don't depend on the API endpoints.
+func requestKey(req *http.Request) string {
+	return req.Method + " " + req.URL.RequestURI()
+}
+
+func readBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, nil
+	}
+	buf, err := io.ReadAll(body)
+	body.Close()
+	return buf, io.NopCloser(bytes.NewReader(buf)), err
+}
+
+// Recorder is a transport that sends the requests with Base and records the
+// interactions.
+type Recorder struct {
+	Base http.RoundTripper
+
+	mutex    sync.Mutex
+	cassette Cassette
+}
+
+func (r *Recorder) base() http.RoundTripper {
+	if r.Base != nil {
+		return r.Base
+	}
+	return http.DefaultTransport
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+
+	resp, err := r.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, body, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = body
+
+	interaction := Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(reqBody),
+		Status:      resp.StatusCode,
+		Body:        string(respBody),
+	}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if interaction.Header == nil {
+				interaction.Header = http.Header{}
+			}
+			interaction.Header.Set(name, value)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	return resp, nil
+}
+
+// SetValue stores a test input in the cassette.
+func (r *Recorder) SetValue(key, value string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cassette.Values == nil {
+		r.cassette.Values = map[string]string{}
+	}
+	r.cassette.Values[key] = value
+}
+
+// Save writes the recorded interactions to the fixture file.
+func (r *Recorder) Save(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	buf, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// Replayer is a transport that serves the responses from a fixture file.
+// Each recorded interaction is served once, in the recorded order for the
+// requests with the same method and URL.
+type Replayer struct {
+	mutex     sync.Mutex
+	cassette  Cassette
+	used      []bool
+	unmatched []string
+}
+
+// Load reads the fixture file.
+func Load(path string) (*Replayer, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(buf, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &Replayer{
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+// Value returns the test input that was stored while recording.
+func (r *Replayer) Value(key string) string {
+	return r.cassette.Values[key]
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := requestKey(req)
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		recorded, err := http.NewRequest(interaction.Method, interaction.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recorded request %s %s: %w", interaction.Method, interaction.URL, err)
+		}
+		if requestKey(recorded) != key {
+			continue
+		}
+		r.used[i] = true
+
+		header := http.Header{}
+		for name, values := range interaction.Header {
+			header[name] = values
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Body)),
+			ContentLength: int64(len(interaction.Body)),
+			Request:       req,
+		}, nil
+	}
+	r.unmatched = append(r.unmatched, key)
+	return nil, fmt.Errorf("replay: no recorded response for %s", key)
+}
+
+// Unused returns the recorded interactions that have not been replayed.
+func (r *Replayer) Unused() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var unused []string
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction.Method+" "+interaction.URL)
+		}
+	}
+	return unused
+}
+
+// Unmatched returns the requests that had no recorded response.
+func (r *Replayer) Unmatched() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.unmatched...)
+}
@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Errorf("the request should be sent with the credentials")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		switch r.URL.Path {
+		case "/issues/1/comments":
+			_, _ = io.WriteString(w, `[{"id": 1}]`)
+		case "/issues/comments/1":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	send := func(client *http.Client, method, path string) (int, string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "token secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	recorder := &Recorder{}
+	recorder.SetValue("pull_request", "quay/quay#1")
+	client := &http.Client{Transport: recorder}
+	send(client, "GET", "/issues/1/comments")
+	send(client, "DELETE", "/issues/comments/1")
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayer.Value("pull_request"); got != "quay/quay#1" {
+		t.Errorf("got value %q, want quay/quay#1", got)
+	}
+	if header := replayer.cassette.Interactions[0].Header; header.Get("X-RateLimit-Remaining") != "" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected recorded headers: %v", header)
+	}
+	if body := replayer.cassette.Interactions[0].RequestBody; body != "{}" {
+		t.Errorf("got recorded request body %q, want {}", body)
+	}
+
+	client = &http.Client{Transport: replayer}
+	if status, body := send(client, "GET", "/issues/1/comments"); status != http.StatusOK || body != `[{"id": 1}]` {
+		t.Errorf("got %d %s, want the recorded response", status, body)
+	}
+	if unused := replayer.Unused(); !reflect.DeepEqual(unused, []string{"DELETE " + server.URL + "/issues/comments/1"}) {
+		t.Errorf("unexpected unused interactions: %v", unused)
+	}
+	if status, _ := send(client, "DELETE", "/issues/comments/1"); status != http.StatusNoContent {
+		t.Errorf("got status %d, want 204", status)
+	}
+
+	req, _ := http.NewRequest("DELETE", server.URL+"/issues/comments/1", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Errorf("each interaction should be replayed once")
+	}
+	if unmatched := replayer.Unmatched(); !reflect.DeepEqual(unmatched, []string{"DELETE /issues/comments/1"}) {
+		t.Errorf("unexpected unmatched requests: %v", unmatched)
+	}
+}
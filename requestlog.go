@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+// statusRecorder remembers the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// logRequests logs every request with its response status and duration. The
+// webhook deliveries are logged at a lower verbosity than the other requests,
+// e.g. the probes, and their delivery ID is added to the request context.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		delivery := r.Header.Get("X-GitHub-Delivery")
+		if delivery != "" {
+			r = r.WithContext(logctx.WithDelivery(r.Context(), delivery))
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		duration := time.Since(start).Round(time.Millisecond)
+		if delivery != "" {
+			klog.V(2).Infof("%s %s from %s: %d in %s (delivery: %s, event: %s)", r.Method, r.URL.Path, r.RemoteAddr, recorder.status, duration, delivery, r.Header.Get("X-GitHub-Event"))
+		} else {
+			klog.V(4).Infof("%s %s from %s: %d in %s", r.Method, r.URL.Path, r.RemoteAddr, recorder.status, duration)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quay/quay-ci-app/logctx"
+)
+
+func TestLogRequests(t *testing.T) {
+	var delivery string
+	handler := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivery = logctx.Delivery(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	req.Header.Set("X-GitHub-Event", "push")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("got %d, want 204", w.Code)
+	}
+	if delivery != "72d3162e-cc78-11e3-81ab-4c9367dc0958" {
+		t.Errorf("got the delivery %q from the context", delivery)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if delivery != "" {
+		t.Errorf("got the delivery %q for a request without one", delivery)
+	}
+}
+
+func TestLogPrefix(t *testing.T) {
+	if prefix := logctx.Prefix(context.Background()); prefix != "" {
+		t.Errorf("got %q without a delivery", prefix)
+	}
+	ctx := logctx.WithDelivery(context.Background(), "abc")
+	if prefix := logctx.Prefix(eventContext(ctx, "push", "{}")); prefix != "[delivery abc] " {
+		t.Errorf("got %q", prefix)
+	}
+}
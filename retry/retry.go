@@ -0,0 +1,243 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ShouldRetryFunc decides if the request should be retried after it failed
+// with err or got resp.
+type ShouldRetryFunc func(resp *http.Response, err error) bool
+
+// ServerErrors retries network errors and 5xx responses. The requests that
+// were cancelled or timed out by their context are not retried.
+func ServerErrors(resp *http.Response, err error) bool {
+	if err != nil {
+		return !isContextError(err)
+	}
+	return resp.StatusCode >= 500
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// SecondaryRateLimitDelay is how long to wait after hitting a GitHub
+// secondary rate limit that doesn't come with a Retry-After header.
+const SecondaryRateLimitDelay = time.Minute
+
+// GitHubErrors retries network errors, 502/503/504 responses, responses that
+// ask the client to come back later with a Retry-After header and secondary
+// rate limit errors.
+func GitHubErrors(resp *http.Response, err error) bool {
+	if err != nil {
+		return !isContextError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		_, ok := GitHubRetryAfter(resp, time.Now())
+		return ok
+	}
+	return false
+}
+
+// isSecondaryRateLimit checks if resp is a GitHub secondary rate limit
+// error. The body of resp is preserved.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	if resp.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	message := strings.ToLower(string(body))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse detection")
+}
+
+// GitHubRetryAfter is like RetryAfter, but it also recognizes secondary rate
+// limit errors without the Retry-After header and asks to wait for
+// SecondaryRateLimitDelay.
+func GitHubRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if delay, ok := RetryAfter(resp, now); ok {
+		return delay, true
+	}
+	if isSecondaryRateLimit(resp) {
+		return SecondaryRateLimitDelay, true
+	}
+	return 0, false
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of resp.
+// The header can be either a number of seconds or an HTTP date.
+func RetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// Transport retries failed requests with exponential backoff. Requests with a
+// body are retried only if the body can be replayed (i.e. GetBody is set).
+// Requests that are not idempotent, like POST, are only retried if they were
+// never sent or the server refused them with a rate limit, so that a comment
+// or a transition isn't made twice; see Idempotent.
+// If a response has the Retry-After header (or RetryAfter returns a delay for
+// it), the delay is used instead of the backoff. Responses that ask to wait
+// longer than MaxRetryAfter are returned to the caller as is.
+type Transport struct {
+	Base           http.RoundTripper
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxRetryAfter  time.Duration
+	ShouldRetry    ShouldRetryFunc
+	RetryAfter     func(resp *http.Response, now time.Time) (time.Duration, bool)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) shouldRetry(resp *http.Response, err error) bool {
+	if t.ShouldRetry == nil {
+		return ServerErrors(resp, err)
+	}
+	return t.ShouldRetry(resp, err)
+}
+
+func (t *Transport) retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if t.RetryAfter == nil {
+		return RetryAfter(resp, now)
+	}
+	return t.RetryAfter(resp, now)
+}
+
+// Idempotent reports whether req can be sent again after the server may have
+// processed it: its method is idempotent, or it has an Idempotency-Key header
+// like net/http recognizes.
+func Idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// unprocessed reports whether the request that failed with err or got resp
+// was not processed by the server: it was never written, or the server
+// refused it with a rate limit.
+func unprocessed(resp *http.Response, err error, written bool) bool {
+	if err != nil {
+		return !written
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden
+}
+
+// trackWrite returns req with a trace that sets written once the request is
+// written to the connection.
+func trackWrite(req *http.Request, written *int32) *http.Request {
+	trace := &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			atomic.StoreInt32(written, 1)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.InitialBackoff
+	idempotent := Idempotent(req)
+	for attempt := 0; ; attempt++ {
+		var written int32
+		sent := req
+		if !idempotent {
+			sent = trackWrite(req, &written)
+		}
+		resp, err := t.base().RoundTrip(sent)
+		if attempt >= t.MaxRetries || req.Context().Err() != nil || !t.shouldRetry(resp, err) {
+			return resp, err
+		}
+		if !idempotent && !unprocessed(resp, err, atomic.LoadInt32(&written) == 1) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		delay := backoff
+		if err != nil {
+			klog.V(4).Infof("%s %s failed, retrying in %s: %v", req.Method, req.URL.Redacted(), delay, err)
+		} else {
+			if retryAfter, ok := t.retryAfter(resp, time.Now()); ok {
+				if t.MaxRetryAfter > 0 && retryAfter > t.MaxRetryAfter {
+					return resp, err
+				}
+				delay = retryAfter
+			}
+			klog.V(4).Infof("%s %s failed with %s, retrying in %s", req.Method, req.URL.Redacted(), resp.Status, delay)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if t.MaxBackoff > 0 && backoff > t.MaxBackoff {
+			backoff = t.MaxBackoff
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
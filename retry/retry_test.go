@@ -0,0 +1,288 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	testCases := []struct {
+		name         string
+		failures     int
+		maxRetries   int
+		wantStatus   int
+		wantRequests int
+	}{
+		{
+			name:         "no failures",
+			failures:     0,
+			maxRetries:   3,
+			wantStatus:   http.StatusOK,
+			wantRequests: 1,
+		},
+		{
+			name:         "recovers after failures",
+			failures:     2,
+			maxRetries:   3,
+			wantStatus:   http.StatusOK,
+			wantRequests: 3,
+		},
+		{
+			name:         "retries are exhausted",
+			failures:     5,
+			maxRetries:   3,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantRequests: 4,
+		},
+	}
+	for _, tc := range testCases {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "payload" {
+				t.Errorf("%s: unexpected body %q", tc.name, body)
+			}
+			if requests <= tc.failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		client := &http.Client{
+			Transport: &Transport{
+				MaxRetries:     tc.maxRetries,
+				InitialBackoff: time.Millisecond,
+			},
+		}
+		req, err := http.NewRequest(http.MethodPut, server.URL, bytes.NewBufferString("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("%s: got status %d, want %d", tc.name, resp.StatusCode, tc.wantStatus)
+			}
+		}
+		if requests != tc.wantRequests {
+			t.Errorf("%s: got %d requests, want %d", tc.name, requests, tc.wantRequests)
+		}
+
+		server.Close()
+	}
+}
+
+func TestTransportNotIdempotent(t *testing.T) {
+	testCases := []struct {
+		name         string
+		status       int
+		retryAfter   string
+		key          bool
+		wantRequests int
+	}{
+		{name: "server error", status: http.StatusBadGateway, wantRequests: 1},
+		{name: "server error with an idempotency key", status: http.StatusBadGateway, key: true, wantRequests: 2},
+		{name: "rate limit", status: http.StatusTooManyRequests, retryAfter: "0", wantRequests: 2},
+	}
+	for _, tc := range testCases {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.status)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		client := &http.Client{
+			Transport: &Transport{
+				MaxRetries:     3,
+				InitialBackoff: time.Millisecond,
+				ShouldRetry:    GitHubErrors,
+			},
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.key {
+			req.Header.Set("Idempotency-Key", "1")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else {
+			resp.Body.Close()
+		}
+		if requests != tc.wantRequests {
+			t.Errorf("%s: got %d requests, want %d", tc.name, requests, tc.wantRequests)
+		}
+		server.Close()
+	}
+}
+
+func TestTransportNotIdempotentNetworkError(t *testing.T) {
+	// The connection is closed after the request is read, so the request was
+	// sent and may have been processed.
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			Base:           &http.Transport{DisableKeepAlives: true},
+			MaxRetries:     3,
+			InitialBackoff: time.Millisecond,
+		},
+	}
+	if _, err := client.Post(server.URL, "text/plain", bytes.NewBufferString("payload")); err == nil {
+		t.Fatal("want an error for the closed connection")
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, the sent request should not be retried", requests)
+	}
+
+	// The request can't be sent to a closed server.
+	attempts := 0
+	client.Transport.(*Transport).Base = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if _, err := client.Post(closed.URL, "text/plain", bytes.NewBufferString("payload")); err == nil {
+		t.Fatal("want an error for the closed server")
+	}
+	if attempts != 4 {
+		t.Errorf("got %d attempts, the request that was never sent should be retried", attempts)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestServerErrorsContext(t *testing.T) {
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded, fmt.Errorf("Get: %w", context.Canceled)} {
+		if ServerErrors(nil, err) || GitHubErrors(nil, err) {
+			t.Errorf("%v: the context errors should not be retried", err)
+		}
+	}
+	if !ServerErrors(nil, io.ErrUnexpectedEOF) {
+		t.Errorf("the network errors should be retried")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{header: "", wantOK: false},
+		{header: "30", want: 30 * time.Second, wantOK: true},
+		{header: "-1", wantOK: false},
+		{header: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute, wantOK: true},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{header: "soon", wantOK: false},
+	}
+	for _, tc := range testCases {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+		got, ok := RetryAfter(resp, now)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%q: got %s, %t, want %s, %t", tc.header, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestGitHubErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		want       bool
+	}{
+		{name: "ok", status: http.StatusOK, want: false},
+		{name: "not found", status: http.StatusNotFound, want: false},
+		{name: "internal server error", status: http.StatusInternalServerError, want: false},
+		{name: "bad gateway", status: http.StatusBadGateway, want: true},
+		{name: "service unavailable", status: http.StatusServiceUnavailable, want: true},
+		{name: "forbidden", status: http.StatusForbidden, want: false},
+		{name: "abuse rate limit", status: http.StatusForbidden, retryAfter: "60", want: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, retryAfter: "1", want: true},
+		{name: "secondary rate limit", status: http.StatusForbidden, body: `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`, want: true},
+		{name: "resource not accessible", status: http.StatusForbidden, body: `{"message":"Resource not accessible by integration"}`, want: false},
+	}
+	for _, tc := range testCases {
+		resp := &http.Response{StatusCode: tc.status, Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString(tc.body))}
+		if tc.retryAfter != "" {
+			resp.Header.Set("Retry-After", tc.retryAfter)
+		}
+		if got := GitHubErrors(resp, nil); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.name, got, tc.want)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != tc.body {
+			t.Errorf("%s: the body is not preserved: got %q", tc.name, body)
+		}
+	}
+}
+
+func TestTransportMaxRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			MaxRetries:     3,
+			InitialBackoff: time.Millisecond,
+			MaxRetryAfter:  time.Minute,
+			ShouldRetry:    GitHubErrors,
+		},
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
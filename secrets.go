@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/vault"
+	"golang.org/x/oauth2"
+	"k8s.io/klog/v2"
+)
+
+// secret is a secret of the app, the private key or the Jira token. It is read
+// from the file, the environment variable or the secret in Vault that its
+// reference points to, see configuration.ParseSecretRef. The secrets from
+// Vault are read again by refresh, so that the rotated secrets are used
+// without a restart.
+type secret struct {
+	name  string
+	ref   configuration.SecretRef
+	vault *vault.Client
+
+	mutex    sync.Mutex
+	value    []byte
+	onChange []func()
+}
+
+// loadSecret reads the secret that ref points to. client is used for the
+// references to Vault, it may be nil if Vault is not configured. An empty or
+// whitespace-only secret is an error.
+func loadSecret(ctx context.Context, name, ref string, client *vault.Client) (*secret, error) {
+	parsed, err := configuration.ParseSecretRef(ref)
+	if err != nil {
+		return nil, fmt.Errorf("the %s: %w", name, err)
+	}
+	s := &secret{name: name, ref: parsed, vault: client}
+	value, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.value = value
+	return s, nil
+}
+
+func (s *secret) read(ctx context.Context) ([]byte, error) {
+	value, err := s.readValue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// An empty secret would let the requests without a secret in, or fail
+	// every request later.
+	if len(bytes.TrimSpace(value)) == 0 {
+		return nil, fmt.Errorf("the %s is empty", s.name)
+	}
+	return value, nil
+}
+
+func (s *secret) readValue(ctx context.Context) ([]byte, error) {
+	switch s.ref.Source {
+	case configuration.SecretSourceEnv:
+		value, ok := os.LookupEnv(s.ref.Name)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("failed to read the %s: the environment variable %s is not set", s.name, s.ref.Name)
+		}
+		return []byte(value), nil
+	case configuration.SecretSourceVault:
+		if s.vault == nil {
+			return nil, fmt.Errorf("the %s is in Vault, but -vault-addr is not set", s.name)
+		}
+		value, err := s.vault.Read(ctx, s.ref.Path, s.ref.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the %s: %w", s.name, err)
+		}
+		return []byte(value), nil
+	}
+	buf, err := os.ReadFile(s.ref.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s: %w", s.name, err)
+	}
+	return buf, nil
+}
+
+// storeSecret writes value to the file or the secret in Vault that ref points
+// to. The files are created only readable by the owner, an existing file is
+// not overwritten. The secrets can't be stored in environment variables.
+func storeSecret(ctx context.Context, name, ref string, value []byte, client *vault.Client) error {
+	parsed, err := configuration.ParseSecretRef(ref)
+	if err != nil {
+		return fmt.Errorf("the %s: %w", name, err)
+	}
+	switch parsed.Source {
+	case configuration.SecretSourceEnv:
+		return fmt.Errorf("the %s can't be stored in the environment variable %s, use a file or Vault", name, parsed.Name)
+	case configuration.SecretSourceVault:
+		if client == nil {
+			return fmt.Errorf("the %s is in Vault, but -vault-addr is not set", name)
+		}
+		if err := client.Write(ctx, parsed.Path, parsed.Key, string(value)); err != nil {
+			return fmt.Errorf("failed to store the %s: %w", name, err)
+		}
+		return nil
+	}
+	if err := writeSecretFile(parsed.Name, value); err != nil {
+		return fmt.Errorf("failed to store the %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeSecretFile writes data into a new file that only the owner can read.
+// Existing files are not overwritten.
+func writeSecretFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Value returns the current value of the secret.
+func (s *secret) Value() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.value
+}
+
+// OnChange registers fn to be called after refresh gets a new value.
+func (s *secret) OnChange(fn func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+// refresh reads the secret from Vault again. The secrets from files are
+// left alone. If the secret can't be read, the previous value is kept.
+func (s *secret) refresh(ctx context.Context) error {
+	if s.ref.Source != configuration.SecretSourceVault {
+		return nil
+	}
+	value, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	changed := !bytes.Equal(value, s.value)
+	s.value = value
+	onChange := s.onChange
+	s.mutex.Unlock()
+	if changed {
+		klog.Infof("the %s has changed in Vault", s.name)
+		for _, fn := range onChange {
+			fn()
+		}
+	}
+	return nil
+}
+
+// refreshSecrets renews the Vault token and reads the secrets again every
+// interval until ctx is done.
+func refreshSecrets(ctx context.Context, client *vault.Client, interval time.Duration, secrets ...*secret) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := client.Renew(ctx); err != nil {
+			klog.Errorf("failed to renew the Vault token: %v", err)
+		}
+		for _, s := range secrets {
+			if err := s.refresh(ctx); err != nil {
+				klog.Errorf("failed to refresh the %s, keeping the previous one: %v", s.name, err)
+			}
+		}
+	}
+}
+
+// loadAppSecrets reads the private keys of -private-key and the Jira token of
+// -jira-token. If a flag is not set on the command line, the reference in the
+// secrets section of the configuration is used instead of its default.
+func loadAppSecrets(ctx context.Context, refs configuration.Secrets, client *vault.Client) ([]*secret, *secret, error) {
+	keyRefs := configuration.SplitSecretRefs(secretRef("private-key", refs.PrivateKey))
+	var keys []*secret
+	for i, ref := range keyRefs {
+		name := "private key"
+		if len(keyRefs) > 1 {
+			name = fmt.Sprintf("private key %d", i+1)
+		}
+		key, err := loadSecret(ctx, name, ref, client)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("no private key, -private-key is empty")
+	}
+	jiraToken, err := loadSecret(ctx, "jira token", secretRef("jira-token", refs.JiraToken), client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, jiraToken, nil
+}
+
+// secretRef returns the secret reference of the flag if it's set on the
+// command line or configured is empty, and configured otherwise.
+func secretRef(flagName, configured string) string {
+	value := flag.Lookup(flagName).Value.String()
+	if configured == "" {
+		return value
+	}
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == flagName {
+			set = true
+		}
+	})
+	if set {
+		return value
+	}
+	return configured
+}
+
+// newVaultClient returns the client of the Vault server of -vault-addr, or nil
+// if it's not set. The token is taken from VAULT_TOKEN unless -vault-role is
+// set.
+func newVaultClient() *vault.Client {
+	if *vaultAddr == "" {
+		return nil
+	}
+	return vault.New(*vaultAddr, os.Getenv("VAULT_TOKEN"), *vaultRole, *vaultAuthPath)
+}
+
+// secretTokenSource returns the current value of the Jira token secret, so
+// that the requests use the token from the last refresh.
+type secretTokenSource struct {
+	secret *secret
+}
+
+func (s secretTokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: strings.TrimSpace(string(s.secret.Value()))}, nil
+}
+
+// newJiraTokenTransport authenticates the requests with the current value of
+// the Jira token. Unlike oauth2.NewClient, it doesn't reuse the first token:
+// the token doesn't expire, so it would be used until the app is restarted.
+func newJiraTokenTransport(token *secret, base http.RoundTripper) http.RoundTripper {
+	return &oauth2.Transport{
+		Source: secretTokenSource{secret: token},
+		Base:   base,
+	}
+}
+
+// appsTransport authenticates the requests as the app with the current values
+// of the private keys. While a key is rotated, both keys are valid, so the
+// keys are tried in order when GitHub rejects a key, and the last key that
+// worked is used for the next requests.
+type appsTransport struct {
+	base  http.RoundTripper
+	appID int64
+	keys  []*secret
+
+	mutex sync.Mutex
+	// current is the index of the key that worked last.
+	current    int
+	transports []*ghinstallation.AppsTransport
+	values     [][]byte
+}
+
+func newAppsTransport(base http.RoundTripper, appID int64, keys []*secret) (*appsTransport, error) {
+	t := &appsTransport{
+		base:       base,
+		appID:      appID,
+		keys:       keys,
+		transports: make([]*ghinstallation.AppsTransport, len(keys)),
+		values:     make([][]byte, len(keys)),
+	}
+	for i := range keys {
+		if _, err := t.transport(i); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// transport returns the transport of the i-th key, it's created again when
+// the key has changed.
+func (t *appsTransport) transport(i int) (*ghinstallation.AppsTransport, error) {
+	key := t.keys[i].Value()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.transports[i] == nil || !bytes.Equal(key, t.values[i]) {
+		current, err := ghinstallation.NewAppsTransport(t.base, t.appID, key)
+		if err != nil {
+			return nil, fmt.Errorf("the %s: %w", t.keys[i].name, err)
+		}
+		t.transports[i], t.values[i] = current, key
+	}
+	return t.transports[i], nil
+}
+
+func (t *appsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	first := t.current
+	t.mutex.Unlock()
+
+	for n := range t.keys {
+		i := (first + n) % len(t.keys)
+		current, err := t.transport(i)
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		last := n == len(t.keys)-1
+		attempt := req
+		if !last {
+			// The request is sent again with the next key if this one is
+			// rejected.
+			attempt, err = rewindableRequest(req)
+			if err != nil {
+				return nil, err
+			}
+		}
+		resp, err := current.RoundTrip(attempt)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !last {
+			resp.Body.Close()
+			klog.Warningf("GitHub rejected the %s, trying the next key", t.keys[i].name)
+			continue
+		}
+		if err == nil && resp.StatusCode != http.StatusUnauthorized {
+			t.mutex.Lock()
+			t.current = i
+			t.mutex.Unlock()
+		}
+		return resp, err
+	}
+	return nil, fmt.Errorf("no private key")
+}
+
+// rewindableRequest returns a copy of req that can be sent while req can still
+// be sent again.
+func rewindableRequest(req *http.Request) (*http.Request, error) {
+	attempt := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return attempt, nil
+	}
+	if req.GetBody == nil {
+		buf, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	attempt.Body = body
+	return attempt, nil
+}
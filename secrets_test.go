@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/quay/quay-ci-app/vault"
+)
+
+func TestSecrets(t *testing.T) {
+	token := "first"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/quay-ci-app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"jira-token":"` + token + `"},"metadata":{}}}`))
+	}))
+	defer server.Close()
+	client := vault.New(server.URL, "token", "", "kubernetes")
+	ctx := context.Background()
+
+	tokenFile := filepath.Join(t.TempDir(), "jira-token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromFile, err := loadSecret(ctx, "jira token", tokenFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := (secretTokenSource{secret: fromFile}).Token(); got.AccessToken != "from-file" {
+		t.Errorf("got the token %q from the file", got.AccessToken)
+	}
+
+	emptyFile := filepath.Join(t.TempDir(), "jira-webhook-secret")
+	if err := os.WriteFile(emptyFile, []byte(" \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSecret(ctx, "jira webhook secret", emptyFile, nil); err == nil {
+		t.Errorf("want an error for an empty file")
+	}
+
+	t.Setenv("QUAY_CI_APP_JIRA_TOKEN", "from-env")
+	fromEnv, err := loadSecret(ctx, "jira token", "env:QUAY_CI_APP_JIRA_TOKEN", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fromEnv.Value()) != "from-env" {
+		t.Errorf("got the token %q from the environment", fromEnv.Value())
+	}
+	if _, err := loadSecret(ctx, "jira token", "env:QUAY_CI_APP_MISSING", nil); err == nil {
+		t.Errorf("want an error for a missing environment variable")
+	}
+
+	fromVault, err := loadSecret(ctx, "jira token", "vault:secret/data/quay-ci-app#jira-token", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes int
+	fromVault.OnChange(func() { changes++ })
+	if err := fromVault.refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if changes != 0 {
+		t.Errorf("the secret has not changed, got %d changes", changes)
+	}
+	token = "second"
+	if err := fromVault.refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if string(fromVault.Value()) != "second" || changes != 1 {
+		t.Errorf("got %q after %d changes, want the rotated token", fromVault.Value(), changes)
+	}
+
+	// The previous value is kept if the secret is emptied or can't be read.
+	token = ""
+	if err := fromVault.refresh(ctx); err == nil {
+		t.Errorf("want an error for an empty secret")
+	}
+	if string(fromVault.Value()) != "second" {
+		t.Errorf("got %q, want the previous token", fromVault.Value())
+	}
+	fromVault.ref.Path = "secret/data/missing"
+	if err := fromVault.refresh(ctx); err == nil {
+		t.Errorf("want an error for a missing secret")
+	}
+	if string(fromVault.Value()) != "second" {
+		t.Errorf("got %q, want the previous token", fromVault.Value())
+	}
+
+	for _, ref := range []string{"vault:secret/data/quay-ci-app", "vault:#jira-token", "vault:secret/data/quay-ci-app#"} {
+		if _, err := loadSecret(ctx, "jira token", ref, client); err == nil {
+			t.Errorf("%s: want an error for an invalid reference", ref)
+		}
+	}
+	if _, err := loadSecret(ctx, "jira token", "vault:secret/data/quay-ci-app#jira-token", nil); err == nil {
+		t.Errorf("want an error without a Vault client")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestPrivateKeyRotation(t *testing.T) {
+	var keys []*secret
+	var public []*rsa.PublicKey
+	for i := 0; i < 2; i++ {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		keys = append(keys, &secret{name: fmt.Sprintf("private key %d", i+1), value: pemKey})
+		public = append(public, &key.PublicKey)
+	}
+
+	// Only the second key is valid, the first one was deleted.
+	valid := public[1]
+	var minted int
+	github := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		respond := func(status int, body string) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}, Request: req}, nil
+		}
+		authorization := req.Header.Get("Authorization")
+		if req.URL.Path == "/repos/quay/quay" {
+			if authorization != fmt.Sprintf("token token-%d", minted) {
+				return respond(http.StatusUnauthorized, `{}`)
+			}
+			return respond(http.StatusOK, `{}`)
+		}
+		_, err := jwt.Parse(strings.TrimPrefix(authorization, "Bearer "), func(*jwt.Token) (interface{}, error) {
+			return valid, nil
+		})
+		if err != nil {
+			return respond(http.StatusUnauthorized, `{"message":"A JSON web token could not be decoded"}`)
+		}
+		switch req.URL.Path {
+		case "/app":
+			return respond(http.StatusOK, `{}`)
+		case "/app/installations/2/access_tokens":
+			minted++
+			return respond(http.StatusCreated, fmt.Sprintf(`{"token":"token-%d","expires_at":%q}`, minted, time.Now().Add(time.Hour).Format(time.RFC3339)))
+		}
+		return respond(http.StatusNotFound, `{}`)
+	})
+
+	itr, err := newInstallationTransport(context.Background(), func(ctx context.Context) (*ghinstallation.Transport, error) {
+		return newKeyedTransport(ctx, github, 1, 2, keys)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	apptr, err := newAppsTransport(github, 1, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(tr http.RoundTripper, url string) int {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get(itr, "https://api.github.com/repos/quay/quay"); status != http.StatusOK {
+		t.Errorf("the installation token should be minted with the second key, got %d", status)
+	}
+	if status := get(apptr, "https://api.github.com/app"); status != http.StatusOK {
+		t.Errorf("the app requests should fall back to the second key, got %d", status)
+	}
+
+	// The keys are rotated again, the first key is the new one.
+	valid = public[0]
+	minted = 0
+	if err := itr.Reset(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if status := get(itr, "https://api.github.com/repos/quay/quay"); status != http.StatusOK {
+		t.Errorf("the installation token should be minted with the first key, got %d", status)
+	}
+	if status := get(apptr, "https://api.github.com/app"); status != http.StatusOK {
+		t.Errorf("the app requests should fall back to the first key, got %d", status)
+	}
+}
+
+func TestKeyedTransportContext(t *testing.T) {
+	var keys []*secret
+	for i := 0; i < 2; i++ {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		keys = append(keys, &secret{name: fmt.Sprintf("private key %d", i+1), value: pemKey})
+	}
+	// GitHub doesn't respond until the request is cancelled.
+	github := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := newKeyedTransport(ctx, github, 1, 2, keys)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("the first key should be kept if GitHub doesn't reject it, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the key check is not cancelled with its context")
+	}
+}
+
+func TestJiraTokenRotation(t *testing.T) {
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	token := &secret{name: "jira token", value: []byte("first\n")}
+	client := &http.Client{Transport: newJiraTokenTransport(token, http.DefaultTransport)}
+	get := func() {
+		resp, err := client.Get(server.URL + "/rest/api/2/myself")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	token.mutex.Lock()
+	token.value = []byte("second\n")
+	token.mutex.Unlock()
+	get()
+	want := []string{"Bearer first", "Bearer second"}
+	if len(authorizations) != 2 || authorizations[0] != want[0] || authorizations[1] != want[1] {
+		t.Errorf("got the authorizations %q, want %q", authorizations, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"k8s.io/klog/v2"
+)
+
+// maxWebhookBodySize is the largest webhook payload that is read. GitHub caps
+// the payloads at 25 MB.
+const maxWebhookBodySize = 25 << 20
+
+// newServer returns the HTTP server of the app with timeouts, so that slow or
+// stuck clients don't hold the connections forever. The write timeout leaves
+// room for the handlers that sync branches before they respond.
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
+
+// serve runs the server until it fails. If certificates is not nil, the
+// server serves HTTPS.
+func serve(server *http.Server, certificates *CertificateReloader) error {
+	if certificates == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = &tls.Config{
+		GetCertificate: certificates.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+// readWebhookBody reads the payload of the webhook delivery. If the request
+// is not a JSON POST or the payload is too large, it responds with an error
+// and returns false.
+func readWebhookBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		http.Error(w, "the webhook content type should be application/json", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	if r.ContentLength > maxWebhookBodySize {
+		http.Error(w, fmt.Sprintf("the payload is larger than %d bytes", maxWebhookBodySize), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize+1))
+	if err != nil {
+		http.Error(w, "failed to read the payload", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(body) > maxWebhookBodySize {
+		http.Error(w, fmt.Sprintf("the payload is larger than %d bytes", maxWebhookBodySize), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
+}
+
+// verifyWebhookSignature checks the X-Hub-Signature-256 header of the webhook
+// delivery, the HMAC of body with the webhook secret of the app. If the
+// signature is missing or doesn't match, it responds with 401 and returns
+// false.
+func verifyWebhookSignature(w http.ResponseWriter, r *http.Request, body []byte, secret *secret) bool {
+	if err := github.ValidateSignature(r.Header.Get(github.SHA256SignatureHeader), body, bytes.TrimSpace(secret.Value())); err != nil {
+		klog.V(2).Infof("rejecting a webhook from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "the webhook signature is invalid", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
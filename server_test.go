@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadWebhookBody(t *testing.T) {
+	testCases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantCode    int
+	}{
+		{name: "webhook", method: http.MethodPost, contentType: "application/json", body: `{"zen":"Keep it logically awesome."}`, wantCode: http.StatusOK},
+		{name: "charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: `{}`, wantCode: http.StatusOK},
+		{name: "get", method: http.MethodGet, wantCode: http.StatusMethodNotAllowed},
+		{name: "form", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "payload=%7B%7D", wantCode: http.StatusUnsupportedMediaType},
+		{name: "too large", method: http.MethodPost, contentType: "application/json", body: `"` + strings.Repeat("a", maxWebhookBodySize) + `"`, wantCode: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			body, ok := readWebhookBody(w, req)
+			if ok != (tc.wantCode == http.StatusOK) || w.Code != tc.wantCode {
+				t.Fatalf("got %v and %d, want %d", ok, w.Code, tc.wantCode)
+			}
+			if ok && string(body) != tc.body {
+				t.Errorf("got the body %q, want %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := &secret{name: "webhook secret", value: []byte("s3cr3t\n")}
+	body := []byte(`{"zen":"Keep it logically awesome."}`)
+	sign := func(key string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	testCases := []struct {
+		name      string
+		signature string
+		wantCode  int
+	}{
+		{name: "signed", signature: sign("s3cr3t"), wantCode: http.StatusOK},
+		{name: "unsigned", wantCode: http.StatusUnauthorized},
+		{name: "other secret", signature: sign("other"), wantCode: http.StatusUnauthorized},
+		{name: "malformed", signature: "sha256=zz", wantCode: http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tc.signature)
+			}
+			w := httptest.NewRecorder()
+			ok := verifyWebhookSignature(w, req, body, secret)
+			if ok != (tc.wantCode == http.StatusOK) || w.Code != tc.wantCode {
+				t.Errorf("got %v and %d, want %d", ok, w.Code, tc.wantCode)
+			}
+		})
+	}
+}
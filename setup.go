@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/clients"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/vault"
+	"k8s.io/klog/v2"
+)
+
+// AppManifest is the manifest of the GitHub App that is created by the
+// manifest flow.
+//
+// https://docs.github.com/en/developers/apps/building-github-apps/creating-a-github-app-from-a-manifest
+type AppManifest struct {
+	Name               string            `json:"name"`
+	URL                string            `json:"url"`
+	HookAttributes     map[string]string `json:"hook_attributes"`
+	RedirectURL        string            `json:"redirect_url"`
+	Public             bool              `json:"public"`
+	DefaultPermissions map[string]string `json:"default_permissions"`
+	DefaultEvents      []string          `json:"default_events"`
+}
+
+func appManifest(name, publicURL string) AppManifest {
+	publicURL = strings.TrimSuffix(publicURL, "/")
+	return AppManifest{
+		Name: name,
+		URL:  "https://github.com/quay/quay-ci-app",
+		HookAttributes: map[string]string{
+			"url": publicURL + "/",
+		},
+		RedirectURL: publicURL + "/setup/callback",
+		Public:      false,
+		DefaultPermissions: map[string]string{
+			"actions":       "write",
+			"checks":        "write",
+			"contents":      "write",
+			"issues":        "write",
+			"metadata":      "read",
+			"pull_requests": "write",
+		},
+		DefaultEvents: []string{
+			"check_run",
+			"check_suite",
+			"issue_comment",
+			"issues",
+			"pull_request",
+			"pull_request_review",
+			"push",
+			"release",
+			"workflow_run",
+		},
+	}
+}
+
+// appCreationURL returns the GitHub page that creates the app, either for the
+// user that opens the page or for the organization org.
+func appCreationURL(org, state string) string {
+	if org != "" {
+		return fmt.Sprintf("https://github.com/organizations/%s/settings/apps/new?state=%s", org, state)
+	}
+	return "https://github.com/settings/apps/new?state=" + state
+}
+
+var setupTemplate = template.Must(template.New("setup").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Quay CI App setup</title></head>
+<body>
+<form action="{{.Action}}" method="post">
+<input type="hidden" name="manifest" value="{{.Manifest}}">
+<p>The app will send webhooks to <code>{{.WebhookURL}}</code>.</p>
+<input type="submit" value="Create GitHub App">
+</form>
+</body>
+</html>
+`))
+
+var setupDoneTemplate = template.Must(template.New("done").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Quay CI App setup</title></head>
+<body>
+<p>The GitHub App <a href="{{.HTMLURL}}">{{.Name}}</a> has been created. Its credentials have been stored.</p>
+<p>Install the app on your repositories, then add the following to your config file and restart quay-ci-app without <code>-setup</code>:</p>
+<pre>app_id: {{.ID}}
+installation_id: # the ID from the URL of the installation page
+</pre>
+</body>
+</html>
+`))
+
+// AppSetup serves the GitHub App manifest flow: it redirects the user to
+// GitHub with the app manifest, exchanges the code that GitHub sends back for
+// the app credentials and stores them.
+type AppSetup struct {
+	client    *clients.GitHub
+	vault     *vault.Client
+	name      string
+	publicURL string
+	org       string
+	state     string
+	secrets   []setupSecret
+}
+
+// setupSecret is a credential of the created app and the secret reference
+// that it is stored to, see configuration.ParseSecretRef.
+type setupSecret struct {
+	name  string
+	ref   string
+	value func(*github.AppConfig) string
+}
+
+// NewAppSetup returns the setup of the app name. The app ID, the private key
+// and the webhook secret of the created app are stored to the secret
+// references appIDRef, privateKeyRef and webhookSecretRef, vaultClient is
+// used for the references to Vault and may be nil. The app ID is only shown
+// if appIDRef is empty.
+func NewAppSetup(name, publicURL, org, appIDRef, privateKeyRef, webhookSecretRef string, vaultClient *vault.Client) (*AppSetup, error) {
+	secrets := []setupSecret{
+		{name: "private key", ref: privateKeyRef, value: (*github.AppConfig).GetPEM},
+		{name: "webhook secret", ref: webhookSecretRef, value: (*github.AppConfig).GetWebhookSecret},
+	}
+	if appIDRef != "" {
+		secrets = append(secrets, setupSecret{name: "app ID", ref: appIDRef, value: func(appConfig *github.AppConfig) string {
+			return strconv.FormatInt(appConfig.GetID(), 10)
+		}})
+	}
+	// The references are checked before the app is created, its credentials
+	// can't be retrieved again.
+	for _, secret := range secrets {
+		ref, err := configuration.ParseSecretRef(secret.ref)
+		if err != nil {
+			return nil, fmt.Errorf("the %s: %w", secret.name, err)
+		}
+		if ref.Source == configuration.SecretSourceEnv {
+			return nil, fmt.Errorf("the %s can't be stored in the environment variable %s, use a file or Vault", secret.name, ref.Name)
+		}
+		if ref.Source == configuration.SecretSourceVault && vaultClient == nil {
+			return nil, fmt.Errorf("the %s is in Vault, but -vault-addr is not set", secret.name)
+		}
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate state: %w", err)
+	}
+	return &AppSetup{
+		client:    clients.NewGitHub(github.NewClient(nil)),
+		vault:     vaultClient,
+		name:      name,
+		publicURL: publicURL,
+		org:       org,
+		state:     hex.EncodeToString(buf),
+		secrets:   secrets,
+	}, nil
+}
+
+func (s *AppSetup) serveManifest(w http.ResponseWriter, r *http.Request) {
+	manifest := appManifest(s.name, s.publicURL)
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		klog.Errorf("failed to encode app manifest: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = setupTemplate.Execute(w, map[string]string{
+		"Action":     appCreationURL(s.org, s.state),
+		"Manifest":   string(buf),
+		"WebhookURL": manifest.HookAttributes["url"],
+	})
+	if err != nil {
+		klog.Errorf("failed to render setup page: %v", err)
+	}
+}
+
+func (s *AppSetup) serveCallback(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("state")), []byte(s.state)) != 1 {
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		return
+	}
+
+	appConfig, _, err := s.client.Apps.CompleteAppManifest(r.Context(), code)
+	if err != nil {
+		klog.Errorf("failed to complete app manifest: %v", err)
+		http.Error(w, "failed to exchange the code for the app credentials", http.StatusBadGateway)
+		return
+	}
+
+	for _, secret := range s.secrets {
+		if err := storeSecret(r.Context(), secret.name, secret.ref, []byte(secret.value(appConfig)), s.vault); err != nil {
+			klog.Errorf("failed to store the %s of the app %d: %v", secret.name, appConfig.GetID(), err)
+			http.Error(w, "failed to store the "+secret.name, http.StatusInternalServerError)
+			return
+		}
+	}
+	klog.Infof("created GitHub App %s (ID: %d), its credentials are stored", appConfig.GetSlug(), appConfig.GetID())
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = setupDoneTemplate.Execute(w, map[string]interface{}{
+		"ID":      appConfig.GetID(),
+		"Name":    appConfig.GetName(),
+		"HTMLURL": appConfig.GetHTMLURL(),
+	})
+	if err != nil {
+		klog.Errorf("failed to render setup page: %v", err)
+	}
+}
+
+func (s *AppSetup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		s.serveManifest(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/setup/callback":
+		s.serveCallback(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
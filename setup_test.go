@@ -0,0 +1,161 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/clients"
+)
+
+func TestAppManifest(t *testing.T) {
+	manifest := appManifest("Quay CI", "https://ci.example.com/")
+	if got, want := manifest.HookAttributes["url"], "https://ci.example.com/"; got != want {
+		t.Errorf("got webhook URL %q, want %q", got, want)
+	}
+	if got, want := manifest.RedirectURL, "https://ci.example.com/setup/callback"; got != want {
+		t.Errorf("got redirect URL %q, want %q", got, want)
+	}
+	if got, want := manifest.DefaultPermissions["contents"], "write"; got != want {
+		t.Errorf("got contents permission %q, want %q", got, want)
+	}
+}
+
+// handledEvents returns the event types of the cases of the switch in
+// EventHandler.HandleEvent.
+func handledEvents(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "HandleEvent" || fn.Recv == nil {
+			continue
+		}
+		for _, stmt := range fn.Body.List {
+			sw, ok := stmt.(*ast.SwitchStmt)
+			if !ok {
+				continue
+			}
+			for _, clause := range sw.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					if lit, ok := expr.(*ast.BasicLit); ok {
+						event, _ := strconv.Unquote(lit.Value)
+						events = append(events, event)
+					}
+				}
+			}
+		}
+	}
+	if len(events) == 0 {
+		t.Fatal("no events found in HandleEvent")
+	}
+	return events
+}
+
+func TestAppManifestEvents(t *testing.T) {
+	// The installation events are sent to all apps, and repository_dispatch
+	// to the apps with the contents permission, without a subscription.
+	unsubscribed := map[string]bool{
+		"installation":              true,
+		"installation_repositories": true,
+		"repository_dispatch":       true,
+	}
+	manifest := appManifest("Quay CI", "https://ci.example.com/")
+	subscribed := map[string]bool{}
+	for _, event := range manifest.DefaultEvents {
+		subscribed[event] = true
+	}
+	for _, event := range handledEvents(t) {
+		if !subscribed[event] && !unsubscribed[event] {
+			t.Errorf("the app handles the %s event, but the manifest doesn't subscribe to it", event)
+		}
+	}
+	if got := manifest.DefaultPermissions["actions"]; got != "write" {
+		t.Errorf("got actions permission %q, the flaky workflows can't be re-run", got)
+	}
+}
+
+func TestAppCreationURL(t *testing.T) {
+	if got, want := appCreationURL("", "abc"), "https://github.com/settings/apps/new?state=abc"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := appCreationURL("quay", "abc"), "https://github.com/organizations/quay/settings/apps/new?state=abc"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAppSetupCallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app-manifests/abc/conversions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":42,"slug":"quay-ci","name":"Quay CI","pem":"PEM","webhook_secret":"secret"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	privateKey := filepath.Join(dir, "private-key.pem")
+	webhookSecret := filepath.Join(dir, "webhook-secret")
+	appID := filepath.Join(dir, "app-id")
+	s, err := NewAppSetup("Quay CI", "https://ci.example.com", "", appID, privateKey, "file:"+webhookSecret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	s.client = clients.NewGitHub(client)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/setup/callback?state=wrong&code=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for a wrong state, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/setup/callback?state="+s.state+"&code=abc", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	for name, want := range map[string]string{privateKey: "PEM", webhookSecret: "secret", appID: "42"} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Errorf("the credential should be stored: %v", err)
+		} else if string(got) != want {
+			t.Errorf("%s: got %q, want %q", filepath.Base(name), got, want)
+		}
+	}
+
+	// The stored credentials are not overwritten.
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/setup/callback?state="+s.state+"&code=abc", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d for the existing files, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestNewAppSetupRefs(t *testing.T) {
+	for _, tc := range []struct {
+		name                             string
+		appID, privateKey, webhookSecret string
+	}{
+		{name: "environment variable", privateKey: "env:PRIVATE_KEY", webhookSecret: "webhook-secret"},
+		{name: "vault without a client", privateKey: "private-key.pem", webhookSecret: "vault:secret/data/quay-ci-app#webhook-secret"},
+		{name: "invalid reference", appID: "vault:secret/data/quay-ci-app", privateKey: "private-key.pem", webhookSecret: "webhook-secret"},
+	} {
+		if _, err := NewAppSetup("Quay CI", "https://ci.example.com", "", tc.appID, tc.privateKey, tc.webhookSecret, nil); err == nil {
+			t.Errorf("%s: want an error before the app is created", tc.name)
+		}
+	}
+}
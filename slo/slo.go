@@ -0,0 +1,139 @@
+package slo
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	WebhookHandling = "webhook_handling"
+	CheckDelivery   = "check_delivery"
+	BranchSync      = "branch_sync"
+)
+
+const (
+	bucketSize = time.Hour
+	numBuckets = 30 * 24
+)
+
+// windows are the rolling windows that are reported.
+var windows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1d", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+type WindowReport struct {
+	Total        int     `json:"total"`
+	Failures     int     `json:"failures"`
+	Availability float64 `json:"availability"`
+}
+
+type Report struct {
+	Name    string                  `json:"name"`
+	Windows map[string]WindowReport `json:"windows"`
+}
+
+// Tracker counts successful and failed operations in hourly buckets and
+// reports the availability over rolling 1, 7 and 30 day windows. The counters
+// are kept in memory, so they start from scratch when the app is restarted.
+type Tracker struct {
+	mutex   sync.Mutex
+	now     func() time.Time
+	buckets map[string]*[numBuckets]bucket
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		now:     time.Now,
+		buckets: make(map[string]*[numBuckets]bucket),
+	}
+}
+
+// Record records the outcome of an operation. It is safe to call Record on a
+// nil Tracker.
+func (t *Tracker) Record(name string, ok bool) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	buckets, found := t.buckets[name]
+	if !found {
+		buckets = &[numBuckets]bucket{}
+		t.buckets[name] = buckets
+	}
+
+	start := t.now().Truncate(bucketSize)
+	b := &buckets[start.Unix()/int64(bucketSize/time.Second)%numBuckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	b.total++
+	if !ok {
+		b.failures++
+	}
+}
+
+func (t *Tracker) Report() []Report {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	var reports []Report
+	for name, buckets := range t.buckets {
+		report := Report{
+			Name:    name,
+			Windows: make(map[string]WindowReport),
+		}
+		for _, w := range windows {
+			since := now.Add(-w.duration)
+			var wr WindowReport
+			for _, b := range buckets {
+				if b.total == 0 || b.start.Add(bucketSize).Before(since) {
+					continue
+				}
+				wr.Total += b.total
+				wr.Failures += b.failures
+			}
+			wr.Availability = 1
+			if wr.Total > 0 {
+				wr.Availability = float64(wr.Total-wr.Failures) / float64(wr.Total)
+			}
+			report.Windows[w.name] = wr
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Name < reports[j].Name
+	})
+	return reports
+}
+
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(t.Report())
+	if err != nil {
+		klog.Errorf("failed to encode SLO report: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package slo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+
+	record := func(at time.Time, ok bool) {
+		now = at
+		tracker.Record(BranchSync, ok)
+	}
+	base := now
+	record(base.Add(-20*24*time.Hour), false)
+	record(base.Add(-3*24*time.Hour), false)
+	record(base.Add(-3*24*time.Hour), true)
+	record(base.Add(-time.Hour), true)
+	record(base, true)
+	now = base
+
+	reports := tracker.Report()
+	if len(reports) != 1 || reports[0].Name != BranchSync {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	want := map[string]WindowReport{
+		"1d":  {Total: 2, Failures: 0, Availability: 1},
+		"7d":  {Total: 4, Failures: 1, Availability: 0.75},
+		"30d": {Total: 5, Failures: 2, Availability: 0.6},
+	}
+	for name, w := range want {
+		if got := reports[0].Windows[name]; got != w {
+			t.Errorf("%s: got %+v, want %+v", name, got, w)
+		}
+	}
+}
+
+func TestTrackerOverwritesOldBuckets(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record(CheckDelivery, false)
+	now = now.Add(numBuckets * bucketSize)
+	tracker.Record(CheckDelivery, true)
+
+	got := tracker.Report()[0].Windows["30d"]
+	want := WindowReport{Total: 1, Failures: 0, Availability: 1}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.Record(WebhookHandling, true)
+}
@@ -0,0 +1,359 @@
+// Package storage keeps the state of the app in SQLite or Postgres, so that
+// the audit log, the deferred rechecks, the sync status of the branches, the
+// handled webhook deliveries and the fix versions set by the Jira rules survive
+// restarts.
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quay/quay-ci-app/audit"
+
+	// Drivers for database/sql.
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Drivers of the databases.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// schema creates the tables. The statements must be idempotent as they run
+// every time the database is opened. The times are stored as Unix nanoseconds.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_entries (
+		id BIGINT PRIMARY KEY,
+		time BIGINT NOT NULL,
+		repository TEXT NOT NULL,
+		entry TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS deferred_events (
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		number INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		time BIGINT NOT NULL,
+		PRIMARY KEY (owner, repo, number, event)
+	)`,
+	`CREATE TABLE IF NOT EXISTS branch_sync_status (
+		branch TEXT PRIMARY KEY,
+		status TEXT NOT NULL,
+		message TEXT NOT NULL,
+		last_heartbeat_time BIGINT NOT NULL,
+		last_transition_time BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS branch_sync_history (
+		branch TEXT NOT NULL,
+		time BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		message TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS branch_sync_history_branch_time ON branch_sync_history (branch, time)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		time BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS fix_versions (
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		number INTEGER NOT NULL,
+		issue TEXT NOT NULL,
+		version TEXT NOT NULL,
+		PRIMARY KEY (owner, repo, number, issue)
+	)`,
+}
+
+// DB is a database with the state of the app.
+type DB struct {
+	db     *sql.DB
+	driver string
+}
+
+// Open opens the database of the driver (sqlite or postgres) at dsn, e.g.
+// /var/lib/quay-ci-app/state.db or postgres://user@host/quay-ci-app, and
+// creates the tables that don't exist yet.
+func Open(driver, dsn string) (*DB, error) {
+	if driver != DriverSQLite && driver != DriverPostgres {
+		return nil, fmt.Errorf("unsupported storage driver %q, expected %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the %s database: %w", driver, err)
+	}
+	if driver == DriverSQLite {
+		// SQLite allows only one writer at a time.
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create the tables in the %s database: %w", driver, err)
+		}
+	}
+	return &DB{db: db, driver: driver}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// rebind replaces the ? placeholders of the query with $1, $2, ... for
+// Postgres.
+func (d *DB) rebind(query string) string {
+	if d.driver != DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (d *DB) exec(query string, args ...interface{}) error {
+	_, err := d.db.Exec(d.rebind(query), args...)
+	return err
+}
+
+func (d *DB) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(d.rebind(query), args...)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// SaveAuditEntry saves the entry of the audit log.
+func (d *DB) SaveAuditEntry(e audit.Entry) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return d.exec(`INSERT INTO audit_entries (id, time, repository, entry) VALUES (?, ?, ?, ?)`,
+		e.ID, unixNano(e.Time), e.Repository, string(buf))
+}
+
+// LoadAuditEntries returns the last limit entries of the audit log, oldest
+// first.
+func (d *DB) LoadAuditEntries(limit int) ([]audit.Entry, error) {
+	rows, err := d.query(`SELECT entry FROM audit_entries ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []audit.Entry
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		var e audit.Entry
+		if err := json.Unmarshal([]byte(value), &e); err != nil {
+			return nil, fmt.Errorf("failed to decode an audit log entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// PullRequest identifies a pull request.
+type PullRequest struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// DeferredEvent is the event of a pull request whose handling is deferred
+// until Jira is available.
+type DeferredEvent struct {
+	PullRequest
+	Event string
+	Time  time.Time
+}
+
+// SaveDeferredEvent saves the event whose handling is deferred. An event that
+// is deferred again keeps its original time.
+func (d *DB) SaveDeferredEvent(e DeferredEvent) error {
+	return d.exec(`INSERT INTO deferred_events (owner, repo, number, event, time) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		e.Owner, e.Repo, e.Number, e.Event, unixNano(e.Time))
+}
+
+// DeleteDeferredEvent deletes the event from the deferred events.
+func (d *DB) DeleteDeferredEvent(e DeferredEvent) error {
+	return d.exec(`DELETE FROM deferred_events WHERE owner = ? AND repo = ? AND number = ? AND event = ?`,
+		e.Owner, e.Repo, e.Number, e.Event)
+}
+
+// LoadDeferredEvents returns the deferred events, oldest first.
+func (d *DB) LoadDeferredEvents() ([]DeferredEvent, error) {
+	rows, err := d.query(`SELECT owner, repo, number, event, time FROM deferred_events ORDER BY time, owner, repo, number, event`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []DeferredEvent
+	for rows.Next() {
+		var e DeferredEvent
+		var t int64
+		if err := rows.Scan(&e.Owner, &e.Repo, &e.Number, &e.Event, &t); err != nil {
+			return nil, err
+		}
+		e.Time = fromUnixNano(t)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// SyncStatus is the sync status of a branch, like Synced or PausedByRepo.
+type SyncStatus struct {
+	Branch             string
+	Status             string
+	Message            string
+	LastHeartbeatTime  time.Time
+	LastTransitionTime time.Time
+}
+
+// SaveSyncStatus saves the sync status of the branch. If the status or the
+// message changed, the new status is also added to the history of the branch
+// at LastHeartbeatTime, as the transition time only changes with the status.
+func (d *DB) SaveSyncStatus(s SyncStatus, changed bool) error {
+	err := d.exec(`INSERT INTO branch_sync_status (branch, status, message, last_heartbeat_time, last_transition_time) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (branch) DO UPDATE SET status = excluded.status, message = excluded.message,
+		last_heartbeat_time = excluded.last_heartbeat_time, last_transition_time = excluded.last_transition_time`,
+		s.Branch, s.Status, s.Message, unixNano(s.LastHeartbeatTime), unixNano(s.LastTransitionTime))
+	if err != nil || !changed {
+		return err
+	}
+	return d.exec(`INSERT INTO branch_sync_history (branch, time, status, message) VALUES (?, ?, ?, ?)`,
+		s.Branch, unixNano(s.LastHeartbeatTime), s.Status, s.Message)
+}
+
+// LoadSyncStatuses returns the last sync status of all branches.
+func (d *DB) LoadSyncStatuses() ([]SyncStatus, error) {
+	rows, err := d.query(`SELECT branch, status, message, last_heartbeat_time, last_transition_time FROM branch_sync_status ORDER BY branch`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []SyncStatus
+	for rows.Next() {
+		var s SyncStatus
+		var heartbeat, transition int64
+		if err := rows.Scan(&s.Branch, &s.Status, &s.Message, &heartbeat, &transition); err != nil {
+			return nil, err
+		}
+		s.LastHeartbeatTime = fromUnixNano(heartbeat)
+		s.LastTransitionTime = fromUnixNano(transition)
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+// SyncHistory returns the last limit transitions of the sync status of the
+// branch, newest first. LastHeartbeatTime is not set.
+func (d *DB) SyncHistory(branch string, limit int) ([]SyncStatus, error) {
+	rows, err := d.query(`SELECT status, message, time FROM branch_sync_history WHERE branch = ? ORDER BY time DESC LIMIT ?`, branch, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []SyncStatus
+	for rows.Next() {
+		s := SyncStatus{Branch: branch}
+		var transition int64
+		if err := rows.Scan(&s.Status, &s.Message, &transition); err != nil {
+			return nil, err
+		}
+		s.LastTransitionTime = fromUnixNano(transition)
+		history = append(history, s)
+	}
+	return history, rows.Err()
+}
+
+// SaveDelivery saves the ID of a handled webhook delivery.
+func (d *DB) SaveDelivery(id string, t time.Time) error {
+	return d.exec(`INSERT INTO webhook_deliveries (id, time) VALUES (?, ?) ON CONFLICT DO NOTHING`, id, unixNano(t))
+}
+
+// HasDelivery returns true if the webhook delivery was handled.
+func (d *DB) HasDelivery(id string) (bool, error) {
+	rows, err := d.query(`SELECT 1 FROM webhook_deliveries WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	found := rows.Next()
+	return found, rows.Err()
+}
+
+// DeleteDeliveriesBefore deletes the webhook deliveries handled before t.
+func (d *DB) DeleteDeliveriesBefore(t time.Time) error {
+	return d.exec(`DELETE FROM webhook_deliveries WHERE time < ?`, unixNano(t))
+}
+
+// SaveFixVersion saves the fix version that a Jira rule set on the issue of
+// the pull request.
+func (d *DB) SaveFixVersion(pr PullRequest, issue, version string) error {
+	return d.exec(`INSERT INTO fix_versions (owner, repo, number, issue, version) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (owner, repo, number, issue) DO UPDATE SET version = excluded.version`,
+		pr.Owner, pr.Repo, pr.Number, issue, version)
+}
+
+// FixVersion returns the fix version that was set on the issue of the pull
+// request, or an empty string if there is none.
+func (d *DB) FixVersion(pr PullRequest, issue string) (string, error) {
+	rows, err := d.query(`SELECT version FROM fix_versions WHERE owner = ? AND repo = ? AND number = ? AND issue = ?`,
+		pr.Owner, pr.Repo, pr.Number, issue)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var version string
+	if rows.Next() {
+		if err := rows.Scan(&version); err != nil {
+			return "", err
+		}
+	}
+	return version, rows.Err()
+}
+
+// DeleteFixVersion forgets the fix version of the issue of the pull request.
+func (d *DB) DeleteFixVersion(pr PullRequest, issue string) error {
+	return d.exec(`DELETE FROM fix_versions WHERE owner = ? AND repo = ? AND number = ? AND issue = ?`,
+		pr.Owner, pr.Repo, pr.Number, issue)
+}
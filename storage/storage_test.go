@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quay/quay-ci-app/audit"
+)
+
+// databases returns the databases to test: a SQLite file, and the Postgres
+// database of QUAY_CI_APP_TEST_POSTGRES if it is set.
+func databases(t *testing.T) map[string]func() *DB {
+	dir := t.TempDir()
+	dbs := map[string]func() *DB{
+		DriverSQLite: func() *DB {
+			db, err := Open(DriverSQLite, filepath.Join(dir, "state.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return db
+		},
+	}
+	if dsn := os.Getenv("QUAY_CI_APP_TEST_POSTGRES"); dsn != "" {
+		dbs[DriverPostgres] = func() *DB {
+			db, err := Open(DriverPostgres, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return db
+		}
+	}
+	return dbs
+}
+
+func TestAuditEntries(t *testing.T) {
+	for driver, open := range databases(t) {
+		t.Run(driver, func(t *testing.T) {
+			db := open()
+			log := audit.NewLog(2, nil)
+			if err := log.SetBackend(db); err != nil {
+				t.Fatal(err)
+			}
+			log.Record(audit.Entry{Repository: "quay/quay", Action: "update_ref", Target: "heads/redhat-3.9"})
+			log.Record(audit.Entry{Repository: "quay/quay", Action: "create_check_run"})
+			log.Record(audit.Entry{Repository: "quay/clair", Action: "transition_issue", Target: "PROJQUAY-123"})
+			db.Close()
+
+			// The log of the restarted app has the last entries.
+			db = open()
+			defer db.Close()
+			log = audit.NewLog(2, nil)
+			if err := log.SetBackend(db); err != nil {
+				t.Fatal(err)
+			}
+			log.Record(audit.Entry{Repository: "quay/quay", Action: "delete_ref"})
+
+			var got []string
+			for _, e := range log.Query("", time.Time{}) {
+				got = append(got, e.Action)
+			}
+			want := []string{"transition_issue", "delete_ref"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if entries := log.Query("quay/quay", time.Time{}); len(entries) != 1 || entries[0].ID != 4 {
+				t.Errorf("the new entry should have the id 4, got %+v", entries)
+			}
+		})
+	}
+}
+
+func TestDeferredEvents(t *testing.T) {
+	for driver, open := range databases(t) {
+		t.Run(driver, func(t *testing.T) {
+			db := open()
+			defer db.Close()
+			start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+			for i, e := range []DeferredEvent{
+				{PullRequest{"quay", "quay", 1234}, "closed", start.Add(time.Minute)},
+				{PullRequest{"quay", "clair", 1}, "opened", start},
+				{PullRequest{"quay", "quay", 1234}, "sync", start},
+				{PullRequest{"quay", "quay", 1234}, "closed", start.Add(time.Hour)},
+			} {
+				if err := db.SaveDeferredEvent(e); err != nil {
+					t.Fatalf("%d: %v", i, err)
+				}
+			}
+			if err := db.DeleteDeferredEvent(DeferredEvent{PullRequest: PullRequest{"quay", "clair", 1}, Event: "opened"}); err != nil {
+				t.Fatal(err)
+			}
+			events, err := db.LoadDeferredEvents()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []DeferredEvent{
+				{PullRequest{"quay", "quay", 1234}, "sync", start},
+				{PullRequest{"quay", "quay", 1234}, "closed", start.Add(time.Minute)},
+			}
+			if !reflect.DeepEqual(events, want) {
+				t.Errorf("got %+v, want %+v", events, want)
+			}
+		})
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	for driver, open := range databases(t) {
+		t.Run(driver, func(t *testing.T) {
+			db := open()
+			defer db.Close()
+			start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+			for i, s := range []struct {
+				status  string
+				changed bool
+			}{
+				{"Synced", true},
+				{"Synced", false},
+				{"PausedByRepo", true},
+			} {
+				now := start.Add(time.Duration(i) * time.Minute)
+				err := db.SaveSyncStatus(SyncStatus{
+					Branch:             "quay/quay:redhat-3.9",
+					Status:             s.status,
+					Message:            s.status + " message",
+					LastHeartbeatTime:  now,
+					LastTransitionTime: now,
+				}, s.changed)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			statuses, err := db.LoadSyncStatuses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []SyncStatus{{
+				Branch:             "quay/quay:redhat-3.9",
+				Status:             "PausedByRepo",
+				Message:            "PausedByRepo message",
+				LastHeartbeatTime:  start.Add(2 * time.Minute),
+				LastTransitionTime: start.Add(2 * time.Minute),
+			}}
+			if !reflect.DeepEqual(statuses, want) {
+				t.Errorf("got statuses %+v, want %+v", statuses, want)
+			}
+
+			history, err := db.SyncHistory("quay/quay:redhat-3.9", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range history {
+				got = append(got, s.Status+" "+s.LastTransitionTime.Format(time.Kitchen))
+			}
+			if want := []string{"PausedByRepo 12:02PM", "Synced 12:00PM"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got history %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRebind(t *testing.T) {
+	db := &DB{driver: DriverPostgres}
+	got := db.rebind(`SELECT a FROM t WHERE b = ? AND c = ?`)
+	if want := `SELECT a FROM t WHERE b = $1 AND c = $2`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOpenUnsupportedDriver(t *testing.T) {
+	if _, err := Open("mysql", "quay"); err == nil {
+		t.Error("opening a mysql database should fail")
+	}
+}
+
+func TestFixVersions(t *testing.T) {
+	for driver, open := range databases(t) {
+		t.Run(driver, func(t *testing.T) {
+			db := open()
+			defer db.Close()
+			pr := PullRequest{"quay", "quay", 1234}
+			if err := db.SaveFixVersion(pr, "PROJQUAY-123", "quay-v3.8.1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.SaveFixVersion(pr, "PROJQUAY-123", "quay-v3.8.2"); err != nil {
+				t.Fatal(err)
+			}
+			if version, err := db.FixVersion(pr, "PROJQUAY-123"); err != nil || version != "quay-v3.8.2" {
+				t.Errorf("got %q, %v, want quay-v3.8.2", version, err)
+			}
+			if version, err := db.FixVersion(pr, "PROJQUAY-456"); err != nil || version != "" {
+				t.Errorf("got %q, %v for another issue, want nothing", version, err)
+			}
+			if err := db.DeleteFixVersion(pr, "PROJQUAY-123"); err != nil {
+				t.Fatal(err)
+			}
+			if version, err := db.FixVersion(pr, "PROJQUAY-123"); err != nil || version != "" {
+				t.Errorf("got %q, %v after the deletion, want nothing", version, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/cache"
+)
+
+// maxSupersededHeads is how many superseded heads of a pull request are
+// remembered to skip their late events.
+const maxSupersededHeads = 20
+
+// PullRequestHeads remembers the latest head of each pull request. When a pull
+// request is pushed to twice in a row, the checks of the first push can
+// finish after the checks of the second one and report the results of an
+// outdated commit. The checks of a head are cancelled when the checks of a
+// newer head start, and the late events of the superseded heads are skipped.
+// It is safe to use a nil PullRequestHeads, it doesn't track anything.
+type PullRequestHeads struct {
+	mutex sync.Mutex
+	heads *cache.Cache
+	next  int
+}
+
+type pullRequestHead struct {
+	sha        string
+	superseded []string
+	// cancels cancel the work on sha that is in progress.
+	cancels map[int]context.CancelFunc
+}
+
+func NewPullRequestHeads() *PullRequestHeads {
+	return &PullRequestHeads{
+		heads: cache.New("pull-request-heads", 10000, 24*time.Hour),
+	}
+}
+
+func pullRequestKey(org, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", org, repo, number)
+}
+
+// Start records that work on the head of pr begins. If the head is newer than
+// the head of the work in progress, that work is cancelled. Start returns the
+// context of the work, which is cancelled when a newer head starts, and the
+// function to call when the work is done. If the head was already
+// superseded, ok is false and the work should be skipped, unless current is
+// set: the head was checked to be the current head of the pull request, e.g.
+// after a force-push back to it, and it becomes the latest head again.
+func (h *PullRequestHeads) Start(ctx context.Context, org, repo string, pr *github.PullRequest, current bool) (workCtx context.Context, done func(), ok bool) {
+	if h == nil {
+		return ctx, func() {}, true
+	}
+	key := pullRequestKey(org, repo, pr.GetNumber())
+	sha := pr.GetHead().GetSHA()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var head *pullRequestHead
+	if value, found := h.heads.Get(key); found {
+		head = value.(*pullRequestHead)
+	}
+	switch {
+	case head == nil:
+		head = &pullRequestHead{sha: sha, cancels: map[int]context.CancelFunc{}}
+		h.heads.Add(key, head)
+	case head.sha == sha:
+	case contains(head.superseded, sha) && !current:
+		return ctx, func() {}, false
+	default:
+		head.superseded = remove(head.superseded, sha)
+		for _, cancel := range head.cancels {
+			cancel()
+		}
+		head.superseded = append(head.superseded, head.sha)
+		if len(head.superseded) > maxSupersededHeads {
+			head.superseded = head.superseded[len(head.superseded)-maxSupersededHeads:]
+		}
+		head.sha = sha
+		head.cancels = map[int]context.CancelFunc{}
+	}
+
+	workCtx, cancel := context.WithCancel(ctx)
+	id := h.next
+	h.next++
+	head.cancels[id] = cancel
+	cancels := head.cancels
+	return workCtx, func() {
+		h.mutex.Lock()
+		delete(cancels, id)
+		h.mutex.Unlock()
+		cancel()
+	}, true
+}
+
+// Superseded reports whether a newer head of the pull request than sha has
+// started.
+func (h *PullRequestHeads) Superseded(org, repo string, number int, sha string) bool {
+	if h == nil {
+		return false
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	value, found := h.heads.Get(pullRequestKey(org, repo, number))
+	return found && value.(*pullRequestHead).sha != sha
+}
+
+func remove(values []string, value string) []string {
+	result := values[:0]
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
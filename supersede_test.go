@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/breaker"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+	"github.com/quay/quay-ci-app/retry"
+)
+
+func withHead(pr *github.PullRequest, sha string) *github.PullRequest {
+	copied := *pr
+	copied.Head = &github.PullRequestBranch{SHA: github.String(sha)}
+	return &copied
+}
+
+func TestPullRequestHeads(t *testing.T) {
+	heads := NewPullRequestHeads()
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	ctx := context.Background()
+
+	firstCtx, firstDone, ok := heads.Start(ctx, "quay", "quay", withHead(pr, "first"), false)
+	if !ok {
+		t.Fatal("the first head should start")
+	}
+	recheckCtx, recheckDone, ok := heads.Start(ctx, "quay", "quay", withHead(pr, "first"), false)
+	if !ok {
+		t.Fatal("the work on the same head should start")
+	}
+	recheckDone()
+	if recheckCtx.Err() == nil {
+		t.Errorf("the context should be cancelled when the work is done")
+	}
+	if firstCtx.Err() != nil {
+		t.Errorf("the work on the same head should not cancel the other work")
+	}
+
+	secondCtx, secondDone, ok := heads.Start(ctx, "quay", "quay", withHead(pr, "second"), false)
+	if !ok {
+		t.Fatal("the second head should start")
+	}
+	defer secondDone()
+	if firstCtx.Err() == nil {
+		t.Errorf("the work on the first head should be cancelled")
+	}
+	firstDone()
+	if secondCtx.Err() != nil {
+		t.Errorf("the work on the second head should go on")
+	}
+	if !heads.Superseded("quay", "quay", 1, "first") || heads.Superseded("quay", "quay", 1, "second") {
+		t.Errorf("the first head should be superseded by the second one")
+	}
+
+	// A late event of the first head is skipped.
+	if _, _, ok := heads.Start(ctx, "quay", "quay", withHead(pr, "first"), false); ok {
+		t.Errorf("the superseded head should be skipped")
+	}
+	// The other pull requests are not affected.
+	if _, done, ok := heads.Start(ctx, "quay", "quay", withHead(fakes.PullRequest("quay", "quay", 2, "Other"), "first"), false); !ok {
+		t.Errorf("the head of another pull request should start")
+	} else {
+		done()
+	}
+
+	var nilHeads *PullRequestHeads
+	if _, done, ok := nilHeads.Start(ctx, "quay", "quay", pr, false); !ok || nilHeads.Superseded("quay", "quay", 1, "first") {
+		t.Errorf("a nil PullRequestHeads should not track anything")
+	} else {
+		done()
+	}
+}
+
+func TestPullRequestHeadsForcePushedBack(t *testing.T) {
+	heads := NewPullRequestHeads()
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	ctx := context.Background()
+
+	_, done, _ := heads.Start(ctx, "quay", "quay", withHead(pr, "a"), false)
+	done()
+	_, done, _ = heads.Start(ctx, "quay", "quay", withHead(pr, "b"), false)
+	done()
+
+	// The pull request is force-pushed back to a.
+	bCtx, bDone, _ := heads.Start(ctx, "quay", "quay", withHead(pr, "b"), false)
+	defer bDone()
+	_, aDone, ok := heads.Start(ctx, "quay", "quay", withHead(pr, "a"), true)
+	if !ok {
+		t.Fatal("the current head should start even if it was superseded before")
+	}
+	defer aDone()
+	if bCtx.Err() == nil {
+		t.Errorf("the work on b should be cancelled")
+	}
+	if heads.Superseded("quay", "quay", 1, "a") || !heads.Superseded("quay", "quay", 1, "b") {
+		t.Errorf("a should be the head again")
+	}
+	if _, done, ok := heads.Start(ctx, "quay", "quay", withHead(pr, "a"), false); !ok {
+		t.Errorf("the events of a should not be skipped anymore")
+	} else {
+		done()
+	}
+}
+
+func TestRunChecksForcePushedBack(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{Owner: "quay", Repo: "quay"}},
+	})
+	r.pullRequestHeads = NewPullRequestHeads()
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build")
+	ctx := context.Background()
+
+	a := withHead(pr, "a")
+	b := withHead(pr, "b")
+	for _, head := range []*github.PullRequest{a, b} {
+		if err := r.runChecks(ctx, checks.EventSync, "quay", "quay", head); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A late event of a is skipped while b is the head.
+	gh.AddPullRequest(b)
+	if err := r.runChecks(ctx, checks.EventSync, "quay", "quay", a); err != nil {
+		t.Fatal(err)
+	}
+	if !r.pullRequestHeads.Superseded("quay", "quay", 1, "a") {
+		t.Errorf("a should still be superseded")
+	}
+
+	// The pull request is force-pushed back to a.
+	gh.AddPullRequest(a)
+	if err := r.runChecks(ctx, checks.EventSync, "quay", "quay", a); err != nil {
+		t.Fatal(err)
+	}
+	if r.pullRequestHeads.Superseded("quay", "quay", 1, "a") {
+		t.Errorf("the checks of a should run after the force-push back to it")
+	}
+}
+
+func TestSupersededCheckKeepsBreakerClosed(t *testing.T) {
+	received := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	// The Jira client of newJiraClient, with a threshold of a single failure.
+	b := breaker.New("jira", 1, time.Minute)
+	client := &http.Client{Transport: &breaker.Transport{
+		Base: &retry.Transport{
+			MaxRetries:     4,
+			InitialBackoff: time.Millisecond,
+		},
+		Breaker: b,
+	}}
+
+	heads := NewPullRequestHeads()
+	pr := fakes.PullRequest("quay", "quay", 1, "Fix the build (PROJQUAY-123)")
+	ctx, done, _ := heads.Start(context.Background(), "quay", "quay", withHead(pr, "a"), false)
+	defer done()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		errc <- err
+	}()
+
+	// The pull request is force-pushed while the check waits for Jira.
+	<-received
+	_, newDone, _ := heads.Start(context.Background(), "quay", "quay", withHead(pr, "b"), false)
+	defer newDone()
+	if err := <-errc; err == nil {
+		t.Fatal("the request of the superseded check should be cancelled")
+	}
+	if state := b.State(); state != breaker.StateClosed {
+		t.Errorf("got the %s circuit, the cancelled check should not count as a failure of Jira", state)
+	}
+	if n := len(received); n != 0 {
+		t.Errorf("got %d retries of the cancelled request", n)
+	}
+}
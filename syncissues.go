@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/cache"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+// syncFailureLabel is the label of the issues that track the branches that
+// fail to sync.
+const syncFailureLabel = "sync-failure"
+
+func syncFailureTitle(dest configuration.BranchReference) string {
+	return fmt.Sprintf("Syncing %s fails", dest)
+}
+
+// maxSyncFailureIssues is how many branches SyncFailureIssues remembers. The
+// issues of the other branches are looked up again.
+const maxSyncFailureIssues = 10000
+
+// SyncFailureIssues remembers the open issues about the branches that fail to
+// sync, so that GitHub is asked for them only once.
+type SyncFailureIssues struct {
+	mutex sync.Mutex
+	// issues maps the branches to the numbers of their open issues, or to 0
+	// if they don't have one.
+	issues *cache.Cache
+}
+
+func NewSyncFailureIssues() *SyncFailureIssues {
+	return &SyncFailureIssues{
+		issues: cache.New("sync-failure-issues", maxSyncFailureIssues, 0),
+	}
+}
+
+// findSyncFailureIssue returns the number of the open issue about the branch,
+// or 0 if there is none.
+func (r reactor) findSyncFailureIssue(ctx context.Context, dest configuration.BranchReference) (int, error) {
+	if number, ok := r.syncIssues.issues.Get(dest.String()); ok {
+		return number.(int), nil
+	}
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		Labels:      []string{syncFailureLabel},
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := r.client.Issues.ListByRepo(ctx, dest.Owner, dest.Repo, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list the %s issues: %w", syncFailureLabel, err)
+		}
+		for _, issue := range issues {
+			if issue.GetTitle() == syncFailureTitle(dest) {
+				r.syncIssues.issues.Add(dest.String(), issue.GetNumber())
+				return issue.GetNumber(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	r.syncIssues.issues.Add(dest.String(), 0)
+	return 0, nil
+}
+
+// trackSyncFailure opens an issue when the branch has been in the Error state
+// for longer than the repository allows, and closes it once the branch syncs
+// again.
+func (r reactor) trackSyncFailure(ctx context.Context, repo configuration.Repository, dest, src configuration.BranchReference) error {
+	if repo.SyncFailureIssue == nil || r.syncIssues == nil {
+		return nil
+	}
+	status := r.statusInformer.BranchSyncStatus(dest.String())
+	if status == nil {
+		return nil
+	}
+	failing := status.Status == "Error"
+	if failing && time.Since(status.LastTransitionTime) < repo.SyncFailureIssue.After.Duration {
+		return nil
+	}
+	if !failing && status.Status != "Synced" {
+		// Keep the issue open while the sync is paused.
+		return nil
+	}
+
+	r.syncIssues.mutex.Lock()
+	defer r.syncIssues.mutex.Unlock()
+
+	number, err := r.findSyncFailureIssue(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case failing && number == 0:
+		labels := append([]string{syncFailureLabel}, repo.SyncFailureIssue.Labels...)
+		body := fmt.Sprintf("The app fails to sync %s from %s since %s:\n\n```\n%s\n```\n\nThe issue is closed automatically once the branch is synced again.\n",
+			dest, src, status.LastTransitionTime.UTC().Format(time.RFC3339), status.Message)
+		issue, _, err := r.client.Issues.Create(ctx, dest.Owner, dest.Repo, &github.IssueRequest{
+			Title:  github.String(syncFailureTitle(dest)),
+			Body:   github.String(body),
+			Labels: &labels,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open an issue about %s: %w", dest, err)
+		}
+		klog.V(2).Infof("%sopened %s/%s#%d about syncing %s", logctx.Prefix(ctx), dest.Owner, dest.Repo, issue.GetNumber(), dest)
+		r.syncIssues.issues.Add(dest.String(), issue.GetNumber())
+	case !failing && number != 0:
+		_, _, err := r.client.Issues.CreateComment(ctx, dest.Owner, dest.Repo, number, &github.IssueComment{
+			Body: github.String(fmt.Sprintf("The branch is synced again: %s.\n", status.Message)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to comment on the issue about %s: %w", dest, err)
+		}
+		_, _, err = r.client.Issues.Edit(ctx, dest.Owner, dest.Repo, number, &github.IssueRequest{
+			State: github.String("closed"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to close the issue about %s: %w", dest, err)
+		}
+		klog.V(2).Infof("%sclosed %s/%s#%d, %s is synced again", logctx.Prefix(ctx), dest.Owner, dest.Repo, number, dest)
+		r.syncIssues.issues.Add(dest.String(), 0)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestTrackSyncFailure(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay:heads/redhat-3.9"] = ref("old")
+	repo := configuration.Repository{
+		Owner: "quay",
+		Repo:  "quay",
+		Branches: []configuration.Branch{
+			{Name: "redhat-3.9", SyncFrom: configuration.BranchReference{Branch: "master"}},
+		},
+		SyncFailureIssue: &configuration.SyncFailureIssue{
+			After:  configuration.Duration{Duration: time.Nanosecond},
+			Labels: []string{"ci"},
+		},
+	}
+	r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+	r.syncIssues = NewSyncFailureIssues()
+	ctx := context.Background()
+
+	// The source branch does not exist.
+	for i := 0; i < 2; i++ {
+		if err := r.syncRepository(ctx, repo, ""); err == nil {
+			t.Fatal("the sync should fail")
+		}
+	}
+	if len(gh.Issues) != 1 {
+		t.Fatalf("got %d issues, want 1", len(gh.Issues))
+	}
+	var number int
+	for _, issue := range gh.Issues {
+		number = issue.GetNumber()
+		if issue.GetTitle() != "Syncing quay/quay:redhat-3.9 fails" || !strings.Contains(issue.GetBody(), "failed to get source ref") {
+			t.Errorf("got the issue %q: %s", issue.GetTitle(), issue.GetBody())
+		}
+		if len(issue.Labels) != 2 || issue.Labels[0].GetName() != "sync-failure" || issue.Labels[1].GetName() != "ci" {
+			t.Errorf("got the labels %v", issue.Labels)
+		}
+	}
+
+	// The app is restarted and the branch is synced again.
+	r.syncIssues = NewSyncFailureIssues()
+	gh.Refs["quay/quay:heads/master"] = ref("new")
+	if err := r.syncRepository(ctx, repo, ""); err != nil {
+		t.Fatal(err)
+	}
+	key := fakes.IssueKey("quay", "quay", number)
+	if state := gh.Issues[key].GetState(); state != "closed" {
+		t.Errorf("the issue should be closed, got %s", state)
+	}
+	if comments := gh.Comments[key]; len(comments) != 1 || !strings.Contains(comments[0].GetBody(), "synced again") {
+		t.Errorf("got the comments %v", comments)
+	}
+}
+
+func TestTrackSyncFailureNewMessage(t *testing.T) {
+	gh := fakes.NewGitHub()
+	repo := configuration.Repository{
+		Owner:            "quay",
+		Repo:             "quay",
+		SyncFailureIssue: &configuration.SyncFailureIssue{After: configuration.Duration{Duration: time.Hour}},
+	}
+	r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+	r.syncIssues = NewSyncFailureIssues()
+	dest := configuration.BranchReference{Owner: "quay", Repo: "quay", Branch: "redhat-3.9"}
+	src := configuration.BranchReference{Owner: "quay", Repo: "quay", Branch: "master"}
+
+	r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Error", "failed to get source ref")
+	failingSince := time.Now().Add(-2 * time.Hour)
+	r.statusInformer.mutex.Lock()
+	branchStatus := r.statusInformer.branchStatus(dest.String())
+	branchStatus.SyncStatus.LastTransitionTime = failingSince
+	r.statusInformer.setBranchStatus(branchStatus)
+	r.statusInformer.mutex.Unlock()
+
+	// The sync keeps failing, with another error.
+	r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Error", "failed to merge")
+	if got := r.statusInformer.BranchSyncStatus(dest.String()).LastTransitionTime; !got.Equal(failingSince) {
+		t.Errorf("got the transition time %s, a new message should keep %s", got, failingSince)
+	}
+	if err := r.trackSyncFailure(context.Background(), repo, dest, src); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.Issues) != 1 {
+		t.Errorf("got %d issues, the branch fails for longer than an hour", len(gh.Issues))
+	}
+
+	r.statusInformer.UpdateBranchSyncStatus(dest.String(), "Synced", "synced from quay/quay:master")
+	if got := r.statusInformer.BranchSyncStatus(dest.String()).LastTransitionTime; got.Equal(failingSince) {
+		t.Errorf("the transition time should change with the status")
+	}
+}
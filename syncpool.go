@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+// BranchLocks serializes the syncs of each destination branch, which can be
+// started at the same time by the sync loop, the push webhooks and the admin
+// API. It also serializes the merges of each pull request, for which several
+// check runs can complete at the same time. It is safe to use a nil
+// BranchLocks, it doesn't lock anything.
+type BranchLocks struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewBranchLocks() *BranchLocks {
+	return &BranchLocks{
+		locks: map[string]*sync.Mutex{},
+	}
+}
+
+// Lock waits until no other sync of the branch is running, and returns the
+// function that releases the branch.
+func (l *BranchLocks) Lock(branch string) func() {
+	if l == nil {
+		return func() {}
+	}
+	l.mutex.Lock()
+	lock, ok := l.locks[branch]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[branch] = lock
+	}
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// syncRepositories calls syncRepo for each repository, with at most workers
+// calls running at the same time, and returns once all of them are done.
+func syncRepositories(repos []configuration.Repository, workers int, syncRepo func(configuration.Repository)) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan configuration.Repository)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(repos); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range jobs {
+				syncRepo(repo)
+			}
+		}()
+	}
+	for _, repo := range repos {
+		jobs <- repo
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// runSyncLoop calls pass right away and then every interval until ctx is
+// done. Each pass is delayed by a random duration up to jitter, so that the
+// instances of the app don't send their bursts of requests at the same time,
+// and its context is cancelled after timeout. The next pass starts right
+// away if a pass takes longer than interval.
+func runSyncLoop(ctx context.Context, interval, jitter, timeout time.Duration, pass func(context.Context)) {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		passCtx, cancel := context.WithTimeout(ctx, timeout)
+		pass(passCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if jitter > 0 {
+			delay := time.NewTimer(time.Duration(random.Int63n(int64(jitter))))
+			select {
+			case <-ctx.Done():
+				delay.Stop()
+				return
+			case <-delay.C:
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestSyncRepositories(t *testing.T) {
+	var repos []configuration.Repository
+	for _, name := range []string{"quay", "quay-docs", "clair", "quay-operator", "quay-bridge-operator"} {
+		repos = append(repos, configuration.Repository{Owner: "quay", Repo: name})
+	}
+
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	synced := map[string]bool{}
+	syncRepositories(repos, 2, func(repo configuration.Repository) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		synced[repo.Repo] = true
+		mutex.Unlock()
+	})
+
+	if len(synced) != len(repos) {
+		t.Errorf("got %d synced repositories, want %d", len(synced), len(repos))
+	}
+	if maxRunning != 2 {
+		t.Errorf("got %d concurrent syncs, want 2", maxRunning)
+	}
+}
+
+func TestBranchLocks(t *testing.T) {
+	locks := NewBranchLocks()
+	unlock := locks.Lock("quay/quay:redhat-3.9")
+
+	// Other branches are not blocked.
+	locks.Lock("quay/quay:redhat-3.8")()
+
+	locked := make(chan struct{})
+	go func() {
+		defer locks.Lock("quay/quay:redhat-3.9")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the branch should stay locked")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the branch should be unlocked")
+	}
+
+	var nilLocks *BranchLocks
+	nilLocks.Lock("quay/quay:redhat-3.9")()
+}
+
+func TestRunSyncLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	passes := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runSyncLoop(ctx, 5*time.Millisecond, 5*time.Millisecond, time.Minute, func(passCtx context.Context) {
+			if _, ok := passCtx.Deadline(); !ok {
+				t.Error("the pass should have a deadline")
+			}
+			passes++
+			if passes == 3 {
+				cancel()
+			}
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the loop should stop once the context is cancelled")
+	}
+	if passes != 3 {
+		t.Errorf("got %d passes, want 3", passes)
+	}
+}
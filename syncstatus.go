@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/cache"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/klog/v2"
+)
+
+// syncStatusContext is the context of the commit status that reports the sync
+// state of the branches.
+const syncStatusContext = "quay-ci-app/sync"
+
+// syncStatusRefresh is how often the commit status is refreshed while nothing
+// changes, so that it shows when the branch was last checked. GitHub keeps at
+// most 1000 statuses per commit and context.
+const syncStatusRefresh = time.Hour
+
+type syncStatusReport struct {
+	sha   string
+	state string
+}
+
+// maxSyncStatusReports is how many branches SyncStatusReports remembers. The
+// status of the other branches is reported again on their next sync.
+const maxSyncStatusReports = 10000
+
+// SyncStatusReports remembers the last commit status of each branch, so that
+// it is not reported again on every sync. The reports expire after
+// syncStatusRefresh, which reports the status again.
+type SyncStatusReports struct {
+	mutex   sync.Mutex
+	reports *cache.Cache
+}
+
+func NewSyncStatusReports() *SyncStatusReports {
+	return &SyncStatusReports{
+		reports: cache.New("sync-status-reports", maxSyncStatusReports, syncStatusRefresh),
+	}
+}
+
+// reportSyncStatus sets the sync commit status on sha, the head of dest, if
+// the branch has sync_status.
+func (r reactor) reportSyncStatus(ctx context.Context, branchConfig configuration.Branch, dest configuration.BranchReference, sha, state, description, targetURL string) {
+	if r.syncStatuses == nil || !branchConfig.SyncStatus {
+		return
+	}
+
+	r.syncStatuses.mutex.Lock()
+	defer r.syncStatuses.mutex.Unlock()
+
+	now := time.Now()
+	if last, ok := r.syncStatuses.reports.Get(dest.String()); ok {
+		if report := last.(syncStatusReport); report.sha == sha && report.state == state {
+			return
+		}
+	}
+
+	description = fmt.Sprintf("%s as of %s", description, now.UTC().Format("2006-01-02 15:04 MST"))
+	if len(description) > 140 {
+		// GitHub rejects longer descriptions.
+		description = description[:137] + "..."
+	}
+	_, _, err := r.client.Repositories.CreateStatus(ctx, dest.Owner, dest.Repo, sha, &github.RepoStatus{
+		State:       github.String(state),
+		Description: github.String(description),
+		TargetURL:   github.String(targetURL),
+		Context:     github.String(syncStatusContext),
+	})
+	if err != nil {
+		klog.V(2).Infof("%sfailed to report sync status for %s: %v", logctx.Prefix(ctx), dest, err)
+		return
+	}
+	r.syncStatuses.reports.Add(dest.String(), syncStatusReport{
+		sha:   sha,
+		state: state,
+	})
+}
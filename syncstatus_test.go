@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestReportSyncStatus(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay:heads/master"] = ref("new")
+	gh.Refs["quay/quay:heads/redhat-3.9"] = ref("old")
+	repo := configuration.Repository{
+		Owner: "quay",
+		Repo:  "quay",
+		Branches: []configuration.Branch{
+			{Name: "redhat-3.9", SyncFrom: configuration.BranchReference{Branch: "master"}, SyncStatus: true},
+		},
+	}
+	r := newAdminTestReactor(gh, &configuration.Configuration{Repositories: []configuration.Repository{repo}})
+	r.syncStatuses = NewSyncStatusReports()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := r.syncRepository(ctx, repo, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statuses := gh.Statuses["quay/quay:new"]
+	if len(statuses) != 1 {
+		t.Fatalf("got %d statuses, want 1", len(statuses))
+	}
+	status := statuses[0]
+	if status.GetState() != "success" || status.GetContext() != "quay-ci-app/sync" || status.GetTargetURL() != "https://github.com/quay/quay/tree/master" {
+		t.Errorf("got the status %s %s %s", status.GetState(), status.GetContext(), status.GetTargetURL())
+	}
+	if !strings.HasPrefix(status.GetDescription(), "Mirror of quay/quay:master, synced as of ") {
+		t.Errorf("got the description %q", status.GetDescription())
+	}
+
+	// The branch is paused.
+	gh.Refs["quay/quay:heads/master"] = ref("newer")
+	gh.Contents["quay/quay:"+syncPausedFile] = ""
+	if err := r.syncRepository(ctx, repo, ""); err != nil {
+		t.Fatal(err)
+	}
+	statuses = gh.Statuses["quay/quay:new"]
+	if len(statuses) != 2 || statuses[1].GetState() != "pending" {
+		t.Errorf("the paused branch should have a pending status, got %d statuses", len(statuses))
+	}
+}
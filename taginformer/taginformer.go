@@ -6,17 +6,167 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/cache"
 	"k8s.io/klog/v2"
 )
 
-var refVersionRegex = regexp.MustCompile(`^refs/tags/v(\d+\.\d+)\.(\d+)$`)
+// versionRegex matches semantic versions, e.g. 3.9.4, 3.10.0-rc.1 or
+// 3.9.4+build.5.
+var versionRegex = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
+
+// Version is a parsed version tag.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	Build      string
+}
+
+// XY returns the y-stream of the version, e.g. 3.9.
+func (v Version) XY() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// ParseVersion parses a semantic version like 3.9.4 or 3.10.0-rc.1.
+func ParseVersion(version string) (Version, bool) {
+	match := versionRegex.FindStringSubmatch(version)
+	if match == nil {
+		return Version{}, false
+	}
+	var v Version
+	var err error
+	for i, n := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		*n, err = strconv.Atoi(match[i+1])
+		if err != nil {
+			return Version{}, false
+		}
+	}
+	v.PreRelease = match[4]
+	v.Build = match[5]
+	return v, true
+}
+
+// TagPattern describes how versions are tagged in a repository, e.g.
+// v{version} for v3.9.4 or quay-v{version} for quay-v3.9.4.
+type TagPattern struct {
+	prefix string
+	suffix string
+}
+
+const versionPlaceholder = "{version}"
+
+// DefaultTagPattern is used for repositories that don't configure their tag
+// pattern.
+var DefaultTagPattern = TagPattern{prefix: "v"}
+
+// ParseTagPattern parses a tag template that has exactly one {version}
+// placeholder. An empty template is the default pattern v{version}.
+func ParseTagPattern(template string) (TagPattern, error) {
+	if template == "" {
+		return DefaultTagPattern, nil
+	}
+	if strings.Count(template, versionPlaceholder) != 1 {
+		return TagPattern{}, fmt.Errorf("tag pattern %q should have exactly one %s placeholder", template, versionPlaceholder)
+	}
+	i := strings.Index(template, versionPlaceholder)
+	return TagPattern{
+		prefix: template[:i],
+		suffix: template[i+len(versionPlaceholder):],
+	}, nil
+}
+
+func (p TagPattern) String() string {
+	return p.prefix + versionPlaceholder + p.suffix
+}
+
+// Parse parses a tag that matches the pattern.
+func (p TagPattern) Parse(tag string) (Version, bool) {
+	if !strings.HasPrefix(tag, p.prefix) || !strings.HasSuffix(tag, p.suffix) || len(tag) < len(p.prefix)+len(p.suffix) {
+		return Version{}, false
+	}
+	return ParseVersion(tag[len(p.prefix) : len(tag)-len(p.suffix)])
+}
+
+// ParseTag parses a release tag into its y-stream and patch version.
+// Pre-release tags are not release tags.
+func (p TagPattern) ParseTag(tag string) (xy string, z int, ok bool) {
+	v, ok := p.Parse(tag)
+	if !ok || v.PreRelease != "" {
+		return "", 0, false
+	}
+	return v.XY(), v.Patch, true
+}
+
+// ParseTag parses a release tag like v3.9.4 into its y-stream (3.9) and patch
+// version (4) using the default tag pattern.
+func ParseTag(tag string) (xy string, z int, ok bool) {
+	return DefaultTagPattern.ParseTag(tag)
+}
 
 type YStream struct {
 	// patchVersions are sorted and unique.
 	patchVersions []int
+
+	// preReleases are the pre-release identifiers of each patch version
+	// (e.g. rc.1), they don't affect the next patch version.
+	preReleases map[int]map[string]bool
+}
+
+func (y *YStream) AddPreRelease(z int, preRelease string) {
+	if y.preReleases == nil {
+		y.preReleases = map[int]map[string]bool{}
+	}
+	if y.preReleases[z] == nil {
+		y.preReleases[z] = map[string]bool{}
+	}
+	y.preReleases[z][preRelease] = true
+}
+
+func (y *YStream) RemovePreRelease(z int, preRelease string) {
+	delete(y.preReleases[z], preRelease)
+	if len(y.preReleases[z]) == 0 {
+		delete(y.preReleases, z)
+	}
+}
+
+// PreReleases returns the sorted pre-release identifiers of the patch
+// version z.
+func (y *YStream) PreReleases(z int) []string {
+	if y == nil {
+		return nil
+	}
+	var result []string
+	for preRelease := range y.preReleases[z] {
+		result = append(result, preRelease)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (y *YStream) empty() bool {
+	return len(y.patchVersions) == 0 && len(y.preReleases) == 0
+}
+
+func (y *YStream) add(v Version) {
+	if v.PreRelease != "" {
+		y.AddPreRelease(v.Patch, v.PreRelease)
+	} else {
+		y.Add(v.Patch)
+	}
+}
+
+func (y *YStream) remove(v Version) {
+	if v.PreRelease != "" {
+		y.RemovePreRelease(v.Patch, v.PreRelease)
+	} else {
+		y.Remove(v.Patch)
+	}
 }
 
 func (y *YStream) Add(z int) {
@@ -45,76 +195,149 @@ func (y *YStream) Next() int {
 	return y.patchVersions[len(y.patchVersions)-1] + 1
 }
 
+// maxCachedRepositories limits the number of repositories whose tags are kept
+// in memory. Evicted repositories are fetched again on the next access.
+const maxCachedRepositories = 500
+
+// repositoryTags are the y-streams of a repository keyed by x.y.
+type repositoryTags struct {
+	streams   map[string]*YStream
+	fetchedAt time.Time
+}
+
 type TagInformer struct {
-	mutex  sync.Mutex
-	client *github.Client
-	synced map[string]bool
-	tags   map[string]*YStream
+	mutex      sync.Mutex
+	client     *github.Client
+	repos      *cache.Cache
+	tagPattern func(org, repo string) TagPattern
 }
 
-func New(client *github.Client) *TagInformer {
+// New creates a tag informer. tagPattern returns the tag pattern of a
+// repository, if it is nil, all repositories use the default pattern.
+func New(client *github.Client, tagPattern func(org, repo string) TagPattern) *TagInformer {
 	return &TagInformer{
-		client: client,
+		client:     client,
+		repos:      cache.New("tags", maxCachedRepositories, 0),
+		tagPattern: tagPattern,
+	}
+}
+
+// Pattern returns the tag pattern of the repository.
+func (ti *TagInformer) Pattern(org, repo string) TagPattern {
+	if ti.tagPattern == nil {
+		return DefaultTagPattern
+	}
+	return ti.tagPattern(org, repo)
+}
+
+func (ti *TagInformer) key(org, repo string) string {
+	return fmt.Sprintf("%s/%s", org, repo)
+}
+
+func (ti *TagInformer) repositoryTags(org, repo string) (*repositoryTags, bool) {
+	tags, ok := ti.repos.Get(ti.key(org, repo))
+	if !ok {
+		return nil, false
 	}
+	return tags.(*repositoryTags), true
 }
 
-func (ti *TagInformer) key(org, repo, xy string) string {
-	return fmt.Sprintf("%s/%s:%s", org, repo, xy)
+func (ti *TagInformer) InvalidateCache() {
+	ti.repos.Purge()
+}
+
+// InvalidateRepository drops the cached tags of the repository, they will be
+// fetched again on the next access.
+func (ti *TagInformer) InvalidateRepository(org, repo string) {
+	ti.repos.Remove(ti.key(org, repo))
 }
 
-func (ti *TagInformer) hasSynced(org, repo string) bool {
+// AddTag adds a pushed tag to the cached tags of the repository. If the tags
+// of the repository are not cached, AddTag does nothing as the tag will be
+// fetched with the others.
+func (ti *TagInformer) AddTag(org, repo, tag string) {
+	v, ok := ti.Pattern(org, repo).Parse(tag)
+	if !ok {
+		return
+	}
+	repoTags, ok := ti.repositoryTags(org, repo)
+	if !ok {
+		return
+	}
+
 	ti.mutex.Lock()
 	defer ti.mutex.Unlock()
-	return ti.synced[fmt.Sprintf("%s/%s", org, repo)]
+
+	if repoTags.streams[v.XY()] == nil {
+		repoTags.streams[v.XY()] = &YStream{}
+	}
+	repoTags.streams[v.XY()].add(v)
 }
 
-func (ti *TagInformer) InvalidateCache() {
+// RemoveTag removes a deleted tag from the cached tags of the repository.
+func (ti *TagInformer) RemoveTag(org, repo, tag string) {
+	v, ok := ti.Pattern(org, repo).Parse(tag)
+	if !ok {
+		return
+	}
+	repoTags, ok := ti.repositoryTags(org, repo)
+	if !ok {
+		return
+	}
+
 	ti.mutex.Lock()
 	defer ti.mutex.Unlock()
-	ti.synced = nil
-	ti.tags = nil
+
+	if stream := repoTags.streams[v.XY()]; stream != nil {
+		stream.remove(v)
+		if stream.empty() {
+			delete(repoTags.streams, v.XY())
+		}
+	}
 }
 
 func (ti *TagInformer) addRefs(org, repo string, tags []*github.Reference) {
+	pattern := ti.Pattern(org, repo)
+
 	ti.mutex.Lock()
 	defer ti.mutex.Unlock()
 
-	if ti.tags == nil {
-		ti.tags = map[string]*YStream{}
+	repoTags := &repositoryTags{
+		streams:   map[string]*YStream{},
+		fetchedAt: time.Now(),
 	}
-
 	for _, tag := range tags {
-		match := refVersionRegex.FindStringSubmatch(tag.GetRef())
-		if match != nil {
-			xy := match[1]
-			z := match[2]
-			zInt, err := strconv.Atoi(z)
-			if err != nil {
-				// should never happen
-				continue
-			}
-			key := ti.key(org, repo, xy)
-			if ti.tags[key] == nil {
-				ti.tags[key] = &YStream{}
-			}
-			ti.tags[key].Add(zInt)
+		v, ok := pattern.Parse(strings.TrimPrefix(tag.GetRef(), "refs/tags/"))
+		if !ok {
+			continue
+		}
+		if repoTags.streams[v.XY()] == nil {
+			repoTags.streams[v.XY()] = &YStream{}
 		}
+		repoTags.streams[v.XY()].add(v)
 	}
 
-	if ti.synced == nil {
-		ti.synced = map[string]bool{}
-	}
-	ti.synced[fmt.Sprintf("%s/%s", org, repo)] = true
+	ti.repos.Add(ti.key(org, repo), repoTags)
 }
 
 func (ti *TagInformer) init(org, repo string) error {
 	klog.V(4).Infof("initializing tag informer for %s/%s", org, repo)
 
-	tags, _, err := ti.client.Git.ListMatchingRefs(context.Background(), org, repo, &github.ReferenceListOptions{
-		Ref: "tags/v",
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list tags: %w", err)
+	var tags []*github.Reference
+	opts := &github.ReferenceListOptions{
+		Ref:         "tags/" + ti.Pattern(org, repo).prefix,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		page, resp, err := ti.client.Git.ListMatchingRefs(context.Background(), org, repo, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list tags: %w", err)
+		}
+		tags = append(tags, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
 	}
 
 	ti.addRefs(org, repo, tags)
@@ -122,17 +345,91 @@ func (ti *TagInformer) init(org, repo string) error {
 	return nil
 }
 
-func (ti *TagInformer) NextVersion(org, repo, xy string) (string, error) {
-	if !ti.hasSynced(org, repo) {
+// Refresh fetches the tags of the repository again if they are cached and
+// were fetched more than ttl ago. Repositories that are not cached are left
+// alone, they will be fetched on the next access.
+func (ti *TagInformer) Refresh(org, repo string, ttl time.Duration) error {
+	repoTags, ok := ti.repositoryTags(org, repo)
+	if !ok {
+		return nil
+	}
+
+	ti.mutex.Lock()
+	stale := time.Since(repoTags.fetchedAt) > ttl
+	ti.mutex.Unlock()
+	if !stale {
+		return nil
+	}
+
+	klog.V(4).Infof("refreshing tags for %s/%s", org, repo)
+	return ti.init(org, repo)
+}
+
+// Repositories returns the sorted repositories whose tags are cached, as
+// owner/repo.
+func (ti *TagInformer) Repositories() []string {
+	repos := ti.repos.Keys()
+	sort.Strings(repos)
+	return repos
+}
+
+// StreamSnapshot is a copy of the cached tags of a y-stream.
+type StreamSnapshot struct {
+	PatchVersions []int            `json:"patchVersions"`
+	PreReleases   map[int][]string `json:"preReleases,omitempty"`
+}
+
+// Snapshot returns a copy of the cached tags of the repository and when they
+// were fetched.
+func (ti *TagInformer) Snapshot(org, repo string) (map[string]StreamSnapshot, time.Time, bool) {
+	repoTags, ok := ti.repositoryTags(org, repo)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	streams := make(map[string]StreamSnapshot, len(repoTags.streams))
+	for xy, stream := range repoTags.streams {
+		snapshot := StreamSnapshot{
+			PatchVersions: append([]int{}, stream.patchVersions...),
+		}
+		for z := range stream.preReleases {
+			if snapshot.PreReleases == nil {
+				snapshot.PreReleases = map[int][]string{}
+			}
+			snapshot.PreReleases[z] = stream.PreReleases(z)
+		}
+		streams[xy] = snapshot
+	}
+	return streams, repoTags.fetchedAt, true
+}
+
+// NextPatchVersion returns the patch version of the next release of the
+// y-stream xy.
+func (ti *TagInformer) NextPatchVersion(org, repo, xy string) (int, error) {
+	repoTags, ok := ti.repositoryTags(org, repo)
+	if !ok {
 		if err := ti.init(org, repo); err != nil {
-			return "", err
+			return 0, err
+		}
+		repoTags, ok = ti.repositoryTags(org, repo)
+		if !ok {
+			return 0, fmt.Errorf("tags for %s/%s were evicted from the cache", org, repo)
 		}
 	}
 
 	ti.mutex.Lock()
 	defer ti.mutex.Unlock()
 
-	key := ti.key(org, repo, xy)
-	z := ti.tags[key].Next()
+	return repoTags.streams[xy].Next(), nil
+}
+
+func (ti *TagInformer) NextVersion(org, repo, xy string) (string, error) {
+	z, err := ti.NextPatchVersion(org, repo, xy)
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%s.%d", xy, z), nil
 }
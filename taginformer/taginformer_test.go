@@ -0,0 +1,242 @@
+package taginformer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+)
+
+func TestNextVersionPaginated(t *testing.T) {
+	var tags []*github.Reference
+	for z := 0; z < 250; z++ {
+		tags = append(tags, &github.Reference{
+			Ref: github.String(fmt.Sprintf("refs/tags/v3.8.%d", z)),
+		})
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/quay/quay/git/matching-refs/tags/v" {
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage == 0 {
+			perPage = 30
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		start := (page - 1) * perPage
+		end := start + perPage
+		if end < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d&per_page=%d>; rel="next"`, server.URL, r.URL.Path, page+1, perPage))
+		} else {
+			end = len(tags)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tags[start:end])
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ti := New(client, nil)
+	got, err := ti.NextVersion("quay", "quay", "3.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3.8.250"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tags := []*github.Reference{
+		{Ref: github.String("refs/tags/v3.8.0")},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tags)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	ti := New(client, nil)
+
+	if err := ti.Refresh("quay", "quay", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ti.repositoryTags("quay", "quay"); ok {
+		t.Errorf("Refresh should not fetch the tags of repositories that are not cached")
+	}
+
+	if got, _ := ti.NextVersion("quay", "quay", "3.8"); got != "3.8.1" {
+		t.Errorf("got %s, want 3.8.1", got)
+	}
+
+	tags = append(tags, &github.Reference{Ref: github.String("refs/tags/v3.8.1")})
+	if err := ti.Refresh("quay", "quay", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ti.NextVersion("quay", "quay", "3.8"); got != "3.8.1" {
+		t.Errorf("got %s, want 3.8.1 as the tags are not stale yet", got)
+	}
+	if err := ti.Refresh("quay", "quay", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ti.NextVersion("quay", "quay", "3.8"); got != "3.8.2" {
+		t.Errorf("got %s, want 3.8.2 after refresh", got)
+	}
+}
+
+func TestAddRemoveTag(t *testing.T) {
+	ti := New(nil, nil)
+	ti.addRefs("quay", "quay", []*github.Reference{
+		{Ref: github.String("refs/tags/v3.8.0")},
+		{Ref: github.String("refs/tags/v3.8.1")},
+	})
+
+	ti.AddTag("quay", "quay", "v3.8.2")
+	ti.AddTag("quay", "quay", "v3.9.0")
+	ti.AddTag("quay", "quay", "latest")
+	ti.RemoveTag("quay", "quay", "v3.8.2")
+	ti.RemoveTag("quay", "quay", "v3.8.1")
+
+	for xy, want := range map[string]string{"3.8": "3.8.1", "3.9": "3.9.1", "3.10": "3.10.0"} {
+		got, err := ti.NextVersion("quay", "quay", xy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", xy, got, want)
+		}
+	}
+
+	// The tags of other repositories are fetched on the next access.
+	ti.AddTag("quay", "other", "v3.8.0")
+	if _, ok := ti.repositoryTags("quay", "other"); ok {
+		t.Errorf("AddTag should not cache an incomplete list of tags")
+	}
+
+	ti.InvalidateRepository("quay", "quay")
+	if _, ok := ti.repositoryTags("quay", "quay"); ok {
+		t.Errorf("the tags of quay/quay should be invalidated")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    Version
+		wantOK  bool
+	}{
+		{version: "3.9.4", want: Version{Major: 3, Minor: 9, Patch: 4}, wantOK: true},
+		{version: "3.10.0-rc.1", want: Version{Major: 3, Minor: 10, Patch: 0, PreRelease: "rc.1"}, wantOK: true},
+		{version: "3.9.4+build.5", want: Version{Major: 3, Minor: 9, Patch: 4, Build: "build.5"}, wantOK: true},
+		{version: "3.9", wantOK: false},
+		{version: "v3.9.4", wantOK: false},
+		{version: "3.9.4-", wantOK: false},
+		{version: "latest", wantOK: false},
+	}
+	for _, tc := range testCases {
+		got, ok := ParseVersion(tc.version)
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("%s: got %+v, %t, want %+v, %t", tc.version, got, ok, tc.want, tc.wantOK)
+		}
+	}
+
+	if _, _, ok := ParseTag("v3.10.0-rc.1"); ok {
+		t.Errorf("pre-release tags should not be parsed as release tags")
+	}
+}
+
+func TestTagPattern(t *testing.T) {
+	testCases := []struct {
+		template string
+		tag      string
+		wantXY   string
+		wantZ    int
+		wantOK   bool
+	}{
+		{template: "", tag: "v3.9.1", wantXY: "3.9", wantZ: 1, wantOK: true},
+		{template: "", tag: "3.9.1", wantOK: false},
+		{template: "{version}", tag: "3.9.1", wantXY: "3.9", wantZ: 1, wantOK: true},
+		{template: "{version}", tag: "v3.9.1", wantOK: false},
+		{template: "quay-v{version}", tag: "quay-v3.9.1", wantXY: "3.9", wantZ: 1, wantOK: true},
+		{template: "quay-v{version}", tag: "clair-v3.9.1", wantOK: false},
+		{template: "release-{version}-final", tag: "release-3.9.1-final", wantXY: "3.9", wantZ: 1, wantOK: true},
+		{template: "release-{version}-final", tag: "release--final", wantOK: false},
+	}
+	for _, tc := range testCases {
+		pattern, err := ParseTagPattern(tc.template)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.template, err)
+		}
+		xy, z, ok := pattern.ParseTag(tc.tag)
+		if xy != tc.wantXY || z != tc.wantZ || ok != tc.wantOK {
+			t.Errorf("%q, %s: got %s, %d, %t, want %s, %d, %t", tc.template, tc.tag, xy, z, ok, tc.wantXY, tc.wantZ, tc.wantOK)
+		}
+	}
+
+	for _, template := range []string{"v", "{version}-{version}"} {
+		if _, err := ParseTagPattern(template); err == nil {
+			t.Errorf("%q: expected an error", template)
+		}
+	}
+}
+
+func TestNextVersionPreReleases(t *testing.T) {
+	ti := New(nil, nil)
+	ti.addRefs("quay", "quay", []*github.Reference{
+		{Ref: github.String("refs/tags/v3.9.0")},
+		{Ref: github.String("refs/tags/v3.9.1-rc.1")},
+		{Ref: github.String("refs/tags/v3.10.0-rc.1")},
+		{Ref: github.String("refs/tags/v3.10.0-rc.2")},
+	})
+
+	for xy, want := range map[string]string{"3.9": "3.9.1", "3.10": "3.10.0"} {
+		got, err := ti.NextVersion("quay", "quay", xy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", xy, got, want)
+		}
+	}
+
+	repoTags, _ := ti.repositoryTags("quay", "quay")
+	if got, want := repoTags.streams["3.10"].PreReleases(0), []string{"rc.1", "rc.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got pre-releases %v, want %v", got, want)
+	}
+
+	streams, _, ok := ti.Snapshot("quay", "quay")
+	if !ok {
+		t.Fatal("the tags of quay/quay should be cached")
+	}
+	wantStreams := map[string]StreamSnapshot{
+		"3.9":  {PatchVersions: []int{0}, PreReleases: map[int][]string{1: {"rc.1"}}},
+		"3.10": {PatchVersions: []int{}, PreReleases: map[int][]string{0: {"rc.1", "rc.2"}}},
+	}
+	if !reflect.DeepEqual(streams, wantStreams) {
+		t.Errorf("got snapshot %+v, want %+v", streams, wantStreams)
+	}
+
+	ti.RemoveTag("quay", "quay", "v3.10.0-rc.1")
+	ti.RemoveTag("quay", "quay", "v3.10.0-rc.2")
+	if _, ok := repoTags.streams["3.10"]; ok {
+		t.Errorf("the 3.10 stream should be removed with its last tag")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/logctx"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// mirrorTag creates the tag that was pushed to org/repo in the repositories
+// that mirror its tags. A tag that already exists in a mirror is moved to the
+// new object.
+func (r reactor) mirrorTag(ctx context.Context, cfg *configuration.Configuration, org, repo, tag string) error {
+	mirrors := cfg.TagMirrors(org, repo, tag)
+	if len(mirrors) == 0 {
+		return nil
+	}
+
+	sourceRef, _, err := r.client.Git.GetRef(ctx, org, repo, "tags/"+tag)
+	if err != nil {
+		return fmt.Errorf("failed to get the tag %s of %s/%s: %w", tag, org, repo, err)
+	}
+	var annotated *github.Tag
+	if sourceRef.GetObject().GetType() == "tag" {
+		annotated, _, err = r.client.Git.GetTag(ctx, org, repo, sourceRef.GetObject().GetSHA())
+		if err != nil {
+			return fmt.Errorf("failed to get the tag object %s of %s/%s: %w", tag, org, repo, err)
+		}
+	}
+
+	var errs []error
+	for _, mirror := range mirrors {
+		if err := r.mirrorTagTo(ctx, mirror, tag, sourceRef, annotated); err != nil {
+			errs = append(errs, fmt.Errorf("failed to mirror the tag %s to %s/%s: %w", tag, mirror.Owner, mirror.Repo, err))
+			continue
+		}
+		r.activity.Record(mirror.Owner, mirror.Repo, activity.Event{
+			Type:    activity.TypeSync,
+			Summary: fmt.Sprintf("Mirrored the tag %s from %s/%s", tag, org, repo),
+		})
+	}
+	return errors.NewAggregate(errs)
+}
+
+// mirrorTagTo creates or moves the tag in the mirror. The annotated tags are
+// recreated in the mirror, the objects of the source repository are not
+// available there.
+func (r reactor) mirrorTagTo(ctx context.Context, mirror configuration.BranchReference, tag string, sourceRef *github.Reference, annotated *github.Tag) error {
+	sha := sourceRef.GetObject().GetSHA()
+	if annotated != nil {
+		created, _, err := r.client.Git.CreateTag(ctx, mirror.Owner, mirror.Repo, &github.Tag{
+			Tag:     annotated.Tag,
+			Message: annotated.Message,
+			Tagger:  annotated.Tagger,
+			Object:  annotated.Object,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create the tag object: %w", err)
+		}
+		sha = created.GetSHA()
+	}
+
+	ref := &github.Reference{
+		Ref:    github.String("tags/" + tag),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+	existing, resp, err := r.client.Git.GetRef(ctx, mirror.Owner, mirror.Repo, "tags/"+tag)
+	if err != nil {
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			return err
+		}
+		klog.V(2).Infof("%screating the tag %s in %s/%s (%s)...", logctx.Prefix(ctx), tag, mirror.Owner, mirror.Repo, sha)
+		_, _, err = r.client.Git.CreateRef(ctx, mirror.Owner, mirror.Repo, &github.Reference{
+			Ref:    github.String("refs/tags/" + tag),
+			Object: ref.Object,
+		})
+		return err
+	}
+	if existing.GetObject().GetSHA() == sha {
+		return nil
+	}
+	klog.V(2).Infof("%smoving the tag %s in %s/%s (%s -> %s)...", logctx.Prefix(ctx), tag, mirror.Owner, mirror.Repo, existing.GetObject().GetSHA(), sha)
+	_, _, err = r.client.Git.UpdateRef(ctx, mirror.Owner, mirror.Repo, ref, true)
+	return err
+}
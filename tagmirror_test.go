@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func TestMirrorTag(t *testing.T) {
+	gh := fakes.NewGitHub()
+	gh.Refs["quay/quay-upstream:tags/v3.9.0"] = &github.Reference{Object: &github.GitObject{SHA: github.String("abc"), Type: github.String("commit")}}
+	gh.Refs["quay/quay-upstream:tags/v3.9.1"] = &github.Reference{Object: &github.GitObject{SHA: github.String("t391"), Type: github.String("tag")}}
+	gh.Tags["quay/quay-upstream:t391"] = &github.Tag{
+		Tag:     github.String("v3.9.1"),
+		Message: github.String("Quay 3.9.1"),
+		Object:  &github.GitObject{SHA: github.String("def"), Type: github.String("commit")},
+	}
+	gh.Refs["quay/quay-upstream:tags/nightly"] = ref("ghi")
+	gh.Refs["quay/quay:tags/v3.9.0"] = ref("old")
+	cfg := &configuration.Configuration{
+		Repositories: []configuration.Repository{
+			{Owner: "quay", Repo: "quay-upstream"},
+			{Owner: "quay", Repo: "quay", MirrorTags: &configuration.MirrorTags{Repo: "quay-upstream", Pattern: "v*"}},
+		},
+	}
+	r := newAdminTestReactor(gh, cfg)
+	ctx := context.Background()
+
+	for _, tag := range []string{"v3.9.0", "v3.9.1", "nightly"} {
+		if err := r.mirrorTag(ctx, r.cfg.Get(), "quay", "quay-upstream", tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sha := gh.Refs["quay/quay:tags/v3.9.0"].GetObject().GetSHA(); sha != "abc" {
+		t.Errorf("the tag v3.9.0 should be moved to abc, got %s", sha)
+	}
+	sha := gh.Refs["quay/quay:tags/v3.9.1"].GetObject().GetSHA()
+	if tag := gh.Tags["quay/quay:"+sha]; tag.GetMessage() != "Quay 3.9.1" || tag.GetObject().GetSHA() != "def" {
+		t.Errorf("the annotated tag v3.9.1 should be recreated, got %+v", tag)
+	}
+	if _, ok := gh.Refs["quay/quay:tags/nightly"]; ok {
+		t.Errorf("the tag nightly does not match the pattern")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// certificateCheckInterval is how often the certificate files are checked for
+// changes.
+const certificateCheckInterval = 10 * time.Second
+
+// CertificateReloader serves the TLS certificate from the files and loads it
+// again when they change, e.g. when cert-manager rotates the certificate. If
+// the new files can't be loaded, the previous certificate is kept.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	c := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(modTime); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// filesModTime returns the modification time of the newer file.
+func (c *CertificateReloader) filesModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, filename := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+func (c *CertificateReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// GetCertificate can be used as GetCertificate of tls.Config.
+func (c *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now := time.Now(); now.Sub(c.checked) >= certificateCheckInterval {
+		c.checked = now
+		modTime, err := c.filesModTime()
+		if err != nil {
+			klog.Errorf("failed to check the TLS certificate files: %v", err)
+		} else if !modTime.Equal(c.modTime) {
+			if err := c.load(modTime); err != nil {
+				klog.Errorf("keeping the previous TLS certificate: %v", err)
+			} else {
+				klog.Infof("reloaded the TLS certificate from %s", c.certFile)
+			}
+		}
+	}
+	return c.cert, nil
+}
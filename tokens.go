@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+var permissionLevels = map[string]int{
+	"read":  1,
+	"write": 2,
+	"admin": 3,
+}
+
+type TokenRequest struct {
+	Repository  string            `json:"repository"`
+	Permissions map[string]string `json:"permissions"`
+}
+
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TokenMinter serves installation tokens that are scoped to a single
+// repository and a subset of the permissions granted to the client.
+type TokenMinter struct {
+	appClient      *github.Client
+	installationID int64
+	clients        []configuration.TokenClient
+}
+
+func (tm *TokenMinter) authenticate(r *http.Request) *configuration.TokenClient {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+	hash := hex.EncodeToString(sum[:])
+	for i := range tm.clients {
+		client := &tm.clients[i]
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(client.TokenSHA256)), []byte(hash)) == 1 {
+			return client
+		}
+	}
+	return nil
+}
+
+func validateTokenRequest(client *configuration.TokenClient, req TokenRequest) error {
+	allowedRepository := false
+	for _, repo := range client.Repositories {
+		if repo == req.Repository {
+			allowedRepository = true
+			break
+		}
+	}
+	if !allowedRepository {
+		return fmt.Errorf("repository %q is not allowed for %s", req.Repository, client.Name)
+	}
+	if len(req.Permissions) == 0 {
+		return fmt.Errorf("no permissions requested")
+	}
+	if err := configuration.CheckTokenPermissions(req.Permissions); err != nil {
+		return err
+	}
+	for name, level := range req.Permissions {
+		requested := permissionLevels[level]
+		allowed := permissionLevels[client.Permissions[name]]
+		if requested > allowed {
+			return fmt.Errorf("permission %s:%s is not allowed for %s", name, level, client.Name)
+		}
+	}
+	return nil
+}
+
+func (tm *TokenMinter) mint(ctx context.Context, req TokenRequest) (*github.InstallationToken, error) {
+	parts := strings.SplitN(req.Repository, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid repository %q, expected owner/repo", req.Repository)
+	}
+
+	// InstallationPermissions uses the same names as the GitHub API, so the
+	// requested permissions can be converted through JSON. A name that it
+	// doesn't know would be dropped, and a token without permissions gets all
+	// permissions of the installation.
+	buf, err := json.Marshal(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	var permissions github.InstallationPermissions
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&permissions); err != nil {
+		return nil, fmt.Errorf("invalid permissions: %w", err)
+	}
+	if permissions == (github.InstallationPermissions{}) {
+		return nil, fmt.Errorf("no permissions requested")
+	}
+
+	token, _, err := tm.appClient.Apps.CreateInstallationToken(ctx, tm.installationID, &github.InstallationTokenOptions{
+		Repositories: []string{parts[1]},
+		Permissions:  &permissions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %w", err)
+	}
+	return token, nil
+}
+
+func (tm *TokenMinter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	client := tm.authenticate(r)
+	if client == nil {
+		klog.V(2).Infof("unauthenticated token request from %s", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := configuration.CheckTokenPermissions(req.Permissions); err != nil {
+		klog.V(2).Infof("invalid token request from %s: %v", client.Name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateTokenRequest(client, req); err != nil {
+		klog.V(2).Infof("rejected token request from %s: %v", client.Name, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	token, err := tm.mint(r.Context(), req)
+	if err != nil {
+		klog.Errorf("failed to mint token for %s: %v", client.Name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	klog.V(2).Infof("minted token for %s: %s %v", client.Name, req.Repository, req.Permissions)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(TokenResponse{
+		Token:     token.GetToken(),
+		ExpiresAt: token.GetExpiresAt(),
+	})
+	if err != nil {
+		klog.Errorf("failed to encode token response: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+)
+
+func TestValidateTokenRequest(t *testing.T) {
+	client := &configuration.TokenClient{
+		Name:         "release-tool",
+		Repositories: []string{"quay/quay"},
+		Permissions: map[string]string{
+			"contents":      "write",
+			"pull_requests": "read",
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		req     TokenRequest
+		wantErr bool
+	}{
+		{
+			name: "allowed permissions",
+			req: TokenRequest{
+				Repository:  "quay/quay",
+				Permissions: map[string]string{"contents": "read", "pull_requests": "read"},
+			},
+		},
+		{
+			name: "repository is not allowed",
+			req: TokenRequest{
+				Repository:  "quay/clair",
+				Permissions: map[string]string{"contents": "read"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "permission level is too high",
+			req: TokenRequest{
+				Repository:  "quay/quay",
+				Permissions: map[string]string{"pull_requests": "write"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "permission is not granted",
+			req: TokenRequest{
+				Repository:  "quay/quay",
+				Permissions: map[string]string{"administration": "read"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown permission",
+			req: TokenRequest{
+				Repository:  "quay/quay",
+				Permissions: map[string]string{"content": "read"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown level",
+			req: TokenRequest{
+				Repository:  "quay/quay",
+				Permissions: map[string]string{"contents": "none"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "no permissions",
+			req: TokenRequest{
+				Repository: "quay/quay",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		err := validateTokenRequest(client, tc.req)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestTokenMinterUnknownPermissions(t *testing.T) {
+	minted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		minted++
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"token":"ghs_token"}`))
+	}))
+	defer server.Close()
+	appClient := github.NewClient(nil)
+	appClient.BaseURL, _ = url.Parse(server.URL + "/")
+
+	sum := sha256.Sum256([]byte("secret"))
+	tm := &TokenMinter{
+		appClient:      appClient,
+		installationID: 1,
+		clients: []configuration.TokenClient{{
+			Name:         "release-tool",
+			TokenSHA256:  hex.EncodeToString(sum[:]),
+			Repositories: []string{"quay/quay"},
+			Permissions:  map[string]string{"contents": "write"},
+		}},
+	}
+
+	for body, want := range map[string]int{
+		`{"repository":"quay/quay","permissions":{"content":"read"}}`:  http.StatusBadRequest,
+		`{"repository":"quay/quay","permissions":{"contents":"all"}}`:  http.StatusBadRequest,
+		`{"repository":"quay/quay","permissions":{}}`:                  http.StatusForbidden,
+		`{"repository":"quay/quay","permissions":{"contents":"read"}}`: http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		tm.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: got status %d, want %d", body, w.Code, want)
+		}
+	}
+	if minted != 1 {
+		t.Errorf("got %d minted tokens, want 1", minted)
+	}
+
+	// A token without permissions would get all permissions of the
+	// installation.
+	if _, err := tm.mint(context.Background(), TokenRequest{Repository: "quay/quay", Permissions: map[string]string{"content": "read"}}); err == nil {
+		t.Error("want an error for an unknown permission")
+	}
+	if _, err := tm.mint(context.Background(), TokenRequest{Repository: "quay/quay"}); err == nil {
+		t.Error("want an error for no permissions")
+	}
+	if minted != 1 {
+		t.Errorf("got %d minted tokens, want 1", minted)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quay/quay-ci-app/configuration"
+	"k8s.io/klog/v2"
+)
+
+// validateConfig reports the problems of the configuration file or directory,
+// the unknown fields first. It returns false if there are any.
+func validateConfig(w io.Writer, filename string) bool {
+	cfg, unknown, err := configuration.DecodeFromFile(filename)
+	report := func(err *configuration.FieldError) {
+		if err.File != "" {
+			fmt.Fprintln(w, err)
+		} else {
+			fmt.Fprintf(w, "%s: %v\n", filename, err)
+		}
+	}
+	for _, fieldErr := range unknown {
+		report(fieldErr)
+	}
+	if err != nil {
+		var fieldErrs configuration.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			for _, fieldErr := range fieldErrs {
+				report(fieldErr)
+			}
+		} else {
+			fmt.Fprintf(w, "%s: %v\n", filename, err)
+		}
+		return false
+	}
+
+	errs := cfg.Validate()
+	for _, fieldErr := range errs {
+		report(fieldErr)
+	}
+	return len(unknown) == 0 && len(errs) == 0
+}
+
+// runValidate checks the configuration file without starting the app. It
+// exits with a non-zero status if the configuration has problems.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	filename := fs.String("config", *configFile, "configuration file or directory to validate")
+	_ = fs.Parse(args)
+
+	if !validateConfig(os.Stderr, *filename) {
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok\n", *filename)
+}
+
+// runSchema prints the JSON Schema of the configuration file.
+func runSchema() {
+	schema, err := configuration.Schema()
+	if err != nil {
+		klog.Exit(err)
+	}
+	os.Stdout.Write(schema)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(dir, name)
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	ok := validateConfig(&buf, writeConfig(t, dir, "invalid.yaml", `
+app_id: 1
+installation_id: 2
+repositories:
+- owner: quay
+  repo: quay
+  jira:
+    key: PROJQUAY
+    rules:
+    - when:
+        event: [merged]
+      transition_to: ON_QA
+    - when:
+        merged: true
+      transiton_to: ON_QA
+`))
+	if ok {
+		t.Errorf("expected the configuration to be invalid")
+	}
+	want := `DIR/invalid.yaml: repositories[0].jira.rules[1].transiton_to: unknown field
+DIR/invalid.yaml: repositories[0].jira.rules[0].when.event[0]: unknown event "merged", expected one of opened, edited, sync, closed, recheck
+DIR/invalid.yaml: repositories[0].jira.rules[1]: the rule does not do anything
+`
+	if got := string(bytes.ReplaceAll(buf.Bytes(), []byte(dir), []byte("DIR"))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if !validateConfig(&buf, writeConfig(t, dir, "valid.yaml", "app_id: 1\ninstallation_id: 2\n")) {
+		t.Errorf("expected the configuration to be valid, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if validateConfig(&buf, writeConfig(t, dir, "broken.yaml", "app_id: [")) {
+		t.Errorf("expected a parse error")
+	}
+}
+
+func TestValidateConfigDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "app.yaml", "app_id: 1\ninstallation_id: 2\n")
+	writeConfig(t, dir, "quay.yaml", `
+repositories:
+- owner: quay
+  repo: quay
+  branches:
+  - name: master
+  - name: master
+`)
+	writeConfig(t, dir, "clair.yaml", `
+repositories:
+- owner: quay
+  repo: clair
+  auto_merge:
+    methd: squash
+`)
+
+	var buf bytes.Buffer
+	if validateConfig(&buf, dir) {
+		t.Errorf("expected the configuration to be invalid")
+	}
+	want := `DIR/clair.yaml: repositories[0].auto_merge.methd: unknown field
+DIR/quay.yaml: repositories[0].branches[1]: duplicate branch master, it is already configured in branches[0]
+`
+	if got := string(bytes.ReplaceAll(buf.Bytes(), []byte(dir), []byte("DIR"))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	writeConfig(t, dir, "more.yaml", "app_id: 3\nrepositories:\n- owner: quay\n  repo: quay\n")
+	buf.Reset()
+	if validateConfig(&buf, dir) {
+		t.Errorf("expected the conflicts to be reported")
+	}
+	want = `DIR/clair.yaml: repositories[0].auto_merge.methd: unknown field
+DIR/more.yaml: app_id: is already set in DIR/app.yaml
+DIR/quay.yaml: repositories[0]: quay/quay is already configured in DIR/more.yaml
+`
+	if got := string(bytes.ReplaceAll(buf.Bytes(), []byte(dir), []byte("DIR"))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,280 @@
+// Package vault is a minimal client for HashiCorp Vault that reads and writes
+// the secrets of the app in the KV secrets engine, so that they don't have to
+// be written to disk.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceAccountTokenFile is the token of the service account of the pod,
+// which is used to log in with the Kubernetes auth method.
+const ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Client reads secrets from the Vault server at Address. It authenticates
+// with Token, or, if Role is set, it logs in with the Kubernetes auth method
+// mounted at AuthPath as Role using the service account token in JWTFile.
+type Client struct {
+	Address    string
+	Token      string
+	Role       string
+	AuthPath   string
+	JWTFile    string
+	HTTPClient *http.Client
+
+	mutex sync.Mutex
+	// token is the token of the last login or renewal, and expires is when
+	// its lease ends, zero if it doesn't expire.
+	token   string
+	expires time.Time
+}
+
+// New returns a client of the Vault server at address. If role is empty, the
+// client uses token, otherwise it logs in with the Kubernetes auth method.
+func New(address, token, role, authPath string) *Client {
+	return &Client{
+		Address:    strings.TrimSuffix(address, "/"),
+		Token:      token,
+		Role:       role,
+		AuthPath:   authPath,
+		JWTFile:    ServiceAccountTokenFile,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is an error response of the Vault server.
+type Error struct {
+	StatusCode int
+	Errors     []string `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("vault: %d %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+type secretResponse struct {
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// Read returns the value of key in the secret at path, e.g.
+// secret/data/quay-ci-app for the KV version 2 engine mounted at secret/, or
+// secret/quay-ci-app for the version 1 engine.
+func (c *Client) Read(ctx context.Context, path, key string) (string, error) {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	var resp secretResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to read the secret %s: %w", path, err)
+	}
+
+	data := resp.Data
+	// The KV version 2 engine wraps the data with its metadata.
+	if raw, ok := data["data"]; ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nil
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return "", fmt.Errorf("failed to decode the secret %s: %w", path, err)
+			}
+		}
+	}
+	raw, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("the secret %s has no key %s", path, key)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("the key %s of the secret %s is not a string", key, path)
+	}
+	return value, nil
+}
+
+// Write sets key to value in the secret at path and keeps its other keys. The
+// secret is created if it doesn't exist. Like for Read, path is the API path
+// of the secret, the paths with data/ after the mount, e.g.
+// secret/data/quay-ci-app, are written to the KV version 2 engine.
+func (c *Client) Write(ctx context.Context, path, key, value string) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+	path = strings.TrimPrefix(path, "/")
+	var resp secretResponse
+	err = c.do(ctx, http.MethodGet, "/v1/"+path, token, nil, &resp)
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusNotFound {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the secret %s: %w", path, err)
+	}
+
+	versioned := isVersionedPath(path)
+	data := map[string]interface{}{}
+	raw := resp.Data
+	if versioned {
+		raw = nil
+		if buf, ok := resp.Data["data"]; ok {
+			if err := json.Unmarshal(buf, &raw); err != nil {
+				return fmt.Errorf("failed to decode the secret %s: %w", path, err)
+			}
+		}
+	}
+	for k, v := range raw {
+		data[k] = v
+	}
+	data[key] = value
+
+	var body interface{} = data
+	if versioned {
+		body = map[string]interface{}{"data": data}
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/"+path, token, body, nil); err != nil {
+		return fmt.Errorf("failed to write the secret %s: %w", path, err)
+	}
+	return nil
+}
+
+// isVersionedPath reports whether path is a secret of the KV version 2 engine,
+// i.e. the segment after the mount is data.
+func isVersionedPath(path string) bool {
+	parts := strings.SplitN(path, "/", 3)
+	return len(parts) == 3 && parts[1] == "data"
+}
+
+// Renew extends the lease of the token of the client. If the token can't be
+// renewed and the client uses the Kubernetes auth method, it logs in again.
+func (c *Client) Renew(ctx context.Context) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+	var resp authResponse
+	err = c.do(ctx, http.MethodPost, "/v1/auth/token/renew-self", token, struct{}{}, &resp)
+	if err == nil {
+		c.setToken(resp)
+		return nil
+	}
+	if c.Role == "" {
+		return fmt.Errorf("failed to renew the token: %w", err)
+	}
+	return c.login(ctx)
+}
+
+// currentToken returns the token to authenticate with, and logs in if the
+// client has no token yet or its token has expired.
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	token, expires := c.token, c.expires
+	c.mutex.Unlock()
+	if token != "" && (expires.IsZero() || time.Now().Before(expires)) {
+		return token, nil
+	}
+	if c.Role == "" {
+		if c.Token == "" {
+			return "", fmt.Errorf("no Vault token: set VAULT_TOKEN or a role for the Kubernetes auth method")
+		}
+		c.mutex.Lock()
+		c.token = c.Token
+		c.mutex.Unlock()
+		return c.Token, nil
+	}
+	if err := c.login(ctx); err != nil {
+		return "", err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.token, nil
+}
+
+// login logs in with the Kubernetes auth method.
+func (c *Client) login(ctx context.Context) error {
+	jwt, err := os.ReadFile(c.JWTFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the service account token: %w", err)
+	}
+	body := map[string]string{
+		"role": c.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	}
+	var resp authResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/auth/"+strings.Trim(c.AuthPath, "/")+"/login", "", body, &resp); err != nil {
+		return fmt.Errorf("failed to log in to Vault as %s: %w", c.Role, err)
+	}
+	if resp.Auth.ClientToken == "" {
+		return fmt.Errorf("failed to log in to Vault as %s: no token in the response", c.Role)
+	}
+	c.setToken(resp)
+	return nil
+}
+
+func (c *Client) setToken(resp authResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if resp.Auth.ClientToken != "" {
+		c.token = resp.Auth.ClientToken
+	}
+	c.expires = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		c.expires = time.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Address+path, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		e := &Error{StatusCode: resp.StatusCode}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(e)
+		return e
+	}
+	// The writes of the KV engine respond with no content.
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,184 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestServer(t *testing.T, logins *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["role"] != "quay-ci-app" || body["jwt"] != "service-account-token" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			*logins++
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"login-token","lease_duration":3600,"renewable":false}}`))
+			return
+		case "/v1/auth/token/renew-self":
+			if r.Header.Get("X-Vault-Token") != "static-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":["lease is not renewable"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"static-token","lease_duration":3600,"renewable":true}}`))
+			return
+		}
+
+		if token := r.Header.Get("X-Vault-Token"); token != "static-token" && token != "login-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/quay-ci-app":
+			_, _ = w.Write([]byte(`{"data":{"data":{"private-key":"PEM","jira-token":"token"},"metadata":{"version":3}}}`))
+		case "/v1/kv/quay-ci-app":
+			_, _ = w.Write([]byte(`{"data":{"jira-token":"v1-token","data":"not versioned"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRead(t *testing.T) {
+	var logins int
+	server := newTestServer(t, &logins)
+	client := New(server.URL, "static-token", "", "kubernetes")
+	ctx := context.Background()
+
+	testCases := []struct {
+		path, key string
+		want      string
+		wantErr   bool
+	}{
+		{path: "secret/data/quay-ci-app", key: "private-key", want: "PEM"},
+		{path: "secret/data/quay-ci-app", key: "jira-token", want: "token"},
+		{path: "kv/quay-ci-app", key: "jira-token", want: "v1-token"},
+		{path: "kv/quay-ci-app", key: "data", want: "not versioned"},
+		{path: "secret/data/quay-ci-app", key: "missing", wantErr: true},
+		{path: "secret/data/other", key: "jira-token", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := client.Read(ctx, tc.path, tc.key)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s#%s: want an error, got %q", tc.path, tc.key, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s#%s: %v", tc.path, tc.key, err)
+		} else if got != tc.want {
+			t.Errorf("%s#%s: got %q, want %q", tc.path, tc.key, got, tc.want)
+		}
+	}
+
+	if err := client.Renew(ctx); err != nil {
+		t.Errorf("failed to renew the token: %v", err)
+	}
+	if _, err := New(server.URL, "wrong-token", "", "kubernetes").Read(ctx, "secret/data/quay-ci-app", "jira-token"); err == nil {
+		t.Errorf("want an error for a wrong token")
+	}
+}
+
+func TestKubernetesLogin(t *testing.T) {
+	var logins int
+	server := newTestServer(t, &logins)
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtFile, []byte("service-account-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client := New(server.URL, "", "quay-ci-app", "kubernetes")
+	client.JWTFile = jwtFile
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		got, err := client.Read(ctx, "secret/data/quay-ci-app", "jira-token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "token" {
+			t.Errorf("got %q, want token", got)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("the token should be reused, got %d logins", logins)
+	}
+
+	// The login token is not renewable, so the client logs in again.
+	if err := client.Renew(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 2 {
+		t.Errorf("the client should log in again, got %d logins", logins)
+	}
+
+	client = New(server.URL, "", "other", "kubernetes")
+	client.JWTFile = jwtFile
+	if _, err := client.Read(ctx, "secret/data/quay-ci-app", "jira-token"); err == nil {
+		t.Errorf("want an error for a wrong role")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	secrets := map[string]string{
+		"/v1/secret/data/quay-ci-app": `{"data":{"data":{"jira-token":"token"},"metadata":{"version":1}}}`,
+	}
+	written := map[string]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "static-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			written[r.URL.Path] = body
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		secret, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(secret))
+	}))
+	t.Cleanup(server.Close)
+	client := New(server.URL, "static-token", "", "kubernetes")
+	ctx := context.Background()
+
+	if err := client.Write(ctx, "secret/data/quay-ci-app", "private-key", "PEM"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := written["/v1/secret/data/quay-ci-app"]["data"].(map[string]interface{})
+	if data["private-key"] != "PEM" || data["jira-token"] != "token" {
+		t.Errorf("the secret should keep its keys and get the new one, got %v", written["/v1/secret/data/quay-ci-app"])
+	}
+
+	// The secret doesn't exist in the version 1 engine.
+	if err := client.Write(ctx, "kv/quay-ci-app", "webhook-secret", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if got := written["/v1/kv/quay-ci-app"]; len(got) != 1 || got["webhook-secret"] != "secret" {
+		t.Errorf("got the secret %v, want only the webhook secret", got)
+	}
+
+	if err := New(server.URL, "wrong-token", "", "kubernetes").Write(ctx, "kv/quay-ci-app", "key", "value"); err == nil {
+		t.Errorf("want an error for a wrong token")
+	}
+}
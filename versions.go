@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/taginformer"
+	"k8s.io/klog/v2"
+)
+
+type StreamVersions struct {
+	Branch        string           `json:"branch"`
+	Version       string           `json:"version,omitempty"`
+	PatchVersions []int            `json:"patchVersions"`
+	PreReleases   map[int][]string `json:"preReleases,omitempty"`
+	NextVersion   string           `json:"nextVersion,omitempty"`
+	Derivation    string           `json:"derivation,omitempty"`
+	Error         string           `json:"error,omitempty"`
+}
+
+type RepositoryVersions struct {
+	Repository string           `json:"repository"`
+	TagPattern string           `json:"tagPattern"`
+	FetchedAt  *time.Time       `json:"fetchedAt,omitempty"`
+	Streams    []StreamVersions `json:"streams"`
+}
+
+// VersionsHandler serves GET /versions, which shows how the fix versions of
+// the configured branches are computed. It fetches the tags and the Jira
+// projects that are not cached, so it is served behind the admin
+// authentication.
+type VersionsHandler struct {
+	cfg         *configuration.Store
+	jiraCheck   *checks.Jira
+	tagInformer *taginformer.TagInformer
+}
+
+func (vh *VersionsHandler) repositoryVersions(ctx context.Context, repo configuration.Repository) RepositoryVersions {
+	result := RepositoryVersions{
+		Repository: repo.Owner + "/" + repo.Repo,
+		TagPattern: vh.tagInformer.Pattern(repo.Owner, repo.Repo).String(),
+		Streams:    []StreamVersions{},
+	}
+	for _, branch := range repo.Branches {
+		if branch.Version == "" && branch.FixVersion == "" {
+			continue
+		}
+		stream := StreamVersions{
+			Branch:  branch.Name,
+			Version: branch.Version,
+		}
+		nextVersion, derivation, err := vh.jiraCheck.NextFixVersion(ctx, repo.Owner, repo.Repo, repo.Jira, branch)
+		if err != nil {
+			stream.Error = err.Error()
+		} else {
+			stream.NextVersion = nextVersion
+			stream.Derivation = derivation
+		}
+		result.Streams = append(result.Streams, stream)
+	}
+
+	// NextFixVersion fetches the tags if they are not cached yet, so the
+	// snapshot is taken afterwards.
+	streams, fetchedAt, ok := vh.tagInformer.Snapshot(repo.Owner, repo.Repo)
+	if ok {
+		result.FetchedAt = &fetchedAt
+	}
+	for i := range result.Streams {
+		snapshot := streams[result.Streams[i].Version]
+		result.Streams[i].PatchVersions = snapshot.PatchVersions
+		result.Streams[i].PreReleases = snapshot.PreReleases
+	}
+	return result
+}
+
+func (vh *VersionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	versions := []RepositoryVersions{}
+	for _, repo := range vh.cfg.Get().ExplicitRepositories() {
+		versions = append(versions, vh.repositoryVersions(r.Context(), repo))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(versions)
+	if err != nil {
+		klog.Errorf("failed to encode versions: %v", err)
+	}
+}
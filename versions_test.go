@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"github.com/quay/quay-ci-app/checks"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+	"github.com/quay/quay-ci-app/taginformer"
+)
+
+func TestVersionsHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var refs []*github.Reference
+		for _, tag := range []string{"v3.9.0", "v3.9.1"} {
+			refs = append(refs, &github.Reference{Ref: github.String("refs/tags/" + tag)})
+		}
+		json.NewEncoder(w).Encode(refs)
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	ti := taginformer.New(client, nil)
+
+	gh := fakes.NewGitHub()
+	cfg := configuration.NewStore(&configuration.Configuration{
+		Repositories: []configuration.Repository{{
+			Owner: "quay",
+			Repo:  "quay",
+			Jira:  configuration.Jira{Key: "PROJQUAY"},
+			Branches: []configuration.Branch{
+				{Name: "master"},
+				{Name: "redhat-3.9", Version: "3.9"},
+				{Name: "redhat-3.8", FixVersion: "3.8.5"},
+			},
+		}},
+	})
+	vh := &VersionsHandler{
+		cfg:         cfg,
+		jiraCheck:   checks.NewJira(gh.Client(), gh.Client(), fakes.NewJira().Client(), ti, nil, activity.NewRecorder(10), time.Minute, time.Minute),
+		tagInformer: ti,
+	}
+
+	w := httptest.NewRecorder()
+	vh.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/versions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var versions []RepositoryVersions
+	if err := json.NewDecoder(w.Body).Decode(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Repository != "quay/quay" || versions[0].FetchedAt == nil {
+		t.Fatalf("got the versions %+v, want the fetched versions of quay/quay", versions)
+	}
+	want := []StreamVersions{
+		{Branch: "redhat-3.9", Version: "3.9", PatchVersions: []int{0, 1}, NextVersion: "3.9.2", Derivation: "the next patch version after the 3.9 tags is 2"},
+		{Branch: "redhat-3.8", NextVersion: "3.8.5", Derivation: "the fix version is set in the branch configuration"},
+	}
+	if !reflect.DeepEqual(versions[0].Streams, want) {
+		t.Errorf("got the streams %+v, want %+v", versions[0].Streams, want)
+	}
+
+	w = httptest.NewRecorder()
+	vh.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/versions", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d for a POST, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestVersionsAuth(t *testing.T) {
+	cfg := configuration.NewStore(&configuration.Configuration{})
+	handler := newAdminAuth(cfg).Wrap(&VersionsHandler{cfg: cfg})
+
+	req := httptest.NewRequest(http.MethodGet, "/versions", nil)
+	req.RemoteAddr = "192.0.2.1:40000"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got status %d for a remote client, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/versions", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d for a loopback client, want %d", w.Code, http.StatusOK)
+	}
+}
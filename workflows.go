@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/activity"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const workflowRetriesMarker = "<!-- quay-ci-app: workflow retries exhausted -->"
+
+// workflowMarker returns the marker of the comments about the failures of the
+// workflow name.
+func workflowMarker(name string) string {
+	return fmt.Sprintf("<!-- quay-ci-app: workflow %q failed -->", name)
+}
+
+// hasComment reports whether the pull request has a comment with marker.
+func (r reactor) hasComment(ctx context.Context, org, repo string, number int, marker string) (bool, error) {
+	opts := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		comments, resp, err := r.client.Issues.ListComments(ctx, org, repo, number, opts)
+		if err != nil {
+			return false, fmt.Errorf("failed to list comments on %s/%s#%d: %w", org, repo, number, err)
+		}
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), marker) {
+				return true, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// HandleWorkflowRunComplete re-runs failed runs of flaky workflows. When the
+// retries are exhausted, the pull requests of the run are notified. If the
+// workflow is never re-run, each pull request is notified only once.
+func (r reactor) HandleWorkflowRunComplete(ctx context.Context, org, repo string, run *github.WorkflowRun) error {
+	if run.GetConclusion() != "failure" {
+		return nil
+	}
+	repoConfig, ok := r.cfg.Get().Repository(org, repo)
+	if !ok || !repoConfig.FlakyWorkflows.IsFlaky(run.GetName()) {
+		return nil
+	}
+
+	// The first attempt is not a retry.
+	retries := run.GetRunAttempt() - 1
+	maxRetries := repoConfig.FlakyWorkflows.MaxRetries
+	if retries < maxRetries {
+		klog.V(2).Infof("re-running workflow %s (run %d, attempt %d) in %s/%s...", run.GetName(), run.GetID(), run.GetRunAttempt(), org, repo)
+		_, err := r.client.Actions.RerunWorkflowByID(ctx, org, repo, run.GetID())
+		if err != nil {
+			return fmt.Errorf("failed to re-run workflow %s (run %d) in %s/%s: %w", run.GetName(), run.GetID(), org, repo, err)
+		}
+		for _, pr := range run.PullRequests {
+			r.activity.Record(org, repo, activity.Event{
+				Type:        activity.TypeCheck,
+				PullRequest: pr.GetNumber(),
+				Summary:     fmt.Sprintf("Re-ran the failed workflow %s (retry %d of %d)", run.GetName(), retries+1, maxRetries),
+			})
+		}
+		return nil
+	}
+	if retries > maxRetries {
+		// The run was re-run manually after the retries were exhausted.
+		return nil
+	}
+
+	var errs []error
+	marker := workflowMarker(run.GetName())
+	for _, pr := range run.PullRequests {
+		if maxRetries == 0 {
+			commented, err := r.hasComment(ctx, org, repo, pr.GetNumber(), marker)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if commented {
+				continue
+			}
+		}
+		klog.V(2).Infof("retries of workflow %s are exhausted for %s/%s#%d", run.GetName(), org, repo, pr.GetNumber())
+		failed := fmt.Sprintf("failed %d times", retries+1)
+		if maxRetries == 0 {
+			failed = "failed"
+		}
+		body := fmt.Sprintf("%s\n%s\nThe workflow %s %s for %s: %s\n\nThe failure may not be a flake, please take a look.\n", workflowRetriesMarker, marker, run.GetName(), failed, run.GetHeadSHA(), run.GetHTMLURL())
+		_, _, err := r.client.Issues.CreateComment(ctx, org, repo, pr.GetNumber(), &github.IssueComment{
+			Body: github.String(body),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to comment on %s/%s#%d: %w", org, repo, pr.GetNumber(), err))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v42/github"
+	"github.com/quay/quay-ci-app/configuration"
+	"github.com/quay/quay-ci-app/fakes"
+)
+
+func newWorkflowTestReactor(gh *fakes.GitHub, maxRetries int) *reactor {
+	return newAdminTestReactor(gh, &configuration.Configuration{
+		Repositories: []configuration.Repository{{
+			Owner: "quay",
+			Repo:  "quay",
+			FlakyWorkflows: configuration.FlakyWorkflows{
+				Names:      []string{"e2e"},
+				MaxRetries: maxRetries,
+			},
+		}},
+	})
+}
+
+func failedWorkflowRun(id int64, attempt int) *github.WorkflowRun {
+	return &github.WorkflowRun{
+		ID:           github.Int64(id),
+		Name:         github.String("e2e"),
+		Conclusion:   github.String("failure"),
+		RunAttempt:   github.Int(attempt),
+		HeadSHA:      github.String("abc123"),
+		PullRequests: []*github.PullRequest{{Number: github.Int(1234)}},
+	}
+}
+
+func TestWorkflowRetries(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newWorkflowTestReactor(gh, 1)
+	ctx := context.Background()
+	key := fakes.IssueKey("quay", "quay", 1234)
+
+	if err := r.HandleWorkflowRunComplete(ctx, "quay", "quay", failedWorkflowRun(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1}; !reflect.DeepEqual(gh.RerunRunIDs, want) {
+		t.Errorf("got the re-runs %v, want %v", gh.RerunRunIDs, want)
+	}
+	if n := len(gh.Comments[key]); n != 0 {
+		t.Errorf("got %d comments before the retries are exhausted", n)
+	}
+
+	if err := r.HandleWorkflowRunComplete(ctx, "quay", "quay", failedWorkflowRun(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.RerunRunIDs) != 1 {
+		t.Errorf("the run should be re-run only once, got the re-runs %v", gh.RerunRunIDs)
+	}
+	if n := len(gh.Comments[key]); n != 1 || !strings.Contains(gh.Comments[key][0].GetBody(), "failed 2 times") {
+		t.Errorf("got the comments %v, want one about the exhausted retries", gh.Comments[key])
+	}
+
+	// The run is re-run manually.
+	if err := r.HandleWorkflowRunComplete(ctx, "quay", "quay", failedWorkflowRun(1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(gh.Comments[key]); n != 1 {
+		t.Errorf("got %d comments after a manual re-run, want 1", n)
+	}
+}
+
+func TestWorkflowNoRetries(t *testing.T) {
+	gh := fakes.NewGitHub()
+	r := newWorkflowTestReactor(gh, 0)
+	ctx := context.Background()
+	key := fakes.IssueKey("quay", "quay", 1234)
+
+	// The workflow fails for two pushes of the pull request.
+	for _, id := range []int64{1, 2} {
+		if err := r.HandleWorkflowRunComplete(ctx, "quay", "quay", failedWorkflowRun(id, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(gh.RerunRunIDs) != 0 {
+		t.Errorf("the workflow should never be re-run, got the re-runs %v", gh.RerunRunIDs)
+	}
+	if n := len(gh.Comments[key]); n != 1 {
+		t.Errorf("got %d comments, want 1", n)
+	}
+}